@@ -0,0 +1,243 @@
+// Package client is a typed Go client for the human-plus-plus users API.
+//
+// It mirrors the server's routes and Response envelope by hand; the
+// server's /openapi.json documents responses but not request bodies, which
+// a generator would need. The contract command catches drift between them.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// User is a user as the server returns it.
+type User struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserInput is a user to create or replace. An empty ID has the server
+// assign one. Password is optional and write-only.
+type UserInput struct {
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password,omitempty"`
+}
+
+// RetryPolicy retries requests the server rejected before acting on them
+// (429 and 503) and, for GET and DELETE, also 502, 504 and network errors.
+// The delay doubles from Backoff up to MaxBackoff unless the server sends
+// Retry-After.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// DefaultRetry makes three attempts.
+var DefaultRetry = RetryPolicy{MaxAttempts: 3, Backoff: 200 * time.Millisecond, MaxBackoff: 2 * time.Second}
+
+// Client calls one server. The zero value is not usable; use New.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	token   string
+	tenant  string
+	retry   RetryPolicy
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option { return func(c *Client) { c.http = hc } }
+
+// WithToken sends token as a bearer access token or admin token.
+func WithToken(token string) Option { return func(c *Client) { c.token = token } }
+
+// WithTenant sends tenant in X-Tenant-ID.
+func WithTenant(tenant string) Option { return func(c *Client) { c.tenant = tenant } }
+
+// WithRetry replaces DefaultRetry; MaxAttempts 1 disables retries.
+func WithRetry(p RetryPolicy) Option { return func(c *Client) { c.retry = p } }
+
+// New returns a client for the server at baseURL, e.g.
+// "https://users.internal:8443".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("client: invalid base URL %q", baseURL)
+	}
+	c := &Client{baseURL: u.Scheme + "://" + u.Host, http: http.DefaultClient, retry: DefaultRetry}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+func (c *Client) CreateUser(ctx context.Context, in UserInput) (User, error) {
+	var user User
+	err := c.do(ctx, http.MethodPost, "/users", in, &user, nil)
+	return user, err
+}
+
+func (c *Client) GetUser(ctx context.Context, id string) (User, error) {
+	var user User
+	err := c.do(ctx, http.MethodGet, "/users/"+url.PathEscape(id), nil, &user, nil)
+	return user, err
+}
+
+func (c *Client) DeleteUser(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/users/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// ListUsers yields every user in ID order, fetching pageSize at a time. It
+// stops after yielding the first error.
+//
+//	for user, err := range c.ListUsers(ctx, 100) {
+//		if err != nil { ... }
+//	}
+func (c *Client) ListUsers(ctx context.Context, pageSize int) iter.Seq2[User, error] {
+	return func(yield func(User, error) bool) {
+		cursor := ""
+		for {
+			q := url.Values{"limit": {strconv.Itoa(pageSize)}}
+			if cursor != "" {
+				q.Set("cursor", cursor)
+			}
+			var page []User
+			var env envelope
+			if err := c.do(ctx, http.MethodGet, "/users?"+q.Encode(), nil, &page, &env); err != nil {
+				yield(User{}, err)
+				return
+			}
+			for _, user := range page {
+				if !yield(user, nil) {
+					return
+				}
+			}
+			if env.NextCursor == "" {
+				return
+			}
+			cursor = env.NextCursor
+		}
+	}
+}
+
+// envelope is the server's Response.
+type envelope struct {
+	Success    bool            `json:"success"`
+	Data       json.RawMessage `json:"data"`
+	Error      string          `json:"error"`
+	Code       string          `json:"code"`
+	TraceID    string          `json:"trace_id"`
+	NextCursor string          `json:"next_cursor"`
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out any, env *envelope) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	delay := c.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, method, path, body)
+		retryable, wait := c.retryable(method, resp, err)
+		if !retryable || attempt >= c.retry.MaxAttempts {
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			return decode(resp, out, env)
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if wait == 0 {
+			wait = delay
+			delay = min(delay*2, c.retry.MaxBackoff)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.tenant != "" {
+		req.Header.Set("X-Tenant-ID", c.tenant)
+	}
+	return c.http.Do(req)
+}
+
+// retryable reports whether the attempt should be retried and, if the
+// server said, after how long.
+func (c *Client) retryable(method string, resp *http.Response, err error) (bool, time.Duration) {
+	idempotent := method == http.MethodGet || method == http.MethodDelete
+	if err != nil {
+		return idempotent && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded), 0
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		if !idempotent {
+			return false, 0
+		}
+	default:
+		return false, 0
+	}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+		return true, time.Duration(secs) * time.Second
+	}
+	return true, 0
+}
+
+func decode(resp *http.Response, out any, env *envelope) error {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return err
+	}
+	var e envelope
+	// Some errors, like 405 and plain 400s, are text rather than the envelope.
+	if json.Unmarshal(data, &e) != nil {
+		e = envelope{Error: string(bytes.TrimSpace(data))}
+	}
+	if resp.StatusCode >= 400 {
+		return &Error{StatusCode: resp.StatusCode, Code: e.Code, Message: e.Error, TraceID: e.TraceID}
+	}
+	if env != nil {
+		*env = e
+	}
+	if out != nil && len(e.Data) > 0 {
+		return json.Unmarshal(e.Data, out)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+//go:build integration
+
+package containertest
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Redis starts a Redis server and returns its URL, in the form the
+// ratelimit.redis_url and auth.revocation_redis_url settings take.
+func Redis(t testing.TB) string {
+	t.Helper()
+	c := Run(t, Spec{Image: "redis:7-alpine", Port: "6379/tcp", Ready: redisPing})
+	return "redis://" + c.Addr + "/0"
+}
+
+// redisPing waits for PONG; the port accepts connections before Redis has
+// loaded and answers LOADING.
+func redisPing(ctx context.Context, c *Container) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+		return err
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if line != "+PONG\r\n" {
+		return fmt.Errorf("%w: %q", errNotReady, line)
+	}
+	return nil
+}
+
+// Postgres starts a PostgreSQL server, applies every *.sql file in
+// migrations (sorted by name, so 0001_init.sql runs first) and returns a
+// connection URL. An empty migrations skips that step.
+//
+// !! There is no PostgreSQL store backend yet; this is for the one that replaces the in-memory store
+func Postgres(t testing.TB, migrations string) string {
+	t.Helper()
+	const user, password, db = "hpp", "hpp", "hpp_test"
+	c := Run(t, Spec{
+		Image: "postgres:16-alpine",
+		Port:  "5432/tcp",
+		Env:   map[string]string{"POSTGRES_USER": user, "POSTGRES_PASSWORD": password, "POSTGRES_DB": db},
+		// pg_isready over TCP: the image's init scripts answer on the
+		// socket first, then restart the server.
+		Ready: func(ctx context.Context, c *Container) error {
+			_, err := c.Exec(ctx, nil, "pg_isready", "-h", "127.0.0.1", "-U", user, "-d", db)
+			return err
+		},
+	})
+	if migrations != "" {
+		if err := migrate(c, user, db, migrations); err != nil {
+			t.Fatalf("containertest: %v", err)
+		}
+	}
+	return fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", user, password, c.Addr, db)
+}
+
+// migrate runs each file through psql in the container, in its own
+// transaction, stopping at the first error.
+func migrate(c *Container, user, db, dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		sql, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		out, err := c.Exec(ctx, sql, "psql", "-U", user, "-d", db, "-v", "ON_ERROR_STOP=1", "--single-transaction", "-q")
+		cancel()
+		if err != nil {
+			return fmt.Errorf("migration %s: %v\n%s", filepath.Base(file), err, out)
+		}
+	}
+	return nil
+}
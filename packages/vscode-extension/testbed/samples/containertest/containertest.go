@@ -0,0 +1,131 @@
+//go:build integration
+
+package containertest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Label marks every container started here, so ones leaked by a killed
+// test run can be found with `docker ps -a --filter label=hpp.containertest`.
+const Label = "hpp.containertest"
+
+// Spec describes a container to run.
+type Spec struct {
+	Image string
+	// Port is the container port to publish on a random loopback port.
+	Port string
+	Env  map[string]string
+	Args []string
+	// Ready reports whether the service accepts work yet; it is polled
+	// until it returns nil or Timeout passes.
+	Ready   func(ctx context.Context, c *Container) error
+	Timeout time.Duration
+}
+
+// Container is a running container. Addr is the published port on
+// 127.0.0.1.
+type Container struct {
+	ID   string
+	Addr string
+}
+
+// Run starts spec and waits for it to be ready, removing the container
+// and its volumes when t ends.
+func Run(t testing.TB, spec Spec) *Container {
+	t.Helper()
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("containertest: docker not found")
+	}
+	if err := exec.Command("docker", "info").Run(); err != nil {
+		t.Skip("containertest: docker daemon unavailable")
+	}
+	args := []string{"run", "-d", "--rm", "--label", Label + "=" + t.Name(), "-p", "127.0.0.1::" + spec.Port}
+	for k, v := range spec.Env {
+		args = append(args, "-e", k+"="+v)
+	}
+	args = append(args, spec.Image)
+	args = append(args, spec.Args...)
+	out, err := docker(context.Background(), nil, args...)
+	if err != nil {
+		t.Fatalf("containertest: start %s: %v", spec.Image, err)
+	}
+	c := &Container{ID: strings.TrimSpace(out)}
+	t.Cleanup(func() {
+		if _, err := docker(context.Background(), nil, "rm", "-f", "-v", c.ID); err != nil {
+			t.Logf("containertest: remove %s: %v", c.ID, err)
+		}
+	})
+
+	out, err = docker(context.Background(), nil, "port", c.ID, spec.Port)
+	if err != nil {
+		t.Fatalf("containertest: %s port %s: %v", spec.Image, spec.Port, err)
+	}
+	// The first line is the IPv4 binding, e.g. "127.0.0.1:49153".
+	c.Addr, _, _ = strings.Cut(strings.TrimSpace(out), "\n")
+
+	timeout := spec.Timeout
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ready := spec.Ready
+	if ready == nil {
+		ready = dialable
+	}
+	for {
+		err := ready(ctx, c)
+		if err == nil {
+			return c
+		}
+		select {
+		case <-ctx.Done():
+			logs, _ := docker(context.Background(), nil, "logs", "--tail", "50", c.ID)
+			t.Fatalf("containertest: %s not ready after %s: %v\n%s", spec.Image, timeout, err, logs)
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// Exec runs a command in the container, with stdin when given, and
+// returns its combined output.
+func (c *Container) Exec(ctx context.Context, stdin []byte, cmd ...string) (string, error) {
+	args := []string{"exec"}
+	if stdin != nil {
+		args = append(args, "-i")
+	}
+	return docker(ctx, stdin, append(append(args, c.ID), cmd...)...)
+}
+
+func docker(ctx context.Context, stdin []byte, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	if err := cmd.Run(); err != nil {
+		return out.String(), fmt.Errorf("docker %s: %w: %s", args[0], err, strings.TrimSpace(out.String()))
+	}
+	return out.String(), nil
+}
+
+func dialable(ctx context.Context, c *Container) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+var errNotReady = errors.New("not ready")
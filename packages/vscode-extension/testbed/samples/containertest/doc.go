@@ -0,0 +1,8 @@
+// Package containertest starts disposable backend containers for
+// integration tests and removes them when the test ends. It drives the
+// docker CLI, so it needs no client library; tests skip when docker is
+// unavailable. Build with -tags integration so plain `go test` stays fast.
+//
+//	url := containertest.Redis(t)
+//	cfg.RateLimit.RedisURL = url
+package containertest
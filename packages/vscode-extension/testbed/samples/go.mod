@@ -0,0 +1,22 @@
+module github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples
+
+go 1.26.0
+
+require (
+	github.com/goccy/go-json v0.11.1
+	github.com/quic-go/quic-go v0.63.0
+	github.com/segmentio/kafka-go v0.4.51
+	golang.org/x/crypto v0.57.0
+	golang.org/x/tools v0.50.0
+)
+
+require (
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+	github.com/quic-go/qpack v0.6.0 // indirect
+	golang.org/x/mod v0.41.0 // indirect
+	golang.org/x/net v0.59.0 // indirect
+	golang.org/x/sync v0.23.0 // indirect
+	golang.org/x/sys v0.48.0 // indirect
+	golang.org/x/text v0.42.0 // indirect
+)
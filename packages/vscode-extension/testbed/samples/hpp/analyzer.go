@@ -0,0 +1,69 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/hpp/marker"
+)
+
+// Analyzer reports each Human++ marker in a package's comments as a
+// diagnostic, categorised by marker kind.
+var Analyzer = &analysis.Analyzer{
+	Name: "hpp",
+	Doc: `report Human++ markers in comments
+
+Comments whose text starts with !!, ??, >> or ~~ are reported with their
+kind (intervention, uncertainty, directive, provisional) and the rest of
+the line. With -keywords, the legacy FIXME/BUG/XXX, TODO/HACK and NOTE/NB
+spellings are reported too. A human:ignore comment hides the markers on
+its line, and human:ignore-file those in its file.`,
+	URL: "https://github.com/fielding/human-plus-plus",
+	Run: run,
+}
+
+// >> Keywords are off by default: matched in any case as whole words, "bug" and "note" turn up in plenty of ordinary prose, and a vet run would drown in them
+var keywords bool
+
+func init() {
+	Analyzer.Flags.BoolVar(&keywords, "keywords", false, "also report the legacy keyword aliases (TODO, FIXME, NOTE, ...)")
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	for _, file := range pass.Files {
+		if ast.IsGenerated(file) {
+			continue
+		}
+		tf := pass.Fset.File(file.FileStart)
+		var sup suppressions
+		for _, group := range file.Comments {
+			for _, c := range group.List {
+				sup.add(tf, c.Slash, c.Text)
+			}
+		}
+		if sup.file {
+			continue
+		}
+		for _, group := range file.Comments {
+			for _, c := range group.List {
+				for _, a := range parser(keywords).Parse(c.Text) {
+					pos := c.Slash + token.Pos(a.Offset)
+					if !sup.lines[tf.Line(pos)] {
+						pass.Report(diagnostic(pos, a))
+					}
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func diagnostic(pos token.Pos, a marker.Annotation) analysis.Diagnostic {
+	msg := a.Kind.Marker + " " + a.Kind.Name
+	if a.Text != "" {
+		msg += ": " + a.Text
+	}
+	return analysis.Diagnostic{Pos: pos, Category: a.Kind.Name, Message: msg}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// baseline is the markers a codebase had when it adopted hpp, which a
+// scan with -baseline leaves out so only new ones are reported:
+//
+//	hpp scan -baseline .humanpp-baseline.json -write-baseline .
+//	hpp scan -baseline .humanpp-baseline.json -fail-on warning .
+//
+// Markers are matched by file, marker and text, not line, so code moving
+// around them doesn't bring them back; a file with the same marker twice
+// keeps only as many as it had. Paths are relative to the baseline file,
+// so it can be committed and checked from any directory.
+type baseline struct {
+	dir   string
+	count map[baselineKey]int
+}
+
+type baselineKey struct {
+	file, marker, text string
+}
+
+type baselineEntry struct {
+	File   string `json:"file"`
+	Marker string `json:"marker"`
+	Text   string `json:"text"`
+	Count  int    `json:"count"`
+}
+
+func loadBaseline(path string) (*baseline, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []baselineEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	b, err := newBaseline(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		b.count[baselineKey{e.File, e.Marker, e.Text}] += max(e.Count, 1)
+	}
+	return b, nil
+}
+
+func newBaseline(path string) (*baseline, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	return &baseline{dir: filepath.Dir(abs), count: map[baselineKey]int{}}, nil
+}
+
+func (b *baseline) key(f finding) baselineKey {
+	file := f.File
+	if abs, err := filepath.Abs(f.File); err == nil {
+		if rel, err := filepath.Rel(b.dir, abs); err == nil {
+			file = rel
+		}
+	}
+	return baselineKey{filepath.ToSlash(file), f.Marker, f.Text}
+}
+
+// filter returns the findings the baseline doesn't account for.
+func (b *baseline) filter(findings []finding) []finding {
+	left := maps.Clone(b.count)
+	return slices.DeleteFunc(findings, func(f finding) bool {
+		k := b.key(f)
+		if left[k] > 0 {
+			left[k]--
+			return true
+		}
+		return false
+	})
+}
+
+// writeBaseline records findings as the baseline at path.
+func writeBaseline(path string, findings []finding) error {
+	b, err := newBaseline(path)
+	if err != nil {
+		return err
+	}
+	for _, f := range findings {
+		b.count[b.key(f)]++
+	}
+	entries := []baselineEntry{}
+	for k, n := range b.count {
+		entries = append(entries, baselineEntry{File: k.file, Marker: k.marker, Text: k.text, Count: n})
+	}
+	slices.SortFunc(entries, func(a, b baselineEntry) int {
+		return cmp.Or(strings.Compare(a.File, b.File), cmp.Compare(kindIndex(a.Marker), kindIndex(b.Marker)), strings.Compare(a.Text, b.Text))
+	})
+	raw, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(raw, '\n'), 0o644)
+}
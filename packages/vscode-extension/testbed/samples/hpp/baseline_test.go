@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.go"), filepath.Join(dir, "pkg", "b.go")
+	recorded := []finding{
+		{File: a, Line: 3, Marker: "!!", Kind: "intervention", Text: "drop the retry"},
+		{File: a, Line: 9, Marker: "??", Kind: "uncertainty", Text: "why"},
+		{File: a, Line: 12, Marker: "??", Kind: "uncertainty", Text: "why"},
+		{File: b, Line: 1, Marker: ">>", Kind: "directive", Text: "see RFC 9110"},
+	}
+	path := filepath.Join(dir, ".humanpp-baseline.json")
+	if err := writeBaseline(path, recorded); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "baseline.json", raw)
+
+	tests := []struct {
+		name     string
+		findings []finding
+		want     []finding
+	}{
+		{"unchanged", recorded, []finding{}},
+		{"moved lines", []finding{
+			{File: a, Line: 30, Marker: "!!", Text: "drop the retry"},
+			{File: b, Line: 7, Marker: ">>", Text: "see RFC 9110"},
+		}, []finding{}},
+		{"one more of a repeated marker", []finding{
+			{File: a, Line: 9, Marker: "??", Text: "why"},
+			{File: a, Line: 12, Marker: "??", Text: "why"},
+			{File: a, Line: 20, Marker: "??", Text: "why"},
+		}, []finding{
+			{File: a, Line: 20, Marker: "??", Text: "why"},
+		}},
+		{"new text", []finding{{File: a, Line: 3, Marker: "!!", Text: "drop the retry loop"}}, []finding{
+			{File: a, Line: 3, Marker: "!!", Text: "drop the retry loop"},
+		}},
+		{"other marker", []finding{{File: a, Line: 3, Marker: "??", Text: "drop the retry"}}, []finding{
+			{File: a, Line: 3, Marker: "??", Text: "drop the retry"},
+		}},
+		{"other file", []finding{{File: filepath.Join(dir, "c.go"), Line: 1, Marker: ">>", Text: "see RFC 9110"}}, []finding{
+			{File: filepath.Join(dir, "c.go"), Line: 1, Marker: ">>", Text: "see RFC 9110"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, err := loadBaseline(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := base.filter(append([]finding{}, tt.findings...)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filter =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBaselineRelative checks that findings reported relative to the
+// working directory match a baseline elsewhere.
+func TestBaselineRelative(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.Mkdir("sub", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	recorded := []finding{{File: "sub/a.go", Marker: "!!", Text: "x"}}
+	path := filepath.Join(dir, "sub", "baseline.json")
+	if err := writeBaseline(path, recorded); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(filepath.Join(dir, "sub"))
+	base, err := loadBaseline("baseline.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := base.filter([]finding{{File: "a.go", Marker: "!!", Text: "x"}}); len(got) != 0 {
+		t.Errorf("relative finding not matched: %+v", got)
+	}
+}
+
+func TestLoadBaselineErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := loadBaseline(filepath.Join(dir, "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("missing file: err = %v", err)
+	}
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadBaseline(bad); err == nil {
+		t.Error("malformed baseline loaded")
+	}
+}
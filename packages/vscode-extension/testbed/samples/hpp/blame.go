@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// blameLine is who last touched a line, and when. A zero Time means the
+// line isn't committed yet.
+type blameLine struct {
+	Author string
+	Email  string
+	Commit string
+	Time   time.Time
+}
+
+// uncommitted is the commit git blame gives lines that are only in the
+// working tree.
+const uncommitted = "0000000000000000000000000000000000000000"
+
+// blameFile runs git blame once over path and returns its lines by number.
+func blameFile(path string) (map[int]blameLine, error) {
+	out, err := git("blame", "--line-porcelain", "--", path)
+	if err != nil {
+		return nil, err
+	}
+	return parseBlame(out), nil
+}
+
+// parseBlame reads --line-porcelain output, where every line comes as a
+// "<commit> <orig> <final>" header, its key-value lines and then the
+// line itself behind a tab.
+func parseBlame(out []byte) map[int]blameLine {
+	lines := map[int]blameLine{}
+	var cur blameLine
+	var final int
+	header := true
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		text := sc.Text()
+		if strings.HasPrefix(text, "\t") {
+			if cur.Commit == uncommitted {
+				cur.Time = time.Time{}
+			}
+			lines[final] = cur
+			cur, header = blameLine{}, true
+			continue
+		}
+		if header {
+			fields := strings.Fields(text)
+			if len(fields) >= 3 {
+				cur.Commit = fields[0]
+				final, _ = strconv.Atoi(fields[2])
+			}
+			header = false
+			continue
+		}
+		key, value, _ := strings.Cut(text, " ")
+		switch key {
+		case "author":
+			cur.Author = value
+		case "author-mail":
+			cur.Email = strings.Trim(value, "<>")
+		case "author-time":
+			if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+				cur.Time = time.Unix(sec, 0)
+			}
+		}
+	}
+	return lines
+}
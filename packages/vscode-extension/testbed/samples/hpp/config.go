@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/hpp/marker"
+)
+
+// configNames are the files hpp looks for, nearest directory first.
+var configNames = []string{".humanpp.yml", ".humanpp.yaml"}
+
+// projectConfig is a .humanpp.yml:
+//
+//	keywords: true        # default for every -keywords flag
+//	builtin: true         # keep !!, ??, >> and ~~ (the default)
+//	markers:
+//	  - marker: "@@"
+//	    name: review
+//	    severity: warning
+//	    description: Needs a second pair of eyes
+//	    keywords: [REVIEW]
+//	  - marker: "??"      # an existing marker keeps what isn't set
+//	    severity: error
+//
+// New markers rank below the built-ins, in file order. Quote markers:
+// YAML reads a bare !! or ? as syntax.
+type projectConfig struct {
+	path     string
+	keywords bool
+	builtin  bool
+	markers  []marker.Kind
+}
+
+// findConfig looks for a config file in dir and its parents, stopping at
+// a repository root so one checkout never picks up another's.
+func findConfig(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		for _, name := range configNames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path, true
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", false
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// loadProjectConfig applies the config file found from dir, if any, to
+// kinds and the -keywords defaults. Without one the built-ins stand.
+func loadProjectConfig(dir string) error {
+	cfg := projectConfig{builtin: true}
+	if path, ok := findConfig(dir); ok {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if cfg, err = parseConfig(f); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		cfg.path = path
+	}
+	next, err := cfg.kinds()
+	if err != nil {
+		return fmt.Errorf("%s: %w", cfg.path, err)
+	}
+	setKinds(next)
+	defaultKeywords = cfg.keywords
+	return nil
+}
+
+// kinds merges the config's markers into the built-ins and checks the
+// result parses.
+func (c projectConfig) kinds() ([]marker.Kind, error) {
+	var out []marker.Kind
+	if c.builtin {
+		out = append(out, marker.Builtin...)
+	}
+	builtins := len(out)
+	for _, k := range c.markers {
+		switch k.Severity {
+		case "", "error", "warning", "note":
+		default:
+			return nil, fmt.Errorf("marker %q: severity must be error, warning or note, not %q", k.Marker, k.Severity)
+		}
+		if i := slices.IndexFunc(out[:builtins], func(b marker.Kind) bool { return b.Marker == k.Marker }); i >= 0 {
+			b := &out[i]
+			b.Name = cmp.Or(k.Name, b.Name)
+			b.Severity = cmp.Or(k.Severity, b.Severity)
+			b.Description = cmp.Or(k.Description, b.Description)
+			if k.Keywords != nil {
+				b.Keywords = k.Keywords
+			}
+			continue
+		}
+		k.Severity = cmp.Or(k.Severity, "note")
+		out = append(out, k)
+	}
+	if _, err := marker.NewParser(out, false); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// >> Deliberately a YAML subset: top-level scalars and one list of flat mappings, with flow sequences for keywords
+func parseConfig(r io.Reader) (projectConfig, error) {
+	cfg := projectConfig{builtin: true}
+	var cur *marker.Kind
+	inMarkers := false
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		raw := stripYAMLComment(sc.Text())
+		line := strings.TrimSpace(raw)
+		if line == "" || line == "---" {
+			continue
+		}
+		indented := raw[0] == ' ' || raw[0] == '\t'
+
+		if !indented {
+			cur, inMarkers = nil, false
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				return cfg, fmt.Errorf("line %d: expected key: value", n)
+			}
+			value = strings.TrimSpace(value)
+			var err error
+			switch strings.TrimSpace(key) {
+			case "keywords":
+				cfg.keywords, err = strconv.ParseBool(value)
+			case "builtin":
+				cfg.builtin, err = strconv.ParseBool(value)
+			case "markers":
+				if value != "" {
+					return cfg, fmt.Errorf("line %d: markers must be a list of mappings", n)
+				}
+				inMarkers = true
+			default:
+				return cfg, fmt.Errorf("line %d: unknown key %q", n, key)
+			}
+			if err != nil {
+				return cfg, fmt.Errorf("line %d: %s: %w", n, key, err)
+			}
+			continue
+		}
+
+		if !inMarkers {
+			return cfg, fmt.Errorf("line %d: unexpected indentation", n)
+		}
+		if rest, ok := strings.CutPrefix(line, "-"); ok {
+			cfg.markers = append(cfg.markers, marker.Kind{})
+			cur = &cfg.markers[len(cfg.markers)-1]
+			line = strings.TrimSpace(rest)
+			if line == "" {
+				continue
+			}
+		}
+		if cur == nil {
+			return cfg, fmt.Errorf("line %d: expected a list item", n)
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return cfg, fmt.Errorf("line %d: expected key: value", n)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if key == "keywords" {
+			words, err := parseYAMLList(value)
+			if err != nil {
+				return cfg, fmt.Errorf("line %d: keywords: %w", n, err)
+			}
+			cur.Keywords = words
+			continue
+		}
+		s, err := parseYAMLScalar(value)
+		if err != nil {
+			return cfg, fmt.Errorf("line %d: %s: %w", n, key, err)
+		}
+		switch key {
+		case "marker":
+			cur.Marker = s
+		case "name":
+			cur.Name = s
+		case "severity":
+			cur.Severity = s
+		case "description":
+			cur.Description = s
+		default:
+			return cfg, fmt.Errorf("line %d: unknown marker key %q", n, key)
+		}
+	}
+	return cfg, sc.Err()
+}
+
+func parseYAMLScalar(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		return strconv.Unquote(raw)
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return strings.ReplaceAll(raw[1:len(raw)-1], "''", "'"), nil
+	default:
+		return raw, nil
+	}
+}
+
+func parseYAMLList(raw string) ([]string, error) {
+	if !strings.HasPrefix(raw, "[") || !strings.HasSuffix(raw, "]") {
+		return nil, fmt.Errorf("expected a [flow, sequence]")
+	}
+	var items []string
+	for _, part := range strings.Split(raw[1:len(raw)-1], ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		s, err := parseYAMLScalar(part)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, s)
+	}
+	return items, nil
+}
+
+// stripYAMLComment drops a # comment, which in YAML needs a space or the
+// start of the line before it, outside quotes.
+func stripYAMLComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return strings.TrimRight(line, " \t")
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var convertOpts struct {
+	dryRun  bool
+	exclude string
+}
+
+func convertFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&convertOpts.dryRun, "dry-run", false, "print a diff of what would change without writing files")
+	excludeFlag(fs, &convertOpts.exclude)
+}
+
+// runConvert rewrites legacy keyword comments under its arguments as the
+// markers they stand for, in place, and lists the files it changed:
+//
+//	// TODO: drop this      ->  // ?? drop this
+//	// FIXME(ann): racy     ->  // !! (ann) racy
+//	/*
+//	 * NOTE: see RFC 9110   ->   * >> see RFC 9110
+//	 */
+//
+// A keyword only counts in capitals at the start of a comment line,
+// followed by a colon, a (who) or a space, so prose that mentions a TODO
+// is left alone. The keywords are the kinds', .humanpp.yml included.
+// Generated files are skipped.
+func runConvert(args []string) error {
+	re := keywordLineRE()
+	if re == nil {
+		return fmt.Errorf("no marker has keywords to convert")
+	}
+	opts := newTreeOptions(false, convertOpts.exclude)
+	return walkTree(args, opts, func(path string, d fs.DirEntry) error {
+		// ?? Only Go is converted; other languages' keyword comments are left for now
+		if filepath.Ext(path) != ".go" {
+			return nil
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		out, n := convertSource(path, src, re)
+		if n == 0 {
+			return nil
+		}
+		if convertOpts.dryRun {
+			return writeLineDiff(os.Stdout, filepath.ToSlash(path), src, out)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, out, info.Mode().Perm()); err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d converted\n", filepath.ToSlash(path), n)
+		return nil
+	})
+}
+
+// keywordLineRE matches a comment line that opens with any kind's
+// keyword. Its groups are the leading space, the keyword, the (who) and
+// the rest of the line.
+func keywordLineRE() *regexp.Regexp {
+	var words []string
+	for _, k := range kinds {
+		for _, w := range k.Keywords {
+			words = append(words, regexp.QuoteMeta(w))
+		}
+	}
+	if len(words) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`^(\s*)(` + strings.Join(words, "|") + `)(?:\(([^)]*)\))?(?::|\s|$)\s*(.*)$`)
+}
+
+// markerForKeyword returns the marker kw stands for. Keywords are matched
+// as written, so a config that lists lower-case ones can convert them.
+func markerForKeyword(kw string) string {
+	for _, k := range kinds {
+		for _, w := range k.Keywords {
+			if w == kw {
+				return k.Marker
+			}
+		}
+	}
+	return ""
+}
+
+// convertSource rewrites the keyword lines in src's comments and returns
+// the result with how many it rewrote. Lines keep their place, so the
+// line count never changes.
+func convertSource(path string, src []byte, re *regexp.Regexp) ([]byte, int) {
+	fset := token.NewFileSet()
+	file := fset.AddFile(path, -1, len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, scanner.ScanComments)
+
+	var out bytes.Buffer
+	last, n := 0, 0
+	header := true
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok != token.COMMENT {
+			header = header && tok != token.PACKAGE
+			continue
+		}
+		if header && generatedRE.MatchString(lit) {
+			return src, 0
+		}
+		start := file.Offset(pos)
+		converted, m := convertComment(lit, re)
+		if m == 0 {
+			continue
+		}
+		out.Write(src[last:start])
+		out.WriteString(converted)
+		last, n = start+len(lit), n+m
+	}
+	if n == 0 {
+		return src, 0
+	}
+	out.Write(src[last:])
+	return out.Bytes(), n
+}
+
+// convertComment rewrites the keyword lines of one comment, delimiters
+// included.
+func convertComment(comment string, re *regexp.Regexp) (string, int) {
+	lead, body, tail := "", comment, ""
+	block := false
+	switch {
+	case strings.HasPrefix(comment, "//"):
+		lead, body = "//", comment[2:]
+	case strings.HasPrefix(comment, "/*"):
+		lead, body, block = "/*", strings.TrimSuffix(comment[2:], "*/"), true
+		tail = comment[len(lead)+len(body):]
+	}
+
+	var b strings.Builder
+	b.WriteString(lead)
+	n := 0
+	for line := range strings.Lines(body) {
+		text := strings.TrimRight(line, "\r\n")
+		eol := line[len(text):]
+		prefix := ""
+		if block {
+			// Keep a continuation " * " and convert what follows it.
+			trimmed := strings.TrimLeft(text, " \t")
+			if rest, ok := strings.CutPrefix(trimmed, "*"); ok && !strings.HasPrefix(rest, "/") {
+				prefix, text = text[:len(text)-len(rest)], rest
+			}
+		}
+		if m := re.FindStringSubmatch(text); m != nil {
+			space, who, rest := m[1], m[3], strings.TrimRight(m[4], " \t")
+			// Trailing space stays, as before a one-line block's */.
+			trailing := m[4][len(rest):]
+			if space == "" {
+				space = " "
+			}
+			mark := markerForKeyword(m[2])
+			if who != "" {
+				rest = withWho(mark, who, rest)
+			}
+			text = space + mark
+			if rest != "" {
+				text += " " + rest
+			}
+			text += trailing
+			n++
+		}
+		b.WriteString(prefix + text + eol)
+	}
+	b.WriteString(tail)
+	return b.String(), n
+}
+
+// withWho puts the who of a TODO(who) into rest as metadata, or as a
+// "who:" prefix when it doesn't read as an assignee or ticket.
+func withWho(mark, who, rest string) string {
+	meta := "(" + who + ")"
+	found := parser(false).Parse(strings.TrimSpace(mark + " " + meta + " " + rest))
+	if len(found) == 1 && found[0].Text == rest && (found[0].Meta.Assignee != "" || found[0].Meta.Ticket != "") {
+		return strings.TrimSpace(meta + " " + rest)
+	}
+	return strings.TrimSpace(who + ": " + rest)
+}
+
+// writeLineDiff writes a unified diff between before and after, which
+// have the same lines, differing only in some of them.
+func writeLineDiff(w io.Writer, path string, before, after []byte) error {
+	const context = 3
+	a := strings.SplitAfter(string(before), "\n")
+	b := strings.SplitAfter(string(after), "\n")
+	fmt.Fprintf(w, "--- a/%s\n+++ b/%s\n", path, path)
+	for i := 0; i < len(a); {
+		if a[i] == b[i] {
+			i++
+			continue
+		}
+		// Grow the hunk while the next change is within two contexts.
+		start, end := max(i-context, 0), i+1
+		for j := i + 1; j < len(a) && j < end+2*context; j++ {
+			if a[j] != b[j] {
+				end = j + 1
+			}
+		}
+		end = min(end+context, len(a))
+		if a[end-1] == "" {
+			end--
+		}
+		fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", start+1, end-start, start+1, end-start)
+		for j := start; j < end; j++ {
+			if a[j] == b[j] {
+				fmt.Fprint(w, " "+noEOL(a[j]))
+				continue
+			}
+			fmt.Fprint(w, "-"+noEOL(a[j]))
+			fmt.Fprint(w, "+"+noEOL(b[j]))
+		}
+		i = end
+	}
+	return nil
+}
+
+// noEOL ends a diff line that has no newline, as diff does.
+func noEOL(line string) string {
+	if strings.HasSuffix(line, "\n") {
+		return line
+	}
+	return line + "\n\\ No newline at end of file\n"
+}
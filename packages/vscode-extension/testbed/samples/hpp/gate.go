@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// severityRank orders severities; a gate at one fails on it and anything
+// above.
+var severityRank = map[string]int{"note": 1, "warning": 2, "error": 3}
+
+// exitError is a failure that sets its own exit status. A gate that trips
+// exits 1 plus the rank of the worst severity it tripped on: 2 for a note,
+// 3 for a warning, 4 for an error, so a pipeline can tell them from hpp
+// itself failing, which exits 1.
+type exitError struct {
+	code int
+	msg  string
+}
+
+func (e *exitError) Error() string { return e.msg }
+
+// gate is a scan's -fail-on and -max settings.
+type gate struct {
+	// failOn is the lowest severity that fails, or "" for none.
+	failOn string
+	// max caps how many of a marker may be found.
+	max map[string]int
+}
+
+func parseGate(failOn, max string) (gate, error) {
+	g := gate{max: map[string]int{}}
+	switch failOn {
+	case "", "none":
+	default:
+		if _, ok := severityRank[failOn]; !ok {
+			return g, fmt.Errorf("-fail-on must be none, note, warning or error, not %q", failOn)
+		}
+		g.failOn = failOn
+	}
+	for _, item := range strings.Split(max, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		m, n, ok := strings.Cut(item, "=")
+		limit, err := strconv.Atoi(strings.TrimSpace(n))
+		if !ok || err != nil || limit < 0 {
+			return g, fmt.Errorf("-max: %q is not marker=count", item)
+		}
+		if m = strings.TrimSpace(m); kindFor(m) == nil {
+			return g, fmt.Errorf("-max: unknown marker %q", m)
+		}
+		g.max[m] = limit
+	}
+	return g, nil
+}
+
+func (g gate) enabled() bool { return g.failOn != "" || len(g.max) > 0 }
+
+// check writes a count per marker to w and returns an exitError if the
+// findings trip the gate. The suppressed ones are listed but never trip
+// it.
+func (g gate) check(w io.Writer, findings, suppressed []finding) error {
+	counts := map[string]int{}
+	for _, f := range findings {
+		counts[f.Marker]++
+	}
+	hidden := map[string]int{}
+	for _, f := range suppressed {
+		hidden[f.Marker]++
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "marker\tseverity\tcount\tlimit\tsuppressed\t")
+	worst := 0
+	var tripped []string
+	for _, k := range kinds {
+		n := counts[k.Marker]
+		limit, capped := g.max[k.Marker]
+		fails := capped && n > limit ||
+			g.failOn != "" && n > 0 && severityRank[k.Severity] >= severityRank[g.failOn]
+		if n == 0 && !capped && hidden[k.Marker] == 0 {
+			continue
+		}
+		shown := "-"
+		if capped {
+			shown = strconv.Itoa(limit)
+		}
+		status := ""
+		if fails {
+			status = "FAIL"
+			worst = max(worst, severityRank[k.Severity])
+			tripped = append(tripped, fmt.Sprintf("%d %s", n, k.Marker))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%d\t%s\n", k.Marker, k.Severity, n, shown, hidden[k.Marker], status)
+	}
+	tw.Flush()
+	if len(tripped) == 0 {
+		return nil
+	}
+	return &exitError{code: 1 + worst, msg: "gate failed: " + strings.Join(tripped, ", ")}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the testdata golden files with the current output")
+
+// golden compares got with testdata/name.golden, or rewrites the file
+// when the test runs with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run with -update to create it", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s differs from the golden file; run with -update if the change is intended\n--- want\n%s\n--- got\n%s", path, want, got)
+	}
+}
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	src, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestConvertGolden(t *testing.T) {
+	src := readTestdata(t, "convert.go")
+	out, n := convertSource("convert.go", src, keywordLineRE())
+	if n != 7 {
+		t.Errorf("converted %d lines, want 7", n)
+	}
+	if got, want := bytes.Count(out, []byte("\n")), bytes.Count(src, []byte("\n")); got != want {
+		t.Errorf("converted source has %d lines, want %d", got, want)
+	}
+	golden(t, "convert.go", out)
+
+	var diff bytes.Buffer
+	if err := writeLineDiff(&diff, "convert.go", src, out); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "convert.diff", diff.Bytes())
+
+	if again, n := convertSource("convert.go", out, keywordLineRE()); n != 0 || !bytes.Equal(again, out) {
+		t.Errorf("converting the output again rewrote %d lines", n)
+	}
+}
+
+func TestConvertSkipsGenerated(t *testing.T) {
+	src := []byte("// Code generated by stringer. DO NOT EDIT.\n\npackage sample\n\n// TODO: regenerate\n")
+	if out, n := convertSource("gen.go", src, keywordLineRE()); n != 0 || !bytes.Equal(out, src) {
+		t.Errorf("generated file converted: %d lines\n%s", n, out)
+	}
+}
+
+func TestSARIFGolden(t *testing.T) {
+	findings := scanSource("testdata/scan.go", readTestdata(t, "scan.go"), treeOptions{})
+	got, err := json.MarshalIndent(sarifReport(findings), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "scan.sarif", append(got, '\n'))
+}
+
+func TestSARIFURI(t *testing.T) {
+	for path, want := range map[string]string{
+		"testdata/scan.go":    "testdata/scan.go",
+		"dir with space/x.go": "dir%20with%20space/x.go",
+		"/abs/x.go":           "file:///abs/x.go",
+	} {
+		if got := sarifURI(path); got != want {
+			t.Errorf("sarifURI(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
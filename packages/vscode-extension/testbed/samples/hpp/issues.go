@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var issuesOpts struct {
+	repo     string
+	api      string
+	label    string
+	markers  string
+	keywords bool
+	exclude  string
+	dryRun   bool
+}
+
+func issuesFlags(fs *flag.FlagSet) {
+	fs.StringVar(&issuesOpts.repo, "repo", os.Getenv("GITHUB_REPOSITORY"), "owner/name of the GitHub repository (default $GITHUB_REPOSITORY)")
+	fs.StringVar(&issuesOpts.api, "api", "https://api.github.com", "GitHub API base URL, for GitHub Enterprise")
+	fs.StringVar(&issuesOpts.label, "label", "hpp", "label that marks the issues hpp owns")
+	fs.StringVar(&issuesOpts.markers, "markers", "??", "comma-separated markers to open issues for")
+	fs.BoolVar(&issuesOpts.keywords, "keywords", defaultKeywords, "count the legacy keyword aliases as their markers")
+	excludeFlag(fs, &issuesOpts.exclude)
+	fs.BoolVar(&issuesOpts.dryRun, "dry-run", false, "print what would change without touching GitHub")
+}
+
+// fingerprintRE finds the fingerprint hpp leaves in the body of each
+// issue it opens; issues without one, even under the label, are left be.
+var fingerprintRE = regexp.MustCompile(`<!-- hpp:fingerprint=([0-9a-f]+) -->`)
+
+// runIssues keeps one GitHub issue, under -label, per marker under its
+// arguments: new markers get an issue, issues closed by hand reopen while
+// their marker is still in the code, and issues whose marker is gone are
+// closed. It needs a token with issue write access in $GITHUB_TOKEN.
+//
+// ?? Markers are fingerprinted by file and text, not line, so edits around them don't churn issues; moving a marker to another file closes its issue and opens a new one
+func runIssues(args []string) error {
+	if issuesOpts.repo == "" {
+		return errors.New("-repo or $GITHUB_REPOSITORY is required")
+	}
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" && !issuesOpts.dryRun {
+		return errors.New("$GITHUB_TOKEN is required")
+	}
+	include := map[string]bool{}
+	for _, m := range strings.Split(issuesOpts.markers, ",") {
+		m = strings.TrimSpace(m)
+		if kindFor(m) == nil {
+			return fmt.Errorf("-markers: unknown marker %q", m)
+		}
+		include[m] = true
+	}
+
+	top, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+	head, err := git("rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	findings, err := scanTree(args, newTreeOptions(issuesOpts.keywords, issuesOpts.exclude))
+	if err != nil {
+		return err
+	}
+	findings = unsuppressed(findings)
+	wanted, err := markerIssues(findings, include, strings.TrimSpace(string(top)))
+	if err != nil {
+		return err
+	}
+
+	gh := &github{api: strings.TrimSuffix(issuesOpts.api, "/"), repo: issuesOpts.repo, token: token, client: &http.Client{Timeout: 30 * time.Second}}
+	existing, err := gh.listIssues(issuesOpts.label)
+	if err != nil {
+		return err
+	}
+
+	var created, reopened, updated, closed int
+	for _, want := range wanted {
+		have, ok := existing[want.fingerprint]
+		switch {
+		case !ok:
+			created++
+			fmt.Printf("create: %s\n", want.Title)
+			if !issuesOpts.dryRun {
+				if err := gh.createIssue(want.ghIssue, issuesOpts.label); err != nil {
+					return err
+				}
+			}
+		case have.State == "closed" || have.Title != want.Title || have.Body != want.Body:
+			if have.State == "closed" {
+				reopened++
+				fmt.Printf("reopen #%d: %s\n", have.Number, want.Title)
+			} else {
+				updated++
+				fmt.Printf("update #%d: %s\n", have.Number, want.Title)
+			}
+			if !issuesOpts.dryRun {
+				if err := gh.editIssue(have.Number, ghIssue{Title: want.Title, Body: want.Body, State: "open"}); err != nil {
+					return err
+				}
+			}
+		}
+		delete(existing, want.fingerprint)
+	}
+	for _, stale := range existing {
+		if stale.State != "open" {
+			continue
+		}
+		closed++
+		fmt.Printf("close #%d: %s\n", stale.Number, stale.Title)
+		if !issuesOpts.dryRun {
+			if err := gh.comment(stale.Number, "The marker is no longer in the code as of "+strings.TrimSpace(string(head))+"."); err != nil {
+				return err
+			}
+			if err := gh.editIssue(stale.Number, ghIssue{State: "closed"}); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Printf("%d created, %d reopened, %d updated, %d closed\n", created, reopened, updated, closed)
+	return nil
+}
+
+type wantedIssue struct {
+	ghIssue
+	fingerprint string
+}
+
+// markerIssues builds the issue each included finding should have. Paths
+// are made relative to the repository root, so the fingerprint doesn't
+// depend on where hpp runs from; identical markers in one file are told
+// apart by their order.
+func markerIssues(findings []finding, include map[string]bool, top string) ([]wantedIssue, error) {
+	var wanted []wantedIssue
+	seen := map[string]int{}
+	for _, f := range findings {
+		if !include[f.Marker] {
+			continue
+		}
+		abs, err := filepath.Abs(f.File)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(top, abs)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+		key := rel + "\x00" + f.Marker + "\x00" + f.Text
+		seen[key]++
+		sum := sha256.Sum256(fmt.Appendf(nil, "%s\x00%d", key, seen[key]))
+		fp := hex.EncodeToString(sum[:8])
+
+		title := f.Marker + " " + f.Text
+		if r := []rune(title); len(r) > 80 {
+			title = string(r[:79]) + "…"
+		}
+		// >> Linking the default branch rather than this commit leaves the body alone until the marker's line moves, so syncs don't rewrite every issue
+		link := fmt.Sprintf("https://github.com/%s/blob/HEAD/%s#L%d", issuesOpts.repo, rel, f.Line)
+		body := fmt.Sprintf("`%s` %s\n\n> %s\n\nFound at [%s:%d](%s).\n\n<!-- hpp:fingerprint=%s -->\n", f.Marker, f.Kind, f.Text, rel, f.Line, link, fp)
+		wanted = append(wanted, wantedIssue{ghIssue: ghIssue{Title: title, Body: body}, fingerprint: fp})
+	}
+	return wanted, nil
+}
+
+// github is the few REST calls issue sync makes.
+type github struct {
+	api    string
+	repo   string
+	token  string
+	client *http.Client
+}
+
+type ghIssue struct {
+	Number      int             `json:"number,omitempty"`
+	Title       string          `json:"title,omitempty"`
+	Body        string          `json:"body,omitempty"`
+	State       string          `json:"state,omitempty"`
+	Labels      []string        `json:"labels,omitempty"`
+	PullRequest json.RawMessage `json:"pull_request,omitempty"`
+}
+
+// listIssues returns the issues under label, open or closed, by
+// fingerprint.
+func (g *github) listIssues(label string) (map[string]ghIssue, error) {
+	issues := map[string]ghIssue{}
+	for page := 1; ; page++ {
+		var batch []ghIssue
+		path := fmt.Sprintf("/repos/%s/issues?state=all&per_page=100&page=%d&labels=%s", g.repo, page, url.QueryEscape(label))
+		if err := g.do(http.MethodGet, path, nil, &batch); err != nil {
+			return nil, err
+		}
+		for _, issue := range batch {
+			if issue.PullRequest != nil {
+				continue
+			}
+			if m := fingerprintRE.FindStringSubmatch(issue.Body); m != nil {
+				issues[m[1]] = issue
+			}
+		}
+		if len(batch) < 100 {
+			return issues, nil
+		}
+	}
+}
+
+func (g *github) createIssue(issue ghIssue, label string) error {
+	issue.Labels = []string{label}
+	return g.do(http.MethodPost, "/repos/"+g.repo+"/issues", issue, nil)
+}
+
+func (g *github) editIssue(number int, change ghIssue) error {
+	return g.do(http.MethodPatch, fmt.Sprintf("/repos/%s/issues/%d", g.repo, number), change, nil)
+}
+
+func (g *github) comment(number int, body string) error {
+	return g.do(http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", g.repo, number), map[string]string{"body": body}, nil)
+}
+
+func (g *github) do(method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, g.api+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// language is enough of a language's lexical syntax to find its comments
+// without mistaking a comment opener inside a string for one.
+type language struct {
+	// line are the openers of comments that run to the end of the line.
+	line []string
+	// block are open/close pairs of comments that may span lines.
+	block [][2]string
+	// quotes are the string delimiters, longest first.
+	quotes []quote
+	// wordHash limits # comments to the start of a word, as in shell,
+	// where $# and a#b are not comments.
+	wordHash bool
+}
+
+type quote struct {
+	delim string
+	// multiline strings may span lines; others end at a newline even
+	// unclosed, so one stray quote can't swallow the file.
+	multiline bool
+	// raw strings have no backslash escapes.
+	raw bool
+}
+
+var (
+	javascript = &language{
+		line:   []string{"//"},
+		block:  [][2]string{{"/*", "*/"}},
+		quotes: []quote{{delim: "`", multiline: true}, {delim: `"`}, {delim: "'"}},
+	}
+	python = &language{
+		line:   []string{"#"},
+		quotes: []quote{{delim: `"""`, multiline: true}, {delim: "'''", multiline: true}, {delim: `"`}, {delim: "'"}},
+	}
+	shell = &language{
+		line:     []string{"#"},
+		quotes:   []quote{{delim: `"`, multiline: true}, {delim: "'", multiline: true, raw: true}},
+		wordHash: true,
+	}
+)
+
+// languages are the non-Go files the scanner reads, by extension.
+//
+// ?? A JS regex literal holding a quote, such as /'/, can hide the comments after it on its line
+var languages = map[string]*language{
+	".js": javascript, ".jsx": javascript, ".mjs": javascript, ".cjs": javascript,
+	".ts": javascript, ".tsx": javascript, ".mts": javascript, ".cts": javascript,
+	".py": python, ".pyi": python,
+	".sh": shell, ".bash": shell, ".zsh": shell, ".ksh": shell,
+}
+
+// scannable reports whether scan reads path: Go, or one of languages.
+func scannable(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".go" || languages[ext] != nil
+}
+
+// rawComment is a comment found in a source file, delimiters included,
+// or the body of a string literal, without them.
+type rawComment struct {
+	offset int
+	text   string
+	str    bool
+}
+
+// comments returns the comments in src, in order, and with strs the
+// string literals too.
+func (l *language) comments(src string, strs bool) []rawComment {
+	var found []rawComment
+	for i := 0; i < len(src); {
+		if q, ok := l.quoteAt(src, i); ok {
+			end := skipString(src, i, q)
+			if strs {
+				body := src[i+len(q.delim) : end]
+				if end-i >= 2*len(q.delim) && strings.HasSuffix(body, q.delim) {
+					body = body[:len(body)-len(q.delim)]
+				}
+				found = append(found, rawComment{i + len(q.delim), body, true})
+			}
+			i = end
+			continue
+		}
+		if open, ok := l.lineAt(src, i); ok {
+			end := strings.IndexByte(src[i+len(open):], '\n')
+			if end < 0 {
+				end = len(src)
+			} else {
+				end += i + len(open)
+			}
+			found = append(found, rawComment{i, strings.TrimRight(src[i:end], "\r"), false})
+			i = end
+			continue
+		}
+		if pair, ok := l.blockAt(src, i); ok {
+			end := strings.Index(src[i+len(pair[0]):], pair[1])
+			if end < 0 {
+				end = len(src)
+			} else {
+				end += i + len(pair[0]) + len(pair[1])
+			}
+			found = append(found, rawComment{i, src[i:end], false})
+			i = end
+			continue
+		}
+		i++
+	}
+	return found
+}
+
+func (l *language) quoteAt(src string, i int) (quote, bool) {
+	for _, q := range l.quotes {
+		if strings.HasPrefix(src[i:], q.delim) {
+			return q, true
+		}
+	}
+	return quote{}, false
+}
+
+func (l *language) lineAt(src string, i int) (string, bool) {
+	for _, open := range l.line {
+		if !strings.HasPrefix(src[i:], open) {
+			continue
+		}
+		if l.wordHash && open == "#" && i > 0 && !strings.ContainsRune(" \t\n;|&(", rune(src[i-1])) {
+			continue
+		}
+		return open, true
+	}
+	return "", false
+}
+
+func (l *language) blockAt(src string, i int) ([2]string, bool) {
+	for _, pair := range l.block {
+		if strings.HasPrefix(src[i:], pair[0]) {
+			return pair, true
+		}
+	}
+	return [2]string{}, false
+}
+
+// skipString returns the offset just past the string opening at i.
+func skipString(src string, i int, q quote) int {
+	for j := i + len(q.delim); j < len(src); j++ {
+		switch {
+		case src[j] == '\\' && !q.raw:
+			j++
+		case src[j] == '\n' && !q.multiline:
+			return j
+		case strings.HasPrefix(src[j:], q.delim):
+			return j + len(q.delim)
+		}
+	}
+	return len(src)
+}
+
+// generatedTextRE is generatedRE without the //, for comments in other
+// languages that follow the Go convention.
+var generatedTextRE = regexp.MustCompile(`^Code generated .* DO NOT EDIT\.$`)
+
+// scanComments is scanSource for the languages other than Go. Comments
+// opened by // or /* go to the parser whole, as in Go; the others lose
+// their opener first, and being single lines still report the column of
+// the opener. Nothing is doc, since only Go's are told apart.
+func scanComments(path string, src []byte, l *language, opts treeOptions) []finding {
+	fset := token.NewFileSet()
+	file := fset.AddFile(path, -1, len(src))
+	file.SetLinesForContent(src)
+	var found []finding
+	var sup suppressions
+	for _, c := range l.comments(string(src), opts.strings) {
+		if c.str {
+			found = appendStringFindings(found, file, path, file.Pos(c.offset), c.text, opts.keywords)
+			continue
+		}
+		text := c.text
+		if !strings.HasPrefix(text, "//") && !strings.HasPrefix(text, "/*") {
+			for _, open := range l.line {
+				if rest, ok := strings.CutPrefix(text, open); ok {
+					text = rest
+					break
+				}
+			}
+		}
+		if generatedTextRE.MatchString(strings.TrimSpace(strings.TrimPrefix(text, "//"))) {
+			return nil
+		}
+		found = appendFindings(found, file, path, file.Pos(c.offset), text, opts.keywords, false)
+		sup.add(file, file.Pos(c.offset), c.text)
+	}
+	return sup.mark(found)
+}
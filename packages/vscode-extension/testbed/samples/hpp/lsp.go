@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var lspOpts struct {
+	keywords bool
+}
+
+func lspFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&lspOpts.keywords, "keywords", defaultKeywords, "also report the legacy keyword aliases (TODO, FIXME, NOTE, ...)")
+}
+
+// runLSP serves the Language Server Protocol on stdin and stdout, so any
+// editor with an LSP client gets the markers as diagnostics and in the
+// document outline. For helix, in languages.toml:
+//
+//	[language-server.hpp]
+//	command = "hpp"
+//	args = ["lsp"]
+//
+//	[[language]]
+//	name = "go"
+//	language-servers = ["gopls", "hpp"]
+//
+// Documents scan doesn't read are accepted and stay empty.
+func runLSP(_ []string) error {
+	s := &lspServer{out: os.Stdout, docs: map[string]string{}, keywords: lspOpts.keywords}
+	return s.serve(os.Stdin)
+}
+
+// lspServer handles one client. Messages are handled in order on the
+// reading goroutine, which is plenty for a scan this cheap and keeps the
+// document map free of locks.
+type lspServer struct {
+	out      io.Writer
+	docs     map[string]string
+	keywords bool
+	shutdown bool
+}
+
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+const (
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspSymbol struct {
+	Name           string   `json:"name"`
+	Detail         string   `json:"detail"`
+	Kind           int      `json:"kind"`
+	Range          lspRange `json:"range"`
+	SelectionRange lspRange `json:"selectionRange"`
+}
+
+type lspDocument struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Text       string `json:"text"`
+}
+
+// >> Markers are notes from people, not broken code, so each is shown one step below its SARIF level and real compile errors stay on top
+var lspSeverity = map[string]int{"error": 2, "warning": 3, "note": 4}
+
+// symbolKey is SymbolKind.Key; markers have no kind of their own.
+const symbolKey = 20
+
+// serve reads messages until exit or end of input. As the protocol asks,
+// an exit without a shutdown first is an error.
+func (s *lspServer) serve(in io.Reader) error {
+	r := textproto.NewReader(bufio.NewReader(in))
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit before shutdown")
+			}
+			return nil
+		}
+		result, rerr := s.handle(msg)
+		if msg.ID == nil {
+			continue
+		}
+		reply := rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: result, Error: rerr}
+		if rerr == nil && result == nil {
+			reply.Result = json.RawMessage("null")
+		}
+		if err := s.send(reply); err != nil {
+			return err
+		}
+	}
+}
+
+func readMessage(r *textproto.Reader) (rpcMessage, error) {
+	header, err := r.ReadMIMEHeader()
+	if err != nil {
+		return rpcMessage{}, err
+	}
+	n, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || n < 0 {
+		return rpcMessage{}, fmt.Errorf("bad Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r.R, body); err != nil {
+		return rpcMessage{}, err
+	}
+	var msg rpcMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return rpcMessage{}, fmt.Errorf("decoding message: %w", err)
+	}
+	return msg, nil
+}
+
+func (s *lspServer) send(msg rpcMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func (s *lspServer) notify(method string, params any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return s.send(rpcMessage{JSONRPC: "2.0", Method: method, Params: raw})
+}
+
+func (s *lspServer) handle(msg rpcMessage) (any, *rpcError) {
+	if s.shutdown && msg.ID != nil {
+		return nil, &rpcError{Code: rpcInvalidRequest, Message: "server is shut down"}
+	}
+	var params struct {
+		RootURI        string      `json:"rootUri"`
+		TextDocument   lspDocument `json:"textDocument"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+	}
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+	}
+	uri := params.TextDocument.URI
+
+	switch msg.Method {
+	case "initialize":
+		// The editor may run us from anywhere; the workspace decides
+		// which .humanpp.yml applies.
+		if u, err := url.Parse(params.RootURI); err == nil && u.Scheme == "file" {
+			if err := loadProjectConfig(u.Path); err != nil {
+				return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+			}
+		}
+		return map[string]any{
+			"capabilities": map[string]any{
+				// Full sync: every change sends the whole text.
+				"textDocumentSync":       1,
+				"documentSymbolProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "hpp"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		s.docs[uri] = params.TextDocument.Text
+		s.publish(uri)
+	case "textDocument/didChange":
+		if n := len(params.ContentChanges); n > 0 {
+			s.docs[uri] = params.ContentChanges[n-1].Text
+			s.publish(uri)
+		}
+	case "textDocument/didClose":
+		delete(s.docs, uri)
+		s.notify("textDocument/publishDiagnostics", map[string]any{"uri": uri, "diagnostics": []lspDiagnostic{}})
+	case "textDocument/documentSymbol":
+		text, ok := s.docs[uri]
+		if !ok {
+			return []lspSymbol{}, nil
+		}
+		symbols := []lspSymbol{}
+		for _, f := range s.scan(uri, text) {
+			rng := lspLineRange(text, f)
+			symbols = append(symbols, lspSymbol{Name: f.Marker + " " + f.Text, Detail: kindNamed(f.Kind).Description, Kind: symbolKey, Range: rng, SelectionRange: rng})
+		}
+		return symbols, nil
+	default:
+		if msg.ID != nil {
+			return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not supported: " + msg.Method}
+		}
+	}
+	return nil, nil
+}
+
+func (s *lspServer) publish(uri string) {
+	text := s.docs[uri]
+	diags := []lspDiagnostic{}
+	for _, f := range s.scan(uri, text) {
+		k := kindNamed(f.Kind)
+		msg := f.Text
+		if msg == "" {
+			msg = f.Marker + " " + f.Kind
+		}
+		diags = append(diags, lspDiagnostic{Range: lspLineRange(text, f), Severity: lspSeverity[k.Severity], Code: f.Kind, Source: "hpp", Message: msg})
+	}
+	s.notify("textDocument/publishDiagnostics", map[string]any{"uri": uri, "diagnostics": diags})
+}
+
+func (s *lspServer) scan(uri, text string) []finding {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil
+	}
+	return unsuppressed(scanSource(u.Path, []byte(text), treeOptions{keywords: s.keywords}))
+}
+
+// lspLineRange spans a finding from its marker to the end of its line.
+// LSP counts characters in UTF-16 code units, where findings count bytes.
+func lspLineRange(text string, f finding) lspRange {
+	line := nthLine(text, f.Line-1)
+	start := utf16Len(line[:min(f.Column-1, len(line))])
+	return lspRange{
+		Start: lspPosition{Line: f.Line - 1, Character: start},
+		End:   lspPosition{Line: f.Line - 1, Character: utf16Len(line)},
+	}
+}
+
+func nthLine(text string, n int) string {
+	for line := range strings.Lines(text) {
+		if n == 0 {
+			return strings.TrimRight(line, "\r\n")
+		}
+		n--
+	}
+	return ""
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,90 @@
+// Command hpp reports Human++ markers (!!, ??, >> and ~~) in comments, so
+// they surface in vet output and CI as well as in the editor. Without a
+// subcommand it is a go/analysis checker, run on its own or under go vet:
+//
+//	go build -o hpp ./hpp
+//	./hpp ./...
+//	go vet -vettool=$(pwd)/hpp ./...
+//
+// The subcommands work on a source tree rather than loaded packages, and
+// read JavaScript, TypeScript, Python and shell as well as Go:
+//
+//	./hpp scan . | jq '.[] | select(.marker == "!!")'
+//	./hpp scan --format sarif . > hpp.sarif
+//
+// hpp lsp is a language server for editors other than VS Code, and hpp
+// rpc a plainer JSON-RPC mode for plugins that would rather not speak LSP.
+//
+// Every mode reads the nearest .humanpp.yml, up to the repository root,
+// for custom markers; see projectConfig.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+// command is one subcommand. run gets the arguments left after flags.
+type command struct {
+	name    string
+	summary string
+	flags   func(*flag.FlagSet)
+	run     func(args []string) error
+}
+
+func commands() []command {
+	return []command{
+		{name: "scan", summary: "list the markers under a directory as JSON or SARIF", run: runScan, flags: scanFlags},
+		{name: "lsp", summary: "serve the markers to editors over the Language Server Protocol", run: runLSP, flags: lspFlags},
+		{name: "precommit", summary: "fail when staged changes add blocking markers", run: runPrecommit, flags: precommitFlags},
+		{name: "report", summary: "summarise markers by package, kind and age as Markdown or HTML", run: runReport, flags: reportFlags},
+		{name: "convert", summary: "rewrite TODO:, FIXME: and the other keyword comments as markers", run: runConvert, flags: convertFlags},
+		{name: "stats", summary: "count markers per author from git blame and list the oldest !!", run: runStats, flags: statsFlags},
+		{name: "rpc", summary: "answer parse and scan requests as line-delimited JSON-RPC on stdio", run: runRPC, flags: rpcFlags},
+		{name: "issues", summary: "sync a GitHub issue per ?? marker, closing those whose marker is gone", run: runIssues, flags: issuesFlags},
+	}
+}
+
+// !! hpp needs golang.org/x/tools - it is the one binary here that won't build from the standard library alone
+func main() {
+	if err := loadProjectConfig("."); err != nil {
+		fmt.Fprintf(os.Stderr, "hpp: %v\n", err)
+		os.Exit(1)
+	}
+	if len(os.Args) > 1 {
+		for _, cmd := range commands() {
+			if cmd.name != os.Args[1] {
+				continue
+			}
+			fs := flag.NewFlagSet(os.Args[0]+" "+cmd.name, flag.ContinueOnError)
+			if cmd.flags != nil {
+				cmd.flags(fs)
+			}
+			err := fs.Parse(os.Args[2:])
+			if err == nil {
+				err = cmd.run(fs.Args())
+			}
+			if err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return
+				}
+				fmt.Fprintf(os.Stderr, "hpp %s: %v\n", cmd.name, err)
+				var exit *exitError
+				if errors.As(err, &exit) {
+					os.Exit(exit.code)
+				}
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	// >> Anything else, including go vet's -V=full and .cfg invocations, belongs to the checker
+	if defaultKeywords {
+		Analyzer.Flags.Set("keywords", "true")
+	}
+	singlechecker.Main(Analyzer)
+}
@@ -0,0 +1,242 @@
+// Package marker parses Human++ annotations out of source comments. It is
+// the one implementation behind the hpp analyzer, scanner and language
+// server.
+//
+// A comment is read line by line, and each line holds at most one
+// annotation:
+//
+//	line       = [ space ] [ "*" ] ( annotation | text )
+//	annotation = marker [ space meta ] ( space text | end )
+//	marker     = "!!" | "??" | ">>" | "~~"
+//	meta       = "(" item { "," item } ")"
+//	item       = date | ticket | assignee
+//	date       = YYYY "-" MM "-" DD
+//	ticket     = PROJ "-" digits | "#" digits
+//	assignee   = [ "@" ] word
+//
+// as in "!! (alice, 2025-07-01, PROJ-123) drop the retry loop". Each item
+// may appear once. A parenthesis that doesn't read as metadata, such as
+// "(see below)", stays part of the text; a lone word, such as
+// "(optional)", reads as an assignee.
+//
+// Those are the Builtin markers; a Parser made with other kinds matches
+// theirs instead. The leading "*" is only skipped in block comments,
+// where it is the usual continuation. An annotation must open the line,
+// so "a ?? b" or a marker mid-sentence is prose, and so is a marker run
+// together with its text, such as "!!important". With keywords on, a line
+// with no marker that contains a kind's keyword as a whole word, in any
+// case, is an annotation of that kind; the strongest kind wins.
+package marker
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Kind is one marker.
+type Kind struct {
+	Marker string
+	Name   string
+	// Severity is how loudly tools report it: error, warning or note.
+	Severity string
+	// Description says what the marker asks of a reader.
+	Description string
+	// Keywords are the legacy spellings that map onto the marker.
+	Keywords []string
+}
+
+// Builtin are the markers the editor extension highlights, in strength
+// order.
+//
+// ?? ~~ has no colour in the extension yet; it is reported so Go code can start using it, but editors won't highlight it until the palette grows a fourth accent
+var Builtin = []Kind{
+	{"!!", "intervention", "error", "Pay attention here", []string{"FIXME", "BUG", "XXX"}},
+	{"??", "uncertainty", "warning", "I'm uncertain", []string{"TODO", "HACK"}},
+	{">>", "directive", "note", "See reference", []string{"NOTE", "NB"}},
+	{"~~", "provisional", "note", "Expected to change", nil},
+}
+
+// Meta is the optional metadata after a marker. Fields not given are
+// empty.
+type Meta struct {
+	Assignee string
+	// Due is a date, as YYYY-MM-DD.
+	Due    string
+	Ticket string
+}
+
+// Annotation is a marker found in one line of a comment.
+type Annotation struct {
+	Kind Kind
+	Meta Meta
+	// Line is the line's index in the comment, from 0.
+	Line int
+	// Offset is the byte offset of the line in the comment: 0 for the
+	// first, so it points at the opening // or /*.
+	Offset int
+	// Text is the rest of the line after the marker and any metadata, or
+	// the whole line when a keyword matched.
+	Text    string
+	Keyword bool
+}
+
+// Parser parses comments against a set of kinds. It is safe for
+// concurrent use.
+type Parser struct {
+	kinds    []Kind
+	markerRE *regexp.Regexp
+	// keywordREs is parallel to kinds; nil for a kind without keywords
+	// or when keywords are off.
+	keywordREs []*regexp.Regexp
+}
+
+// NewParser returns a parser for kinds, strongest first. Markers must be
+// non-empty, free of spaces and letters, and unique, as must names.
+func NewParser(kinds []Kind, keywords bool) (*Parser, error) {
+	var errs []error
+	markers := map[string]bool{}
+	names := map[string]bool{}
+	for _, k := range kinds {
+		switch {
+		case k.Marker == "":
+			errs = append(errs, fmt.Errorf("kind %q: empty marker", k.Name))
+		case strings.IndexFunc(k.Marker, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0:
+			errs = append(errs, fmt.Errorf("kind %q: marker %q may not contain spaces, letters or digits", k.Name, k.Marker))
+		case markers[k.Marker]:
+			errs = append(errs, fmt.Errorf("marker %q defined twice", k.Marker))
+		}
+		if k.Name == "" {
+			errs = append(errs, fmt.Errorf("marker %q: empty name", k.Marker))
+		} else if names[k.Name] {
+			errs = append(errs, fmt.Errorf("kind name %q used twice", k.Name))
+		}
+		markers[k.Marker], names[k.Name] = true, true
+	}
+	if len(kinds) == 0 {
+		errs = append(errs, errors.New("no kinds"))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	p := &Parser{kinds: slices.Clone(kinds), keywordREs: make([]*regexp.Regexp, len(kinds))}
+	// Longest first, so a marker that prefixes another can't shadow it.
+	alts := make([]string, len(kinds))
+	for i, k := range kinds {
+		alts[i] = regexp.QuoteMeta(k.Marker)
+	}
+	slices.SortStableFunc(alts, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+	p.markerRE = regexp.MustCompile(`^\s*(` + strings.Join(alts, "|") + `)(?:\s+|$)`)
+	if keywords {
+		for i, k := range kinds {
+			if len(k.Keywords) == 0 {
+				continue
+			}
+			words := make([]string, len(k.Keywords))
+			for j, w := range k.Keywords {
+				words[j] = regexp.QuoteMeta(w)
+			}
+			p.keywordREs[i] = regexp.MustCompile(`(?i)\b(?:` + strings.Join(words, "|") + `)\b`)
+		}
+	}
+	return p, nil
+}
+
+// Kinds returns the parser's kinds, strongest first.
+func (p *Parser) Kinds() []Kind { return p.kinds }
+
+var builtin, _ = NewParser(Builtin, false)
+
+// Parse parses comment for the built-in markers, without keywords.
+func Parse(comment string) []Annotation { return builtin.Parse(comment) }
+
+// Parse returns the annotations in comment, in line order. A comment
+// starting with // or /* is taken as written in Go and similar languages,
+// delimiters included; anything else as comment text whose delimiters
+// the caller has already removed.
+func (p *Parser) Parse(comment string) []Annotation {
+	body, offset, block := comment, 0, false
+	switch {
+	case strings.HasPrefix(comment, "//"):
+		body, offset = comment[2:], 2
+	case strings.HasPrefix(comment, "/*"):
+		body, offset, block = strings.TrimSuffix(comment[2:], "*/"), 2, true
+	}
+
+	var found []Annotation
+	start, n := 0, 0
+	for line := range strings.Lines(body) {
+		text := strings.TrimRight(line, "\r\n")
+		if block {
+			text = strings.TrimPrefix(strings.TrimLeft(text, " \t"), "*")
+		}
+		if a, ok := p.parseLine(text); ok {
+			a.Line, a.Offset = n, start
+			found = append(found, a)
+		}
+		offset += len(line)
+		start, n = offset, n+1
+	}
+	return found
+}
+
+func (p *Parser) parseLine(text string) (Annotation, bool) {
+	if m := p.markerRE.FindStringSubmatchIndex(text); m != nil {
+		i := slices.IndexFunc(p.kinds, func(k Kind) bool { return k.Marker == text[m[2]:m[3]] })
+		meta, rest := parseMeta(strings.TrimSpace(text[m[1]:]))
+		return Annotation{Kind: p.kinds[i], Meta: meta, Text: rest}, true
+	}
+	for i, re := range p.keywordREs {
+		if re != nil && re.MatchString(text) {
+			return Annotation{Kind: p.kinds[i], Text: strings.TrimSpace(text), Keyword: true}, true
+		}
+	}
+	return Annotation{}, false
+}
+
+var (
+	dateRE     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	ticketRE   = regexp.MustCompile(`^(?:[A-Z][A-Z0-9]+-\d+|#\d+)$`)
+	assigneeRE = regexp.MustCompile(`^@?[\pL\pN][\pL\pN._-]*$`)
+)
+
+// parseMeta splits a leading metadata group off text. Without one, or
+// with one that doesn't parse, text comes back whole.
+func parseMeta(text string) (Meta, string) {
+	if !strings.HasPrefix(text, "(") {
+		return Meta{}, text
+	}
+	end := strings.IndexByte(text, ')')
+	if end < 0 || end+1 < len(text) && text[end+1] != ' ' && text[end+1] != '\t' {
+		return Meta{}, text
+	}
+	var meta Meta
+	for _, item := range strings.Split(text[1:end], ",") {
+		item = strings.TrimSpace(item)
+		var field *string
+		switch {
+		case dateRE.MatchString(item):
+			if _, err := time.Parse(time.DateOnly, item); err != nil {
+				return Meta{}, text
+			}
+			field = &meta.Due
+		case ticketRE.MatchString(item):
+			field = &meta.Ticket
+		case assigneeRE.MatchString(item):
+			field, item = &meta.Assignee, strings.TrimPrefix(item, "@")
+		default:
+			return Meta{}, text
+		}
+		if *field != "" {
+			return Meta{}, text
+		}
+		*field = item
+	}
+	return meta, strings.TrimSpace(text[end+1:])
+}
@@ -0,0 +1,159 @@
+package marker
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var (
+	intervention = Builtin[0]
+	uncertainty  = Builtin[1]
+	directive    = Builtin[2]
+	provisional  = Builtin[3]
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment string
+		want    []Annotation
+	}{
+		{"intervention", "// !! drop the retry loop", []Annotation{{Kind: intervention, Text: "drop the retry loop"}}},
+		{"uncertainty", "// ?? is this still needed", []Annotation{{Kind: uncertainty, Text: "is this still needed"}}},
+		{"directive", "// >> see RFC 9110", []Annotation{{Kind: directive, Text: "see RFC 9110"}}},
+		{"provisional", "// ~~ until v2 ships", []Annotation{{Kind: provisional, Text: "until v2 ships"}}},
+		{"bare marker", "// !!", []Annotation{{Kind: intervention}}},
+		{"tab after marker", "//\t??\tmaybe", []Annotation{{Kind: uncertainty, Text: "maybe"}}},
+		{"no marker", "// plain prose", nil},
+		{"mid-sentence", "// a ?? b", nil},
+		{"run together", "// !!important", nil},
+		{"star outside a block", "// * !! x", nil},
+		{"stripped text", "!! one\nplain\r\n?? two\r\n", []Annotation{
+			{Kind: intervention, Text: "one"},
+			{Kind: uncertainty, Line: 2, Offset: 14, Text: "two"},
+		}},
+		{"block", "/* intro\n * !! first\n * plain\n   ?? second */", []Annotation{
+			{Kind: intervention, Line: 1, Offset: 9, Text: "first"},
+			{Kind: uncertainty, Line: 3, Offset: 30, Text: "second"},
+		}},
+		{"block first line", "/* >> see below */", []Annotation{{Kind: directive, Text: "see below"}}},
+		{"block without stars", "/*\n!! flush\n*/", []Annotation{{Kind: intervention, Line: 1, Offset: 3, Text: "flush"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.comment); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q)\n got %+v\nwant %+v", tt.comment, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMeta(t *testing.T) {
+	tests := []struct {
+		text string
+		meta Meta
+		rest string
+	}{
+		{"(alice, 2025-07-01, PROJ-123) drop it", Meta{Assignee: "alice", Due: "2025-07-01", Ticket: "PROJ-123"}, "drop it"},
+		{"(PROJ-123,alice) any order", Meta{Assignee: "alice", Ticket: "PROJ-123"}, "any order"},
+		{"(@bob) at sign dropped", Meta{Assignee: "bob"}, "at sign dropped"},
+		{"(#42) issue ticket", Meta{Ticket: "#42"}, "issue ticket"},
+		{"(optional) a lone word", Meta{Assignee: "optional"}, "a lone word"},
+		{"(j.doe-2)\ttab", Meta{Assignee: "j.doe-2"}, "tab"},
+		{"(alice)", Meta{Assignee: "alice"}, ""},
+		{"no metadata", Meta{}, "no metadata"},
+		{"(see below) prose", Meta{}, "(see below) prose"},
+		{"(alice, bob) two assignees", Meta{}, "(alice, bob) two assignees"},
+		{"(2025-02-30) no such day", Meta{}, "(2025-02-30) no such day"},
+		{"(alice,) empty item", Meta{}, "(alice,) empty item"},
+		{"() empty", Meta{}, "() empty"},
+		{"(alice unclosed", Meta{}, "(alice unclosed"},
+		{"(alice)run together", Meta{}, "(alice)run together"},
+		{"(proj-1) not a ticket", Meta{Assignee: "proj-1"}, "not a ticket"},
+	}
+	for _, tt := range tests {
+		meta, rest := parseMeta(tt.text)
+		if meta != tt.meta || rest != tt.rest {
+			t.Errorf("parseMeta(%q) = %+v, %q; want %+v, %q", tt.text, meta, rest, tt.meta, tt.rest)
+		}
+	}
+}
+
+func TestParseMarkerMeta(t *testing.T) {
+	got := Parse("// ?? (alice, #7) retry here?")
+	want := []Annotation{{Kind: uncertainty, Meta: Meta{Assignee: "alice", Ticket: "#7"}, Text: "retry here?"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseKeywords(t *testing.T) {
+	p, err := NewParser(Builtin, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		comment string
+		want    []Annotation
+	}{
+		{"// TODO: fix this", []Annotation{{Kind: uncertainty, Text: "TODO: fix this", Keyword: true}}},
+		{"// call it, todo later", []Annotation{{Kind: uncertainty, Text: "call it, todo later", Keyword: true}}},
+		{"// NB the order matters", []Annotation{{Kind: directive, Text: "NB the order matters", Keyword: true}}},
+		{"// TODO and FIXME", []Annotation{{Kind: intervention, Text: "TODO and FIXME", Keyword: true}}},
+		{"// ?? FIXME", []Annotation{{Kind: uncertainty, Text: "FIXME"}}},
+		{"// TODOS and mytodo", nil},
+		{"// buggy", nil},
+	}
+	for _, tt := range tests {
+		if got := p.Parse(tt.comment); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q)\n got %+v\nwant %+v", tt.comment, got, tt.want)
+		}
+	}
+	if got := Parse("// TODO: fix this"); got != nil {
+		t.Errorf("package Parse matched a keyword: %+v", got)
+	}
+}
+
+func TestNewParserCustomKinds(t *testing.T) {
+	short := Kind{Marker: "!", Name: "short"}
+	long := Kind{Marker: "!!!", Name: "long"}
+	p, err := NewParser([]Kind{short, long}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for comment, want := range map[string][]Annotation{
+		"// ! one":   {{Kind: short, Text: "one"}},
+		"// !!! two": {{Kind: long, Text: "two"}},
+		"// !! none": nil,
+		"// ?? none": nil,
+	} {
+		if got := p.Parse(comment); !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", comment, got, want)
+		}
+	}
+}
+
+func TestNewParserErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		kinds []Kind
+		want  string
+	}{
+		{"no kinds", nil, "no kinds"},
+		{"empty marker", []Kind{{Name: "a"}}, `kind "a": empty marker`},
+		{"letters", []Kind{{Marker: "!a", Name: "a"}}, "may not contain spaces, letters or digits"},
+		{"space", []Kind{{Marker: "! !", Name: "a"}}, "may not contain spaces, letters or digits"},
+		{"duplicate marker", []Kind{{Marker: "!!", Name: "a"}, {Marker: "!!", Name: "b"}}, `marker "!!" defined twice`},
+		{"empty name", []Kind{{Marker: "!!"}}, `marker "!!": empty name`},
+		{"duplicate name", []Kind{{Marker: "!!", Name: "a"}, {Marker: "??", Name: "a"}}, `kind name "a" used twice`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewParser(tt.kinds, false)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewParser error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
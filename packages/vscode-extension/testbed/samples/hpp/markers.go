@@ -0,0 +1,69 @@
+package main
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/hpp/marker"
+)
+
+// kinds are the markers every command works with, strongest first: the
+// built-ins, or what a .humanpp.yml makes of them.
+var kinds = marker.Builtin
+
+// defaultKeywords is the default of every -keywords flag, set by the
+// config file.
+var defaultKeywords bool
+
+var parsers struct {
+	mu sync.Mutex
+	by map[bool]*marker.Parser
+}
+
+// parser returns the parser for kinds, with or without keywords. The
+// analyzer calls it from passes running in parallel.
+func parser(keywords bool) *marker.Parser {
+	parsers.mu.Lock()
+	defer parsers.mu.Unlock()
+	p, ok := parsers.by[keywords]
+	if !ok {
+		var err error
+		if p, err = marker.NewParser(kinds, keywords); err != nil {
+			panic(err)
+		}
+		if parsers.by == nil {
+			parsers.by = map[bool]*marker.Parser{}
+		}
+		parsers.by[keywords] = p
+	}
+	return p
+}
+
+// setKinds replaces kinds and drops the parsers built for the old ones.
+func setKinds(k []marker.Kind) {
+	parsers.mu.Lock()
+	defer parsers.mu.Unlock()
+	kinds = k
+	parsers.by = nil
+}
+
+// kindFor returns the kind spelled m, or nil.
+func kindFor(m string) *marker.Kind {
+	if i := kindIndex(m); i >= 0 {
+		return &kinds[i]
+	}
+	return nil
+}
+
+// kindIndex returns the strength rank of m, 0 the strongest, or -1.
+func kindIndex(m string) int {
+	return slices.IndexFunc(kinds, func(k marker.Kind) bool { return k.Marker == m })
+}
+
+// kindNamed returns the kind called name, or nil.
+func kindNamed(name string) *marker.Kind {
+	if i := slices.IndexFunc(kinds, func(k marker.Kind) bool { return k.Name == name }); i >= 0 {
+		return &kinds[i]
+	}
+	return nil
+}
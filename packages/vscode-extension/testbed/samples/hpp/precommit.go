@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+var precommitOpts struct {
+	markers  string
+	keywords bool
+}
+
+func precommitFlags(fs *flag.FlagSet) {
+	fs.StringVar(&precommitOpts.markers, "markers", "!!", "comma-separated markers that block a commit")
+	fs.BoolVar(&precommitOpts.keywords, "keywords", defaultKeywords, "count the legacy keyword aliases as their markers")
+}
+
+// runPrecommit fails when the staged changes add any of -markers, and
+// prints the lines that do. Markers already in the tree don't count, so a
+// commit that only moves code around keeps passing. As a git hook:
+//
+//	printf '#!/bin/sh\nexec hpp precommit\n' > .git/hooks/pre-commit
+//	chmod +x .git/hooks/pre-commit
+//
+// It reads the index, not the working tree, so unstaged edits neither
+// rescue nor fail a commit.
+func runPrecommit(_ []string) error {
+	blocked := map[string]bool{}
+	for _, m := range strings.Split(precommitOpts.markers, ",") {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+		if kindFor(m) == nil {
+			return fmt.Errorf("-markers: unknown marker %q", m)
+		}
+		blocked[m] = true
+	}
+
+	diff, err := git("diff", "--cached", "--unified=0", "--no-color", "--no-ext-diff", "--diff-filter=ACMR")
+	if err != nil {
+		return err
+	}
+	var offending []finding
+	for path, lines := range addedLines(diff) {
+		if !scannable(path) {
+			continue
+		}
+		src, err := git("show", ":"+path)
+		if err != nil {
+			return err
+		}
+		for _, f := range scanSource(path, src, treeOptions{keywords: precommitOpts.keywords}) {
+			if blocked[f.Marker] && lines[f.Line] && !f.Suppressed {
+				offending = append(offending, f)
+			}
+		}
+	}
+	if len(offending) == 0 {
+		return nil
+	}
+	sortFindings(offending)
+	for _, f := range offending {
+		fmt.Fprintf(os.Stderr, "%s:%d: %s %s\n", f.File, f.Line, f.Marker, f.Text)
+	}
+	return fmt.Errorf("%d staged marker(s) from %s; resolve them or commit with --no-verify", len(offending), precommitOpts.markers)
+}
+
+// addedLines maps each file in a --unified=0 diff to the line numbers, in
+// the new version, of the lines it adds.
+func addedLines(diff []byte) map[string]map[int]bool {
+	added := map[string]map[int]bool{}
+	var path string
+	var line int
+	sc := bufio.NewScanner(bytes.NewReader(diff))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		text := sc.Text()
+		switch {
+		case strings.HasPrefix(text, "+++ "):
+			path = strings.TrimPrefix(strings.TrimPrefix(text, "+++ "), "b/")
+			added[path] = map[int]bool{}
+		case strings.HasPrefix(text, "@@ "):
+			// @@ -a,b +c,d @@: the new side starts at line c.
+			fields := strings.Fields(text)
+			if len(fields) < 3 {
+				continue
+			}
+			start, _, _ := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+			line, _ = strconv.Atoi(start)
+		case strings.HasPrefix(text, "+"):
+			if path != "" {
+				added[path][line] = true
+			}
+			line++
+		}
+	}
+	return added
+}
+
+func git(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
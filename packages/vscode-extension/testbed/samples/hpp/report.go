@@ -0,0 +1,295 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var reportOpts struct {
+	format   string
+	markers  string
+	keywords bool
+	exclude  string
+	assignee string
+	ticket   string
+	dueBy    string
+}
+
+func reportFlags(fs *flag.FlagSet) {
+	fs.StringVar(&reportOpts.format, "format", "markdown", "output format: markdown or html")
+	fs.StringVar(&reportOpts.markers, "markers", "!!,??", "comma-separated markers to include")
+	fs.BoolVar(&reportOpts.keywords, "keywords", defaultKeywords, "count the legacy keyword aliases as their markers")
+	excludeFlag(fs, &reportOpts.exclude)
+	fs.StringVar(&reportOpts.assignee, "assignee", "", "only include markers assigned to this name")
+	fs.StringVar(&reportOpts.ticket, "ticket", "", "only include markers citing this ticket, or any ticket of a project such as PROJ")
+	fs.StringVar(&reportOpts.dueBy, "due-by", "", "only include markers due on or before this YYYY-MM-DD date")
+}
+
+// ageBuckets are the columns of the report's summary, by how long ago a
+// marker's line was last touched. Lines with no history land in unknown.
+type ageBucket struct {
+	name string
+	max  time.Duration
+}
+
+var ageBuckets = []ageBucket{
+	{"this week", 7 * 24 * time.Hour},
+	{"this month", 30 * 24 * time.Hour},
+	{"this quarter", 91 * 24 * time.Hour},
+	{"older", 1<<63 - 1},
+}
+
+type reportData struct {
+	Generated time.Time
+	Buckets   []string
+	Kinds     []reportKind
+	Packages  []reportPackage
+}
+
+type reportKind struct {
+	Marker string
+	Name   string
+	Counts []int
+	Total  int
+	// Suppressed counts the markers human:ignore hides, which are in
+	// neither Counts nor Total.
+	Suppressed int
+}
+
+type reportPackage struct {
+	Name  string
+	Items []reportItem
+}
+
+type reportItem struct {
+	finding
+	Age string
+	// added is when the line was last touched; zero if unknown.
+	added time.Time
+}
+
+// runReport summarises the markers under its arguments for review: a
+// count per kind and age, then each package's markers, oldest first
+// within each kind. Ages come from git blame, so run it in a checkout.
+// -assignee, -ticket and -due-by narrow it to markers whose metadata
+// match, as in !! (alice, 2025-07-01, PROJ-123).
+func runReport(args []string) error {
+	if reportOpts.format != "markdown" && reportOpts.format != "html" {
+		return fmt.Errorf("-format must be markdown or html, not %q", reportOpts.format)
+	}
+	include := map[string]bool{}
+	for _, m := range strings.Split(reportOpts.markers, ",") {
+		m = strings.TrimSpace(m)
+		if kindFor(m) == nil {
+			return fmt.Errorf("-markers: unknown marker %q", m)
+		}
+		include[m] = true
+	}
+
+	if reportOpts.dueBy != "" {
+		if _, err := time.Parse(time.DateOnly, reportOpts.dueBy); err != nil {
+			return fmt.Errorf("-due-by must be YYYY-MM-DD, not %q", reportOpts.dueBy)
+		}
+	}
+	findings, err := scanTree(args, newTreeOptions(reportOpts.keywords, reportOpts.exclude))
+	if err != nil {
+		return err
+	}
+	findings = slices.DeleteFunc(findings, func(f finding) bool { return !reportMatches(f) })
+	now := time.Now()
+	data := reportData{Generated: now}
+	for _, b := range ageBuckets {
+		data.Buckets = append(data.Buckets, b.name)
+	}
+	data.Buckets = append(data.Buckets, "unknown")
+	rows := map[string]*reportKind{}
+	for _, k := range kinds {
+		if include[k.Marker] {
+			data.Kinds = append(data.Kinds, reportKind{Marker: k.Marker, Name: k.Name, Counts: make([]int, len(data.Buckets))})
+		}
+	}
+	for i := range data.Kinds {
+		rows[data.Kinds[i].Marker] = &data.Kinds[i]
+	}
+
+	packages := map[string][]reportItem{}
+	blames := map[string]map[int]blameLine{}
+	for _, f := range findings {
+		row, ok := rows[f.Marker]
+		if !ok {
+			continue
+		}
+		if f.Suppressed {
+			row.Suppressed++
+			continue
+		}
+		lines, ok := blames[f.File]
+		if !ok {
+			// ?? A file git doesn't track has no blame; its markers are reported with an unknown age rather than failing the report
+			lines, _ = blameFile(f.File)
+			blames[f.File] = lines
+		}
+		item := reportItem{finding: f, Age: "unknown"}
+		bucket := len(ageBuckets)
+		if b, ok := lines[f.Line]; ok {
+			item.added = b.Time
+			if b.Time.IsZero() {
+				item.added = now
+			}
+			age := now.Sub(item.added)
+			item.Age = formatAge(age)
+			bucket = slices.IndexFunc(ageBuckets, func(b ageBucket) bool { return age < b.max })
+		}
+		row.Counts[bucket]++
+		row.Total++
+		pkg := filepath.ToSlash(filepath.Dir(f.File))
+		packages[pkg] = append(packages[pkg], item)
+	}
+
+	for name, items := range packages {
+		slices.SortFunc(items, func(a, b reportItem) int {
+			return cmp.Or(
+				cmp.Compare(kindIndex(a.Marker), kindIndex(b.Marker)),
+				compareAdded(a.added, b.added),
+				strings.Compare(a.File, b.File),
+				cmp.Compare(a.Line, b.Line),
+			)
+		})
+		data.Packages = append(data.Packages, reportPackage{Name: name, Items: items})
+	}
+	slices.SortFunc(data.Packages, func(a, b reportPackage) int { return strings.Compare(a.Name, b.Name) })
+
+	return renderReport(os.Stdout, reportOpts.format, data)
+}
+
+// reportMatches applies the metadata filters. Dates compare as strings,
+// which YYYY-MM-DD allows.
+func reportMatches(f finding) bool {
+	project, _, _ := strings.Cut(f.Ticket, "-")
+	switch {
+	case reportOpts.assignee != "" && f.Assignee != reportOpts.assignee:
+		return false
+	case reportOpts.ticket != "" && f.Ticket != reportOpts.ticket && project != reportOpts.ticket:
+		return false
+	case reportOpts.dueBy != "" && (f.Due == "" || f.Due > reportOpts.dueBy):
+		return false
+	}
+	return true
+}
+
+// compareAdded puts older lines first and lines of unknown age last.
+func compareAdded(a, b time.Time) int {
+	switch {
+	case a.IsZero() || b.IsZero():
+		return cmp.Compare(btoi(a.IsZero()), btoi(b.IsZero()))
+	default:
+		return a.Compare(b)
+	}
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func formatAge(d time.Duration) string {
+	days := int(d.Hours() / 24)
+	switch {
+	case days < 1:
+		return "today"
+	case days < 14:
+		return fmt.Sprintf("%dd", days)
+	case days < 60:
+		return fmt.Sprintf("%dw", days/7)
+	case days < 730:
+		return fmt.Sprintf("%dmo", days/30)
+	default:
+		return fmt.Sprintf("%dy", days/365)
+	}
+}
+
+func renderReport(w io.Writer, format string, data reportData) error {
+	if format == "html" {
+		return htmlReport.Execute(w, data)
+	}
+	return markdownReport.Execute(w, data)
+}
+
+var reportFuncs = map[string]any{
+	// cell keeps a marker's text from breaking out of its table cell.
+	"cell": func(s string) string {
+		return strings.NewReplacer("|", `\|`, "`", "'").Replace(s)
+	},
+	"date": func(t time.Time) string { return t.Format("2006-01-02") },
+	// meta shows a marker's metadata in one cell.
+	"meta": func(f reportItem) string {
+		var parts []string
+		if f.Assignee != "" {
+			parts = append(parts, f.Assignee)
+		}
+		if f.Due != "" {
+			parts = append(parts, "due "+f.Due)
+		}
+		if f.Ticket != "" {
+			parts = append(parts, f.Ticket)
+		}
+		return strings.Join(parts, ", ")
+	},
+}
+
+var markdownReport = template.Must(template.New("markdown").Funcs(reportFuncs).Parse(`# Human++ markers
+
+Generated {{date .Generated}}.
+
+| Marker |{{range .Buckets}} {{.}} |{{end}} Total | Suppressed |
+|--------|{{range .Buckets}}------|{{end}}-------|------------|
+{{range .Kinds}}| ` + "`{{.Marker}}`" + ` {{.Name}} |{{range .Counts}} {{.}} |{{end}} **{{.Total}}** | {{.Suppressed}} |
+{{end}}{{range .Packages}}
+## {{.Name}}
+
+| Marker | Age | Location | Text | Details |
+|--------|-----|----------|------|---------|
+{{range .Items}}| ` + "`{{.Marker}}`" + ` | {{.Age}} | {{.File}}:{{.Line}} | {{cell .Text}} | {{cell (meta .)}} |
+{{end}}{{end}}`))
+
+var htmlReport = htmltemplate.Must(htmltemplate.New("html").Funcs(reportFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Human++ markers</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; color: #1a1c22; }
+table { border-collapse: collapse; margin-bottom: 2rem; }
+th, td { border: 1px solid #ccc; padding: 0.25rem 0.5rem; text-align: left; }
+code { font-weight: bold; padding: 0 0.25rem; border-radius: 4px; }
+.intervention { background: #bbff00; }
+.uncertainty { background: #9871fe; color: #f8f6f2; }
+.directive { background: #1ad0d6; }
+</style>
+</head>
+<body>
+<h1>Human++ markers</h1>
+<p>Generated {{date .Generated}}.</p>
+<table>
+<tr><th>Marker</th>{{range .Buckets}}<th>{{.}}</th>{{end}}<th>Total</th><th>Suppressed</th></tr>
+{{range .Kinds}}<tr><td><code class="{{.Name}}">{{.Marker}}</code> {{.Name}}</td>{{range .Counts}}<td>{{.}}</td>{{end}}<td><strong>{{.Total}}</strong></td><td>{{.Suppressed}}</td></tr>
+{{end}}</table>
+{{range .Packages}}<h2>{{.Name}}</h2>
+<table>
+<tr><th>Marker</th><th>Age</th><th>Location</th><th>Text</th><th>Details</th></tr>
+{{range .Items}}<tr><td><code class="{{.Kind}}">{{.Marker}}</code></td><td>{{.Age}}</td><td>{{.File}}:{{.Line}}</td><td>{{.Text}}</td><td>{{meta .}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
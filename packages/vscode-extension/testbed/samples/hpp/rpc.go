@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+)
+
+var rpcOpts struct {
+	keywords bool
+}
+
+func rpcFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&rpcOpts.keywords, "keywords", defaultKeywords, "default for requests that don't set keywords")
+}
+
+// runRPC answers JSON-RPC 2.0 requests on stdin, one message per line,
+// with one reply per line on stdout, until stdin closes. It is for editor
+// plugins too small for an LSP client that still want the Go parser
+// rather than a copy of it:
+//
+//	{"jsonrpc":"2.0","id":1,"method":"parse","params":{"comment":"// ?? why"}}
+//	{"jsonrpc":"2.0","id":2,"method":"scan","params":{"path":"a.go","text":"..."}}
+//	{"jsonrpc":"2.0","id":3,"method":"scan","params":{"paths":["."]}}
+//	{"jsonrpc":"2.0","id":4,"method":"kinds"}
+//
+// scan takes either a document, whose text needn't be saved, or paths to
+// walk as hpp scan does. Both return scan's findings; parse returns the
+// annotations in one comment, and kinds the markers in effect. The
+// .humanpp.yml is the one for the directory hpp was started in.
+func runRPC(_ []string) error {
+	return serveRPC(os.Stdin, os.Stdout)
+}
+
+const rpcParseError = -32700
+
+type rpcKind struct {
+	Marker      string   `json:"marker"`
+	Name        string   `json:"name"`
+	Severity    string   `json:"severity"`
+	Description string   `json:"description,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+}
+
+type rpcAnnotation struct {
+	Marker   string `json:"marker"`
+	Kind     string `json:"kind"`
+	Severity string `json:"severity"`
+	Line     int    `json:"line"`
+	Offset   int    `json:"offset"`
+	Text     string `json:"text"`
+	Keyword  bool   `json:"keyword"`
+	Assignee string `json:"assignee,omitempty"`
+	Due      string `json:"due,omitempty"`
+	Ticket   string `json:"ticket,omitempty"`
+}
+
+type rpcParams struct {
+	// parse
+	Comment string `json:"comment"`
+	// scan, of a document
+	Path string  `json:"path"`
+	Text *string `json:"text"`
+	// scan, of a tree
+	Paths   []string `json:"paths"`
+	Exclude []string `json:"exclude"`
+	// Keywords overrides -keywords for one request.
+	Keywords *bool `json:"keywords"`
+	// Strings reads string literals too, as scan -strings does.
+	Strings bool `json:"strings"`
+}
+
+// serveRPC handles requests in order. A line that isn't JSON gets a parse
+// error and the stream carries on, since the next line is a fresh message.
+func serveRPC(in io.Reader, out io.Writer) error {
+	enc := json.NewEncoder(out)
+	sc := bufio.NewScanner(in)
+	sc.Buffer(nil, 64<<20)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			reply := rpcMessage{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: err.Error()}}
+			if err := enc.Encode(reply); err != nil {
+				return err
+			}
+			continue
+		}
+		result, rerr := handleRPC(msg)
+		if msg.ID == nil {
+			continue
+		}
+		reply := rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: result, Error: rerr}
+		if err := enc.Encode(reply); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+func handleRPC(msg rpcMessage) (any, *rpcError) {
+	var params rpcParams
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+	}
+	keywords := rpcOpts.keywords
+	if params.Keywords != nil {
+		keywords = *params.Keywords
+	}
+
+	switch msg.Method {
+	case "kinds":
+		out := []rpcKind{}
+		for _, k := range kinds {
+			out = append(out, rpcKind{Marker: k.Marker, Name: k.Name, Severity: k.Severity, Description: k.Description, Keywords: k.Keywords})
+		}
+		return out, nil
+	case "parse":
+		out := []rpcAnnotation{}
+		for _, a := range parser(keywords).Parse(params.Comment) {
+			out = append(out, rpcAnnotation{Marker: a.Kind.Marker, Kind: a.Kind.Name, Severity: a.Kind.Severity, Line: a.Line, Offset: a.Offset, Text: a.Text, Keyword: a.Keyword, Assignee: a.Meta.Assignee, Due: a.Meta.Due, Ticket: a.Meta.Ticket})
+		}
+		return out, nil
+	case "scan":
+		var found []finding
+		switch {
+		case params.Text != nil:
+			if params.Path == "" {
+				return nil, &rpcError{Code: rpcInvalidParams, Message: "scan: text needs a path"}
+			}
+			found = scanSource(params.Path, []byte(*params.Text), treeOptions{keywords: keywords, strings: params.Strings})
+		case len(params.Paths) > 0:
+			var err error
+			if found, err = scanTree(params.Paths, treeOptions{keywords: keywords, exclude: params.Exclude, strings: params.Strings}); err != nil {
+				return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+			}
+		default:
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "scan: needs path and text, or paths"}
+		}
+		if found = unsuppressed(found); found == nil {
+			found = []finding{}
+		}
+		return found, nil
+	}
+	if msg.ID == nil {
+		return nil, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not supported: " + msg.Method}
+}
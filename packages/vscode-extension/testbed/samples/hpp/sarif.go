@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+)
+
+// The subset of SARIF 2.1.0 that code-scanning tools read: one run, one
+// rule per marker kind, one result per finding.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string        `json:"id"`
+	ShortDescription     sarifMessage  `json:"shortDescription"`
+	FullDescription      *sarifMessage `json:"fullDescription,omitempty"`
+	DefaultConfiguration struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+	// Suppressions marks a result a human:ignore covers; scanning tools
+	// hide it but keep count.
+	Suppressions []sarifSuppression `json:"suppressions,omitempty"`
+	// Properties carries a marker's metadata, which SARIF has no field
+	// for.
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifSuppression struct {
+	Kind string `json:"kind"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region struct {
+			StartLine   int `json:"startLine"`
+			StartColumn int `json:"startColumn"`
+		} `json:"region"`
+	} `json:"physicalLocation"`
+}
+
+// sarifReport turns findings into a SARIF log, each at its kind's
+// severity: !! as an error, ?? as a warning, >> and ~~ as notes.
+func sarifReport(findings []finding) sarifLog {
+	rules := make([]sarifRule, len(kinds))
+	index := map[string]int{}
+	for i, k := range kinds {
+		rules[i].ID = k.Name
+		rules[i].ShortDescription.Text = k.Marker + " " + k.Name + " marker"
+		rules[i].DefaultConfiguration.Level = k.Severity
+		if k.Description != "" {
+			rules[i].FullDescription = &sarifMessage{k.Description}
+		}
+		index[k.Name] = i
+	}
+
+	results := make([]sarifResult, 0, len(findings))
+	for _, f := range findings {
+		i := index[f.Kind]
+		r := sarifResult{RuleID: f.Kind, RuleIndex: i, Level: kinds[i].Severity, Message: sarifMessage{f.Text}}
+		// >> SARIF requires a non-empty message, and a bare marker is still worth a look
+		if r.Message.Text == "" {
+			r.Message.Text = f.Marker + " " + f.Kind
+		}
+		var loc sarifLocation
+		loc.PhysicalLocation.ArtifactLocation.URI = sarifURI(f.File)
+		loc.PhysicalLocation.Region.StartLine = f.Line
+		loc.PhysicalLocation.Region.StartColumn = f.Column
+		r.Locations = []sarifLocation{loc}
+		if f.Suppressed {
+			r.Suppressions = []sarifSuppression{{Kind: "inSource"}}
+		}
+		for key, value := range map[string]string{"assignee": f.Assignee, "due": f.Due, "ticket": f.Ticket} {
+			if value == "" {
+				continue
+			}
+			if r.Properties == nil {
+				r.Properties = map[string]string{}
+			}
+			r.Properties[key] = value
+		}
+		results = append(results, r)
+	}
+
+	return sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: "hpp", InformationURI: Analyzer.URL, Rules: rules}},
+			Results: results,
+		}},
+	}
+}
+
+// sarifURI keeps relative paths relative, which is what lets code
+// scanning map results onto the repository, and makes absolute ones file
+// URIs.
+func sarifURI(path string) string {
+	u := url.URL{Path: filepath.ToSlash(path)}
+	if filepath.IsAbs(path) {
+		u.Scheme = "file"
+	}
+	return u.String()
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	"go/scanner"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var scanOpts struct {
+	keywords bool
+	format   string
+	comments string
+	exclude  string
+	failOn   string
+	max      string
+	watch    bool
+	interval time.Duration
+	baseline string
+	write    bool
+	// suppressed includes human:ignore'd markers in JSON output.
+	suppressed bool
+	strings    bool
+}
+
+func scanFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&scanOpts.keywords, "keywords", defaultKeywords, "also report the legacy keyword aliases (TODO, FIXME, NOTE, ...)")
+	fs.StringVar(&scanOpts.format, "format", "json", "output format: json, or sarif for code-scanning tools")
+	fs.StringVar(&scanOpts.comments, "comments", "all", "which comments to report: all, doc or inline")
+	excludeFlag(fs, &scanOpts.exclude)
+	fs.StringVar(&scanOpts.failOn, "fail-on", "none", "exit non-zero if any marker is at least this severe: none, note, warning or error")
+	fs.StringVar(&scanOpts.max, "max", "", "comma-separated marker=count limits, such as '!!=0,??=20', past which the scan fails")
+	fs.BoolVar(&scanOpts.watch, "watch", false, "keep re-scanning changed files and show a live summary instead of JSON")
+	fs.DurationVar(&scanOpts.interval, "interval", time.Second, "how often -watch looks for changes")
+	fs.StringVar(&scanOpts.baseline, "baseline", "", "leave out the markers recorded in this baseline file")
+	fs.BoolVar(&scanOpts.write, "write-baseline", false, "record the markers found as the -baseline file instead of printing them")
+	fs.BoolVar(&scanOpts.strings, "strings", false, "also report markers that open a line of a string literal")
+	fs.BoolVar(&scanOpts.suppressed, "suppressed", false, "include markers suppressed by human:ignore in JSON output, marked as such")
+}
+
+// excludeFlag registers -exclude for the commands that walk a tree.
+func excludeFlag(fs *flag.FlagSet, p *string) {
+	fs.StringVar(p, "exclude", "", "comma-separated directory names to skip, such as vendor,testdata")
+}
+
+// treeOptions are what scanTree and scanSource need from a command's
+// flags.
+type treeOptions struct {
+	keywords bool
+	// exclude holds directory names skipped wherever they appear.
+	exclude []string
+	// strings reads string literals as well as comments. Off, a marker
+	// that only looks like one, as in fmt.Println("?? huh"), is never
+	// reported, since every scanner knows a string from a comment.
+	strings bool
+}
+
+func newTreeOptions(keywords bool, exclude string) treeOptions {
+	opts := treeOptions{keywords: keywords}
+	for _, name := range strings.Split(exclude, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			opts.exclude = append(opts.exclude, name)
+		}
+	}
+	return opts
+}
+
+// finding is one marker as scan reports it. Line and Column are 1-based,
+// and Column counts bytes, as in go/token. Doc is set for markers in a
+// doc comment: the one directly above a package clause, declaration or
+// field. Suppressed is set for markers a human:ignore directive covers;
+// commands drop those, scan after counting them.
+type finding struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Marker string `json:"marker"`
+	Kind   string `json:"kind"`
+	Text   string `json:"text"`
+	Doc    bool   `json:"doc"`
+	// Assignee, Due and Ticket are the marker's metadata, if any.
+	Assignee string `json:"assignee,omitempty"`
+	Due      string `json:"due,omitempty"`
+	Ticket   string `json:"ticket,omitempty"`
+
+	Suppressed bool `json:"suppressed,omitempty"`
+	// String is set for markers found in a string literal, with -strings.
+	String bool `json:"string,omitempty"`
+}
+
+// runScan prints every marker in the source files under its arguments: as one
+// JSON array of findings, or as a SARIF log. With -fail-on or -max it
+// also prints a count per marker to stderr and fails with an exitError
+// when they are exceeded, so CI can gate on !! but let >> through:
+//
+//	hpp scan -fail-on error -max '??=25' . > markers.json
+//
+// With -baseline the gate counts only markers new since the baseline.
+// Suppressed markers never count; their number goes to stderr, and SARIF
+// lists them with an inSource suppression.
+func runScan(args []string) error {
+	if scanOpts.write && scanOpts.baseline == "" {
+		return fmt.Errorf("-write-baseline needs -baseline")
+	}
+	if scanOpts.watch {
+		if scanOpts.baseline != "" {
+			return fmt.Errorf("-baseline doesn't apply to -watch")
+		}
+		if scanOpts.interval <= 0 {
+			return fmt.Errorf("-interval must be positive")
+		}
+		opts := newTreeOptions(scanOpts.keywords, scanOpts.exclude)
+		opts.strings = scanOpts.strings
+		return runWatch(args, opts, scanOpts.interval)
+	}
+	g, err := parseGate(scanOpts.failOn, scanOpts.max)
+	if err != nil {
+		return err
+	}
+	if scanOpts.format != "json" && scanOpts.format != "sarif" {
+		return fmt.Errorf("-format must be json or sarif, not %q", scanOpts.format)
+	}
+	if scanOpts.comments != "all" && scanOpts.comments != "doc" && scanOpts.comments != "inline" {
+		return fmt.Errorf("-comments must be all, doc or inline, not %q", scanOpts.comments)
+	}
+	opts := newTreeOptions(scanOpts.keywords, scanOpts.exclude)
+	opts.strings = scanOpts.strings
+	findings, err := scanTree(args, opts)
+	if err != nil {
+		return err
+	}
+	if scanOpts.comments != "all" {
+		doc := scanOpts.comments == "doc"
+		findings = slices.DeleteFunc(findings, func(f finding) bool { return f.Doc != doc })
+	}
+	suppressed := suppressedOnly(findings)
+	findings = unsuppressed(findings)
+	if scanOpts.write {
+		if err := writeBaseline(scanOpts.baseline, findings); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "hpp scan: wrote %d markers to %s\n", len(findings), scanOpts.baseline)
+		return nil
+	}
+	if scanOpts.baseline != "" {
+		b, err := loadBaseline(scanOpts.baseline)
+		if err != nil {
+			return err
+		}
+		findings = b.filter(findings)
+	}
+	out := findings
+	if scanOpts.format == "sarif" || scanOpts.suppressed {
+		out = append(slices.Clone(findings), suppressed...)
+		sortFindings(out)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if scanOpts.format == "sarif" {
+		err = enc.Encode(sarifReport(out))
+	} else {
+		err = enc.Encode(out)
+	}
+	if err != nil {
+		return err
+	}
+	if !g.enabled() {
+		if len(suppressed) > 0 {
+			fmt.Fprintf(os.Stderr, "hpp scan: %d markers suppressed by %s\n", len(suppressed), ignoreDirective)
+		}
+		return nil
+	}
+	return g.check(os.Stderr, findings, suppressed)
+}
+
+// scanTree scans the scannable files under each root, "." when there are
+// none, skipping hidden directories such as .git, node_modules and any
+// opts exclude by name.
+// Findings are in path order.
+func scanTree(roots []string, opts treeOptions) ([]finding, error) {
+	findings := []finding{}
+	err := walkTree(roots, opts, func(path string, _ fs.DirEntry) error {
+		found, err := scanFile(path, opts)
+		findings = append(findings, found...)
+		return err
+	})
+	return findings, err
+}
+
+// walkTree calls fn for each file scanTree would scan.
+func walkTree(roots []string, opts treeOptions, fn func(path string, d fs.DirEntry) error) error {
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules" || slices.Contains(opts.exclude, d.Name())) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !scannable(path) {
+				return nil
+			}
+			return fn(path, d)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanFile(path string, opts treeOptions) ([]finding, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return scanSource(path, src, opts), nil
+}
+
+// scanSource returns the markers in src, by path's extension, or none
+// for a generated file or one that isn't scannable.
+func scanSource(path string, src []byte, opts treeOptions) []finding {
+	if filepath.Ext(path) == ".go" {
+		return scanGo(path, src, opts)
+	}
+	if l := languages[filepath.Ext(path)]; l != nil {
+		return scanComments(path, src, l, opts)
+	}
+	return nil
+}
+
+// scanGo parses src to tell doc comments apart; when that fails it falls
+// back to tokenizing, so markers in code that doesn't compile are still
+// found, only none of them as doc.
+func scanGo(path string, src []byte, opts treeOptions) []finding {
+	fset := token.NewFileSet()
+	file, err := goparser.ParseFile(fset, path, src, goparser.ParseComments|goparser.SkipObjectResolution)
+	if err != nil {
+		return tokenizeSource(path, src, opts)
+	}
+	if ast.IsGenerated(file) {
+		return nil
+	}
+	docs := docComments(file)
+	tf := fset.File(file.FileStart)
+	var found []finding
+	var sup suppressions
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			found = appendFindings(found, tf, path, c.Slash, c.Text, opts.keywords, docs[group])
+			sup.add(tf, c.Slash, c.Text)
+		}
+	}
+	if opts.strings {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				found = appendStringFindings(found, tf, path, lit.ValuePos+1, lit.Value[1:len(lit.Value)-1], opts.keywords)
+			}
+			return true
+		})
+		sortFindings(found)
+	}
+	return sup.mark(found)
+}
+
+// generatedRE is the header that marks a file as generated, as
+// ast.IsGenerated reads it.
+var generatedRE = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+func tokenizeSource(path string, src []byte, opts treeOptions) []finding {
+	fset := token.NewFileSet()
+	file := fset.AddFile(path, -1, len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, scanner.ScanComments)
+
+	var found []finding
+	var sup suppressions
+	header := true
+	for {
+		pos, tok, lit := s.Scan()
+		switch {
+		case tok == token.EOF:
+			return sup.mark(found)
+		case tok == token.STRING && opts.strings && len(lit) >= 2:
+			found = appendStringFindings(found, file, path, pos+1, lit[1:len(lit)-1], opts.keywords)
+		case tok != token.COMMENT:
+			header = header && tok != token.PACKAGE
+		case header && generatedRE.MatchString(lit):
+			return nil
+		default:
+			found = appendFindings(found, file, path, pos, lit, opts.keywords, false)
+			sup.add(file, pos, lit)
+		}
+	}
+}
+
+func appendFindings(found []finding, file *token.File, path string, pos token.Pos, comment string, keywords, doc bool) []finding {
+	for _, a := range parser(keywords).Parse(comment) {
+		p := file.Position(pos + token.Pos(a.Offset))
+		found = append(found, finding{
+			File:   filepath.ToSlash(path),
+			Line:   p.Line,
+			Column: p.Column,
+			Marker: a.Kind.Marker,
+			Kind:   a.Kind.Name,
+			Text:   a.Text,
+			Doc:    doc,
+
+			Assignee: a.Meta.Assignee,
+			Due:      a.Meta.Due,
+			Ticket:   a.Meta.Ticket,
+		})
+	}
+	return found
+}
+
+// appendStringFindings is appendFindings for the body of a string
+// literal starting at pos, quotes removed. It is parsed as it stands in
+// the source, escapes and all, so positions match.
+func appendStringFindings(found []finding, file *token.File, path string, pos token.Pos, body string, keywords bool) []finding {
+	n := len(found)
+	found = appendFindings(found, file, path, pos, body, keywords, false)
+	for i := n; i < len(found); i++ {
+		found[i].String = true
+	}
+	return found
+}
+
+// docComments returns the comment groups in file that are doc comments.
+func docComments(file *ast.File) map[*ast.CommentGroup]bool {
+	docs := map[*ast.CommentGroup]bool{}
+	add := func(g *ast.CommentGroup) {
+		if g != nil {
+			docs[g] = true
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.File:
+			add(n.Doc)
+		case *ast.GenDecl:
+			add(n.Doc)
+		case *ast.FuncDecl:
+			add(n.Doc)
+		case *ast.TypeSpec:
+			add(n.Doc)
+		case *ast.ValueSpec:
+			add(n.Doc)
+		case *ast.ImportSpec:
+			add(n.Doc)
+		case *ast.Field:
+			add(n.Doc)
+		}
+		return true
+	})
+	return docs
+}
+
+func sortFindings(findings []finding) {
+	slices.SortFunc(findings, func(a, b finding) int {
+		return cmp.Or(strings.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var statsOpts struct {
+	format   string
+	oldest   int
+	keywords bool
+	exclude  string
+}
+
+func statsFlags(fs *flag.FlagSet) {
+	fs.StringVar(&statsOpts.format, "format", "text", "output format: text or json")
+	fs.IntVar(&statsOpts.oldest, "oldest", 10, "how many of the oldest !! markers to list")
+	fs.BoolVar(&statsOpts.keywords, "keywords", defaultKeywords, "count the legacy keyword aliases as their markers")
+	excludeFlag(fs, &statsOpts.exclude)
+}
+
+// unknownAuthor stands in for whoever wrote lines blame can't attribute:
+// files git doesn't track, and lines not yet committed.
+const unknownAuthor = "(uncommitted)"
+
+type statsData struct {
+	Markers []string      `json:"markers"`
+	Authors []statsAuthor `json:"authors"`
+	Oldest  []statsItem   `json:"oldest"`
+}
+
+type statsAuthor struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+	// Counts is parallel to statsData.Markers.
+	Counts []int `json:"counts"`
+	Total  int   `json:"total"`
+}
+
+type statsItem struct {
+	finding
+	Author string    `json:"author"`
+	Commit string    `json:"commit,omitempty"`
+	Added  time.Time `json:"added"`
+}
+
+// runStats attributes the markers under its arguments with git blame: a
+// count per author and kind, most markers first, and the !! markers that
+// have waited longest. A marker belongs to whoever last touched its line,
+// which is usually but not always who wrote it.
+func runStats(args []string) error {
+	if statsOpts.format != "text" && statsOpts.format != "json" {
+		return fmt.Errorf("-format must be text or json, not %q", statsOpts.format)
+	}
+	findings, err := scanTree(args, newTreeOptions(statsOpts.keywords, statsOpts.exclude))
+	if err != nil {
+		return err
+	}
+	findings = unsuppressed(findings)
+
+	data := statsData{Markers: []string{}, Authors: []statsAuthor{}, Oldest: []statsItem{}}
+	for _, k := range kinds {
+		data.Markers = append(data.Markers, k.Marker)
+	}
+	authors := map[string]*statsAuthor{}
+	blames := map[string]map[int]blameLine{}
+	for _, f := range findings {
+		lines, ok := blames[f.File]
+		if !ok {
+			lines, _ = blameFile(f.File)
+			blames[f.File] = lines
+		}
+		b := lines[f.Line]
+		if b.Time.IsZero() {
+			b = blameLine{Author: unknownAuthor}
+		}
+		key := b.Email
+		if key == "" {
+			key = b.Author
+		}
+		a, ok := authors[key]
+		if !ok {
+			a = &statsAuthor{Name: b.Author, Email: b.Email, Counts: make([]int, len(kinds))}
+			authors[key] = a
+		}
+		a.Counts[kindIndex(f.Marker)]++
+		a.Total++
+		if f.Marker == "!!" && !b.Time.IsZero() {
+			data.Oldest = append(data.Oldest, statsItem{finding: f, Author: b.Author, Commit: b.Commit, Added: b.Time})
+		}
+	}
+	for _, a := range authors {
+		data.Authors = append(data.Authors, *a)
+	}
+	slices.SortFunc(data.Authors, func(a, b statsAuthor) int {
+		return cmp.Or(cmp.Compare(b.Total, a.Total), strings.Compare(a.Name, b.Name))
+	})
+	slices.SortFunc(data.Oldest, func(a, b statsItem) int {
+		return cmp.Or(a.Added.Compare(b.Added), strings.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line))
+	})
+	data.Oldest = data.Oldest[:min(len(data.Oldest), max(statsOpts.oldest, 0))]
+
+	if statsOpts.format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	}
+	return writeStats(os.Stdout, data, time.Now())
+}
+
+func writeStats(w io.Writer, data statsData, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "author\t%s\ttotal\n", strings.Join(data.Markers, "\t"))
+	for _, a := range data.Authors {
+		name := a.Name
+		if a.Email != "" {
+			name += " <" + a.Email + ">"
+		}
+		fmt.Fprint(tw, name)
+		for _, n := range a.Counts {
+			fmt.Fprintf(tw, "\t%d", n)
+		}
+		fmt.Fprintf(tw, "\t%d\n", a.Total)
+	}
+	if err := tw.Flush(); err != nil || len(data.Oldest) == 0 {
+		return err
+	}
+	fmt.Fprintln(w, "\noldest !!:")
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, it := range data.Oldest {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s:%d\t%s\n", formatAge(now.Sub(it.Added)), it.Added.Format("2006-01-02"), it.Author, it.File, it.Line, it.Text)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"go/token"
+	"slices"
+	"strings"
+)
+
+// Suppression directives, written in any comment:
+//
+//	x := legacy() // ?? why does this work human:ignore
+//	// human:ignore-file
+//
+// human:ignore suppresses the markers on its own line; human:ignore-file
+// every marker in the file. Suppressed markers are still counted, so
+// scan and report can show how many there are.
+const (
+	ignoreDirective     = "human:ignore"
+	ignoreFileDirective = "human:ignore-file"
+)
+
+// suppressions are the directives found in one file.
+type suppressions struct {
+	file  bool
+	lines map[int]bool
+}
+
+// add records the directives in comment, which starts at pos.
+func (s *suppressions) add(file *token.File, pos token.Pos, comment string) {
+	if !strings.Contains(comment, ignoreDirective) {
+		return
+	}
+	offset := 0
+	for line := range strings.Lines(comment) {
+		if strings.Contains(line, ignoreFileDirective) {
+			s.file = true
+		} else if strings.Contains(line, ignoreDirective) {
+			if s.lines == nil {
+				s.lines = map[int]bool{}
+			}
+			s.lines[file.Position(pos+token.Pos(offset)).Line] = true
+		}
+		offset += len(line)
+	}
+}
+
+// mark sets Suppressed on the findings the directives cover.
+func (s *suppressions) mark(found []finding) []finding {
+	for i := range found {
+		found[i].Suppressed = s.file || s.lines[found[i].Line]
+	}
+	return found
+}
+
+// unsuppressed returns the findings that aren't suppressed, leaving
+// findings as it is.
+func unsuppressed(findings []finding) []finding {
+	return slices.DeleteFunc(slices.Clone(findings), func(f finding) bool { return f.Suppressed })
+}
+
+// suppressedOnly returns the suppressed findings.
+func suppressedOnly(findings []finding) []finding {
+	return slices.DeleteFunc(slices.Clone(findings), func(f finding) bool { return !f.Suppressed })
+}
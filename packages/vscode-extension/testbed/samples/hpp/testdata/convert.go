@@ -0,0 +1,32 @@
+// Package sample has the legacy keyword comments convert rewrites.
+package sample
+
+// TODO: drop this once v2 ships
+func a() {}
+
+// FIXME(ann): racy under load
+func b() {}
+
+// TODO(PROJ-12) track the rollout
+func c() {}
+
+// HACK(see below): who that isn't an assignee
+func d() {}
+
+/*
+ * NOTE: see RFC 9110
+ * plain continuation
+ */
+func e() {}
+
+/* XXX: one-line block */
+func f() {}
+
+func g() {
+	x := 1 // BUG: off by one
+	_ = x
+	// this mentions a TODO mid-sentence and stays
+	// todo: lower case stays too
+	// TODOS is a different word
+	_ = "TODO: strings are not comments"
+}
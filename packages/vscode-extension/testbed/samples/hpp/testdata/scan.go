@@ -0,0 +1,16 @@
+// Package sample has one marker of each kind for the SARIF golden.
+package sample
+
+// !! (alice, 2025-07-01, PROJ-123) drop the retry loop
+func a() {}
+
+func b() {
+	// ?? is this still needed
+	x := 1 // >> see RFC 9110
+	_ = x
+	/*
+	 * ~~ until v2 ships
+	 */
+	// !!
+	_ = 2 // ?? (#42) why does this work human:ignore
+}
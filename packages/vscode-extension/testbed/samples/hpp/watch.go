@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// watchedFile is what the watcher knows of one file since its last scan.
+type watchedFile struct {
+	mod      time.Time
+	size     int64
+	findings []finding
+}
+
+// watcher re-scans a tree every interval and redraws a summary when
+// anything changed. It polls, as the TLS reloader in the server does,
+// rather than relying on platform file events.
+type watcher struct {
+	roots    []string
+	opts     treeOptions
+	interval time.Duration
+	out      io.Writer
+	// clear redraws in place; off when out isn't a terminal, where each
+	// summary is appended instead.
+	clear bool
+	files map[string]*watchedFile
+	// primed is set after the first poll, whose markers are all new and
+	// so not worth listing as changes.
+	primed bool
+}
+
+// runWatch is scan -watch: it runs until interrupted.
+func runWatch(roots []string, opts treeOptions, interval time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	w := &watcher{roots: roots, opts: opts, interval: interval, out: os.Stdout, clear: isTerminal(os.Stdout), files: map[string]*watchedFile{}}
+	return w.run(ctx)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (w *watcher) run(ctx context.Context) error {
+	for {
+		first := !w.primed
+		changes, rescanned, err := w.poll()
+		if err != nil {
+			return err
+		}
+		if first || rescanned > 0 {
+			w.render(changes, rescanned)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(w.interval):
+		}
+	}
+}
+
+// poll re-scans files whose size or modification time moved, forgets
+// deleted ones, and returns the markers that came and went, as +/- lines.
+func (w *watcher) poll() (changes []string, rescanned int, err error) {
+	seen := map[string]bool{}
+	err = walkTree(w.roots, w.opts, func(path string, d fs.DirEntry) error {
+		info, err := d.Info()
+		if err != nil {
+			// Deleted between listing and stat; the next poll drops it.
+			return nil
+		}
+		seen[path] = true
+		old := w.files[path]
+		if old != nil && old.mod.Equal(info.ModTime()) && old.size == info.Size() {
+			return nil
+		}
+		found, err := scanFile(path, w.opts)
+		if err != nil {
+			return nil
+		}
+		found = unsuppressed(found)
+		if old != nil {
+			changes = append(changes, diffFindings(old.findings, found)...)
+		} else if w.primed {
+			changes = append(changes, diffFindings(nil, found)...)
+		}
+		w.files[path] = &watchedFile{mod: info.ModTime(), size: info.Size(), findings: found}
+		rescanned++
+		return nil
+	})
+	for path, f := range w.files {
+		if !seen[path] {
+			changes = append(changes, diffFindings(f.findings, nil)...)
+			delete(w.files, path)
+			rescanned++
+		}
+	}
+	w.primed = true
+	return changes, rescanned, err
+}
+
+// diffFindings lists the markers in after but not before and the other
+// way round, matched by marker and text so that lines moving don't count.
+func diffFindings(before, after []finding) []string {
+	left := map[string]int{}
+	for _, f := range before {
+		left[f.Marker+" "+f.Text]++
+	}
+	var out []string
+	for _, f := range after {
+		key := f.Marker + " " + f.Text
+		if left[key] > 0 {
+			left[key]--
+			continue
+		}
+		out = append(out, fmt.Sprintf("+ %s:%d: %s", f.File, f.Line, key))
+	}
+	for _, f := range before {
+		key := f.Marker + " " + f.Text
+		if left[key] > 0 {
+			left[key]--
+			out = append(out, fmt.Sprintf("- %s:%d: %s", f.File, f.Line, key))
+		}
+	}
+	return out
+}
+
+func (w *watcher) render(changes []string, rescanned int) {
+	counts := map[string]int{}
+	files := 0
+	for _, f := range w.files {
+		for _, found := range f.findings {
+			counts[found.Marker]++
+		}
+		if len(f.findings) > 0 {
+			files++
+		}
+	}
+	if w.clear {
+		fmt.Fprint(w.out, "\x1b[H\x1b[2J")
+	}
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	fmt.Fprintf(w.out, "hpp: %d markers in %d files, %s (%d rescanned)\n\n", total, files, time.Now().Format(time.TimeOnly), rescanned)
+	tw := tabwriter.NewWriter(w.out, 0, 0, 2, ' ', 0)
+	for _, k := range kinds {
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", k.Marker, k.Name, counts[k.Marker])
+	}
+	tw.Flush()
+	if len(changes) > 0 {
+		slices.Sort(changes)
+		fmt.Fprintf(w.out, "\nchanged:\n  %s\n", strings.Join(changes, "\n  "))
+	}
+	if !w.clear {
+		fmt.Fprintln(w.out)
+	}
+}
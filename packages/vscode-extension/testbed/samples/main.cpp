@@ -1,3 +1,5 @@
+//go:build ignore
+
 // Human++ C++ sample — templates, macros, raw strings
 #include <iostream>
 #include <string>
@@ -1,237 +1,34 @@
 // Human++ Go Sample
 //
 // HTTP server with graceful shutdown and middleware chain.
+//
+// This file is only the process entrypoint. The server, its config and
+// subcommands are the importable server package, the user model and
+// in-memory store the store package, and the middleware that needs
+// nothing from the server the middleware package, so other programs can
+// embed the service.
 
 package main
 
 import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"log"
-	"net/http"
+	"errors"
+	"flag"
+	"log/slog"
 	"os"
-	"os/signal"
-	"sync"
-	"syscall"
-	"time"
-)
-
-type contextKey string
-
-const requestIDKey contextKey = "requestID"
-
-type Response struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
-}
-
-type User struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-}
-
-// !! In-memory store is not persistent - replace with database in production
-type UserStore struct {
-	mu    sync.RWMutex
-	users map[string]User
-}
-
-func NewUserStore() *UserStore {
-	return &UserStore{
-		users: make(map[string]User),
-	}
-}
-
-func (s *UserStore) Get(id string) (User, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	user, ok := s.users[id]
-	return user, ok
-}
-
-func (s *UserStore) Set(user User) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.users[user.ID] = user
-}
-
-func (s *UserStore) Delete(id string) bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if _, ok := s.users[id]; ok {
-		delete(s.users, id)
-		return true
-	}
-	return false
-}
-
-func (s *UserStore) List() []User {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	users := make([]User, 0, len(s.users))
-	for _, user := range s.users {
-		users = append(users, user)
-	}
-	return users
-}
-
-type Middleware func(http.Handler) http.Handler
-
-func Chain(middlewares ...Middleware) Middleware {
-	return func(next http.Handler) http.Handler {
-		for i := len(middlewares) - 1; i >= 0; i-- {
-			next = middlewares[i](next)
-		}
-		return next
-	}
-}
-
-func LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		next.ServeHTTP(w, r)
-		log.Printf("%s %s %s", r.Method, r.URL.Path, time.Since(start))
-	})
-}
-
-// ?? Should we add rate limiting middleware here?
-func RecoveryMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				log.Printf("panic recovered: %v", err)
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			}
-		}()
-		next.ServeHTTP(w, r)
-	})
-}
-
-func RequestIDMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		requestID := fmt.Sprintf("%d", time.Now().UnixNano())
-		ctx := context.WithValue(r.Context(), requestIDKey, requestID)
-		w.Header().Set("X-Request-ID", requestID)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
-}
 
-type Server struct {
-	store  *UserStore
-	server *http.Server
-}
-
-func NewServer(addr string, store *UserStore) *Server {
-	s := &Server{store: store}
-
-	mux := http.NewServeMux()
-	mux.HandleFunc("/health", s.handleHealth)
-	mux.HandleFunc("/users", s.handleUsers)
-	mux.HandleFunc("/users/", s.handleUser)
-
-	middleware := Chain(
-		RecoveryMiddleware,
-		LoggingMiddleware,
-		RequestIDMiddleware,
-	)
-
-	s.server = &http.Server{
-		Addr:         addr,
-		Handler:      middleware(mux),
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  60 * time.Second,
-	}
-
-	return s
-}
-
-func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	json.NewEncoder(w).Encode(Response{Success: true, Data: "ok"})
-}
-
-func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		users := s.store.List()
-		json.NewEncoder(w).Encode(Response{Success: true, Data: users})
-	case http.MethodPost:
-		var user User
-		if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
-			return
-		}
-		user.CreatedAt = time.Now()
-		s.store.Set(user)
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(Response{Success: true, Data: user})
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-func (s *Server) handleUser(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Path[len("/users/"):]
-	if id == "" {
-		http.Error(w, "User ID required", http.StatusBadRequest)
-		return
-	}
-
-	switch r.Method {
-	case http.MethodGet:
-		user, ok := s.store.Get(id)
-		if !ok {
-			http.Error(w, "User not found", http.StatusNotFound)
-			return
-		}
-		json.NewEncoder(w).Encode(Response{Success: true, Data: user})
-	case http.MethodDelete:
-		if !s.store.Delete(id) {
-			http.Error(w, "User not found", http.StatusNotFound)
-			return
-		}
-		json.NewEncoder(w).Encode(Response{Success: true})
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-func (s *Server) Start() error {
-	return s.server.ListenAndServe()
-}
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/server"
+)
 
-// >> Graceful shutdown waits for in-flight requests to complete
-func (s *Server) Shutdown(ctx context.Context) error {
-	return s.server.Shutdown(ctx)
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
 }
 
 func main() {
-	store := NewUserStore()
-	server := NewServer(":8080", store)
-
-	go func() {
-		log.Printf("Server starting on :8080")
-		if err := server.Start(); err != http.ErrServerClosed {
-			log.Fatalf("Server error: %v", err)
+	if err := server.RunCommand(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
 		}
-	}()
-
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
-	log.Println("Shutting down server...")
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		fatal("command failed", err)
 	}
-
-	log.Println("Server stopped")
 }
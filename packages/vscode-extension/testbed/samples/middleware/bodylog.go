@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Redacted replaces the values BodyLog withholds.
+const Redacted = "[REDACTED]"
+
+// BodyLog logs request and response bodies up to maxBytes each.
+// Paths are dotted JSON paths where "*" matches any array element or key
+// and "**" any number of levels, e.g. "email", "data.*.email" or
+// "**.password".
+func BodyLog(maxBytes int, paths []string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var reqBody []byte
+			if r.Body != nil {
+				var err error
+				// One byte past the cap is enough to tell the body was cut.
+				reqBody, err = io.ReadAll(io.LimitReader(r.Body, int64(maxBytes)+1))
+				if err != nil {
+					http.Error(w, "Invalid body", http.StatusBadRequest)
+					return
+				}
+				r.Body = replayBody(reqBody, r.Body)
+			}
+
+			rec := &bodyRecorder{ResponseWriter: w, status: http.StatusOK, max: maxBytes}
+			next.ServeHTTP(rec, r)
+
+			LoggerFrom(r.Context()).Debug("bodies",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"request", redactBody(truncate(reqBody, maxBytes), paths),
+				"status", rec.status,
+				"response", redactBody(rec.buf.Bytes(), paths),
+			)
+		})
+	}
+}
+
+type bodyRecorder struct {
+	http.ResponseWriter
+	status int
+	max    int
+	buf    bytes.Buffer
+}
+
+func (r *bodyRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	if room := r.max - r.buf.Len(); room > 0 {
+		r.buf.Write(truncate(b, room))
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func truncate(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[:n]
+	}
+	return b
+}
+
+// ?? Truncated JSON can't be parsed, so it is withheld entirely - is that too strict?
+func redactBody(body []byte, paths []string) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return "<empty>"
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		if len(paths) > 0 {
+			return "<unparseable body withheld>"
+		}
+		return string(body)
+	}
+	for _, p := range paths {
+		v = redactPath(v, strings.Split(p, "."))
+	}
+	out, _ := json.Marshal(v)
+	return string(out)
+}
+
+func redactPath(v interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return Redacted
+	}
+	key, rest := path[0], path[1:]
+	if key == "**" {
+		v = redactPath(v, rest)
+		switch node := v.(type) {
+		case map[string]interface{}:
+			for k, child := range node {
+				node[k] = redactPath(child, path)
+			}
+		case []interface{}:
+			for i, child := range node {
+				node[i] = redactPath(child, path)
+			}
+		}
+		return v
+	}
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for k, child := range node {
+			if key == "*" || k == key {
+				node[k] = redactPath(child, rest)
+			}
+		}
+	case []interface{}:
+		for i, child := range node {
+			if key == "*" {
+				node[i] = redactPath(child, rest)
+			} else {
+				// Keys pass through arrays so "data.email" also covers lists.
+				node[i] = redactPath(child, path)
+			}
+		}
+	}
+	return v
+}
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gzipPools keeps one pool of writers per level, HuffmanOnly through
+// BestCompression. A gzip.Writer holds several hundred KiB of window and
+// tables, so allocating one per response dominates compression's cost
+// under load; Reset makes them reusable across responses.
+var gzipPools [gzip.BestCompression - gzip.HuffmanOnly + 1]sync.Pool
+
+func getGzip(w io.Writer, level int) *gzip.Writer {
+	if gz, ok := gzipPools[level-gzip.HuffmanOnly].Get().(*gzip.Writer); ok {
+		gz.Reset(w)
+		return gz
+	}
+	// Validate has already rejected levels NewWriterLevel would refuse.
+	gz, _ := gzip.NewWriterLevel(w, level)
+	return gz
+}
+
+func putGzip(gz *gzip.Writer, level int) {
+	// Drop the reference to the finished response before pooling.
+	gz.Reset(io.Discard)
+	gzipPools[level-gzip.HuffmanOnly].Put(gz)
+}
+
+// Compression gzips responses for clients that accept it.
+// Responses that declare a Content-Length below minBytes, or that already
+// carry a Content-Encoding, go out as they are.
+func Compression(level, minBytes int) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead || !acceptsGzip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Add("Vary", "Accept-Encoding")
+			cw := &gzipWriter{ResponseWriter: w, level: level, min: minBytes}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(coding) == "gzip" {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// gzipWriter decides whether to compress when the headers go out, using
+// the Content-Length renderJSON sets; streamed responses have none and are
+// always compressed.
+type gzipWriter struct {
+	http.ResponseWriter
+	level   int
+	min     int
+	gz      *gzip.Writer
+	decided bool
+}
+
+func (w *gzipWriter) WriteHeader(status int) {
+	if !w.decided {
+		w.decided = true
+		if w.compressible(status) {
+			h := w.Header()
+			h.Del("Content-Length")
+			h.Set("Content-Encoding", "gzip")
+			w.gz = getGzip(w.ResponseWriter, w.level)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipWriter) compressible(status int) bool {
+	h := w.Header()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified || h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < w.min {
+		return false
+	}
+	return true
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.gz.Write(b)
+}
+
+// Flush pushes what has been compressed so far, so streamed responses
+// still reach the client as they are written.
+func (w *gzipWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *gzipWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *gzipWriter) close() {
+	if w.gz == nil {
+		return
+	}
+	w.gz.Close()
+	putGzip(w.gz, w.level)
+	w.gz = nil
+}
+
+var gunzipPool sync.Pool
+
+// Decompression inflates request bodies sent with
+// Content-Encoding: gzip, so handlers read them as they would any other.
+// maxBytes bounds the body both on the wire and once inflated: a small
+// body that expands without end, a zip bomb, fails its reader with
+// *http.MaxBytesError at the limit, which decodeJSON answers with 413.
+// Other encodings get 415 with Accept-Encoding naming gzip, as RFC 9110
+// asks.
+func Decompression(maxBytes int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			coding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+			if coding == "" || coding == "identity" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if coding != "gzip" && coding != "x-gzip" {
+				w.Header().Set("Accept-Encoding", "gzip")
+				http.Error(w, "Unsupported Content-Encoding", http.StatusUnsupportedMediaType)
+				return
+			}
+			raw := http.MaxBytesReader(w, r.Body, maxBytes)
+			gz, ok := gunzipPool.Get().(*gzip.Reader)
+			var err error
+			if ok {
+				err = gz.Reset(raw)
+			} else {
+				gz, err = gzip.NewReader(raw)
+			}
+			if err != nil {
+				// ?? a body over the limit before its gzip header ends is reported as malformed, not 413
+				http.Error(w, "Malformed gzip body", http.StatusBadRequest)
+				return
+			}
+			defer gunzipPool.Put(gz)
+
+			r2 := r.Clone(r.Context())
+			r2.Body = http.MaxBytesReader(w, gz, maxBytes)
+			r2.ContentLength = -1
+			r2.Header.Del("Content-Encoding")
+			r2.Header.Del("Content-Length")
+			next.ServeHTTP(w, r2)
+		})
+	}
+}
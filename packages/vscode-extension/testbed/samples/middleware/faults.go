@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+type FaultConfig struct {
+	Percent float64
+	Latency time.Duration
+	Errors  bool
+	Drops   bool
+}
+
+// Faults injects latency, 500s and dropped connections into cfg.Percent
+// of requests.
+//
+// !! Chaos testing only - never enable fault injection against real users
+func Faults(cfg FaultConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rand.Float64()*100 >= cfg.Percent {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if cfg.Latency > 0 {
+				time.Sleep(time.Duration(rand.Int63n(int64(cfg.Latency))))
+			}
+
+			switch {
+			case cfg.Drops && rand.Intn(2) == 0:
+				// The controller unwraps the logging and metrics writers to
+				// reach the connection; HTTP/2 can't be hijacked and falls
+				// through to a 500.
+				if conn, _, err := http.NewResponseController(w).Hijack(); err == nil {
+					LoggerFrom(r.Context()).Debug("fault: dropped connection")
+					conn.Close()
+					return
+				}
+				fallthrough
+			case cfg.Errors:
+				LoggerFrom(r.Context()).Debug("fault: injected 500")
+				http.Error(w, "Injected fault", http.StatusInternalServerError)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type featuresKey struct{}
+
+// Features is a parsed flag set. Each entry is "name" (on), "name=off", or
+// "name=N%" to enable it for a stable N percent of clients.
+type Features map[string]float64
+
+// ParseFeatures reads a list of flag entries into Features.
+func ParseFeatures(entries []string) (Features, error) {
+	f := Features{}
+	for _, entry := range entries {
+		name, value, hasValue := strings.Cut(strings.TrimSpace(entry), "=")
+		if name == "" {
+			return nil, fmt.Errorf("feature %q: empty name", entry)
+		}
+		percent := 100.0
+		switch {
+		case !hasValue, value == "on", value == "true":
+		case value == "off", value == "false":
+			percent = 0
+		case strings.HasSuffix(value, "%"):
+			p, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+			if err != nil || p < 0 || p > 100 {
+				return nil, fmt.Errorf("feature %q: rollout must be 0-100%%", entry)
+			}
+			percent = p
+		default:
+			return nil, fmt.Errorf("feature %q: want on, off or N%%", entry)
+		}
+		f[name] = percent
+	}
+	return f, nil
+}
+
+// enabledFor buckets key into 0-99.99 per flag, so each flag rolls out to an
+// independent slice of clients and a client keeps its answer as N grows.
+func (f Features) enabledFor(name, key string) bool {
+	percent, ok := f[name]
+	switch {
+	case !ok || percent <= 0:
+		return false
+	case percent >= 100:
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return float64(h.Sum32()%10000)/100 < percent
+}
+
+// ResolveFeatures resolves the flags for the request's client once and
+// stores the result for FeatureEnabled. It is rebuilt on reload, so flag
+// changes apply to new requests without a restart.
+func ResolveFeatures(f Features, keyHeader string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(keyHeader)
+			if key == "" {
+				key, _, _ = net.SplitHostPort(r.RemoteAddr)
+			}
+			enabled := make(map[string]bool, len(f))
+			for name := range f {
+				enabled[name] = f.enabledFor(name, key)
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), featuresKey{}, enabled)))
+		})
+	}
+}
+
+// FeatureEnabled reports whether name is on for the current request.
+// Unknown flags and contexts outside a request are off.
+func FeatureEnabled(ctx context.Context, name string) bool {
+	enabled, _ := ctx.Value(featuresKey{}).(map[string]bool)
+	return enabled[name]
+}
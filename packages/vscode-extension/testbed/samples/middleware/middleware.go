@@ -0,0 +1,42 @@
+// Package middleware holds the HTTP middleware that needs nothing from the
+// server: compression, mirroring, fault injection, body logging, feature
+// flags and trace context, with the Middleware type and request logger
+// they share. The server package builds its chain from these and its
+// own, which read its config and state.
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+type Middleware func(http.Handler) http.Handler
+
+// Chain composes middlewares so the first listed is the outermost.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next)
+		}
+		return next
+	}
+}
+
+type loggerKey struct{}
+
+// LoggerFrom returns the request-scoped logger stored by the middleware, or
+// the default logger outside of a request. Inside a request it already
+// carries request_id, trace_id, tenant, route and principal as they become
+// known, so handlers log through it rather than slog directly.
+func LoggerFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// WithLogger returns ctx carrying logger for LoggerFrom.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
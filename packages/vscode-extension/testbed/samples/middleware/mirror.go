@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const maxMirrorBody = 1 << 20
+
+// Mirror replays a percentage of requests to a shadow upstream.
+// Shadow responses are discarded; the client only ever sees the primary.
+func Mirror(upstream string, percent float64) Middleware {
+	upstream = strings.TrimSuffix(upstream, "/")
+	client := &http.Client{Timeout: 5 * time.Second, Transport: tracingTransport{http.DefaultTransport}}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if upstream == "" || rand.Float64()*100 >= percent {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var body []byte
+			var err error
+			if r.Body != nil {
+				body, err = io.ReadAll(io.LimitReader(r.Body, maxMirrorBody+1))
+				r.Body = replayBody(body, r.Body)
+			}
+			// A shadow with a cut-off body would be a different request, so
+			// bodies over the cap, or that failed to read, aren't mirrored;
+			// the primary gets what was read and the handler sees the error.
+			if err != nil || len(body) > maxMirrorBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// >> Detached from the request context so the shadow outlives the response
+			ctx := context.WithoutCancel(r.Context())
+			shadow, err := http.NewRequestWithContext(ctx, r.Method, upstream+r.URL.RequestURI(), bytes.NewReader(body))
+			if err == nil {
+				shadow.Header = r.Header.Clone()
+				shadow.Header.Set("X-Mirrored-From", r.Host)
+				go mirror(client, shadow)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// replayBody returns a body reading head, already consumed from rest, and
+// then whatever is left of rest, so a middleware can peek at the start of
+// a request body without the handler seeing less of it.
+func replayBody(head []byte, rest io.ReadCloser) io.ReadCloser {
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), rest), rest}
+}
+
+// >> Shadow failures are logged only; they must never affect the primary request
+func mirror(client *http.Client, req *http.Request) {
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Warn("mirror request failed", "method", req.Method, "path", req.URL.Path, "err", err)
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
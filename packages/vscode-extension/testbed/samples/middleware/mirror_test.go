@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingBody yields data and then fails, like a client that hangs up
+// part way through its upload.
+type failingBody struct{ data *strings.Reader }
+
+var errUpload = errors.New("connection reset")
+
+func (b failingBody) Read(p []byte) (int, error) {
+	if b.data.Len() == 0 {
+		return 0, errUpload
+	}
+	return b.data.Read(p)
+}
+
+func (failingBody) Close() error { return nil }
+
+func TestMirrorLeavesPrimaryAlone(t *testing.T) {
+	shadows := make(chan string, 4)
+	upstream := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		shadows <- string(body)
+	}))
+	defer upstream.Close()
+	mw := Mirror(upstream.URL, 100)
+
+	tests := []struct {
+		name    string
+		body    func() io.ReadCloser
+		want    string
+		wantErr error
+		shadow  bool
+	}{
+		{"small body", func() io.ReadCloser { return io.NopCloser(strings.NewReader(`{"id":"1"}`)) }, `{"id":"1"}`, nil, true},
+		{"over the cap", func() io.ReadCloser {
+			return io.NopCloser(bytes.NewReader(bytes.Repeat([]byte("x"), maxMirrorBody+10)))
+		}, strings.Repeat("x", maxMirrorBody+10), nil, false},
+		// !! a failed read must reach the handler as its own error, not a 400 from the mirror
+		{"read error", func() io.ReadCloser { return failingBody{strings.NewReader("partial")} }, "partial", errUpload, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []byte
+			var gotErr error
+			h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, gotErr = io.ReadAll(r.Body)
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			r := httptest.NewRequest(http.MethodPost, "/users", nil)
+			r.Body = tt.body()
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != http.StatusAccepted {
+				t.Errorf("primary status = %d, want the handler's 202", w.Code)
+			}
+			if string(got) != tt.want || !errors.Is(gotErr, tt.wantErr) {
+				t.Errorf("handler read %d bytes, err %v; want %d bytes, err %v", len(got), gotErr, len(tt.want), tt.wantErr)
+			}
+			select {
+			case body := <-shadows:
+				if !tt.shadow {
+					t.Errorf("mirrored a request that should have been skipped")
+				} else if body != tt.want {
+					t.Errorf("shadow body = %q, want %q", body, tt.want)
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tt.shadow {
+					t.Errorf("request was not mirrored")
+				}
+			}
+		})
+	}
+}
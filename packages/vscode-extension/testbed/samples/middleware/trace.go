@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+type traceKey struct{}
+
+// TraceContext is the W3C Trace Context for the span this server is
+// handling. ParentID is the caller's span, empty when the trace starts here.
+type TraceContext struct {
+	TraceID  string
+	SpanID   string
+	ParentID string
+	Flags    string
+	State    string
+}
+
+// parseTraceparent accepts version 00 and, as the spec requires, any later
+// version whose first four fields have the 00 layout.
+func parseTraceparent(h string) (traceID, parentID, flags string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return "", "", "", false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return "", "", "", false
+	}
+	traceID, parentID, flags = parts[1], parts[2], parts[3]
+	if !lowerHex(parts[0], 2) || !lowerHex(traceID, 32) || !lowerHex(parentID, 16) || !lowerHex(flags, 2) {
+		return "", "", "", false
+	}
+	if traceID == strings.Repeat("0", 32) || parentID == strings.Repeat("0", 16) {
+		return "", "", "", false
+	}
+	return traceID, parentID, flags, true
+}
+
+func lowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(bytes int) string {
+	b := make([]byte, bytes)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Traceparent renders tc as the header for a call made from this span.
+func (tc TraceContext) Traceparent() string {
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + tc.Flags
+}
+
+// Trace continues the caller's trace, or starts one, with a new
+// span for this request. IDs are added to the request logger and echoed in
+// a traceresponse header so clients can quote them in bug reports.
+func Trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tc := TraceContext{SpanID: randomHex(8), Flags: "00"}
+		if traceID, parentID, flags, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+			tc.TraceID, tc.ParentID, tc.Flags = traceID, parentID, flags
+			// ?? tracestate is only meaningful alongside a valid traceparent
+			tc.State = strings.Join(r.Header.Values("tracestate"), ",")
+		} else {
+			tc.TraceID = randomHex(16)
+		}
+		w.Header().Set("traceresponse", tc.Traceparent())
+
+		ctx := context.WithValue(r.Context(), traceKey{}, tc)
+		ctx = WithLogger(ctx, LoggerFrom(ctx).With("trace_id", tc.TraceID, "span_id", tc.SpanID))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// TraceFrom returns the request's trace context, if any.
+func TraceFrom(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceKey{}).(TraceContext)
+	return tc, ok
+}
+
+// InjectTrace propagates the trace in ctx onto an outbound request's
+// headers, replacing any copied from the inbound request.
+func InjectTrace(ctx context.Context, h http.Header) {
+	tc, ok := TraceFrom(ctx)
+	if !ok {
+		return
+	}
+	h.Set("traceparent", tc.Traceparent())
+	h.Del("tracestate")
+	if tc.State != "" {
+		h.Set("tracestate", tc.State)
+	}
+}
+
+// tracingTransport injects the trace from each request's context, for
+// clients whose requests are built with NewRequestWithContext.
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, ok := TraceFrom(req.Context()); !ok {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	InjectTrace(req.Context(), req.Header)
+	return t.base.RoundTrip(req)
+}
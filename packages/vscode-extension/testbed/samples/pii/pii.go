@@ -0,0 +1,58 @@
+// Package pii masks personal data as it is logged: the fields of any
+// struct tagged pii:"true", through LogValue, and values passed to Mask.
+// Free-text scrubbing and the key-based rules of logging.pii_keys stay
+// with the log handler in the server package.
+package pii
+
+import (
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+var redact atomic.Bool
+
+// SetRedact turns masking in LogValue on or off; the logger sets it from
+// logging.redact_pii.
+func SetRedact(on bool) { redact.Store(on) }
+
+// Mask keeps the first character, and an email's domain, so operators can
+// still tell values apart: "alice@example.com" becomes "a***@example.com".
+func Mask(s string) string {
+	if s == "" {
+		return s
+	}
+	first, _ := utf8.DecodeRuneInString(s)
+	if at := strings.LastIndexByte(s, '@'); at > 0 {
+		return string(first) + "***" + s[at:]
+	}
+	return string(first) + "***"
+}
+
+// LogValue renders a struct as a log group keyed by its json names,
+// masking fields tagged pii:"true" and skipping fields tagged json:"-".
+// Types with PII implement slog.LogValuer through it.
+func LogValue(v any) slog.Value {
+	rv := reflect.ValueOf(v)
+	rt := rv.Type()
+	masked := redact.Load()
+	var attrs []slog.Attr
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		value := rv.Field(i).Interface()
+		if s, ok := value.(string); ok && masked && f.Tag.Get("pii") == "true" {
+			value = Mask(s)
+		}
+		attrs = append(attrs, slog.Any(name, value))
+	}
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,30 @@
+//go:build autocert
+
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newACMEManager returns the certificate source for ACME mode and, for the
+// HTTP-01 challenge, the handler that must be served on port 80.
+func newACMEManager(cfg ACMEConfig, fallback http.Handler) (func(*tls.ClientHelloInfo) (*tls.Certificate, error), []string, http.Handler, error) {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cfg.CacheDir),
+		HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+		Email:      cfg.Email,
+	}
+	if cfg.DirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: cfg.DirectoryURL}
+	}
+
+	if cfg.Challenge == "tls-alpn-01" {
+		return m.GetCertificate, []string{acme.ALPNProto}, nil, nil
+	}
+	return m.GetCertificate, nil, m.HTTPHandler(fallback), nil
+}
@@ -0,0 +1,14 @@
+//go:build !autocert
+
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+)
+
+// !! ACME needs golang.org/x/crypto - build with -tags autocert to enable it
+func newACMEManager(ACMEConfig, http.Handler) (func(*tls.ClientHelloInfo) (*tls.Certificate, error), []string, http.Handler, error) {
+	return nil, nil, nil, errors.New("ACME support not compiled in (rebuild with -tags autocert)")
+}
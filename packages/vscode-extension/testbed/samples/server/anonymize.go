@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// scrubUser blanks every field of u tagged pii:"true", and its password
+// hash so the record can't be logged into, keeping the ID and creation
+// time so references to the user still resolve. It returns the json
+// names of the fields it cleared, none when u is already anonymous.
+func scrubUser(u User) (User, []string) {
+	var cleared []string
+	rv := reflect.ValueOf(&u).Elem()
+	rt := rv.Type()
+	for i := range rt.NumField() {
+		f := rt.Field(i)
+		if f.Tag.Get("pii") != "true" || f.Type.Kind() != reflect.String || rv.Field(i).String() == "" {
+			continue
+		}
+		rv.Field(i).SetString("")
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		cleared = append(cleared, name)
+	}
+	if u.PasswordHash != "" {
+		u.PasswordHash = ""
+		cleared = append(cleared, "password")
+	}
+	return u, cleared
+}
+
+// anonymizeUser scrubs user in store, publishing the change as an update
+// and auditing which fields went. The audit entry names the fields only;
+// the values are gone for good. The user's sessions end with it: every
+// refresh token is dropped and every access token issued so far revoked.
+// It reports false when there was nothing left to scrub.
+func (s *Server) anonymizeUser(ctx context.Context, store Store, user User, category string) bool {
+	scrubbed, cleared := scrubUser(user)
+	if len(cleared) == 0 {
+		return false
+	}
+	store.Set(scrubbed)
+	tenant := TenantFrom(ctx)
+	s.refresh.revokeAllFor(user.ID, tenant)
+	now := s.clock.Now()
+	if err := s.revoked.revokeSubject(ctx, tenant, user.ID, now, now.Add(s.auth.AccessTTL)); err != nil {
+		LoggerFrom(ctx).Error("token revocation failed", "user_id", user.ID, "err", err)
+	}
+	// >> Copies already sent to webhooks, brokers and other replicas are out of reach
+	s.changes.scrub(tenant, scrubbed)
+	LoggerFrom(ctx).Info("user anonymized", "user_id", user.ID)
+	s.events.Publish(ctx, UserEvent{Action: "updated", User: scrubbed})
+	s.audit(ctx, category, "user.anonymize", user.ID, map[string]auditChange{"fields": {From: nil, To: cleared}})
+	return true
+}
+
+// handleAnonymizeUser answers POST /admin/users/{id}/anonymize with the
+// scrubbed user. Anonymizing an anonymous user succeeds and changes
+// nothing.
+func (s *Server) handleAnonymizeUser(w http.ResponseWriter, r *http.Request) {
+	rest, _ := pathParam(r.URL.Path, "/admin/users/")
+	id, ok := strings.CutSuffix(rest, "/anonymize")
+	if !ok || id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	store := s.storeFor(r)
+	user, ok := store.Get(id)
+	if !ok {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	s.anonymizeUser(r.Context(), store, user, "admin")
+	user, _ = scrubUser(user)
+	renderJSON(w, r, http.StatusOK, dataOK(user))
+}
+
+// anonymizeRequest selects users for POST /admin/users/anonymize. A user
+// must match every criterion given, and at least one must be.
+type anonymizeRequest struct {
+	IDs           []string   `json:"ids,omitempty"`
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
+	// EmailDomain matches the part after @, ignoring case.
+	EmailDomain string `json:"email_domain,omitempty"`
+	// DryRun reports the matches without changing them.
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+func (c anonymizeRequest) matches(u User, ids map[string]bool) bool {
+	if len(ids) > 0 && !ids[u.ID] {
+		return false
+	}
+	if c.CreatedBefore != nil && !u.CreatedAt.Before(*c.CreatedBefore) {
+		return false
+	}
+	if c.EmailDomain != "" {
+		_, domain, _ := strings.Cut(u.Email, "@")
+		if !strings.EqualFold(domain, c.EmailDomain) {
+			return false
+		}
+	}
+	return true
+}
+
+type anonymizeResult struct {
+	// IDs are the users anonymized, or with dry_run those that would be.
+	// Users already anonymous are left out.
+	IDs    []string `json:"ids"`
+	DryRun bool     `json:"dry_run"`
+}
+
+// handleAnonymizeUsers anonymizes every user of the tenant matching the
+// request's criteria.
+func (s *Server) handleAnonymizeUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req anonymizeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.IDs) == 0 && req.CreatedBefore == nil && req.EmailDomain == "" {
+		unprocessable(w, r, "field_required", "ids, created_before or email_domain")
+		return
+	}
+	release, ok := s.heavy.acquire(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+	ids := make(map[string]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		ids[id] = true
+	}
+	store := s.storeFor(r)
+	var matched []User
+	if err := store.Range(r.Context(), func(u User) bool {
+		if req.matches(u, ids) {
+			matched = append(matched, u)
+		}
+		return true
+	}); err != nil {
+		return
+	}
+	result := anonymizeResult{IDs: []string{}, DryRun: req.DryRun}
+	for _, u := range matched {
+		if req.DryRun {
+			if _, cleared := scrubUser(u); len(cleared) > 0 {
+				result.IDs = append(result.IDs, u.ID)
+			}
+		} else if s.anonymizeUser(r.Context(), store, u, "admin") {
+			result.IDs = append(result.IDs, u.ID)
+		}
+	}
+	renderJSON(w, r, http.StatusOK, dataOK(result))
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestAnonymizeEndsSessions(t *testing.T) {
+	store := NewUserStore()
+	srv, err := NewServer(DefaultConfig(), WithStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := withLogger(context.Background(), slog.New(slog.DiscardHandler))
+	tenant := TenantFrom(ctx)
+	store.Set(User{ID: "u-1", Name: "Ada", Email: "ada@example.com"})
+	user, _ := store.Get("u-1")
+	refresh := srv.refresh.issue("u-1", tenant, "family", time.Hour)
+	other := srv.refresh.issue("u-2", tenant, "other", time.Hour)
+
+	now := srv.clock.Now().Unix()
+	before := Claims{Subject: "u-1", Tenant: tenant, IssuedAt: now - 1, ID: "before"}
+	if !srv.anonymizeUser(ctx, store, user, "admin") {
+		t.Fatal("anonymizeUser found nothing to scrub")
+	}
+
+	if _, err := srv.refresh.rotate(refresh, tenant); err == nil {
+		t.Error("refresh token still rotates after anonymize")
+	}
+	if _, err := srv.refresh.rotate(other, tenant); err != nil {
+		t.Errorf("another user's refresh token: %v", err)
+	}
+	tests := []struct {
+		name   string
+		claims Claims
+		want   bool
+	}{
+		{"token issued before", before, true},
+		{"token issued after", Claims{Subject: "u-1", Tenant: tenant, IssuedAt: now + 1, ID: "after"}, false},
+		{"another user", Claims{Subject: "u-2", Tenant: tenant, IssuedAt: now - 1, ID: "u-2"}, false},
+		{"same ID in another tenant", Claims{Subject: "u-1", Tenant: "acme", IssuedAt: now - 1, ID: "acme"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := srv.revoked.revoked(ctx, tt.claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("revoked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiKeyPrefix marks keys so they are recognizable in logs and secret
+// scanners. A key is "hpp_<id>_<secret>"; only sha256(secret) is stored.
+const apiKeyPrefix = "hpp_"
+
+// APIKey is an issued key as stored and listed. The secret itself is only
+// returned once, by create.
+type APIKey struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Tenant    string     `json:"tenant,omitempty"`
+	Roles     []string   `json:"roles"`
+	Hash      string     `json:"hash,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	LastUsed  *time.Time `json:"last_used,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// apiKeyStore holds keys in memory and, with a path, rewrites a JSON file
+// on every change the same way FileStore snapshots users.
+type apiKeyStore struct {
+	path  string
+	clock Clock
+
+	mu   sync.RWMutex
+	keys map[string]*APIKey
+}
+
+func openAPIKeyStore(path string, clock Clock) (*apiKeyStore, error) {
+	ks := &apiKeyStore{path: path, clock: clock, keys: map[string]*APIKey{}}
+	if path == "" {
+		return ks, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []*APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		ks.keys[k.ID] = k
+	}
+	return ks, nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// create issues a key and returns it with the plaintext token.
+func (ks *apiKeyStore) create(name, tenant string, roles []string) (APIKey, string, error) {
+	id, secret := randomHex(8), randomHex(24)
+	key := &APIKey{ID: id, Name: name, Tenant: tenant, Roles: roles, Hash: hashSecret(secret), CreatedAt: ks.clock.Now()}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[id] = key
+	if err := ks.save(); err != nil {
+		delete(ks.keys, id)
+		return APIKey{}, "", err
+	}
+	return *key, apiKeyPrefix + id + "_" + secret, nil
+}
+
+func (ks *apiKeyStore) list(tenant string) []APIKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	out := []APIKey{}
+	for _, k := range ks.keys {
+		if k.Tenant == tenant {
+			listed := *k
+			listed.Hash = ""
+			out = append(out, listed)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// revoke keeps the record so the key still shows up, revoked, in listings.
+func (ks *apiKeyStore) revoke(id, tenant string) (bool, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	k, ok := ks.keys[id]
+	if !ok || k.Tenant != tenant {
+		return false, nil
+	}
+	if k.RevokedAt == nil {
+		now := ks.clock.Now()
+		k.RevokedAt = &now
+	}
+	return true, ks.save()
+}
+
+// authenticate resolves a presented token to its key.
+func (ks *apiKeyStore) authenticate(token string) (APIKey, bool) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, apiKeyPrefix), "_")
+	if !ok || !strings.HasPrefix(token, apiKeyPrefix) {
+		return APIKey{}, false
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	k, ok := ks.keys[id]
+	if !ok || k.RevokedAt != nil || subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hashSecret(secret))) != 1 {
+		return APIKey{}, false
+	}
+	// ?? last_used is only persisted with the next change, to keep auth off the disk path
+	now := ks.clock.Now()
+	k.LastUsed = &now
+	return *k, true
+}
+
+func (ks *apiKeyStore) save() error {
+	if ks.path == "" {
+		return nil
+	}
+	keys := make([]*APIKey, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		keys = append(keys, k)
+	}
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(ks.path), ".apikeys-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), ks.path)
+}
+
+type rolesKey struct{}
+
+// RolesFrom returns the roles granted to the request's credentials.
+func RolesFrom(ctx context.Context) []string {
+	roles, _ := ctx.Value(rolesKey{}).([]string)
+	return roles
+}
+
+// HasRole reports whether the request's credentials carry role.
+func HasRole(ctx context.Context, role string) bool {
+	for _, r := range RolesFrom(ctx) {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRoleScopes reads apikeys.roles entries such as
+// "writer=users:read users:write".
+func parseRoleScopes(entries []string) (map[string][]string, error) {
+	roles := make(map[string][]string, len(entries))
+	for _, entry := range entries {
+		role, scopes, ok := strings.Cut(entry, "=")
+		role = strings.TrimSpace(role)
+		if !ok || role == "" {
+			return nil, fmt.Errorf("role %q: want role=scope ...", entry)
+		}
+		if _, dup := roles[role]; dup {
+			return nil, fmt.Errorf("role %q listed twice", role)
+		}
+		roles[role] = strings.Fields(scopes)
+	}
+	return roles, nil
+}
+
+// keyScopes returns the scopes roles grant, never nil: a key is a scoped
+// credential even when its roles grant nothing.
+func keyScopes(roles []string, grants map[string][]string) []string {
+	scopes := []string{}
+	for _, role := range roles {
+		for _, scope := range grants[role] {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+// APIKeyMiddleware authenticates X-API-Key, or "Authorization: ApiKey",
+// and sets the principal, roles and the scopes grants gives those roles.
+// Requests without a key pass through; a wrong, revoked or cross-tenant
+// key is rejected and counted by lockouts.
+func APIKeyMiddleware(ks *apiKeyStore, lockouts *lockoutTracker, grants map[string][]string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get("X-API-Key")
+			if token == "" {
+				if given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "ApiKey "); ok {
+					token = given
+				}
+			}
+			if token == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			id, _, _ := strings.Cut(strings.TrimPrefix(token, apiKeyPrefix), "_")
+			if retry, locked := lockouts.locked("apikey:"+id, "ip:"+clientIP(r)); locked {
+				writeLockedOut(w, r, retry)
+				return
+			}
+			key, ok := ks.authenticate(token)
+			if !ok || key.Tenant != TenantFrom(r.Context()) {
+				LoggerFrom(r.Context()).Info("API key rejected")
+				lockouts.fail(r.Context(), "apikey", id, clientIP(r))
+				renderJSON(w, r, http.StatusUnauthorized, errorResponse(r.Context(), "invalid_api_key"))
+				return
+			}
+			ctx := withPrincipal(r.Context(), "apikey:"+key.ID)
+			ctx = context.WithValue(ctx, rolesKey{}, key.Roles)
+			next.ServeHTTP(w, r.WithContext(withScopes(ctx, keyScopes(key.Roles, grants))))
+		})
+	}
+}
+
+type createAPIKeyRequest struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+type createAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// handleAPIKeys lists and creates keys in the request's tenant.
+func (s *Server) handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	tenant := TenantFrom(r.Context())
+	switch r.Method {
+	case http.MethodGet:
+		renderJSON(w, r, http.StatusOK, Response{Success: true, Data: s.apiKeys.list(tenant)})
+	case http.MethodPost:
+		var req createAPIKeyRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if req.Name == "" {
+			unprocessable(w, r, "field_required", "name")
+			return
+		}
+		if req.Roles == nil {
+			req.Roles = []string{}
+		}
+		key, token, err := s.apiKeys.create(req.Name, tenant, req.Roles)
+		if err != nil {
+			LoggerFrom(r.Context()).Error("API key save failed", "err", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		LoggerFrom(r.Context()).Info("API key created", "key_id", key.ID, "roles", key.Roles)
+		s.audit(r.Context(), "admin", "apikey.create", key.ID, map[string]auditChange{
+			"name":  {From: nil, To: key.Name},
+			"roles": {From: nil, To: key.Roles},
+		})
+		key.Hash = ""
+		renderJSON(w, r, http.StatusCreated, Response{Success: true, Data: createAPIKeyResponse{APIKey: key, Key: token}})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathParam(r.URL.Path, "/admin/apikeys/")
+	if !ok {
+		http.Error(w, "API key ID required", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ok, err := s.apiKeys.revoke(id, TenantFrom(r.Context()))
+	if err != nil {
+		LoggerFrom(r.Context()).Error("API key save failed", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	LoggerFrom(r.Context()).Info("API key revoked", "key_id", id)
+	s.audit(r.Context(), "admin", "apikey.revoke", id, nil)
+	renderJSON(w, r, http.StatusOK, Response{Success: true})
+}
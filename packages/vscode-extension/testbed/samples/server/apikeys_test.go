@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeyRoleScopes(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Auth.RequireScopes = true
+	srv, err := NewServer(cfg, WithStore(NewUserStore()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := srv.Handler()
+	key := func(roles ...string) string {
+		_, token, err := srv.apiKeys.create("test", DefaultTenant, roles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	reader, writer, none := key("reader"), key("writer"), key("unlisted")
+
+	tests := []struct {
+		name   string
+		method string
+		key    string
+		want   int
+	}{
+		{"reader lists", http.MethodGet, reader, http.StatusOK},
+		{"reader can't write", http.MethodPost, reader, http.StatusForbidden},
+		{"writer writes", http.MethodPost, writer, http.StatusCreated},
+		// !! a role apikeys.roles doesn't list must grant nothing, not everything
+		{"unlisted role", http.MethodGet, none, http.StatusForbidden},
+		{"no key", http.MethodGet, "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/users", strings.NewReader(`{"name":"Ada"}`))
+			r.Header.Set("Content-Type", "application/json")
+			if tt.key != "" {
+				r.Header.Set("X-API-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("%s /users = %d, want %d: %s", tt.method, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestParseRoleScopes(t *testing.T) {
+	roles, err := parseRoleScopes([]string{"reader=users:read", " writer = users:read  users:write", "nobody="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(keyScopes([]string{"reader", "writer", "missing"}, roles), " "); got != "users:read users:write" {
+		t.Errorf("scopes = %q, want users:read users:write", got)
+	}
+	for _, bad := range [][]string{{"reader"}, {"=users:read"}, {"a=x", "a=y"}} {
+		if _, err := parseRoleScopes(bad); err == nil {
+			t.Errorf("parseRoleScopes(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AuditEntry records one privileged operation. Category is admin for the
+// admin API, config for reloads and auth for authentication failures.
+type AuditEntry struct {
+	ID        int64                  `json:"id"`
+	Time      time.Time              `json:"time"`
+	Category  string                 `json:"category"`
+	Action    string                 `json:"action"`
+	Actor     string                 `json:"actor"`
+	Tenant    string                 `json:"tenant,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Target    string                 `json:"target,omitempty"`
+	Diff      map[string]auditChange `json:"diff,omitempty"`
+}
+
+// auditChange is one field's value before and after the operation.
+type auditChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// auditLog keeps the newest Keep entries in memory for queries and, with a
+// file, appends every entry as a JSON line. The file is the durable record;
+// the memory copy is reloaded from its tail on start.
+type auditLog struct {
+	keep int
+
+	mu      sync.Mutex
+	entries []AuditEntry
+	nextID  int64
+	file    *os.File
+}
+
+func openAuditLog(cfg AuditConfig) (*auditLog, error) {
+	a := &auditLog{keep: cfg.Keep, nextID: 1}
+	if cfg.File == "" {
+		return a, nil
+	}
+	f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var e AuditEntry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		a.append(e)
+		a.nextID = e.ID + 1
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("read %s: %w", cfg.File, err)
+	}
+	a.file = f
+	return a, nil
+}
+
+func (a *auditLog) append(e AuditEntry) {
+	a.entries = append(a.entries, e)
+	if over := len(a.entries) - a.keep; over > 0 {
+		a.entries = append(a.entries[:0:0], a.entries[over:]...)
+	}
+}
+
+// record stamps e with an ID and time, masks PII in its free-text fields
+// and stores it. A failed file write is returned; the entry is still
+// queryable.
+func (a *auditLog) record(e AuditEntry) error {
+	e.Time = time.Now().UTC()
+	e.Target = scrubPII(e.Target)
+	for field, ch := range e.Diff {
+		if s, ok := ch.From.(string); ok {
+			ch.From = scrubPII(s)
+		}
+		if s, ok := ch.To.(string); ok {
+			ch.To = scrubPII(s)
+		}
+		e.Diff[field] = ch
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e.ID = a.nextID
+	a.nextID++
+	a.append(e)
+	if a.file == nil {
+		return nil
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = a.file.Write(append(line, '\n'))
+	return err
+}
+
+type auditQuery struct {
+	category, action, actor string
+	since                   time.Time
+	limit                   int
+}
+
+// query returns matching entries, newest first.
+func (a *auditLog) query(q auditQuery) []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := []AuditEntry{}
+	for i := len(a.entries) - 1; i >= 0 && len(out) < q.limit; i-- {
+		e := a.entries[i]
+		if (q.category == "" || e.Category == q.category) &&
+			(q.action == "" || e.Action == q.action) &&
+			(q.actor == "" || e.Actor == q.actor) &&
+			!e.Time.Before(q.since) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (a *auditLog) Close(context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// audit records an operation made through the request in ctx. The actor is
+// the request's principal.
+func (s *Server) audit(ctx context.Context, category, action, target string, diff map[string]auditChange) {
+	e := AuditEntry{Category: category, Action: action, Actor: PrincipalFrom(ctx), Tenant: TenantFrom(ctx), Target: target, Diff: diff}
+	e.RequestID, _ = ctx.Value(requestIDKey).(string)
+	if e.Actor == "" {
+		e.Actor = "system"
+	}
+	if err := s.auditLog.record(e); err != nil {
+		LoggerFrom(ctx).Error("audit write failed", "action", action, "err", err)
+	}
+}
+
+// auditAuthFailures copies authentication failures from the event bus into
+// the audit log.
+func (s *Server) auditAuthFailures() {
+	SubscribeTo(s.events, "audit", 1024, func(ev Event, f AuthFailureEvent) {
+		e := AuditEntry{Category: "auth", Action: f.Method + ".failed", Actor: f.IP, Tenant: ev.Tenant, RequestID: ev.RequestID, Target: f.Identity}
+		if f.LockedFor > 0 {
+			e.Action = f.Method + ".locked"
+			e.Diff = map[string]auditChange{"locked_for": {From: nil, To: f.LockedFor.String()}}
+		}
+		if err := s.auditLog.record(e); err != nil {
+			s.logger.Error("audit write failed", "action", e.Action, "err", err)
+		}
+	})
+}
+
+// handleAudit serves GET /admin/audit?category=&action=&actor=&since=&limit=.
+// since is RFC 3339; limit defaults to 100.
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	q := auditQuery{category: params.Get("category"), action: params.Get("action"), actor: params.Get("actor"), limit: 100}
+	if v := params.Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		q.since = since
+	}
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		q.limit = n
+	}
+	renderJSON(w, r, http.StatusOK, Response{Success: true, Data: s.auditLog.query(q)})
+}
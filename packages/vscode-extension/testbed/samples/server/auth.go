@@ -0,0 +1,269 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// refreshToken is the server-side record of one issued refresh token. Every
+// token descends from a login through family; presenting a token that was
+// already rotated revokes the whole family, since one of the two holders is
+// not the user.
+type refreshToken struct {
+	userID  string
+	tenant  string
+	family  string
+	expires time.Time
+	used    bool
+}
+
+// !! Refresh tokens live in memory - a restart or a second replica logs everyone out
+type refreshStore struct {
+	mu     sync.Mutex
+	tokens map[string]*refreshToken // sha256(token) -> record
+	clock  Clock
+}
+
+func newRefreshStore(clock Clock) *refreshStore {
+	return &refreshStore{tokens: map[string]*refreshToken{}, clock: clock}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (rs *refreshStore) issue(userID, tenant, family string, ttl time.Duration) string {
+	token := randomHex(32)
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	now := rs.clock.Now()
+	rs.prune(now)
+	rs.tokens[hashToken(token)] = &refreshToken{userID: userID, tenant: tenant, family: family, expires: now.Add(ttl)}
+	return token
+}
+
+// rotate consumes token and returns its record. Reuse of a consumed token
+// revokes every token in its family.
+func (rs *refreshStore) rotate(token, tenant string) (refreshToken, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rec, ok := rs.tokens[hashToken(token)]
+	switch {
+	case !ok, rec.tenant != tenant, rs.clock.Now().After(rec.expires):
+		return refreshToken{}, errInvalidToken
+	case rec.used:
+		for key, other := range rs.tokens {
+			if other.family == rec.family {
+				delete(rs.tokens, key)
+			}
+		}
+		return refreshToken{}, newAPIError("refresh_token_reused")
+	}
+	// Keep the spent token until it expires so reuse can be detected.
+	rec.used = true
+	return *rec, nil
+}
+
+// revokeFamily drops every token descended from the same login as token,
+// provided token belongs to userID in tenant.
+func (rs *refreshStore) revokeFamily(token, userID, tenant string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rec, ok := rs.tokens[hashToken(token)]
+	if !ok || rec.userID != userID || rec.tenant != tenant {
+		return
+	}
+	family := rec.family
+	for key, other := range rs.tokens {
+		if other.family == family {
+			delete(rs.tokens, key)
+		}
+	}
+}
+
+// revokeAllFor drops every refresh token of userID in tenant, whichever
+// login it came from.
+func (rs *refreshStore) revokeAllFor(userID, tenant string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for key, rec := range rs.tokens {
+		if rec.userID == userID && rec.tenant == tenant {
+			delete(rs.tokens, key)
+		}
+	}
+}
+
+func (rs *refreshStore) prune(now time.Time) {
+	for key, rec := range rs.tokens {
+		if now.After(rec.expires) {
+			delete(rs.tokens, key)
+		}
+	}
+}
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// findUser matches username against the user ID, then email, within the
+// request's tenant.
+func findUser(store Store, username string) (User, bool) {
+	if user, ok := store.Get(username); ok {
+		return user, true
+	}
+	for _, user := range store.List() {
+		if user.Email != "" && strings.EqualFold(user.Email, username) {
+			return user, true
+		}
+	}
+	return User{}, false
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req loginRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Username == "" {
+		unprocessable(w, r, "field_required", "username")
+		return
+	}
+	identity := TenantFrom(r.Context()) + "/" + strings.ToLower(req.Username)
+	if retry, locked := s.lockouts.locked("login:"+identity, "ip:"+clientIP(r)); locked {
+		writeLockedOut(w, r, retry)
+		return
+	}
+	user, found := findUser(s.storeFor(r), req.Username)
+	ok, err := VerifyPassword(user, req.Password)
+	if errors.Is(err, errNoArgon2) {
+		http.Error(w, "Password storage unavailable", http.StatusNotImplemented)
+		return
+	}
+	if !found || !ok {
+		LoggerFrom(r.Context()).Info("login failed", "username", req.Username)
+		s.lockouts.fail(r.Context(), "login", identity, clientIP(r))
+		renderJSON(w, r, http.StatusUnauthorized, errorResponse(r.Context(), "invalid_credentials"))
+		return
+	}
+	s.lockouts.succeed("login", identity)
+	s.issueTokens(w, r, user.ID, randomHex(16))
+}
+
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req refreshRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.RefreshToken == "" {
+		unprocessable(w, r, "field_required", "refresh_token")
+		return
+	}
+	rec, err := s.refresh.rotate(req.RefreshToken, TenantFrom(r.Context()))
+	if err == nil {
+		if _, exists := s.storeFor(r).Get(rec.userID); !exists {
+			err = errInvalidToken
+		}
+	}
+	if err != nil {
+		LoggerFrom(r.Context()).Warn("refresh rejected", "err", err)
+		renderJSON(w, r, http.StatusUnauthorized, errorFor(r.Context(), err, "invalid_token"))
+		return
+	}
+	s.issueTokens(w, r, rec.userID, rec.family)
+}
+
+func (s *Server) issueTokens(w http.ResponseWriter, r *http.Request, userID, family string) {
+	cfg := s.auth
+	now := s.clock.Now()
+	access, err := s.jwtKeys.Load().sign(Claims{
+		Issuer:    cfg.Issuer,
+		Subject:   userID,
+		Tenant:    TenantFrom(r.Context()),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(cfg.AccessTTL).Unix(),
+		ID:        randomHex(16),
+		Scope:     strings.Join(cfg.Scopes, " "),
+	})
+	if err != nil {
+		LoggerFrom(r.Context()).Error("token signing failed", "err", err)
+		http.Error(w, "Authentication unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	refresh := s.refresh.issue(userID, TenantFrom(r.Context()), family, cfg.RefreshTTL)
+	LoggerFrom(r.Context()).Info("tokens issued", "user_id", userID)
+	renderJSON(w, r, http.StatusOK, Response{Success: true, Data: tokenResponse{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(cfg.AccessTTL.Seconds()),
+		RefreshToken: refresh,
+	}})
+}
+
+// BearerAuthMiddleware sets the principal from a valid access token. It
+// does not require one; a token that fails verification, was issued for
+// another tenant or has been revoked is rejected rather than ignored. Expiry
+// is judged against clock, the one tokens are issued with.
+func BearerAuthMiddleware(ring *rotating[signingKeys], revocations revocationList, clock Clock) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys := ring.Load()
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			// >> Admin tokens share the Bearer scheme; only JWT-shaped values are checked here
+			if !ok || len(keys) == 0 || strings.Count(token, ".") != 2 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			claims, err := keys.verify(token, clock.Now())
+			if err == nil && claims.Tenant != TenantFrom(r.Context()) {
+				err = errInvalidToken
+			}
+			if err == nil {
+				revoked, rerr := revocations.revoked(r.Context(), claims)
+				if rerr != nil {
+					// !! Fails closed: while the revocation list is unreachable no token is accepted
+					LoggerFrom(r.Context()).Error("revocation check failed", "err", rerr)
+					http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+					return
+				}
+				if revoked {
+					err = newAPIError("token_revoked")
+				}
+			}
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				renderJSON(w, r, http.StatusUnauthorized, errorFor(r.Context(), err, "invalid_token"))
+				return
+			}
+			ctx := context.WithValue(r.Context(), claimsKey{}, claims)
+			ctx = withPrincipal(ctx, "user:"+claims.Subject)
+			next.ServeHTTP(w, r.WithContext(withScopes(ctx, strings.Fields(claims.Scope))))
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+var benchOpts struct {
+	target      string
+	rps         int
+	concurrency int
+	duration    time.Duration
+	mix         string
+	token       string
+	tenant      string
+	insecure    bool
+}
+
+func benchFlags(fs *flag.FlagSet) {
+	fs.StringVar(&benchOpts.target, "target", "", "base URL to load (default: this config's server.addr)")
+	fs.IntVar(&benchOpts.rps, "rps", 50, "requests per second across all workers; 0 sends as fast as workers allow")
+	fs.IntVar(&benchOpts.concurrency, "concurrency", 8, "number of concurrent workers")
+	fs.DurationVar(&benchOpts.duration, "duration", 30*time.Second, "how long to send load")
+	fs.StringVar(&benchOpts.mix, "mix", "GET /users=8,GET /health=1,POST /users=1", "weighted endpoints as METHOD /path=weight, comma-separated")
+	fs.StringVar(&benchOpts.token, "token", "", "bearer token sent with every request")
+	fs.StringVar(&benchOpts.tenant, "tenant", "", "X-Tenant-ID sent with every request")
+	fs.BoolVar(&benchOpts.insecure, "insecure", false, "skip TLS certificate verification")
+}
+
+// benchEndpoint is one entry of the mix. POST and PUT send a generated
+// user, so a write-heavy mix grows the target's store.
+type benchEndpoint struct {
+	method string
+	path   string
+	weight int
+}
+
+func (e benchEndpoint) String() string { return e.method + " " + e.path }
+
+func parseBenchMix(spec string) ([]benchEndpoint, error) {
+	var mix []benchEndpoint
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, weight, hasWeight := strings.Cut(entry, "=")
+		method, path, ok := strings.Cut(strings.TrimSpace(route), " ")
+		path = strings.TrimSpace(path)
+		if !ok || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("mix entry %q: want METHOD /path=weight", entry)
+		}
+		w := 1
+		if hasWeight {
+			n, err := strconv.Atoi(weight)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("mix entry %q: weight must be a positive integer", entry)
+			}
+			w = n
+		}
+		mix = append(mix, benchEndpoint{method: strings.ToUpper(method), path: path, weight: w})
+	}
+	if len(mix) == 0 {
+		return nil, errors.New("mix is empty")
+	}
+	return mix, nil
+}
+
+// benchStats collects one endpoint's results.
+type benchStats struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	statuses  map[int]int
+	failures  int
+}
+
+func (st *benchStats) record(d time.Duration, status int, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if err != nil {
+		st.failures++
+		return
+	}
+	st.latencies = append(st.latencies, d)
+	st.statuses[status]++
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
+
+func runBench(cfg Config, _ []string) error {
+	mix, err := parseBenchMix(benchOpts.mix)
+	if err != nil {
+		return err
+	}
+	if benchOpts.concurrency < 1 {
+		return errors.New("concurrency must be at least 1")
+	}
+	target := benchOpts.target
+	if target == "" {
+		target = benchTarget(cfg)
+	}
+	target = strings.TrimSuffix(target, "/")
+
+	// Weighted picks without per-request randomness: the schedule repeats
+	// every sum(weights) requests.
+	var schedule []int
+	for i, e := range mix {
+		for range e.weight {
+			schedule = append(schedule, i)
+		}
+	}
+	stats := make([]*benchStats, len(mix))
+	for i := range stats {
+		stats[i] = &benchStats{statuses: map[int]int{}}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = benchOpts.concurrency
+	if benchOpts.insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	client := &http.Client{Transport: transport, Timeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, benchOpts.duration)
+	defer cancel()
+
+	work := make(chan int)
+	var seq, skipped atomic.Int64
+	var wg sync.WaitGroup
+	for range benchOpts.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				n := seq.Add(1)
+				d, status, err := benchRequest(ctx, client, target, mix[i], n)
+				if ctx.Err() != nil && err != nil {
+					return // cut off by the deadline, not a failure
+				}
+				stats[i].record(d, status, err)
+			}
+		}()
+	}
+
+	fmt.Printf("loading %s for %s: %d workers, %s\n", target, benchOpts.duration, benchOpts.concurrency, benchRate())
+	start := time.Now()
+	var tick <-chan time.Time
+	if benchOpts.rps > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(benchOpts.rps))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+send:
+	for n := 0; ; n++ {
+		if tick != nil {
+			select {
+			case <-ctx.Done():
+				break send
+			case <-tick:
+			}
+			// An open-loop rate: if every worker is busy the slot is
+			// skipped and reported rather than queued.
+			select {
+			case work <- schedule[n%len(schedule)]:
+			default:
+				skipped.Add(1)
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			break send
+		case work <- schedule[n%len(schedule)]:
+		}
+	}
+	close(work)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	return printBenchReport(os.Stdout, mix, stats, elapsed, skipped.Load())
+}
+
+func benchRate() string {
+	if benchOpts.rps > 0 {
+		return fmt.Sprintf("%d req/s", benchOpts.rps)
+	}
+	return "unthrottled"
+}
+
+// benchTarget points at this config's own server, for a quick check of a
+// local instance.
+func benchTarget(cfg Config) string {
+	scheme := "http"
+	if cfg.TLS.Enabled() || cfg.ACME.Enabled() {
+		scheme = "https"
+	}
+	addr := cfg.Server.Addr
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	return scheme + "://" + addr
+}
+
+func benchRequest(ctx context.Context, client *http.Client, target string, e benchEndpoint, n int64) (time.Duration, int, error) {
+	var body io.Reader
+	if e.method == http.MethodPost || e.method == http.MethodPut {
+		body = bytes.NewReader(fmt.Appendf(nil, `{"id":"bench-%d","name":"Bench User %d","email":"bench%d@example.com"}`, n, n, n))
+	}
+	req, err := http.NewRequestWithContext(ctx, e.method, target+e.path, body)
+	if err != nil {
+		return 0, 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if benchOpts.token != "" {
+		req.Header.Set("Authorization", "Bearer "+benchOpts.token)
+	}
+	if benchOpts.tenant != "" {
+		req.Header.Set("X-Tenant-ID", benchOpts.tenant)
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return time.Since(start), resp.StatusCode, nil
+}
+
+// printBenchReport writes one row per endpoint and a total. Errors are
+// transport failures and 5xx answers; 4xx are counted apart since a mix
+// aimed at protected routes without a token expects them.
+func printBenchReport(out io.Writer, mix []benchEndpoint, stats []*benchStats, elapsed time.Duration, skipped int64) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "endpoint\trequests\treq/s\tp50\tp90\tp99\tmax\t4xx\terrors\terror rate\t")
+	var all []time.Duration
+	var total, total4xx, totalErr int
+	row := func(name string, lat []time.Duration, n, n4xx, nErr int) {
+		slices.Sort(lat)
+		rate := 0.0
+		if n > 0 {
+			rate = float64(nErr) / float64(n) * 100
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%s\t%s\t%s\t%s\t%d\t%d\t%.2f%%\t\n", name, n, float64(n)/elapsed.Seconds(),
+			roundLatency(percentile(lat, 0.50)), roundLatency(percentile(lat, 0.90)), roundLatency(percentile(lat, 0.99)),
+			roundLatency(percentile(lat, 1)), n4xx, nErr, rate)
+	}
+	for i, st := range stats {
+		st.mu.Lock()
+		lat := slices.Clone(st.latencies)
+		n, n4xx, nErr := len(lat)+st.failures, 0, st.failures
+		for status, count := range st.statuses {
+			switch {
+			case status >= 500:
+				nErr += count
+			case status >= 400:
+				n4xx += count
+			}
+		}
+		st.mu.Unlock()
+		all = append(all, lat...)
+		total += n
+		total4xx += n4xx
+		totalErr += nErr
+		row(mix[i].String(), lat, n, n4xx, nErr)
+	}
+	row("total", all, total, total4xx, totalErr)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if skipped > 0 {
+		fmt.Fprintf(out, "\n%d scheduled requests skipped because every worker was busy; raise -concurrency to reach -rps\n", skipped)
+	}
+	return nil
+}
+
+func roundLatency(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
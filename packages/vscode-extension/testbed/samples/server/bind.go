@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// bindPolicy retries binds that fail because the address is still held,
+// typically by the previous process during a restart.
+type bindPolicy struct {
+	retries int
+	backoff time.Duration
+	logger  *slog.Logger
+}
+
+func transientBindError(err error) bool {
+	return errors.Is(err, syscall.EADDRINUSE) || errors.Is(err, syscall.EADDRNOTAVAIL)
+}
+
+func (p bindPolicy) listen(network, addr string) (net.Listener, error) {
+	backoff := p.backoff
+	for attempt := 0; ; attempt++ {
+		ln, err := net.Listen(network, addr)
+		if err == nil || attempt >= p.retries || !transientBindError(err) {
+			return ln, err
+		}
+		p.logger.Warn("bind failed, retrying", "addr", addr, "attempt", attempt+1, "retry_in", backoff, "err", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// retryingServer binds an auxiliary http.Server through the bind policy
+// rather than letting ListenAndServe give up on the first EADDRINUSE.
+type retryingServer struct {
+	srv  *http.Server
+	bind bindPolicy
+}
+
+func (r retryingServer) ListenAndServe() error {
+	ln, err := r.bind.listen("tcp", r.srv.Addr)
+	if err != nil {
+		return err
+	}
+	return r.srv.Serve(ln)
+}
+
+func (r retryingServer) Shutdown(ctx context.Context) error {
+	return r.srv.Shutdown(ctx)
+}
+
+func (s *Server) bindPolicy() bindPolicy {
+	return bindPolicy{retries: s.cfg.Server.BindRetries, backoff: s.cfg.Server.BindBackoff, logger: s.logger}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CachePolicy is the Cache-Control a route sends for one method. The zero
+// policy sends none, leaving caches to their heuristics.
+type CachePolicy struct {
+	NoStore bool
+	// Private keeps shared caches from storing the response; set it on
+	// anything that depends on the caller's credentials or tenant.
+	Private              bool
+	MaxAge               time.Duration
+	StaleWhileRevalidate time.Duration
+}
+
+var noStore = CachePolicy{NoStore: true}
+
+func (p CachePolicy) String() string {
+	if p.NoStore {
+		return "no-store"
+	}
+	var parts []string
+	if p.Private {
+		parts = append(parts, "private")
+	} else if p.MaxAge > 0 {
+		parts = append(parts, "public")
+	}
+	if p.MaxAge > 0 {
+		parts = append(parts, "max-age="+strconv.Itoa(int(p.MaxAge.Seconds())))
+	}
+	if p.StaleWhileRevalidate > 0 {
+		parts = append(parts, "stale-while-revalidate="+strconv.Itoa(int(p.StaleWhileRevalidate.Seconds())))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// cachePolicy returns the policy rt declares for method, falling back to
+// its "*" entry; HEAD shares GET's. Without either, admin routes and
+// methods that change state get no-store.
+func (rt Route) cachePolicy(method string) CachePolicy {
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	if p, ok := rt.Cache[method]; ok {
+		return p
+	}
+	if p, ok := rt.Cache["*"]; ok {
+		return p
+	}
+	if rt.Admin || method != http.MethodGet {
+		return noStore
+	}
+	return CachePolicy{}
+}
+
+// CacheControl sets the Cache-Control header from rt's policy as the
+// response goes out, unless the handler set one itself. Errors from a
+// cacheable route get no-store, so a brief outage isn't served from
+// cache for MaxAge.
+func CacheControl(rt Route) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := rt.cachePolicy(r.Method)
+			if p == (CachePolicy{}) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(&cacheWriter{ResponseWriter: w, policy: p}, r)
+		})
+	}
+}
+
+type cacheWriter struct {
+	http.ResponseWriter
+	policy  CachePolicy
+	written bool
+}
+
+func (w *cacheWriter) WriteHeader(status int) {
+	if !w.written {
+		w.written = true
+		h := w.Header()
+		if h.Get("Cache-Control") == "" {
+			p := w.policy
+			if status >= 400 {
+				p = noStore // >> 304s keep the policy: RFC 9111 wants it on the revalidation too
+			}
+			h.Set("Cache-Control", p.String())
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxChangesBatch caps how many changes one poll returns; a client that
+// is further behind gets the rest on its next poll, at once.
+const maxChangesBatch = 100
+
+// userChange is one entry of GET /users/changes. Deletes carry only the
+// user's ID.
+type userChange struct {
+	Action string    `json:"action"`
+	Time   time.Time `json:"time"`
+	User   User      `json:"user"`
+	tenant string
+}
+
+type changesPage struct {
+	Changes []userChange `json:"changes"`
+	// Cursor is what to send as since= on the next poll.
+	Cursor string `json:"cursor"`
+}
+
+// changeLog keeps the newest user events in a ring, numbered in arrival
+// order, for long polling. A cursor is the log's epoch and the number of
+// the next change to return, so one from another replica or an earlier
+// process is refused rather than misread.
+// !! One ring serves every tenant: a busy tenant can push a quiet one's changes out before it polls
+type changeLog struct {
+	epoch   string
+	maxWait time.Duration
+	// writeTimeout is server.write_timeout, which a long poll extends.
+	writeTimeout time.Duration
+
+	mu   sync.Mutex
+	ring []userChange
+	next uint64        // number of the next change to arrive
+	wake chan struct{} // closed, and replaced, when a change arrives
+}
+
+func newChangeLog(cfg ChangesConfig, writeTimeout time.Duration) *changeLog {
+	return &changeLog{
+		epoch:        randomHex(4),
+		maxWait:      cfg.MaxWait,
+		writeTimeout: writeTimeout,
+		ring:         make([]userChange, cfg.Retain),
+		wake:         make(chan struct{}),
+	}
+}
+
+func (l *changeLog) record(ev Event, ue UserEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ring[l.next%uint64(len(l.ring))] = userChange{Action: ue.Action, Time: ev.Time, User: ue.User, tenant: ev.Tenant}
+	l.next++
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
+
+// scrub replaces the user's earlier entries with scrubbed, so the log
+// can't hand back what anonymization removed.
+func (l *changeLog) scrub(tenant string, scrubbed User) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range l.ring {
+		if c := &l.ring[i]; c.tenant == tenant && c.User.ID == scrubbed.ID {
+			c.User = scrubbed
+		}
+	}
+}
+
+func (l *changeLog) cursor(n uint64) string {
+	return l.epoch + "." + strconv.FormatUint(n, 10)
+}
+
+// since returns tenant's changes after cursor, the cursor for the poll
+// after, and a channel closed when the next change arrives. An empty
+// cursor means from now. ok is false for a cursor that isn't this log's
+// or is older than the ring still holds.
+func (l *changeLog) since(tenant, cursor string) (changes []userChange, next string, wake <-chan struct{}, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	from := l.next
+	if cursor != "" {
+		epoch, n, _ := strings.Cut(cursor, ".")
+		var err error
+		if from, err = strconv.ParseUint(n, 10, 64); err != nil || epoch != l.epoch || from > l.next {
+			return nil, "", nil, false
+		}
+		if l.next-from > uint64(len(l.ring)) {
+			return nil, "", nil, false
+		}
+	}
+	changes = []userChange{}
+	for ; from < l.next && len(changes) < maxChangesBatch; from++ {
+		if c := l.ring[from%uint64(len(l.ring))]; c.tenant == tenant {
+			changes = append(changes, c)
+		}
+	}
+	return changes, l.cursor(from), l.wake, true
+}
+
+// handleUserChanges answers GET /users/changes?since=<cursor>&wait=<d>.
+// With changes after since, or no wait, it answers at once; otherwise it
+// holds the request until a change arrives, wait runs out or the server
+// shuts down, and answers with whatever it has, possibly nothing. Either
+// way the cursor in the reply continues from there.
+func (s *Server) handleUserChanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var wait time.Duration
+	if v := r.URL.Query().Get("wait"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			renderJSON(w, r, http.StatusBadRequest, errorResponse(r.Context(), "invalid_wait", v))
+			return
+		}
+		wait = min(d, s.changes.maxWait)
+	}
+	if wait > 0 {
+		// >> server.write_timeout would cut a long poll off, so this request gets the wait on top
+		http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + s.changes.writeTimeout))
+	}
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+
+	tenant, cursor := TenantFrom(r.Context()), r.URL.Query().Get("since")
+	for {
+		changes, next, wake, ok := s.changes.since(tenant, cursor)
+		if !ok {
+			renderJSON(w, r, http.StatusGone, errorResponse(r.Context(), "cursor_expired"))
+			return
+		}
+		if len(changes) > 0 || wait == 0 {
+			renderJSON(w, r, http.StatusOK, dataOK(changesPage{Changes: changes, Cursor: next}))
+			return
+		}
+		cursor = next
+		select {
+		case <-wake:
+			continue
+		case <-timeout.C:
+		case <-s.done:
+		case <-r.Context().Done():
+			return
+		}
+		renderJSON(w, r, http.StatusOK, dataOK(changesPage{Changes: changes, Cursor: next}))
+		return
+	}
+}
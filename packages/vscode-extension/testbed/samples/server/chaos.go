@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/store"
+)
+
+// chaosRules is set by NewServer when chaos.enabled is on, and read by the
+// store wrapper, the Redis client and Shutdown. Nil means off.
+var chaosRules atomic.Pointer[ChaosConfig]
+
+var errChaos = errors.New("chaos: injected transient failure")
+
+// chaosFault delays by up to StoreLatency and then, ErrorPercent of the
+// time, returns errChaos. It is a no-op while chaos is off.
+func chaosFault(ctx context.Context) error {
+	cfg := chaosRules.Load()
+	if cfg == nil {
+		return nil
+	}
+	if cfg.StoreLatency > 0 {
+		t := time.NewTimer(time.Duration(rand.Int63n(int64(cfg.StoreLatency))))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+	if rand.Float64()*100 < cfg.ErrorPercent {
+		return errChaos
+	}
+	return nil
+}
+
+// chaosPause sleeps for a random part of ShutdownJitter, or until ctx
+// ends, so drain and hook ordering is exercised under varied timing.
+func chaosPause(ctx context.Context, stage string) {
+	cfg := chaosRules.Load()
+	if cfg == nil || cfg.ShutdownJitter <= 0 {
+		return
+	}
+	d := time.Duration(rand.Int63n(int64(cfg.ShutdownJitter)))
+	LoggerFrom(ctx).Debug("chaos: delaying shutdown", "stage", stage, "delay", d)
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+// chaosStore wraps the store with chaosFault. Store methods return no
+// errors, so an injected one panics as a *store.Error and the request gets
+// a 500 from RecoveryMiddleware, as a real backend outage would look.
+type chaosStore struct {
+	Store
+}
+
+func (s chaosStore) fault(op string) {
+	if err := chaosFault(context.Background()); err != nil {
+		panic(&store.Error{Op: op, Err: err})
+	}
+}
+
+func (s chaosStore) Get(id string) (User, bool) {
+	s.fault("get")
+	return s.Store.Get(id)
+}
+
+func (s chaosStore) Set(user User) {
+	s.fault("set")
+	s.Store.Set(user)
+}
+
+func (s chaosStore) Delete(id string) bool {
+	s.fault("delete")
+	return s.Store.Delete(id)
+}
+
+func (s chaosStore) List() []User {
+	s.fault("list")
+	return s.Store.List()
+}
+
+func (s chaosStore) Range(ctx context.Context, fn func(User) bool) error {
+	s.fault("range")
+	return s.Store.Range(ctx, fn)
+}
+
+func (s chaosStore) Suggest(prefix string, n int) ([]User, bool) {
+	s.fault("suggest")
+	if sg, ok := s.Store.(store.Suggester); ok {
+		return sg.Suggest(prefix, n)
+	}
+	return nil, false
+}
+
+func (s chaosStore) ForTenant(tenant string) Store {
+	return chaosStore{Store: s.Store.ForTenant(tenant)}
+}
+
+// Flush fails outright rather than panicking, since its callers handle
+// errors.
+func (s chaosStore) Flush() error {
+	if err := chaosFault(context.Background()); err != nil {
+		return err
+	}
+	if f, ok := s.Store.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package server
+
+import "time"
+
+// Clock tells the time wherever it is observable through the API or
+// decides an expiry: user and API key timestamps, token and session
+// lifetimes, refresh and revocation TTLs, lockouts and local rate-limit
+// windows. Latency measurements, tickers and backoff stay on real time.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// jsonCodec encodes response bodies for renderJSON. Request bodies always
+// go through encoding/json, whose error types describeDecodeError turns
+// into client messages.
+type jsonCodec interface {
+	Encode(w io.Writer, v any) error
+}
+
+type stdCodec struct{}
+
+func (stdCodec) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// responseCodec is set by NewServer from server.json_codec. Nil means
+// encoding/json.
+var responseCodec atomic.Pointer[jsonCodec]
+
+func currentCodec() jsonCodec {
+	if c := responseCodec.Load(); c != nil {
+		return *c
+	}
+	return stdCodec{}
+}
+
+// newJSONCodec returns the codec for server.json_codec: "std" for
+// encoding/json, or "fast" for the one compiled in with -tags goccy.
+// TestCodecConformance holds the fast codec to encoding/json's exact
+// output, since clients and golden files depend on it.
+func newJSONCodec(name string) (jsonCodec, error) {
+	switch name {
+	case "", "std":
+		return stdCodec{}, nil
+	case "fast":
+		return newFastCodec()
+	}
+	return nil, fmt.Errorf("unknown server.json_codec %q (want std or fast)", name)
+}
@@ -0,0 +1,21 @@
+//go:build goccy
+
+package server
+
+import (
+	"io"
+
+	gojson "github.com/goccy/go-json"
+)
+
+type goccyCodec struct{}
+
+// newFastCodec returns a goccy/go-json encoder. Its defaults, HTML
+// escaping included, match encoding/json, which TestCodecConformance confirms.
+func newFastCodec() (jsonCodec, error) {
+	return goccyCodec{}, nil
+}
+
+func (goccyCodec) Encode(w io.Writer, v any) error {
+	return gojson.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,10 @@
+//go:build !goccy
+
+package server
+
+import "errors"
+
+// !! The fast codec needs github.com/goccy/go-json - build with -tags goccy to enable it
+func newFastCodec() (jsonCodec, error) {
+	return nil, errors.New("fast JSON codec not compiled in (rebuild with -tags goccy)")
+}
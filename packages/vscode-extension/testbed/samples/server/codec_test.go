@@ -0,0 +1,51 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// codecSamples cover what responses contain and where JSON encoders are
+// known to disagree: HTML and line-separator escaping, float formatting,
+// nil versus empty slices, map key order, times and omitempty.
+var codecSamples = []any{
+	Response{Success: true, Data: []User{
+		{ID: "u1", Name: "Ada <admin> & co", Email: "ada@example.com", CreatedAt: time.Date(2024, 2, 29, 13, 4, 5, 600, time.UTC)},
+		{ID: "u2", Name: "line sep  \"quoted\" \\ tab\t", CreatedAt: time.Date(1999, 12, 31, 23, 59, 59, 0, time.FixedZone("", -7*3600))},
+	}, NextCursor: "u2"},
+	Response{Success: false, Error: "invalid \x00 body", TraceID: "0af7651916cd43dd8448eb211c80319c"},
+	Response{Success: true, Data: []User(nil)},
+	dataResponse[[]User]{Success: true, Data: []User{{ID: "u3"}}, NextCursor: "u3"},
+	dataOK(User{ID: "u4", Name: "<b>"}),
+	Response{Success: true, Data: []User{}},
+	map[string]any{"zeta": 1, "alpha": []any{0.1, 1e21, 1e-7, 12345678.9, nil, true}, "Mid": map[string]int{"b": 2, "a": 1}},
+	[]byte("bytes are base64"),
+	json.RawMessage(`{"raw" : [1, 2]}`),
+}
+
+// TestCodecConformance holds the fast codec to encoding/json byte for
+// byte. Run it with -tags goccy; without the tag there is no fast codec
+// and it skips.
+func TestCodecConformance(t *testing.T) {
+	fast, err := newFastCodec()
+	if err != nil {
+		t.Skip(err)
+	}
+	for i, v := range codecSamples {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			var want, got bytes.Buffer
+			if err := (stdCodec{}).Encode(&want, v); err != nil {
+				t.Fatalf("encoding/json: %v", err)
+			}
+			if err := fast.Encode(&got, v); err != nil {
+				t.Fatalf("fast: %v", err)
+			}
+			if !bytes.Equal(want.Bytes(), got.Bytes()) {
+				t.Errorf("encodes differently from encoding/json:\n  want %s  got  %s", want.Bytes(), got.Bytes())
+			}
+		})
+	}
+}
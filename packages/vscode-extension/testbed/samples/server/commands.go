@@ -0,0 +1,203 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// command is one subcommand of the binary. Every command shares the config
+// flags, file and environment; flags registers any command-specific ones.
+// run receives the raw arguments so serve can re-parse them on reload.
+type command struct {
+	name    string
+	summary string
+	flags   func(*flag.FlagSet)
+	run     func(cfg Config, args []string) error
+}
+
+var seedOpts struct {
+	count int
+	file  string
+}
+
+func commands() []command {
+	return []command{
+		{name: "serve", summary: "run the HTTP server (default)", run: runServe},
+		{name: "check", summary: "validate the config and probe the store and certificates", run: runCheck},
+		{name: "migrate", summary: "upgrade the store's on-disk format", run: runMigrate},
+		{name: "seed", summary: "load sample or fixture users into the store", run: runSeed, flags: func(fs *flag.FlagSet) {
+			fs.IntVar(&seedOpts.count, "count", 10, "number of generated users")
+			fs.StringVar(&seedOpts.file, "file", "", "JSON array of users to load instead of generated ones")
+		}},
+		{name: "bench", summary: "send load to a running instance and report latency", run: runBench, flags: benchFlags},
+		{name: "perf", summary: "benchmark the store, middleware and handlers, optionally against a baseline", run: runPerf, flags: perfFlags},
+		{name: "stress", summary: "race concurrent requests, store calls, reloads and shutdown in-process", run: runStress, flags: stressFlags},
+		{name: "contract", summary: "check a running instance's responses against its OpenAPI document", run: runContract, flags: contractFlags},
+		{name: "version", summary: "print build information", run: runVersion},
+	}
+}
+
+// RunCommand runs the subcommand named by args[0], the arguments without
+// the program name.
+//
+// >> A bare invocation or one starting with a flag still means serve
+func RunCommand(args []string) error {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	for _, cmd := range commands() {
+		if cmd.name != name {
+			continue
+		}
+		if cmd.name == "version" {
+			return cmd.run(Config{}, args)
+		}
+		cfg, err := loadConfig(os.Args[0]+" "+cmd.name, args, cmd.flags)
+		if err != nil {
+			return err
+		}
+		closeLog, err := setupLogging(cfg.Logging)
+		if err != nil {
+			return err
+		}
+		defer closeLog()
+		return cmd.run(cfg, args)
+	}
+	usage(os.Stderr)
+	return fmt.Errorf("unknown command %q", name)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: %s <command> [flags]\n\ncommands:\n", os.Args[0])
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+func setupLogging(cfg LoggingConfig) (func() error, error) {
+	sinks, err := openLogSinks(cfg)
+	if err != nil {
+		return nil, err
+	}
+	out := &fanout{sinks: sinks}
+	slog.SetDefault(newLogger(cfg, out))
+	return out.Close, nil
+}
+
+func runCheck(cfg Config, _ []string) error {
+	var errs []error
+	store, err := OpenStore(cfg)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("store %s: %w", cfg.Store.Backend, err))
+	} else {
+		fmt.Printf("store %s: ok (%d users)\n", cfg.Store.Backend, store.Len())
+	}
+	if cfg.TLS.Enabled() {
+		if _, err := newCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile); err != nil {
+			errs = append(errs, fmt.Errorf("tls: %w", err))
+		} else {
+			fmt.Println("tls: ok")
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	fmt.Println("config: ok")
+	return nil
+}
+
+func runMigrate(cfg Config, _ []string) error {
+	store, err := OpenStore(cfg)
+	if err != nil {
+		return err
+	}
+	fs, ok := store.(*FileStore)
+	if !ok {
+		fmt.Printf("store %s has no on-disk format; nothing to migrate\n", cfg.Store.Backend)
+		return nil
+	}
+	from, err := fs.Migrate()
+	if err != nil {
+		return err
+	}
+	if from == snapshotVersion {
+		fmt.Printf("%s already at version %d\n", cfg.Store.Path, snapshotVersion)
+	} else {
+		fmt.Printf("%s migrated from version %d to %d\n", cfg.Store.Path, from, snapshotVersion)
+	}
+	return nil
+}
+
+func runSeed(cfg Config, _ []string) error {
+	store, err := OpenStore(cfg)
+	if err != nil {
+		return err
+	}
+	f, persistent := store.(interface{ Flush() error })
+	// !! Seeding the memory backend would be thrown away on exit
+	if !persistent {
+		return fmt.Errorf("seed needs a persistent store, got %q", cfg.Store.Backend)
+	}
+
+	users, err := seedUsers(seedOpts.file, seedOpts.count)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		store.Set(user)
+	}
+	if err := f.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("seeded %d users (%d total)\n", len(users), store.Len())
+	return nil
+}
+
+func seedUsers(path string, count int) ([]User, error) {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var users []User
+		if err := json.Unmarshal(data, &users); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return users, nil
+	}
+	now := time.Now()
+	users := make([]User, count)
+	for i := range users {
+		users[i] = User{
+			ID:        fmt.Sprintf("seed-%03d", i+1),
+			Name:      fmt.Sprintf("Seed User %d", i+1),
+			Email:     fmt.Sprintf("seed%d@example.com", i+1),
+			CreatedAt: now,
+		}
+	}
+	return users, nil
+}
+
+func runVersion(_ Config, _ []string) error {
+	info := buildInfo()
+	keys := make([]string, 0, len(info))
+	for k, v := range info {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("%s %s\n", k, info[k])
+	}
+	return nil
+}
@@ -0,0 +1,1176 @@
+package server
+
+import (
+	"compress/gzip"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/middleware"
+)
+
+// Config is resolved in increasing order of precedence:
+//
+//	defaults < config file (-config or CONFIG_FILE) < environment < flags
+//
+// Each field is addressed by its toml tag as section.key. The environment
+// variable defaults to SECTION_KEY and the flag to the toml key with dashes,
+// either of which the env and flag tags override. Fields tagged secret are
+// masked when the config is echoed, and fields tagged reload can be changed
+// by SIGHUP without a restart.
+type Config struct {
+	Server     ServerConfig     `toml:"server"`
+	Store      StoreConfig      `toml:"store"`
+	TLS        TLSConfig        `toml:"tls"`
+	ACME       ACMEConfig       `toml:"acme"`
+	HTTP2      H2Config         `toml:"http2"`
+	HTTP3      H3Config         `toml:"http3"`
+	Unix       UnixConfig       `toml:"unix"`
+	Admin      AdminConfig      `toml:"admin"`
+	Debug      DebugConfig      `toml:"debug"`
+	Middleware MiddlewareConfig `toml:"middleware"`
+	Logging    LoggingConfig    `toml:"logging"`
+	Leader     LeaderConfig     `toml:"leader"`
+	Jobs       JobsConfig       `toml:"jobs"`
+	Schedule   ScheduleConfig   `toml:"schedule"`
+	Features   FeaturesConfig   `toml:"features"`
+	Tenant     TenantConfig     `toml:"tenant"`
+	Metrics    MetricsConfig    `toml:"metrics"`
+	Health     HealthConfig     `toml:"health"`
+	Profiling  ProfilingConfig  `toml:"profiling"`
+	Errors     ErrorsConfig     `toml:"errors"`
+	SLO        SLOConfig        `toml:"slo"`
+	Auth       AuthConfig       `toml:"auth"`
+	APIKeys    APIKeysConfig    `toml:"apikeys"`
+	Signing    SigningConfig    `toml:"signing"`
+	Secrets    SecretsConfig    `toml:"secrets"`
+	RateLimit  RateLimitConfig  `toml:"ratelimit"`
+	Lockout    LockoutConfig    `toml:"lockout"`
+	Audit      AuditConfig      `toml:"audit"`
+	Notify     NotifyConfig     `toml:"notify"`
+	Publish    PublishConfig    `toml:"publish"`
+	Fanout     FanoutConfig     `toml:"fanout"`
+	Session    SessionConfig    `toml:"session"`
+	Chaos      ChaosConfig      `toml:"chaos"`
+	Heavy      HeavyConfig      `toml:"heavy"`
+	Changes    ChangesConfig    `toml:"changes"`
+	Quotas     QuotaConfig      `toml:"quotas"`
+	QoS        QoSConfig        `toml:"qos"`
+	Memory     MemoryConfig     `toml:"memory"`
+	Retention  RetentionConfig  `toml:"retention"`
+	Suggest    SuggestConfig    `toml:"suggest"`
+}
+
+type ServerConfig struct {
+	Addr           string        `toml:"addr"`
+	ReadTimeout    time.Duration `toml:"read_timeout"`
+	WriteTimeout   time.Duration `toml:"write_timeout"`
+	IdleTimeout    time.Duration `toml:"idle_timeout"`
+	ShutdownGrace  time.Duration `toml:"shutdown_grace" env:"SHUTDOWN_GRACE"`
+	HookTimeout    time.Duration `toml:"hook_timeout"`
+	DrainDelay     time.Duration `toml:"drain_delay"`
+	UpgradeTimeout time.Duration `toml:"upgrade_timeout"`
+	DiagDir        string        `toml:"diag_dir"`
+	// BindRetries and BindBackoff cover EADDRINUSE while a previous
+	// process still holds the port; the backoff doubles per attempt.
+	BindRetries int           `toml:"bind_retries"`
+	BindBackoff time.Duration `toml:"bind_backoff"`
+	// ShutdownReportEvery paces progress logs while shutdown waits.
+	ShutdownReportEvery time.Duration `toml:"shutdown_report_interval"`
+	// MaxBodyBytes and MaxJSONDepth bound JSON request bodies; see
+	// decodeJSON.
+	MaxBodyBytes int64 `toml:"max_body_bytes" reload:"true"`
+	MaxJSONDepth int   `toml:"max_json_depth" reload:"true"`
+	// JSONCodec encodes responses: std, or fast when built with -tags
+	// goccy; see newJSONCodec.
+	JSONCodec string `toml:"json_codec"`
+	// Timestamps is how response bodies write times: rfc3339, utc or
+	// unix. A request's ?ts= overrides it; see TimestampMiddleware.
+	Timestamps string `toml:"timestamps" reload:"true"`
+	// Connection handling. ReadHeaderTimeout 0 falls back to ReadTimeout.
+	// MaxConns caps open connections across the main listeners and
+	// MaxIdleConns closes keep-alive connections beyond that many idle
+	// ones; 0 means no limit for either.
+	ReadHeaderTimeout time.Duration `toml:"read_header_timeout"`
+	MaxHeaderBytes    int           `toml:"max_header_bytes"`
+	MaxConns          int           `toml:"max_conns"`
+	MaxIdleConns      int           `toml:"max_idle_conns"`
+	KeepAlives        bool          `toml:"keep_alives"`
+}
+
+type StoreConfig struct {
+	Backend        string        `toml:"backend" flag:"store"`
+	Path           string        `toml:"path" flag:"store-path"`
+	ConnectTimeout time.Duration `toml:"connect_timeout" flag:"store-connect-timeout"`
+	// BatchMax and BatchDelay tune the file backend's group commit; see
+	// FileStore.persist.
+	BatchMax   int           `toml:"batch_max" flag:"store-batch-max"`
+	BatchDelay time.Duration `toml:"batch_delay" flag:"store-batch-delay"`
+}
+
+type TLSConfig struct {
+	CertFile   string        `toml:"cert_file" flag:"tls-cert"`
+	KeyFile    string        `toml:"key_file" flag:"tls-key"`
+	MinVersion string        `toml:"min_version" flag:"tls-min-version"`
+	WatchEvery time.Duration `toml:"watch_interval" flag:"tls-watch-interval"`
+	// RedirectAddr, when set, serves 301 redirects to the HTTPS listener.
+	RedirectAddr string `toml:"redirect_addr" flag:"tls-redirect-addr"`
+	// ClientAuth is none, request or require; see parseClientAuth. With
+	// request, ClientCertRoutes lists the route patterns that need a cert.
+	ClientAuth       string   `toml:"client_auth" flag:"tls-client-auth"`
+	ClientCAFile     string   `toml:"client_ca_file" flag:"tls-client-ca"`
+	ClientCertRoutes []string `toml:"client_cert_routes" flag:"tls-client-cert-routes"`
+}
+
+func (t TLSConfig) Enabled() bool { return t.CertFile != "" }
+
+type ACMEConfig struct {
+	Domains      []string `toml:"domains" flag:"acme-domains"`
+	Email        string   `toml:"email" flag:"acme-email"`
+	CacheDir     string   `toml:"cache_dir" flag:"acme-cache-dir"`
+	Challenge    string   `toml:"challenge" flag:"acme-challenge"`
+	HTTPAddr     string   `toml:"http_addr" flag:"acme-http-addr"`
+	DirectoryURL string   `toml:"directory_url" flag:"acme-directory"`
+}
+
+func (a ACMEConfig) Enabled() bool { return len(a.Domains) > 0 }
+
+type H2Config struct {
+	Enabled              bool   `toml:"enabled" flag:"http2"`
+	H2CAddr              string `toml:"h2c_addr" flag:"h2c-addr"`
+	MaxConcurrentStreams int    `toml:"max_concurrent_streams" flag:"http2-max-streams"`
+}
+
+type H3Config struct {
+	Addr         string        `toml:"addr" flag:"http3-addr"`
+	AltSvcMaxAge time.Duration `toml:"alt_svc_max_age" flag:"http3-alt-svc-max-age"`
+}
+
+type UnixConfig struct {
+	Path string `toml:"path" flag:"unix-socket"`
+	Mode string `toml:"mode" flag:"unix-socket-mode"`
+	Only bool   `toml:"only" flag:"unix-only"`
+}
+
+type AdminConfig struct {
+	Token string `toml:"token" flag:"admin-token" secret:"true"`
+}
+
+type DebugConfig struct {
+	Pprof  bool   `toml:"pprof" flag:"pprof"`
+	Expvar bool   `toml:"expvar" flag:"expvar"`
+	Addr   string `toml:"addr" flag:"debug-addr"`
+}
+
+func (d DebugConfig) Enabled() bool { return d.Pprof || d.Expvar }
+
+type MiddlewareConfig struct {
+	MirrorURL     string  `toml:"mirror_url" flag:"mirror" secret:"true" reload:"true"`
+	MirrorPercent float64 `toml:"mirror_percent" reload:"true"`
+	// The fault settings don't reload, so a config edit and SIGHUP can't
+	// switch injection on in a running process.
+	Faults        bool          `toml:"faults"`
+	FaultPercent  float64       `toml:"fault_percent"`
+	FaultLatency  time.Duration `toml:"fault_latency"`
+	FaultErrors   bool          `toml:"fault_errors"`
+	FaultDrops    bool          `toml:"fault_drops"`
+	LogBodies     bool          `toml:"log_bodies" reload:"true"`
+	LogBodyMax    int           `toml:"log_body_max" reload:"true"`
+	Redact        []string      `toml:"redact" reload:"true"`
+	Compress      bool          `toml:"compress" reload:"true"`
+	CompressLevel int           `toml:"compress_level" reload:"true"`
+	CompressMin   int           `toml:"compress_min_bytes" reload:"true"`
+	// Decompress accepts gzip request bodies of up to DecompressMax bytes,
+	// counted both compressed and inflated; see middleware.Decompression.
+	Decompress    bool  `toml:"decompress" reload:"true"`
+	DecompressMax int64 `toml:"decompress_max_bytes" reload:"true"`
+}
+
+type LoggingConfig struct {
+	Level  string `toml:"level" flag:"log-level" reload:"true"`
+	Format string `toml:"format" flag:"log-format"`
+
+	File       string        `toml:"file" flag:"log-file"`
+	MaxSizeMB  int           `toml:"max_size_mb"`
+	MaxAge     time.Duration `toml:"max_age"`
+	MaxBackups int           `toml:"max_backups"`
+
+	// Sinks selects outputs: stderr, file, syslog and http. Empty means
+	// file when logging.file is set, stderr otherwise.
+	Sinks          []string      `toml:"sinks" flag:"log-sinks"`
+	SinkBuffer     int           `toml:"sink_buffer"`
+	SyslogAddr     string        `toml:"syslog_addr"`
+	SyslogTag      string        `toml:"syslog_tag"`
+	CollectorURL   string        `toml:"collector_url" secret:"true"`
+	CollectorBatch int           `toml:"collector_batch"`
+	CollectorFlush time.Duration `toml:"collector_flush"`
+
+	// RedactPII masks email addresses everywhere and the values of PIIKeys
+	// attributes in logs and error reports; see piiRedactor.
+	RedactPII bool     `toml:"redact_pii" flag:"log-redact-pii"`
+	PIIKeys   []string `toml:"pii_keys"`
+}
+
+// LeaderConfig elects one instance to run singleton background work. With
+// no lease every instance considers itself the leader.
+type LeaderConfig struct {
+	Lease string        `toml:"lease" flag:"leader-lease"`
+	TTL   time.Duration `toml:"ttl" flag:"leader-ttl"`
+	ID    string        `toml:"id" flag:"leader-id"`
+}
+
+// JobsConfig sizes the background job queue. Dir enables persistence; by
+// default queued jobs are lost on exit.
+type JobsConfig struct {
+	Workers     int           `toml:"workers" flag:"job-workers"`
+	QueueSize   int           `toml:"queue_size" flag:"job-queue-size"`
+	MaxAttempts int           `toml:"max_attempts" flag:"job-max-attempts"`
+	Backoff     time.Duration `toml:"backoff" flag:"job-backoff"`
+	Timeout     time.Duration `toml:"timeout" flag:"job-timeout"`
+	Dir         string        `toml:"dir" flag:"job-dir"`
+}
+
+// ScheduleConfig holds cron expressions for the built-in maintenance tasks.
+// An empty expression disables the task.
+type ScheduleConfig struct {
+	Jitter   time.Duration `toml:"jitter" flag:"schedule-jitter"`
+	Snapshot string        `toml:"snapshot" flag:"schedule-snapshot"`
+}
+
+// RetentionConfig deletes or anonymizes users past their retention on
+// the Schedule cron expression, empty to run only from
+// /admin/retention; see parseRetentionRules for the rule syntax. With
+// DryRun the runs only report what they would do.
+type RetentionConfig struct {
+	Schedule string   `toml:"schedule" flag:"retention-schedule"`
+	Rules    []string `toml:"rules" flag:"retention-rules"`
+	DryRun   bool     `toml:"dry_run" flag:"retention-dry-run"`
+}
+
+// FeaturesConfig lists feature flags; see middleware.Features for the syntax.
+// Percentage rollouts are keyed on KeyHeader, or the client IP without it.
+type FeaturesConfig struct {
+	Flags     []string `toml:"flags" flag:"features" reload:"true"`
+	KeyHeader string   `toml:"key_header" reload:"true"`
+}
+
+// TenantConfig controls how requests are mapped to tenants. Domain enables
+// subdomain routing, so acme.example.com is tenant "acme" for "example.com".
+type TenantConfig struct {
+	Header   string `toml:"header" flag:"tenant-header"`
+	Domain   string `toml:"domain" flag:"tenant-domain"`
+	Required bool   `toml:"required" flag:"tenant-required"`
+}
+
+// MetricsConfig enables /metrics. With Addr it is served, without auth, on
+// a separate internal listener; otherwise on the main one behind admin auth.
+type MetricsConfig struct {
+	Enabled bool   `toml:"enabled" flag:"metrics"`
+	Addr    string `toml:"addr" flag:"metrics-addr"`
+}
+
+// HealthConfig tunes the /readyz checks. Results are cached for CacheTTL;
+// a MaxQueueDepth of zero leaves the job backlog out of readiness.
+type HealthConfig struct {
+	Timeout       time.Duration `toml:"timeout" flag:"health-timeout"`
+	CacheTTL      time.Duration `toml:"cache_ttl" flag:"health-cache-ttl"`
+	MinFreeMB     int           `toml:"min_free_mb" flag:"health-min-free-mb"`
+	MaxQueueDepth int           `toml:"max_queue_depth" flag:"health-max-queue-depth"`
+}
+
+// ProfilingConfig schedules continuous profile capture. Every of zero
+// disables it; otherwise each round captures Profiles, with the CPU profile
+// sampled for CPUDuration, and keeps the newest Keep files per kind in Dir.
+type ProfilingConfig struct {
+	Every        time.Duration `toml:"every" flag:"profile-every"`
+	CPUDuration  time.Duration `toml:"cpu_duration" flag:"profile-cpu-duration"`
+	Profiles     []string      `toml:"profiles" flag:"profiles"`
+	Dir          string        `toml:"dir" flag:"profile-dir"`
+	Keep         int           `toml:"keep" flag:"profile-keep"`
+	CollectorURL string        `toml:"collector_url" flag:"profile-collector" secret:"true"`
+	Service      string        `toml:"service" flag:"profile-service"`
+}
+
+// ErrorsConfig sends panics, 5xx responses and dead-lettered jobs to a
+// Sentry-compatible tracker. Without a DSN nothing is reported.
+type ErrorsConfig struct {
+	DSN         string `toml:"dsn" flag:"errors-dsn" secret:"true"`
+	Environment string `toml:"environment" flag:"errors-environment"`
+}
+
+// NotifyConfig emails users when their account is created or updated, as
+// listed in Events, through the job queue. Provider is smtp, sending
+// through SMTPAddr, or log, which only logs each message; empty disables
+// notifications.
+type NotifyConfig struct {
+	Provider     string   `toml:"provider" flag:"notify-provider"`
+	From         string   `toml:"from" flag:"notify-from"`
+	Events       []string `toml:"events" flag:"notify-events"`
+	SMTPAddr     string   `toml:"smtp_addr" flag:"notify-smtp-addr"`
+	SMTPUser     string   `toml:"smtp_user" flag:"notify-smtp-user"`
+	SMTPPassword string   `toml:"smtp_password" flag:"notify-smtp-password" secret:"true"`
+}
+
+// PublishConfig sends user change events to a message broker so other
+// systems can react without polling the API. Broker is kafka, which needs
+// -tags kafka, or nats; empty disables publishing. Addrs are host:port
+// brokers for Kafka and nats:// URLs for NATS. Encoding is json or
+// protobuf, a users.v1.UserEvent.
+type PublishConfig struct {
+	Broker   string        `toml:"broker" flag:"publish-broker"`
+	Addrs    []string      `toml:"addrs" flag:"publish-addrs" secret:"true"`
+	Topic    string        `toml:"topic" flag:"publish-topic"`
+	Encoding string        `toml:"encoding" flag:"publish-encoding"`
+	Buffer   int           `toml:"buffer" flag:"publish-buffer"`
+	Timeout  time.Duration `toml:"timeout" flag:"publish-timeout"`
+}
+
+// FanoutConfig relays user events between replicas over a Redis pub/sub
+// Channel, so event subscribers on every instance see changes made on any
+// of them. Without RedisURL events stay on the instance that made them.
+type FanoutConfig struct {
+	RedisURL string `toml:"redis_url" flag:"fanout-redis" secret:"true"`
+	Channel  string `toml:"channel" flag:"fanout-channel"`
+}
+
+// SLOConfig declares per-route objectives; see parseObjectives for the
+// syntax. Compliance is computed over Window and the fast burn rate over
+// ShortWindow.
+type SLOConfig struct {
+	Objectives  []string      `toml:"objectives" flag:"slo"`
+	Window      time.Duration `toml:"window" flag:"slo-window"`
+	ShortWindow time.Duration `toml:"short_window" flag:"slo-short-window"`
+}
+
+// AuthConfig controls /auth/login and /auth/refresh. SigningKeys are
+// "kid:secret" HS256 keys; the first signs and all verify. SigningKeysSecret
+// names a secret holding the same entries, one per line, and replaces
+// SigningKeys. Without keys the auth endpoints are not registered.
+//
+// Scopes are granted to every issued token. With RequireScopes, routes
+// that declare scopes refuse anonymous requests instead of only limiting
+// scoped credentials.
+type AuthConfig struct {
+	SigningKeys       []string      `toml:"signing_keys" flag:"auth-signing-keys" secret:"true"`
+	SigningKeysSecret string        `toml:"signing_keys_secret" flag:"auth-signing-keys-secret"`
+	Issuer            string        `toml:"issuer" flag:"auth-issuer"`
+	AccessTTL         time.Duration `toml:"access_ttl" flag:"auth-access-ttl"`
+	RefreshTTL        time.Duration `toml:"refresh_ttl" flag:"auth-refresh-ttl"`
+	Scopes            []string      `toml:"scopes" flag:"auth-scopes"`
+	RequireScopes     bool          `toml:"require_scopes" flag:"auth-require-scopes"`
+	// RevocationRedisURL shares revoked tokens between replicas; without
+	// it each instance only knows its own logouts.
+	RevocationRedisURL string `toml:"revocation_redis_url" flag:"auth-revocation-redis" secret:"true"`
+}
+
+// APIKeysConfig persists issued API keys, hashed, to File. Without it keys
+// are lost on exit.
+type APIKeysConfig struct {
+	File string `toml:"file" flag:"apikeys-file"`
+	// Roles maps key roles to the scopes they grant, as "role=scope
+	// scope". A key holds the scopes of all its roles; a role not listed
+	// grants none.
+	Roles []string `toml:"roles" flag:"apikeys-roles"`
+}
+
+// SigningConfig enables HMAC request signatures for machine callers. Keys
+// are "id:secret", or come from the secret named by KeysSecret; Window
+// bounds clock skew and how long a signature can be replayed against.
+// Required rejects unsigned requests other than probes.
+type SigningConfig struct {
+	Keys       []string      `toml:"keys" flag:"signing-keys" secret:"true"`
+	KeysSecret string        `toml:"keys_secret" flag:"signing-keys-secret"`
+	Window     time.Duration `toml:"window" flag:"signing-window"`
+	Required   bool          `toml:"required" flag:"signing-required"`
+}
+
+// Enabled reports whether any signing keys are configured.
+func (c SigningConfig) Enabled() bool { return len(c.Keys) > 0 || c.KeysSecret != "" }
+
+// RateLimitConfig allows each caller Requests per Window, keyed by its
+// principal or, for anonymous callers, its address. Requests of 0 limits
+// only keys with an admin override. With RedisURL the counters and
+// overrides are shared by every replica under KeyPrefix.
+//
+// Routes gives route groups limits of their own, in place of the default;
+// see parseRouteLimits for the syntax. The limits reload; counters and
+// overrides carry over. Redis needs a restart.
+type RateLimitConfig struct {
+	Requests  int           `toml:"requests" flag:"ratelimit-requests" reload:"true"`
+	Window    time.Duration `toml:"window" flag:"ratelimit-window" reload:"true"`
+	RedisURL  string        `toml:"redis_url" flag:"ratelimit-redis" secret:"true"`
+	KeyPrefix string        `toml:"key_prefix" flag:"ratelimit-key-prefix"`
+	Routes    []string      `toml:"routes" flag:"ratelimit-routes" reload:"true"`
+}
+
+// LockoutConfig locks an identity or address out of login and API key
+// authentication after Threshold failures within Window, for Base doubling
+// per repeat up to Max. Threshold 0 disables lockouts.
+type LockoutConfig struct {
+	Threshold int           `toml:"threshold" flag:"lockout-threshold"`
+	Window    time.Duration `toml:"window" flag:"lockout-window"`
+	Base      time.Duration `toml:"base" flag:"lockout-base"`
+	Max       time.Duration `toml:"max" flag:"lockout-max"`
+}
+
+// ChaosConfig, turned on with --chaos, slows and fails store and Redis
+// calls at random and jitters shutdown, to exercise retries, fallbacks and
+// draining end to end.
+type ChaosConfig struct {
+	Enabled        bool          `toml:"enabled" flag:"chaos"`
+	StoreLatency   time.Duration `toml:"store_latency" flag:"chaos-store-latency"`
+	ErrorPercent   float64       `toml:"error_percent" flag:"chaos-error-percent"`
+	ShutdownJitter time.Duration `toml:"shutdown_jitter" flag:"chaos-shutdown-jitter"`
+}
+
+// HeavyConfig bounds requests whose cost grows with the store, such as
+// full user lists and exports: Workers run at once, 0 meaning GOMAXPROCS,
+// up to Queue more wait at most QueueTimeout, and the rest get a 503.
+type HeavyConfig struct {
+	Workers      int           `toml:"workers"`
+	Queue        int           `toml:"queue" flag:"heavy-queue"`
+	QueueTimeout time.Duration `toml:"queue_timeout" flag:"heavy-queue-timeout"`
+}
+
+// QoSConfig caps the requests running handlers at once at MaxInFlight, 0
+// meaning no cap. Up to Queue more wait at most QueueTimeout, reads ahead
+// of writes ahead of bulk work, and the lowest classes are shed first
+// when the queue is full; probes never wait. See Route.Priority.
+type QoSConfig struct {
+	MaxInFlight  int           `toml:"max_in_flight" flag:"qos-max-in-flight"`
+	Queue        int           `toml:"queue" flag:"qos-queue"`
+	QueueTimeout time.Duration `toml:"queue_timeout" flag:"qos-queue-timeout"`
+}
+
+// MemoryConfig sheds bulk requests with 503 once the process uses High of
+// LimitBytes, until use falls back to Low, checked Every. LimitBytes 0
+// takes GOMEMLIMIT, and with neither nothing is shed.
+type MemoryConfig struct {
+	LimitBytes int64         `toml:"limit_bytes"`
+	High       float64       `toml:"high"`
+	Low        float64       `toml:"low"`
+	Every      time.Duration `toml:"every"`
+}
+
+// SuggestConfig shapes GET /users/suggest: Limit matches unless limit=
+// asks for more, up to MaxLimit, found within Budget. Past the budget the
+// matches found so far go out marked partial, so a slow store can't stall
+// a typeahead.
+type SuggestConfig struct {
+	Limit    int           `toml:"limit"`
+	MaxLimit int           `toml:"max_limit"`
+	Budget   time.Duration `toml:"budget" flag:"suggest-budget"`
+}
+
+// ChangesConfig sizes GET /users/changes: the newest Retain user events
+// are kept for polling, and a poll waits at most MaxWait, whatever its
+// wait= asks.
+type ChangesConfig struct {
+	Retain  int           `toml:"retain"`
+	MaxWait time.Duration `toml:"max_wait"`
+}
+
+// QuotaConfig caps what each tenant, and each API key, may use: stored
+// users and bytes, refused with 403, and requests per window, refused
+// with 429; see parseQuotas for the syntax. Usage is reported at
+// /admin/quotas.
+type QuotaConfig struct {
+	Limits []string `toml:"limits" flag:"quota-limits"`
+}
+
+// AuditConfig keeps the newest Keep audit entries queryable at
+// /admin/audit and, with File, appends every entry to it as JSON lines.
+type AuditConfig struct {
+	File string `toml:"file" flag:"audit-file"`
+	Keep int    `toml:"keep" flag:"audit-keep"`
+}
+
+// SessionConfig enables encrypted session cookies. Keys are "kid:secret"
+// entries, or come from the secret named by KeysSecret; the first seals and
+// all open, so rotating means putting a new key first and dropping the old
+// one after MaxAge. Without keys SessionFrom returns nil.
+type SessionConfig struct {
+	Keys       []string      `toml:"keys" flag:"session-keys" secret:"true"`
+	KeysSecret string        `toml:"keys_secret" flag:"session-keys-secret"`
+	CookieName string        `toml:"cookie_name" flag:"session-cookie"`
+	MaxAge     time.Duration `toml:"max_age" flag:"session-max-age"`
+	Secure     bool          `toml:"secure" flag:"session-secure"`
+}
+
+// Enabled reports whether any session keys are configured.
+func (c SessionConfig) Enabled() bool { return len(c.Keys) > 0 || c.KeysSecret != "" }
+
+// SecretsConfig selects where named secrets are read from: env (EnvPrefix
+// plus the upper-cased name), file (one file per name in Dir) or vault (KV
+// v2 at VaultAddr under VaultMount, authenticated by $VAULT_TOKEN). Fetched
+// secrets are re-read every Refresh so rotations apply without a restart.
+type SecretsConfig struct {
+	Provider   string        `toml:"provider" flag:"secrets-provider"`
+	EnvPrefix  string        `toml:"env_prefix" flag:"secrets-env-prefix"`
+	Dir        string        `toml:"dir" flag:"secrets-dir"`
+	VaultAddr  string        `toml:"vault_addr" flag:"secrets-vault-addr" env:"VAULT_ADDR"`
+	VaultMount string        `toml:"vault_mount" flag:"secrets-vault-mount"`
+	Refresh    time.Duration `toml:"refresh" flag:"secrets-refresh"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:                ":8080",
+			ReadTimeout:         10 * time.Second,
+			WriteTimeout:        10 * time.Second,
+			IdleTimeout:         60 * time.Second,
+			ShutdownGrace:       30 * time.Second,
+			HookTimeout:         5 * time.Second,
+			DrainDelay:          5 * time.Second,
+			UpgradeTimeout:      30 * time.Second,
+			ShutdownReportEvery: time.Second,
+			BindRetries:         5,
+			BindBackoff:         250 * time.Millisecond,
+			MaxBodyBytes:        1 << 20,
+			MaxJSONDepth:        32,
+			JSONCodec:           "std",
+			Timestamps:          tsRFC3339,
+			MaxHeaderBytes:      http.DefaultMaxHeaderBytes,
+			KeepAlives:          true,
+		},
+		Store: StoreConfig{Backend: "memory", ConnectTimeout: 30 * time.Second},
+		TLS:   TLSConfig{MinVersion: "1.2", WatchEvery: 30 * time.Second},
+		ACME:  ACMEConfig{CacheDir: "acme-cache", Challenge: "http-01", HTTPAddr: ":80"},
+		HTTP2: H2Config{Enabled: true, MaxConcurrentStreams: 250},
+		HTTP3: H3Config{AltSvcMaxAge: 24 * time.Hour},
+		Unix:  UnixConfig{Mode: "0660"},
+		Middleware: MiddlewareConfig{
+			MirrorPercent: 10,
+			FaultPercent:  5,
+			FaultErrors:   true,
+			LogBodyMax:    4096,
+			Redact:        []string{"email", "data.email", "**.password", "**.refresh_token", "**.token"},
+			CompressLevel: gzip.DefaultCompression,
+			CompressMin:   1024,
+			Decompress:    true,
+			DecompressMax: 8 << 20,
+		},
+		Logging: LoggingConfig{
+			Level:          "info",
+			Format:         "text",
+			MaxSizeMB:      100,
+			MaxBackups:     7,
+			SinkBuffer:     10000,
+			SyslogTag:      "human-plus-plus",
+			CollectorBatch: 500,
+			CollectorFlush: 2 * time.Second,
+			RedactPII:      true,
+			PIIKeys:        []string{"email", "name", "username"},
+		},
+		Leader: LeaderConfig{TTL: 15 * time.Second},
+		Jobs: JobsConfig{
+			Workers:     4,
+			QueueSize:   1024,
+			MaxAttempts: 5,
+			Backoff:     time.Second,
+			Timeout:     30 * time.Second,
+		},
+		Schedule: ScheduleConfig{Jitter: 5 * time.Second, Snapshot: "*/5 * * * *"},
+		Features: FeaturesConfig{KeyHeader: "X-User-ID"},
+		Tenant:   TenantConfig{Header: "X-Tenant-ID"},
+		Health:   HealthConfig{Timeout: 2 * time.Second, CacheTTL: time.Second, MinFreeMB: 100},
+		Errors:   ErrorsConfig{Environment: "production"},
+		SLO:      SLOConfig{Window: time.Hour, ShortWindow: 5 * time.Minute},
+		Signing:  SigningConfig{Window: 5 * time.Minute},
+		APIKeys:  APIKeysConfig{Roles: []string{"reader=users:read", "writer=users:read users:write"}},
+		Secrets:  SecretsConfig{Provider: "env", EnvPrefix: "SECRET_", Dir: "/run/secrets", VaultMount: "secret", Refresh: 5 * time.Minute},
+		Auth:     AuthConfig{Issuer: "human-plus-plus", AccessTTL: 15 * time.Minute, RefreshTTL: 30 * 24 * time.Hour, Scopes: []string{"users:read", "users:write"}},
+		Profiling: ProfilingConfig{
+			CPUDuration: 10 * time.Second,
+			Profiles:    []string{"cpu", "heap"},
+			Keep:        48,
+			Service:     "human-plus-plus",
+		},
+		RateLimit: RateLimitConfig{
+			Window:    time.Minute,
+			KeyPrefix: "hpp:ratelimit:",
+		},
+		Audit:   AuditConfig{Keep: 10000},
+		Notify:  NotifyConfig{Events: []string{"created"}},
+		Publish: PublishConfig{Topic: "users", Encoding: "json", Buffer: 1024, Timeout: 5 * time.Second},
+		Fanout:  FanoutConfig{Channel: "hpp:events:users"},
+		Session: SessionConfig{
+			CookieName: "hpp_session",
+			MaxAge:     24 * time.Hour,
+			Secure:     true,
+		},
+		Lockout: LockoutConfig{
+			Threshold: 5,
+			Window:    15 * time.Minute,
+			Base:      time.Minute,
+			Max:       time.Hour,
+		},
+		Chaos: ChaosConfig{
+			StoreLatency:   50 * time.Millisecond,
+			ErrorPercent:   1,
+			ShutdownJitter: 2 * time.Second,
+		},
+		Heavy:   HeavyConfig{Queue: 64, QueueTimeout: 5 * time.Second},
+		Changes: ChangesConfig{Retain: 10000, MaxWait: time.Minute},
+		QoS:     QoSConfig{Queue: 256, QueueTimeout: 2 * time.Second},
+		Memory:  MemoryConfig{High: 0.9, Low: 0.8, Every: time.Second},
+		Suggest: SuggestConfig{Limit: 10, MaxLimit: 50, Budget: 50 * time.Millisecond},
+	}
+}
+
+func (m MiddlewareConfig) FaultConfig() middleware.FaultConfig {
+	return middleware.FaultConfig{
+		Percent: m.FaultPercent,
+		Latency: m.FaultLatency,
+		Errors:  m.FaultErrors,
+		Drops:   m.FaultDrops,
+	}
+}
+
+// LoadConfig resolves the configuration from all sources. Malformed values
+// are reported together rather than one at a time.
+func LoadConfig(args []string) (Config, error) {
+	return loadConfig(os.Args[0], args, nil)
+}
+
+// loadConfig is LoadConfig for a subcommand: extra registers the command's
+// own flags next to the config flags.
+func loadConfig(name string, args []string, extra func(*flag.FlagSet)) (Config, error) {
+	// Flags are parsed twice: once to find -config, and again after the file
+	// and environment are applied so that explicit flags win.
+	cfg := DefaultConfig()
+	path := os.Getenv("CONFIG_FILE")
+	fs := configFlagSet(name, &cfg, &path, extra)
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	cfg = DefaultConfig()
+	var errs []error
+	if path != "" {
+		if err := cfg.applyFile(path); err != nil {
+			return cfg, fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+	errs = append(errs, cfg.applyEnv()...)
+
+	fs = configFlagSet(name, &cfg, &path, extra)
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if fs.NArg() > 0 {
+		errs = append(errs, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " ")))
+	}
+	return cfg, errors.Join(errs...)
+}
+
+func (c Config) Validate() error {
+	var errs []error
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr must not be empty"))
+	}
+	if c.Server.BindRetries < 0 || c.Server.BindBackoff < 0 {
+		errs = append(errs, errors.New("server.bind_retries and bind_backoff must not be negative"))
+	}
+	if c.Server.DrainDelay < 0 {
+		errs = append(errs, fmt.Errorf("server.drain_delay must not be negative, got %s", c.Server.DrainDelay))
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_grace", c.Server.ShutdownGrace},
+		{"server.hook_timeout", c.Server.HookTimeout},
+		{"server.upgrade_timeout", c.Server.UpgradeTimeout},
+		{"store.connect_timeout", c.Store.ConnectTimeout},
+	} {
+		if d.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", d.name, d.value))
+		}
+	}
+	switch c.Store.Backend {
+	case "memory":
+	case "file":
+		if c.Store.Path == "" {
+			errs = append(errs, errors.New("file store backend requires store.path"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown store backend %q", c.Store.Backend))
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("tls.cert_file and tls.key_file must be set together"))
+	}
+	if _, err := parseTLSVersion(c.TLS.MinVersion); err != nil {
+		errs = append(errs, fmt.Errorf("tls.min_version: %w", err))
+	}
+	if c.TLS.RedirectAddr != "" && !c.TLS.Enabled() && !c.ACME.Enabled() {
+		errs = append(errs, errors.New("tls.redirect_addr requires tls or acme to be configured"))
+	}
+	if mode, err := parseClientAuth(c.TLS.ClientAuth); err != nil {
+		errs = append(errs, fmt.Errorf("tls.client_auth: %w", err))
+	} else if mode != tls.NoClientCert {
+		if !c.TLS.Enabled() && !c.ACME.Enabled() {
+			errs = append(errs, errors.New("tls.client_auth requires tls or acme to be configured"))
+		}
+		if c.TLS.ClientCAFile == "" {
+			errs = append(errs, errors.New("tls.client_auth requires tls.client_ca_file"))
+		}
+	} else if len(c.TLS.ClientCertRoutes) > 0 {
+		errs = append(errs, errors.New("tls.client_cert_routes requires tls.client_auth"))
+	}
+	if c.TLS.Enabled() && c.TLS.WatchEvery <= 0 {
+		errs = append(errs, fmt.Errorf("tls.watch_interval must be positive, got %s", c.TLS.WatchEvery))
+	}
+	if c.ACME.Enabled() {
+		if c.TLS.Enabled() {
+			errs = append(errs, errors.New("acme.domains and tls.cert_file are mutually exclusive"))
+		}
+		if c.ACME.CacheDir == "" {
+			errs = append(errs, errors.New("acme.cache_dir must not be empty"))
+		}
+		switch c.ACME.Challenge {
+		case "http-01", "tls-alpn-01":
+		default:
+			errs = append(errs, fmt.Errorf("unknown acme.challenge %q", c.ACME.Challenge))
+		}
+	}
+	if c.HTTP2.MaxConcurrentStreams < 0 {
+		errs = append(errs, fmt.Errorf("http2.max_concurrent_streams must not be negative, got %d", c.HTTP2.MaxConcurrentStreams))
+	}
+	if c.HTTP3.Addr != "" {
+		if !c.TLS.Enabled() && !c.ACME.Enabled() {
+			errs = append(errs, errors.New("http3.addr requires tls or acme to be configured"))
+		}
+		if _, _, err := net.SplitHostPort(c.HTTP3.Addr); err != nil {
+			errs = append(errs, fmt.Errorf("http3.addr: %w", err))
+		}
+	}
+	if c.Unix.Path != "" {
+		if _, err := parseFileMode(c.Unix.Mode); err != nil {
+			errs = append(errs, fmt.Errorf("unix.mode: %w", err))
+		}
+	} else if c.Unix.Only {
+		errs = append(errs, errors.New("unix.only requires unix.path"))
+	}
+	if c.Metrics.Enabled && c.Metrics.Addr == "" && c.Admin.Token == "" {
+		errs = append(errs, errors.New("metrics on the main listener require admin.token (or set metrics.addr)"))
+	}
+	if c.Debug.Enabled() && c.Debug.Addr == "" && c.Admin.Token == "" {
+		errs = append(errs, errors.New("debug endpoints on the main listener require admin.token (or set debug.addr)"))
+	}
+	if p := c.Middleware.MirrorPercent; p < 0 || p > 100 {
+		errs = append(errs, fmt.Errorf("middleware.mirror_percent must be within 0-100, got %g", p))
+	}
+	if p := c.Middleware.FaultPercent; p < 0 || p > 100 {
+		errs = append(errs, fmt.Errorf("middleware.fault_percent must be within 0-100, got %g", p))
+	}
+	switch c.Logging.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("unknown log level %q", c.Logging.Level))
+	}
+	switch c.Logging.Format {
+	case "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("unknown log format %q", c.Logging.Format))
+	}
+	if c.Leader.Lease != "" && c.Leader.TTL < time.Second {
+		errs = append(errs, fmt.Errorf("leader.ttl must be at least 1s, got %s", c.Leader.TTL))
+	}
+	if c.Jobs.Workers < 1 || c.Jobs.QueueSize < 1 || c.Jobs.MaxAttempts < 1 {
+		errs = append(errs, errors.New("jobs.workers, queue_size and max_attempts must be at least 1"))
+	}
+	if c.Jobs.Backoff <= 0 || c.Jobs.Timeout <= 0 {
+		errs = append(errs, errors.New("jobs.backoff and jobs.timeout must be positive"))
+	}
+	if c.Schedule.Snapshot != "" {
+		if _, err := parseCron(c.Schedule.Snapshot); err != nil {
+			errs = append(errs, fmt.Errorf("schedule.snapshot: %w", err))
+		}
+	}
+	if _, err := middleware.ParseFeatures(c.Features.Flags); err != nil {
+		errs = append(errs, fmt.Errorf("features.flags: %w", err))
+	}
+	if c.Health.Timeout <= 0 || c.Health.CacheTTL < 0 || c.Health.MinFreeMB < 0 || c.Health.MaxQueueDepth < 0 {
+		errs = append(errs, errors.New("health.timeout must be positive and cache_ttl, min_free_mb and max_queue_depth not negative"))
+	}
+	if _, err := parseObjectives(c.SLO.Objectives); err != nil {
+		errs = append(errs, fmt.Errorf("slo.objectives: %w", err))
+	}
+	if c.SLO.Window < sloBuckets*time.Second || c.SLO.ShortWindow <= 0 || c.SLO.ShortWindow > c.SLO.Window {
+		errs = append(errs, fmt.Errorf("slo.window must be at least %ds and slo.short_window within it", sloBuckets))
+	}
+	if _, err := parseHMACKeys(c.Signing.Keys); err != nil {
+		errs = append(errs, fmt.Errorf("signing.keys: %w", err))
+	}
+	if c.Signing.Window <= 0 {
+		errs = append(errs, errors.New("signing.window must be positive"))
+	}
+	if c.Signing.Required && !c.Signing.Enabled() {
+		errs = append(errs, errors.New("signing.required needs signing.keys or signing.keys_secret"))
+	}
+	if c.Server.ReadHeaderTimeout < 0 || c.Server.MaxHeaderBytes <= 0 || c.Server.MaxConns < 0 || c.Server.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("server.read_header_timeout, max_conns and max_idle_conns must not be negative and server.max_header_bytes must be positive"))
+	}
+	if !validTimestampFormat(c.Server.Timestamps) {
+		errs = append(errs, fmt.Errorf("server.timestamps must be rfc3339, utc or unix, got %q", c.Server.Timestamps))
+	}
+	if c.Server.JSONCodec != "std" && c.Server.JSONCodec != "fast" {
+		errs = append(errs, fmt.Errorf("server.json_codec must be std or fast, got %q", c.Server.JSONCodec))
+	}
+	if c.Middleware.Decompress && c.Middleware.DecompressMax <= 0 {
+		errs = append(errs, errors.New("middleware.decompress_max_bytes must be positive"))
+	}
+	if c.Middleware.CompressLevel < gzip.HuffmanOnly || c.Middleware.CompressLevel > gzip.BestCompression || c.Middleware.CompressMin < 0 {
+		errs = append(errs, fmt.Errorf("middleware.compress_level must be between %d and %d and compress_min_bytes must not be negative", gzip.HuffmanOnly, gzip.BestCompression))
+	}
+	if c.Server.MaxBodyBytes <= 0 || c.Server.MaxJSONDepth <= 0 {
+		errs = append(errs, errors.New("server.max_body_bytes and server.max_json_depth must be positive"))
+	}
+	if c.RateLimit.Requests < 0 || c.RateLimit.Window <= 0 {
+		errs = append(errs, errors.New("ratelimit.requests must not be negative and ratelimit.window must be positive"))
+	}
+	if _, err := parseRouteLimits(c.RateLimit.Routes); err != nil {
+		errs = append(errs, fmt.Errorf("ratelimit.routes: %w", err))
+	}
+	if _, err := parseRoleScopes(c.APIKeys.Roles); err != nil {
+		errs = append(errs, fmt.Errorf("apikeys.roles: %w", err))
+	}
+	if c.Auth.RevocationRedisURL != "" {
+		if _, err := newRedisClient(c.Auth.RevocationRedisURL, 0); err != nil {
+			errs = append(errs, fmt.Errorf("auth.revocation_redis_url: %w", err))
+		}
+	}
+	if c.RateLimit.RedisURL != "" {
+		if _, err := newRedisClient(c.RateLimit.RedisURL, 0); err != nil {
+			errs = append(errs, fmt.Errorf("ratelimit.redis_url: %w", err))
+		}
+	}
+	if n := c.Notify; n.Provider != "" {
+		if n.Provider != "log" && n.Provider != "smtp" {
+			errs = append(errs, fmt.Errorf("notify.provider %q must be log or smtp", n.Provider))
+		}
+		if _, err := mail.ParseAddress(n.From); err != nil {
+			errs = append(errs, fmt.Errorf("notify.from: %w", err))
+		}
+		if _, _, err := net.SplitHostPort(n.SMTPAddr); n.Provider == "smtp" && err != nil {
+			errs = append(errs, fmt.Errorf("notify.smtp_addr: %w", err))
+		}
+		for _, action := range n.Events {
+			if _, ok := userTemplates[action]; !ok {
+				errs = append(errs, fmt.Errorf("notify.events: %q is not created or updated", action))
+			}
+		}
+	}
+	if p := c.Publish; p.Broker != "" {
+		if p.Broker != "kafka" && p.Broker != "nats" {
+			errs = append(errs, fmt.Errorf("publish.broker %q must be kafka or nats", p.Broker))
+		}
+		if len(p.Addrs) == 0 || p.Topic == "" {
+			errs = append(errs, errors.New("publish.addrs and publish.topic are required"))
+		}
+		if p.Encoding != "json" && p.Encoding != "protobuf" {
+			errs = append(errs, fmt.Errorf("publish.encoding %q must be json or protobuf", p.Encoding))
+		}
+		if p.Buffer <= 0 || p.Timeout <= 0 {
+			errs = append(errs, errors.New("publish.buffer and publish.timeout must be positive"))
+		}
+	}
+	if c.Fanout.RedisURL != "" {
+		if _, err := newRedisClient(c.Fanout.RedisURL, 0); err != nil {
+			errs = append(errs, fmt.Errorf("fanout.redis_url: %w", err))
+		}
+		if c.Fanout.Channel == "" {
+			errs = append(errs, errors.New("fanout.channel is required with fanout.redis_url"))
+		}
+	}
+	if c.Lockout.Threshold < 0 || c.Lockout.Threshold > 0 && (c.Lockout.Window <= 0 || c.Lockout.Base <= 0 || c.Lockout.Max < c.Lockout.Base) {
+		errs = append(errs, errors.New("lockout.window and lockout.base must be positive and lockout.max at least lockout.base"))
+	}
+	if _, err := parseSessionKeys(c.Session.Keys); err != nil {
+		errs = append(errs, fmt.Errorf("session.keys: %w", err))
+	}
+	if c.Session.Enabled() && (c.Session.CookieName == "" || c.Session.MaxAge <= 0) {
+		errs = append(errs, errors.New("session.cookie_name must be set and session.max_age positive"))
+	}
+	if p := c.Chaos.ErrorPercent; p < 0 || p > 100 {
+		errs = append(errs, fmt.Errorf("chaos.error_percent must be within 0-100, got %g", p))
+	}
+	if c.Store.BatchMax < 0 || c.Store.BatchDelay < 0 {
+		errs = append(errs, errors.New("store.batch_max and store.batch_delay must not be negative"))
+	}
+	if c.Suggest.Limit <= 0 || c.Suggest.MaxLimit < c.Suggest.Limit || c.Suggest.Budget <= 0 {
+		errs = append(errs, errors.New("suggest.limit and suggest.budget must be positive and suggest.max_limit at least suggest.limit"))
+	}
+	if c.Changes.Retain <= 0 || c.Changes.MaxWait < 0 {
+		errs = append(errs, errors.New("changes.retain must be positive and changes.max_wait not negative"))
+	}
+	if c.Retention.Schedule != "" {
+		if _, err := parseCron(c.Retention.Schedule); err != nil {
+			errs = append(errs, fmt.Errorf("retention.schedule: %w", err))
+		}
+	}
+	if _, err := parseRetentionRules(c.Retention.Rules); err != nil {
+		errs = append(errs, fmt.Errorf("retention.rules: %w", err))
+	}
+	if _, err := parseQuotas(c.Quotas.Limits); err != nil {
+		errs = append(errs, fmt.Errorf("quotas.limits: %w", err))
+	}
+	if c.Heavy.Workers < 0 || c.Heavy.Queue < 0 || c.Heavy.QueueTimeout <= 0 {
+		errs = append(errs, errors.New("heavy.workers and heavy.queue must not be negative and heavy.queue_timeout must be positive"))
+	}
+	if c.QoS.MaxInFlight < 0 || c.QoS.Queue < 0 || c.QoS.QueueTimeout <= 0 {
+		errs = append(errs, errors.New("qos.max_in_flight and qos.queue must not be negative and qos.queue_timeout must be positive"))
+	}
+	if m := c.Memory; m.LimitBytes < 0 || m.Low <= 0 || m.Low >= m.High || m.High > 1 || m.Every <= 0 {
+		errs = append(errs, errors.New("memory.limit_bytes must not be negative, memory.low and memory.high must satisfy 0 < low < high <= 1 and memory.every must be positive"))
+	}
+	if c.Chaos.StoreLatency < 0 || c.Chaos.ShutdownJitter < 0 {
+		errs = append(errs, errors.New("chaos.store_latency and chaos.shutdown_jitter must not be negative"))
+	}
+	if c.Audit.Keep <= 0 {
+		errs = append(errs, errors.New("audit.keep must be positive"))
+	}
+	if err := validateSecrets(c); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := parseSigningKeys(c.Auth.SigningKeys); err != nil {
+		errs = append(errs, fmt.Errorf("auth.signing_keys: %w", err))
+	}
+	if c.Auth.AccessTTL <= 0 || c.Auth.RefreshTTL < c.Auth.AccessTTL {
+		errs = append(errs, errors.New("auth.access_ttl must be positive and auth.refresh_ttl at least as long"))
+	}
+	if err := validateProfiling(c.Profiling); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateLogSinks(c.Logging); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Logging.MaxSizeMB < 0 || c.Logging.MaxBackups < 0 || c.Logging.MaxAge < 0 {
+		errs = append(errs, errors.New("logging.max_size_mb, max_age and max_backups must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Redacted renders the resolved config one key per line.
+func (c Config) Redacted() string {
+	var b strings.Builder
+	for _, attr := range c.RedactedAttrs() {
+		a := attr.(slog.Attr)
+		fmt.Fprintf(&b, "  %s = %v\n", a.Key, a.Value)
+	}
+	return b.String()
+}
+
+// RedactedAttrs returns the resolved config as log attributes for the
+// startup log, with secrets masked.
+func (c Config) RedactedAttrs() []any {
+	var attrs []any
+	c.eachField(func(f configField) {
+		value := f.value.Interface()
+		if f.secret && !f.value.IsZero() {
+			value = redactSecret(fmt.Sprint(value))
+		}
+		attrs = append(attrs, slog.Any(f.name, value))
+	})
+	return attrs
+}
+
+// >> URLs keep their host visible so operators can still tell targets apart
+func redactSecret(s string) string {
+	if u, err := url.Parse(s); err == nil && u.Host != "" {
+		if u.User != nil {
+			u.User = url.User("REDACTED")
+		}
+		u.RawQuery = ""
+		return u.String()
+	}
+	return middleware.Redacted
+}
+
+type configField struct {
+	name   string
+	env    string
+	flag   string
+	secret bool
+	reload bool
+	value  reflect.Value
+}
+
+func (c *Config) eachField(fn func(configField)) {
+	root := reflect.ValueOf(c).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Type().Field(i).Tag.Get("toml")
+		sv := root.Field(i)
+		for j := 0; j < sv.NumField(); j++ {
+			tf := sv.Type().Field(j)
+			key := tf.Tag.Get("toml")
+			f := configField{
+				name:   section + "." + key,
+				env:    strings.ToUpper(section + "_" + key),
+				flag:   strings.ReplaceAll(key, "_", "-"),
+				secret: tf.Tag.Get("secret") == "true",
+				reload: tf.Tag.Get("reload") == "true",
+				value:  sv.Field(j),
+			}
+			if env := tf.Tag.Get("env"); env != "" {
+				f.env = env
+			}
+			if name := tf.Tag.Get("flag"); name != "" {
+				f.flag = name
+			}
+			fn(f)
+		}
+	}
+}
+
+func (c *Config) applyFile(path string) error {
+	fh, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	doc, err := parseTOML(fh)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	seen := map[string]bool{}
+	c.eachField(func(f configField) {
+		section, key, _ := strings.Cut(f.name, ".")
+		raw, ok := doc[section][key]
+		if !ok {
+			return
+		}
+		seen[f.name] = true
+		if err := setConfigValue(f.value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.name, err))
+		}
+	})
+	for section, keys := range doc {
+		for key := range keys {
+			if !seen[section+"."+key] {
+				errs = append(errs, fmt.Errorf("unknown key %s.%s", section, key))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) applyEnv() []error {
+	var errs []error
+	c.eachField(func(f configField) {
+		if raw, ok := os.LookupEnv(f.env); ok {
+			if err := setConfigValue(f.value, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", f.env, err))
+			}
+		}
+	})
+	return errs
+}
+
+func configFlagSet(name string, c *Config, path *string, extra func(*flag.FlagSet)) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(path, "config", *path, "path to a TOML config file")
+	c.eachField(func(f configField) {
+		fs.Var(configFlag{f.value}, f.flag, "sets "+f.name+" (env "+f.env+")")
+	})
+	if extra != nil {
+		extra(fs)
+	}
+	return fs
+}
+
+type configFlag struct{ v reflect.Value }
+
+func (f configFlag) String() string {
+	if !f.v.IsValid() {
+		return ""
+	}
+	if f.v.Kind() == reflect.Slice {
+		return strings.Join(f.v.Interface().([]string), ",")
+	}
+	return fmt.Sprint(f.v.Interface())
+}
+
+func (f configFlag) Set(s string) error { return setConfigValue(f.v, s) }
+
+func (f configFlag) IsBoolFlag() bool { return f.v.IsValid() && f.v.Kind() == reflect.Bool }
+
+func setConfigValue(v reflect.Value, raw interface{}) error {
+	if items, ok := raw.([]string); ok {
+		if v.Kind() != reflect.Slice {
+			return fmt.Errorf("unexpected array")
+		}
+		v.Set(reflect.ValueOf(items))
+		return nil
+	}
+
+	s := raw.(string)
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case v.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case v.Kind() == reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestConfigNames keeps every field's flag and environment variable
+// distinct; a repeated flag panics configFlagSet in every subcommand.
+func TestConfigNames(t *testing.T) {
+	var c Config
+	flags, envs := map[string]string{}, map[string]string{}
+	c.eachField(func(f configField) {
+		if other, ok := flags[f.flag]; ok {
+			t.Errorf("%s and %s share flag -%s", other, f.name, f.flag)
+		}
+		if other, ok := envs[f.env]; ok {
+			t.Errorf("%s and %s share env %s", other, f.name, f.env)
+		}
+		flags[f.flag], envs[f.env] = f.name, f.name
+	})
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	cfg, err := loadConfig("test", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := DefaultConfig(); cfg.Server.Addr != want.Server.Addr {
+		t.Errorf("server.addr = %q, want the default %q", cfg.Server.Addr, want.Server.Addr)
+	}
+}
+
+// lookupConfigField returns the field of c named like "server.addr".
+func lookupConfigField(t *testing.T, c *Config, name string) configField {
+	t.Helper()
+	var found *configField
+	c.eachField(func(f configField) {
+		if f.name == name {
+			found = &f
+		}
+	})
+	if found == nil {
+		t.Fatalf("no config field %s", name)
+	}
+	return *found
+}
+
+// TestConfigSources sets each key from the file, the environment and a
+// flag in turn.
+func TestConfigSources(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"server.max_body_bytes", "12345"},
+		{"middleware.decompress_max_bytes", "67890"},
+		{"memory.limit_bytes", "1073741824"},
+	}
+	for _, tt := range tests {
+		section, key, _ := strings.Cut(tt.name, ".")
+		f := lookupConfigField(t, &Config{}, tt.name)
+		sources := []struct {
+			source string
+			setup  func(t *testing.T) []string
+		}{
+			{"file", func(t *testing.T) []string {
+				path := filepath.Join(t.TempDir(), "config.toml")
+				if err := os.WriteFile(path, []byte("["+section+"]\n"+key+" = "+tt.value+"\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("CONFIG_FILE", path)
+				return nil
+			}},
+			{"env", func(t *testing.T) []string {
+				t.Setenv(f.env, tt.value)
+				return nil
+			}},
+			{"flag", func(t *testing.T) []string {
+				return []string{"-" + f.flag + "=" + tt.value}
+			}},
+		}
+		for _, src := range sources {
+			t.Run(tt.name+"/"+src.source, func(t *testing.T) {
+				t.Setenv("CONFIG_FILE", "")
+				cfg, err := loadConfig("test", src.setup(t), nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := fmt.Sprint(lookupConfigField(t, &cfg, tt.name).value.Interface()); got != tt.value {
+					t.Errorf("%s = %s, want %s", tt.name, got, tt.value)
+				}
+			})
+		}
+	}
+}
+
+// TestConfigPrecedence layers the sources for one key: the file beats the
+// defaults, the environment beats the file and a flag beats them all.
+func TestConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("[server]\naddr = \":1001\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f := lookupConfigField(t, &Config{}, "server.addr")
+	tests := []struct {
+		name      string
+		file, env bool
+		args      []string
+		want      string
+	}{
+		{"defaults", false, false, nil, DefaultConfig().Server.Addr},
+		{"file", true, false, nil, ":1001"},
+		{"env over file", true, true, nil, ":1002"},
+		{"flag over env", true, true, []string{"-" + f.flag, ":1003"}, ":1003"},
+		{"flag over file", true, false, []string{"-" + f.flag, ":1003"}, ":1003"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", "")
+			t.Setenv(f.env, "") // restores any outside value afterwards
+			os.Unsetenv(f.env)
+			if tt.file {
+				t.Setenv("CONFIG_FILE", path)
+			}
+			if tt.env {
+				t.Setenv(f.env, ":1002")
+			}
+			cfg, err := loadConfig("test", tt.args, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Server.Addr != tt.want {
+				t.Errorf("server.addr = %q, want %q", cfg.Server.Addr, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net"
+	"sync"
+)
+
+// connLimit caps the connections open at once across every listener that
+// shares it, like x/net/netutil.LimitListener. At the cap Accept waits, so
+// new clients queue in the kernel backlog instead of being refused.
+type connLimit chan struct{}
+
+func newConnLimit(n int) connLimit {
+	if n <= 0 {
+		return nil
+	}
+	return make(connLimit, n)
+}
+
+// wrap returns ln unchanged when there is no limit.
+func (l connLimit) wrap(ln net.Listener) net.Listener {
+	if l == nil {
+		return ln
+	}
+	return &limitListener{Listener: ln, sem: l, done: make(chan struct{})}
+}
+
+type limitListener struct {
+	net.Listener
+	sem       connLimit
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	select {
+	case l.sem <- struct{}{}:
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+	c, err := l.Listener.Accept()
+	if err != nil {
+		<-l.sem
+		return nil, err
+	}
+	return &limitConn{Conn: c, release: func() { <-l.sem }}, nil
+}
+
+// Close also wakes an Accept waiting for a free slot, so Shutdown isn't
+// held up by a full server.
+func (l *limitListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+type limitConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
@@ -0,0 +1,467 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiBody describes a response body for the OpenAPI document: a JSON
+// schema, plain text as http.Error writes it, or either when the status
+// comes from code paths that differ.
+type apiBody struct {
+	schema map[string]any
+	text   bool
+}
+
+var (
+	errorBody   = apiBody{schema: envelopeSchema(nil, false, true)}
+	textBody    = apiBody{text: true}
+	errorOrText = apiBody{schema: errorBody.schema, text: true}
+)
+
+// envelope is a successful Response carrying data, or no data when nil.
+// A list also documents next_cursor.
+func envelope(data any) apiBody {
+	var t reflect.Type
+	if data != nil {
+		t = reflect.TypeOf(data)
+	}
+	return apiBody{schema: envelopeSchema(t, t != nil && t.Kind() == reflect.Slice, false)}
+}
+
+func envelopeSchema(data reflect.Type, list, failure bool) map[string]any {
+	props := map[string]any{
+		"success":  map[string]any{"type": "boolean"},
+		"trace_id": map[string]any{"type": "string"},
+	}
+	required := []string{"success"}
+	if data != nil {
+		props["data"] = schemaOf(data)
+	}
+	if list {
+		props["next_cursor"] = map[string]any{"type": "string"}
+	}
+	if failure {
+		props["error"] = map[string]any{"type": "string"}
+		props["code"] = map[string]any{"type": "string"}
+		required = append(required, "error", "code")
+	}
+	return map[string]any{"type": "object", "properties": props, "required": required, "additionalProperties": false}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf derives a schema from t as encoding/json would encode it.
+// Objects forbid additional properties so the contract check reports
+// fields a handler sends but the document doesn't name.
+func schemaOf(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem()), "nullable": t.Kind() == reflect.Slice}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Struct:
+		props := map[string]any{}
+		var required []string
+		addFields(t, props, &required)
+		sort.Strings(required)
+		return map[string]any{"type": "object", "properties": props, "required": required, "additionalProperties": false}
+	}
+	return map[string]any{}
+}
+
+func addFields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			addFields(f.Type, props, required)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = schemaOf(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func (b apiBody) content() map[string]any {
+	content := map[string]any{}
+	if b.schema != nil {
+		content["application/json"] = map[string]any{"schema": b.schema}
+	}
+	if b.text {
+		content["text/plain"] = map[string]any{"schema": map[string]any{"type": "string"}}
+	}
+	return content
+}
+
+// middlewareStatuses can answer any route before its handler runs: auth,
+// scopes, lockouts and rate limits, recovery, startup and draining.
+var middlewareStatuses = []int{
+	http.StatusUnauthorized,
+	http.StatusForbidden,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusServiceUnavailable,
+}
+
+func openAPIResponses(declared map[int]apiBody) map[string]any {
+	all := map[int]apiBody{}
+	for _, status := range middlewareStatuses {
+		all[status] = errorOrText
+	}
+	for status, body := range declared {
+		all[status] = body
+	}
+	responses := map[string]any{}
+	for status, body := range all {
+		resp := map[string]any{"description": http.StatusText(status)}
+		if content := body.content(); len(content) > 0 {
+			resp["content"] = content
+		}
+		responses[strconv.Itoa(status)] = resp
+	}
+	return responses
+}
+
+// checkSchema reports where v, decoded JSON, departs from schema, with at
+// naming the position, e.g. "data[2].email".
+func checkSchema(schema map[string]any, v any, at string) []string {
+	if v == nil {
+		if schema["nullable"] == true || schema["type"] == nil {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: null, want %v", at, schema["type"])}
+	}
+	mismatch := func(want string) []string {
+		return []string{fmt.Sprintf("%s: got %s, want %s", at, jsonKind(v), want)}
+	}
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return mismatch("object")
+		}
+		var problems []string
+		props, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing field %q", at, name))
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if ps, ok := props[k].(map[string]any); ok {
+				problems = append(problems, checkSchema(ps, obj[k], at+"."+k)...)
+			} else if ap, ok := schema["additionalProperties"].(map[string]any); ok {
+				problems = append(problems, checkSchema(ap, obj[k], at+"."+k)...)
+			} else if schema["additionalProperties"] == false {
+				problems = append(problems, fmt.Sprintf("%s: undocumented field %q", at, k))
+			}
+		}
+		return problems
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return mismatch("array")
+		}
+		items, _ := schema["items"].(map[string]any)
+		var problems []string
+		for i, item := range arr {
+			problems = append(problems, checkSchema(items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+		return problems
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return mismatch("string")
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				return []string{fmt.Sprintf("%s: %q is not a date-time", at, s)}
+			}
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != math.Trunc(n) {
+			return mismatch("integer")
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return mismatch("number")
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return mismatch("boolean")
+		}
+	}
+	return nil
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
+
+// matchOpenAPIPath finds the documented path for a request path: an exact
+// entry, or a "/prefix/{id}" template whose prefix it extends.
+func matchOpenAPIPath(paths map[string]any, path string) (string, bool) {
+	if _, ok := paths[path]; ok {
+		return path, true
+	}
+	for tmpl := range paths {
+		if prefix, ok := strings.CutSuffix(tmpl, "{id}"); ok {
+			if _, ok := pathParam(path, prefix); ok {
+				return tmpl, true
+			}
+		}
+	}
+	return "", false
+}
+
+// checkResponse compares one exchange with the document.
+func checkResponse(doc map[string]any, method, path string, status int, body []byte) []string {
+	paths, _ := doc["paths"].(map[string]any)
+	tmpl, ok := matchOpenAPIPath(paths, path)
+	if !ok {
+		return []string{"undocumented path " + path}
+	}
+	item, _ := paths[tmpl].(map[string]any)
+	op, ok := item[strings.ToLower(method)].(map[string]any)
+	if !ok {
+		return []string{fmt.Sprintf("undocumented operation %s %s", method, tmpl)}
+	}
+	responses, _ := op["responses"].(map[string]any)
+	resp, ok := responses[strconv.Itoa(status)].(map[string]any)
+	if !ok {
+		return []string{fmt.Sprintf("undocumented status %d for %s %s", status, method, tmpl)}
+	}
+	content, _ := resp["content"].(map[string]any)
+	body = bytes.TrimSpace(body)
+	switch {
+	case len(body) == 0:
+		if len(content) > 0 {
+			return []string{"empty body, want one"}
+		}
+	case json.Valid(body):
+		media, ok := content["application/json"].(map[string]any)
+		if !ok {
+			return []string{"JSON body where none is documented"}
+		}
+		var v any
+		json.Unmarshal(body, &v)
+		schema, _ := media["schema"].(map[string]any)
+		return checkSchema(schema, v, "body")
+	default:
+		if _, ok := content["text/plain"]; !ok {
+			return []string{"text body where JSON is documented"}
+		}
+	}
+	return nil
+}
+
+var contractOpts struct {
+	target   string
+	requests string
+	token    string
+	tenant   string
+	insecure bool
+}
+
+func contractFlags(fs *flag.FlagSet) {
+	fs.StringVar(&contractOpts.target, "target", "", "base URL to check (default: this config's server.addr)")
+	fs.StringVar(&contractOpts.requests, "requests", "", "JSON Lines file of requests to replay (default: a built-in users round trip)")
+	fs.StringVar(&contractOpts.token, "token", "", "bearer token sent with every request")
+	fs.StringVar(&contractOpts.tenant, "tenant", "", "X-Tenant-ID sent with every request")
+	fs.BoolVar(&contractOpts.insecure, "insecure", false, "skip TLS certificate verification")
+}
+
+// contractCase is one line of the -requests file. Status, when recorded,
+// must also match.
+type contractCase struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+	Status int             `json:"status,omitempty"`
+}
+
+func defaultContractCases() []contractCase {
+	id := fmt.Sprintf("contract-%d", time.Now().UnixNano())
+	user := json.RawMessage(fmt.Sprintf(`{"id":%q,"name":"Contract Check","email":"contract@example.com"}`, id))
+	return []contractCase{
+		{Method: http.MethodGet, Path: "/health", Status: http.StatusOK},
+		{Method: http.MethodPost, Path: "/users", Body: user, Status: http.StatusCreated},
+		{Method: http.MethodPost, Path: "/users", Body: json.RawMessage(`{"id":1}`), Status: http.StatusUnprocessableEntity},
+		{Method: http.MethodGet, Path: "/users", Status: http.StatusOK},
+		{Method: http.MethodGet, Path: "/users?limit=1", Status: http.StatusOK},
+		{Method: http.MethodGet, Path: "/users?limit=x", Status: http.StatusBadRequest},
+		{Method: http.MethodGet, Path: "/users/" + id, Status: http.StatusOK},
+		{Method: http.MethodDelete, Path: "/users/" + id, Status: http.StatusOK},
+		{Method: http.MethodGet, Path: "/users/" + id, Status: http.StatusNotFound},
+	}
+}
+
+func readContractCases(path string) ([]contractCase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var cases []contractCase
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var c contractCase
+		if err := json.Unmarshal(sc.Bytes(), &c); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if c.Method == "" || !strings.HasPrefix(c.Path, "/") {
+			return nil, fmt.Errorf("%s:%d: want method and a path starting with /", path, line)
+		}
+		cases = append(cases, c)
+	}
+	return cases, sc.Err()
+}
+
+// runContract replays requests against a running instance and checks each
+// response against the /openapi.json that instance serves, failing on
+// undocumented paths, statuses and fields and on schema drift.
+func runContract(cfg Config, _ []string) error {
+	cases := defaultContractCases()
+	if contractOpts.requests != "" {
+		var err error
+		if cases, err = readContractCases(contractOpts.requests); err != nil {
+			return err
+		}
+	}
+	target := contractOpts.target
+	if target == "" {
+		target = benchTarget(cfg)
+	}
+	target = strings.TrimSuffix(target, "/")
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if contractOpts.insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	client := &http.Client{Transport: transport, Timeout: 10 * time.Second}
+
+	status, body, err := contractRequest(client, target, contractCase{Method: http.MethodGet, Path: "/openapi.json"})
+	if err != nil {
+		return fmt.Errorf("fetch document: %w", err)
+	}
+	var doc map[string]any
+	if status != http.StatusOK || json.Unmarshal(body, &doc) != nil {
+		return fmt.Errorf("fetch document: %s/openapi.json returned %d", target, status)
+	}
+
+	failed := 0
+	for _, c := range cases {
+		status, body, err := contractRequest(client, target, c)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", c.Method, c.Path, err)
+		}
+		path, _, _ := strings.Cut(c.Path, "?")
+		problems := checkResponse(doc, c.Method, path, status, body)
+		if c.Status != 0 && c.Status != status {
+			problems = append(problems, fmt.Sprintf("status %d, recorded %d", status, c.Status))
+		}
+		if len(problems) == 0 {
+			fmt.Printf("ok    %s %s -> %d\n", c.Method, c.Path, status)
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL  %s %s -> %d\n", c.Method, c.Path, status)
+		for _, p := range problems {
+			fmt.Printf("      %s\n", p)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d requests broke the contract", failed, len(cases))
+	}
+	fmt.Printf("%d requests match the contract\n", len(cases))
+	return nil
+}
+
+func contractRequest(client *http.Client, target string, c contractCase) (int, []byte, error) {
+	var body io.Reader
+	if len(c.Body) > 0 {
+		body = bytes.NewReader(c.Body)
+	}
+	req, err := http.NewRequest(c.Method, target+c.Path, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if contractOpts.token != "" {
+		req.Header.Set("Authorization", "Bearer "+contractOpts.token)
+	}
+	if contractOpts.tenant != "" {
+		req.Header.Set("X-Tenant-ID", contractOpts.tenant)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return 0, nil, fmt.Errorf("read body: %w", err)
+	}
+	return resp.StatusCode, data, nil
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"errors"
+	"sync"
+)
+
+// minPasswordLength is the shortest password SetPassword accepts.
+const minPasswordLength = 8
+
+// errNoArgon2 is returned by every password operation in builds without
+// the argon2 tag.
+var errNoArgon2 = errors.New("password storage not compiled in (rebuild with -tags argon2)")
+
+// ErrNoPassword is returned by VerifyPassword for users without credentials.
+var ErrNoPassword = errors.New("user has no password")
+
+// SetPassword hashes password into user.PasswordHash. The plaintext is not
+// kept anywhere.
+func SetPassword(user *User, password string) error {
+	if len(password) < minPasswordLength {
+		return errors.New("password must be at least 8 characters")
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	return nil
+}
+
+// dummyHash stands in for a user without a password, such as the zero
+// User of an unknown username, so checking one costs as much as a wrong
+// password and login times don't tell which accounts exist.
+var dummyHash = sync.OnceValues(func() (string, error) { return hashPassword(randomHex(16)) })
+
+// VerifyPassword reports whether password matches the user's stored hash.
+// A mismatch is (false, nil); an error means the hash couldn't be checked.
+func VerifyPassword(user User, password string) (bool, error) {
+	if user.PasswordHash == "" {
+		hash, err := dummyHash()
+		if err != nil {
+			return false, err
+		}
+		verifyPassword(hash, password)
+		return false, ErrNoPassword
+	}
+	return verifyPassword(user.PasswordHash, password)
+}
@@ -0,0 +1,287 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cronSchedule is a parsed five-field cron expression (minute hour
+// day-of-month month day-of-week) held as bitmasks, or a fixed interval for
+// "@every <duration>".
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+	every                         time.Duration
+}
+
+var cronAliases = map[string]string{
+	"@yearly":  "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly":  "0 0 * * 0",
+	"@daily":   "0 0 * * *",
+	"@hourly":  "0 * * * *",
+}
+
+func parseCron(spec string) (cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d <= 0 {
+			return cronSchedule{}, fmt.Errorf("cron %q: invalid interval", spec)
+		}
+		return cronSchedule{every: d}, nil
+	}
+	if alias, ok := cronAliases[spec]; ok {
+		spec = alias
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return cronSchedule{}, fmt.Errorf("cron %q: want 5 fields, got %d", spec, len(fields))
+	}
+	var c cronSchedule
+	var err error
+	bounds := []struct {
+		dst      *uint64
+		min, max int
+	}{{&c.minute, 0, 59}, {&c.hour, 0, 23}, {&c.dom, 1, 31}, {&c.month, 1, 12}, {&c.dow, 0, 6}}
+	for i, b := range bounds {
+		if *b.dst, err = parseCronField(fields[i], b.min, b.max); err != nil {
+			return cronSchedule{}, fmt.Errorf("cron %q: %w", spec, err)
+		}
+	}
+	c.domStar, c.dowStar = fields[2] == "*", fields[4] == "*"
+	return c, nil
+}
+
+// parseCronField handles "*", "n", "a-b" and "/step" on either, joined by commas.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var mask uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("bad value in %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("bad range in %q", part)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			mask |= 1 << v
+		}
+	}
+	return mask, nil
+}
+
+func (c cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	// Classic cron: when both day fields are restricted, either may match.
+	if !c.domStar && !c.dowStar {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// Next returns the first activation strictly after t, or the zero time if
+// the expression can never fire (such as February 30th).
+func (c cronSchedule) Next(t time.Time) time.Time {
+	if c.every > 0 {
+		return t.Add(c.every)
+	}
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		switch {
+		case c.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// TaskStatus is the last-run record reported on /debug/vars.
+type TaskStatus struct {
+	Spec         string    `json:"spec"`
+	Next         time.Time `json:"next"`
+	LastStart    time.Time `json:"last_start,omitempty"`
+	LastDuration string    `json:"last_duration,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+	Runs         int64     `json:"runs"`
+	Skipped      int64     `json:"skipped_overlap"`
+}
+
+type scheduledTask struct {
+	name       string
+	schedule   cronSchedule
+	leaderOnly bool
+	fn         func(ctx context.Context) error
+	running    atomic.Bool
+
+	mu     sync.Mutex
+	status TaskStatus
+}
+
+// Scheduler runs registered tasks on cron expressions. Each activation is
+// delayed by a random jitter so a fleet doesn't fire in lockstep, and an
+// activation is skipped while the previous run is still going.
+type Scheduler struct {
+	jitter  time.Duration
+	srv     *Server
+	running bool
+
+	mu    sync.Mutex
+	tasks []*scheduledTask
+}
+
+// Schedule registers fn under name. leaderOnly tasks run only on the
+// instance holding the leader lease. Tasks added after Start begin at
+// once.
+func (s *Server) Schedule(name, spec string, leaderOnly bool, fn func(ctx context.Context) error) error {
+	sched, err := parseCron(spec)
+	if err != nil {
+		return err
+	}
+	if sched.every == 0 && sched.Next(time.Now()).IsZero() {
+		return fmt.Errorf("cron %q never fires", spec)
+	}
+	task := &scheduledTask{name: name, schedule: sched, leaderOnly: leaderOnly, fn: fn}
+	task.status.Spec = spec
+	s.scheduler.mu.Lock()
+	defer s.scheduler.mu.Unlock()
+	s.scheduler.tasks = append(s.scheduler.tasks, task)
+	if s.scheduler.running {
+		s.scheduler.supervise(task)
+	}
+	return nil
+}
+
+func (sc *Scheduler) Start() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.running = true
+	for _, task := range sc.tasks {
+		sc.supervise(task)
+	}
+}
+
+func (sc *Scheduler) supervise(task *scheduledTask) {
+	sc.srv.supervisor.Go("schedule/"+task.name, func() { sc.loop(task) })
+}
+
+func (sc *Scheduler) loop(task *scheduledTask) {
+	for {
+		next := task.schedule.Next(time.Now())
+		task.mu.Lock()
+		task.status.Next = next
+		task.mu.Unlock()
+
+		wait := time.Until(next)
+		if sc.jitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(sc.jitter)))
+		}
+		select {
+		case <-sc.srv.done:
+			return
+		case <-time.After(wait):
+		}
+		if task.leaderOnly && !sc.srv.elector.IsLeader() {
+			continue
+		}
+		if !task.running.CompareAndSwap(false, true) {
+			task.mu.Lock()
+			task.status.Skipped++
+			task.mu.Unlock()
+			sc.srv.logger.Warn("scheduled task still running, skipping", "task", task.name)
+			continue
+		}
+		go sc.run(task, next)
+	}
+}
+
+func (sc *Scheduler) run(task *scheduledTask, deadline time.Time) {
+	defer task.running.Store(false)
+	start := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-sc.srv.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	err := sc.srv.supervisor.Protect("task/"+task.name, func() error { return task.fn(ctx) })
+
+	task.mu.Lock()
+	defer task.mu.Unlock()
+	task.status.Runs++
+	task.status.LastStart = start
+	task.status.LastDuration = time.Since(start).String()
+	task.status.LastError = ""
+	if err != nil {
+		task.status.LastError = err.Error()
+		sc.srv.logger.Error("scheduled task failed", "task", task.name, "err", err)
+	}
+}
+
+func (sc *Scheduler) statuses() map[string]TaskStatus {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	out := make(map[string]TaskStatus, len(sc.tasks))
+	for _, task := range sc.tasks {
+		task.mu.Lock()
+		out[task.name] = task.status
+		task.mu.Unlock()
+	}
+	return out
+}
+
+// scheduleMaintenance registers the built-in periodic tasks.
+func (s *Server) scheduleMaintenance(cfg ScheduleConfig) error {
+	if cfg.Snapshot == "" {
+		return nil
+	}
+	// Each instance snapshots its own store, so this is not leader-only.
+	return s.Schedule("snapshot", cfg.Snapshot, false, func(context.Context) error {
+		if !s.started.Load() {
+			return nil
+		}
+		if f, ok := s.store.(interface{ Flush() error }); ok {
+			return f.Flush()
+		}
+		return nil
+	})
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type bodyLimits struct {
+	maxBytes int64
+	maxDepth int
+}
+
+type bodyLimitsKey struct{}
+
+// BodyLimitsMiddleware makes server.max_body_bytes and max_json_depth
+// available to decodeJSON, so a reload applies them to the next request.
+func BodyLimitsMiddleware(cfg ServerConfig) Middleware {
+	limits := bodyLimits{maxBytes: cfg.MaxBodyBytes, maxDepth: cfg.MaxJSONDepth}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), bodyLimitsKey{}, limits)))
+		})
+	}
+}
+
+func bodyLimitsFrom(ctx context.Context) bodyLimits {
+	if limits, ok := ctx.Value(bodyLimitsKey{}).(bodyLimits); ok {
+		return limits
+	}
+	d := DefaultConfig().Server
+	return bodyLimits{maxBytes: d.MaxBodyBytes, maxDepth: d.MaxJSONDepth}
+}
+
+// decodeError is a rejected body with the status to answer it with: 400
+// when the body isn't usable JSON, 413 when it is too large and 422 when
+// it is JSON that doesn't fit v.
+type decodeError struct {
+	status int
+	*apiError
+}
+
+func newDecodeError(status int, code string, args ...any) *decodeError {
+	return &decodeError{status, newAPIError(code, args...)}
+}
+
+// decodeJSON reads exactly one JSON document from r's body into v. Unknown
+// fields, trailing data and nesting beyond the depth limit are rejected.
+// On failure it has already written the error response and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := readJSON(w, r, v)
+	if err == nil {
+		return true
+	}
+	var de *decodeError
+	if !errors.As(err, &de) {
+		de = newDecodeError(http.StatusBadRequest, "invalid_body")
+	}
+	LoggerFrom(r.Context()).Debug("rejected request body", "status", de.status, "err", err)
+	renderJSON(w, r, de.status, errorResponse(r.Context(), de.code, de.args...))
+	return false
+}
+
+// unprocessable writes a 422 for a body that decoded but failed
+// validation.
+func unprocessable(w http.ResponseWriter, r *http.Request, code string, args ...any) {
+	renderJSON(w, r, http.StatusUnprocessableEntity, errorResponse(r.Context(), code, args...))
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	limits := bodyLimitsFrom(r.Context())
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limits.maxBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return newDecodeError(http.StatusRequestEntityTooLarge, "body_too_large", limits.maxBytes)
+	}
+	if err != nil {
+		return err
+	}
+	return parseJSON(body, limits.maxDepth, v)
+}
+
+// parseJSON applies every body rule except the size limit to an
+// already-read body. It has no request or writer to set up, so it can be
+// fuzzed directly, and must not panic on any input.
+func parseJSON(body []byte, maxDepth int, v any) error {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return newDecodeError(http.StatusBadRequest, "body_empty")
+	}
+	if depth := jsonDepth(body); depth > maxDepth {
+		return newDecodeError(http.StatusBadRequest, "json_too_deep", maxDepth)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return describeDecodeError(err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return newDecodeError(http.StatusBadRequest, "json_trailing_data")
+	}
+	return nil
+}
+
+func describeDecodeError(err error) error {
+	var syntax *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntax):
+		return newDecodeError(http.StatusBadRequest, "json_malformed", syntax.Offset)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return newDecodeError(http.StatusBadRequest, "json_truncated")
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return newDecodeError(http.StatusUnprocessableEntity, "json_wrong_type", typeErr.Type)
+		}
+		return newDecodeError(http.StatusUnprocessableEntity, "field_wrong_type", typeErr.Field, typeErr.Type)
+	}
+	// >> encoding/json has no typed error for unknown fields; its message already names the field
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return newDecodeError(http.StatusUnprocessableEntity, "unknown_field", field)
+	}
+	return newDecodeError(http.StatusBadRequest, "json_invalid")
+}
+
+// jsonDepth returns the deepest object or array nesting in data, skipping
+// brackets inside strings. It runs before decoding so a deeply nested body
+// is refused without building it.
+func jsonDepth(data []byte) int {
+	depth, deepest := 0, 0
+	inString, escaped := false, false
+	for _, c := range data {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch c {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+			if depth > deepest {
+				deepest = depth
+			}
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return deepest
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func FuzzParseJSON(f *testing.F) {
+	for _, seed := range []string{
+		`{"id":"u1","name":"Ada","email":"ada@example.com","password":"hunter22"}`,
+		`{"name":"Ada"} {"name":"Grace"}`,
+		`{"name":1}`,
+		`{"nickname":"x"}`,
+		`[[[[[[[[[[]]]]]]]]]]`,
+		`{"name":"\"}[{"}`,
+		`{"name":"Ada"`,
+		"  \n\t",
+		"",
+		`null`,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var in userInput
+		err := parseJSON(body, 8, &in)
+		if err == nil {
+			return
+		}
+		// Every rejection must carry the status and code clients get.
+		var de *decodeError
+		if !errors.As(err, &de) {
+			t.Fatalf("parseJSON(%q) = %v, not a decodeError", body, err)
+		}
+		if de.status != http.StatusBadRequest && de.status != http.StatusUnprocessableEntity {
+			t.Fatalf("parseJSON(%q) status %d, want 400 or 422", body, de.status)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+// DumpDiagnostics writes goroutine stacks, memory stats, and the redacted
+// config to a timestamped file in dir, or to the log when dir is empty.
+func (s *Server) DumpDiagnostics(dir string) {
+	var buf bytes.Buffer
+	at := time.Now()
+	fmt.Fprintf(&buf, "=== diagnostics pid=%d at %s\n\n", os.Getpid(), at.Format(time.RFC3339))
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	fmt.Fprintf(&buf, "=== memory\n")
+	fmt.Fprintf(&buf, "heap_alloc=%d heap_sys=%d heap_objects=%d\n", m.HeapAlloc, m.HeapSys, m.HeapObjects)
+	fmt.Fprintf(&buf, "stack_inuse=%d sys=%d num_gc=%d pause_total=%s\n\n",
+		m.StackInuse, m.Sys, m.NumGC, time.Duration(m.PauseTotalNs))
+
+	fmt.Fprintf(&buf, "=== requests\n")
+	fmt.Fprintf(&buf, "in_flight=%d completed=%d routes: %s\n\n",
+		s.stats.inFlight.Load(), s.stats.completed.Load(), s.stats.inFlightSummary())
+
+	s.mu.Lock()
+	cfg := s.cfg
+	s.mu.Unlock()
+	fmt.Fprintf(&buf, "=== config\n%s\n", cfg.Redacted())
+
+	fmt.Fprintf(&buf, "=== goroutines (%d)\n", runtime.NumGoroutine())
+	pprof.Lookup("goroutine").WriteTo(&buf, 2)
+
+	if dir == "" {
+		s.logger.Info("diagnostic dump", "dump", buf.String())
+		return
+	}
+	path := filepath.Join(dir, fmt.Sprintf("diag-%d-%s.txt", os.Getpid(), at.Format("20060102T150405")))
+	// !! The dump includes stack contents - keep diag_dir out of world-readable locations
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		s.logger.Error("diagnostic dump failed", "err", err)
+		return
+	}
+	s.logger.Info("diagnostic dump written", "path", path)
+}
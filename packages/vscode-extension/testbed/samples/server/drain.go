@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// requestStats counts requests as they pass through the outermost middleware,
+// and per route pattern once the mux has matched one.
+type requestStats struct {
+	inFlight  atomic.Int64
+	completed atomic.Int64
+	routes    sync.Map // pattern -> *atomic.Int64
+}
+
+func (st *requestStats) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st.inFlight.Add(1)
+		defer func() {
+			st.inFlight.Add(-1)
+			st.completed.Add(1)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (st *requestStats) RouteMiddleware(pattern string) Middleware {
+	counter, _ := st.routes.LoadOrStore(pattern, new(atomic.Int64))
+	n := counter.(*atomic.Int64)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n.Add(1)
+			defer n.Add(-1)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// InFlightByRoute reports the routes that currently have requests running.
+func (st *requestStats) InFlightByRoute() map[string]int64 {
+	counts := map[string]int64{}
+	st.routes.Range(func(k, v any) bool {
+		if n := v.(*atomic.Int64).Load(); n > 0 {
+			counts[k.(string)] = n
+		}
+		return true
+	})
+	return counts
+}
+
+func (st *requestStats) inFlightSummary() string {
+	counts := st.InFlightByRoute()
+	patterns := make([]string, 0, len(counts))
+	for p := range counts {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	total, routed := st.inFlight.Load(), int64(0)
+	parts := make([]string, 0, len(patterns)+1)
+	for _, p := range patterns {
+		routed += counts[p]
+		parts = append(parts, fmt.Sprintf("%s=%d", p, counts[p]))
+	}
+	// Requests still inside middleware haven't reached a route yet.
+	if pending := total - routed; pending > 0 {
+		parts = append(parts, fmt.Sprintf("(unrouted)=%d", pending))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, " ")
+}
+
+// handleReady fails while draining or starting without running the checks;
+// otherwise the body carries each check's result either way.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() || !s.started.Load() {
+		renderJSON(w, r, http.StatusServiceUnavailable, errorResponse(r.Context(), "not_ready"))
+		return
+	}
+	checks, ok := s.health.Evaluate(r.Context())
+	if !ok {
+		LoggerFrom(r.Context()).Warn("readiness check failing", "checks", checks)
+		resp := errorResponse(r.Context(), "not_ready")
+		resp.Data = map[string]any{"checks": checks}
+		renderJSON(w, r, http.StatusServiceUnavailable, resp)
+		return
+	}
+	renderJSON(w, r, http.StatusOK, Response{Success: true, Data: map[string]any{"status": "ready", "checks": checks}})
+}
+
+// shutdownProgress records which stage shutdown has reached so it can be
+// logged while waiting and exported on /debug/vars.
+type shutdownProgress struct {
+	started atomic.Int64 // unix nanos, zero until Shutdown begins
+	phase   atomic.Value // string
+}
+
+func (p *shutdownProgress) enter(phase string) {
+	p.started.CompareAndSwap(0, time.Now().UnixNano())
+	p.phase.Store(phase)
+}
+
+func (p *shutdownProgress) elapsed() time.Duration {
+	started := p.started.Load()
+	if started == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, started))
+}
+
+// reportShutdown logs progress every interval until stop is closed, so a
+// slow shutdown shows which routes are holding it up.
+func (s *Server) reportShutdown(interval time.Duration, stop <-chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.logger.Info("shutdown in progress",
+				"phase", s.shutdown.phase.Load(),
+				"elapsed", s.shutdown.elapsed().Round(time.Millisecond),
+				"in_flight", s.stats.inFlight.Load(),
+				"routes", s.stats.inFlightSummary())
+		}
+	}
+}
+
+func (s *Server) shutdownVars() map[string]interface{} {
+	if s.shutdown.started.Load() == 0 {
+		return nil
+	}
+	return map[string]interface{}{
+		"phase":           s.shutdown.phase.Load(),
+		"elapsed_seconds": s.shutdown.elapsed().Seconds(),
+		"in_flight":       s.stats.InFlightByRoute(),
+	}
+}
+
+// drain fails readiness and keeps serving for the configured delay so load
+// balancers notice and stop routing here before the listeners close.
+func (s *Server) drain(ctx context.Context) {
+	s.mu.Lock()
+	delay := s.cfg.Server.DrainDelay
+	s.mu.Unlock()
+	if delay <= 0 {
+		return
+	}
+
+	s.draining.Store(true)
+	s.events.Publish(ctx, LifecycleEvent{State: "draining"})
+	before := s.stats.completed.Load()
+	s.logger.Info("draining before shutdown", "delay", delay, "in_flight", s.stats.inFlight.Load())
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+	}
+
+	s.logger.Info("drain complete",
+		"served", s.stats.completed.Load()-before,
+		"in_flight", s.stats.inFlight.Load())
+}
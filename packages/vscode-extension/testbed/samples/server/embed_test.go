@@ -0,0 +1,29 @@
+package server_test
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/server"
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/servertest"
+)
+
+// TestEmbed builds the server from outside the package, as an embedding
+// program would, and drives it through servertest.
+func TestEmbed(t *testing.T) {
+	clock := servertest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	ids := servertest.NewSequence()
+	srv, err := server.NewServer(server.DefaultConfig(),
+		server.WithStore(server.NewUserStore()),
+		server.WithClock(clock),
+		server.WithIDs(ids),
+		server.WithLogger(slog.New(slog.DiscardHandler)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := servertest.Start(t, srv.Handler())
+	h.Post("/users", map[string]string{"id": "1", "name": "Ada"}).ExpectStatus(201).ExpectSuccess()
+	h.Get("/users/1").ExpectStatus(200).Golden("get_user")
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/middleware"
+)
+
+// ErrorEvent is one failure handed to an ErrorReporter. Kind is "panic",
+// "http_5xx" or "job_failed".
+type ErrorEvent struct {
+	Kind      string
+	Message   string
+	Stack     []byte
+	Time      time.Time
+	TraceID   string
+	RequestID string
+	Tags      map[string]string
+}
+
+// ErrorReporter forwards failures to an error tracker. Report is called on
+// the failing goroutine, so implementations must not block.
+type ErrorReporter interface {
+	Report(ctx context.Context, ev ErrorEvent)
+}
+
+type nopReporter struct{}
+
+func (nopReporter) Report(context.Context, ErrorEvent) {}
+
+// WithErrorReporter replaces the reporter built from errors.dsn.
+func WithErrorReporter(r ErrorReporter) Option {
+	return func(s *Server) { s.reporter = r }
+}
+
+func newErrorReporter(cfg ErrorsConfig, logger *slog.Logger) (ErrorReporter, error) {
+	if cfg.DSN == "" {
+		return nopReporter{}, nil
+	}
+	return NewSentryReporter(cfg.DSN, cfg.Environment, logger)
+}
+
+// reportEvent fills the request identifiers from ctx before reporting.
+func reportEvent(ctx context.Context, r ErrorReporter, ev ErrorEvent) {
+	ev.Time = time.Now()
+	if tc, ok := middleware.TraceFrom(ctx); ok {
+		ev.TraceID = tc.TraceID
+	}
+	ev.RequestID, _ = ctx.Value(requestIDKey).(string)
+	ev.Message = scrubPII(ev.Message)
+	for k, v := range ev.Tags {
+		ev.Tags[k] = scrubPII(v)
+	}
+	r.Report(ctx, ev)
+}
+
+func (s *Server) reportCrash(name string, v any, stack []byte) {
+	reportEvent(context.Background(), s.reporter, ErrorEvent{
+		Kind:    "panic",
+		Message: fmt.Sprint(v),
+		Stack:   stack,
+		Tags:    map[string]string{"worker": name},
+	})
+}
+
+func (s *Server) reportJobFailure(job Job, err error) {
+	reportEvent(context.Background(), s.reporter, ErrorEvent{
+		Kind:    "job_failed",
+		Message: err.Error(),
+		Tags:    map[string]string{"job": job.ID, "kind": job.Kind, "attempts": fmt.Sprint(job.Attempts)},
+	})
+}
+
+type reporterKey struct{}
+
+// requestErrors lets RecoveryMiddleware claim a request's 500 so it is
+// reported once, as the panic.
+type requestErrors struct {
+	reporter ErrorReporter
+	reported bool
+}
+
+func reporterFrom(ctx context.Context) (*requestErrors, bool) {
+	re, ok := ctx.Value(reporterKey{}).(*requestErrors)
+	return re, ok
+}
+
+// ErrorReportMiddleware reports 5xx responses. It sits outside
+// RecoveryMiddleware so it also sees the 500 written after a panic.
+func ErrorReportMiddleware(reporter ErrorReporter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			re := &requestErrors{reporter: reporter}
+			ctx := context.WithValue(r.Context(), reporterKey{}, re)
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+			if sw.status >= 500 && !re.reported {
+				reportEvent(ctx, reporter, ErrorEvent{
+					Kind:    "http_5xx",
+					Message: fmt.Sprintf("%s %s returned %d", r.Method, r.URL.Path, sw.status),
+					Tags:    map[string]string{"method": r.Method, "path": r.URL.Path, "status": fmt.Sprint(sw.status)},
+				})
+			}
+		})
+	}
+}
+
+// SentryReporter posts events to Sentry's store endpoint, or anything that
+// accepts the same JSON. Events are sent from a background goroutine and
+// dropped when its buffer is full.
+type SentryReporter struct {
+	endpoint    string
+	auth        string
+	environment string
+	logger      *slog.Logger
+	client      *http.Client
+	events      chan ErrorEvent
+}
+
+// NewSentryReporter parses a DSN of the form https://KEY@HOST/PROJECT.
+func NewSentryReporter(dsn, environment string, logger *slog.Logger) (*SentryReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil || u.Host == "" {
+		return nil, errors.New("errors.dsn: want https://KEY@HOST/PROJECT")
+	}
+	project := strings.Trim(u.Path, "/")
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		u.Path = "/" + project[:i]
+		project = project[i+1:]
+	} else {
+		u.Path = ""
+	}
+	endpoint := fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, u.Path, project)
+	r := &SentryReporter{
+		endpoint:    endpoint,
+		auth:        "Sentry sentry_version=7, sentry_client=human-plus-plus/1, sentry_key=" + u.User.Username(),
+		environment: environment,
+		logger:      logger,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		events:      make(chan ErrorEvent, 256),
+	}
+	go r.loop()
+	return r, nil
+}
+
+func (r *SentryReporter) Report(_ context.Context, ev ErrorEvent) {
+	select {
+	case r.events <- ev:
+	default:
+		r.logger.Warn("error report dropped", "kind", ev.Kind, "message", ev.Message)
+	}
+}
+
+func (r *SentryReporter) loop() {
+	for ev := range r.events {
+		if err := r.send(ev); err != nil {
+			r.logger.Warn("error report failed", "err", err)
+		}
+	}
+}
+
+func (r *SentryReporter) send(ev ErrorEvent) error {
+	tags := map[string]string{"kind": ev.Kind}
+	for k, v := range ev.Tags {
+		tags[k] = v
+	}
+	if ev.RequestID != "" {
+		tags["request_id"] = ev.RequestID
+	}
+	payload := map[string]any{
+		"event_id":    randomHex(16),
+		"timestamp":   ev.Time.UTC().Format(time.RFC3339),
+		"level":       "error",
+		"platform":    "go",
+		"logger":      ev.Kind,
+		"message":     ev.Message,
+		"environment": r.environment,
+		"tags":        tags,
+	}
+	extra := map[string]string{}
+	if len(ev.Stack) > 0 {
+		extra["stack"] = string(ev.Stack)
+	}
+	if ev.TraceID != "" {
+		payload["contexts"] = map[string]any{"trace": map[string]string{"trace_id": ev.TraceID}}
+	}
+	payload["extra"] = extra
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", r.auth)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error tracker returned %s", resp.Status)
+	}
+	return nil
+}
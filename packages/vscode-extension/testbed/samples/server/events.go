@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event is one entry on the server's event bus. Payload is a UserEvent,
+// LifecycleEvent, ConfigReloadEvent or AuthFailureEvent; SubscribeTo
+// filters on it.
+type Event struct {
+	Time      time.Time
+	Tenant    string
+	RequestID string
+	// Origin is the instance that published the event when another
+	// replica relayed it here, and "" for events from this one; see
+	// FanoutConfig. Subscribers with side effects skip relayed events, or
+	// every replica would repeat them.
+	Origin  string
+	Payload any
+}
+
+// UserEvent is published after a user is written or removed. User is the
+// stored value, or only the ID for deletes.
+type UserEvent struct {
+	Action string // created, updated or deleted
+	User   User
+}
+
+// LifecycleEvent marks server state changes: started, draining, stopped.
+type LifecycleEvent struct {
+	State string
+}
+
+// ConfigReloadEvent lists the keys a reload changed.
+type ConfigReloadEvent struct {
+	Applied []string
+}
+
+// EventBus fans events out to subscribers, each with its own bounded queue
+// and goroutine. A slow subscriber loses events rather than delaying the
+// publisher or the other subscribers.
+type EventBus struct {
+	logger *slog.Logger
+
+	mu     sync.RWMutex
+	subs   map[int]*subscriber
+	nextID int
+	closed bool
+}
+
+type subscriber struct {
+	name    string
+	queue   chan Event
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+func NewEventBus(logger *slog.Logger) *EventBus {
+	return &EventBus{logger: logger, subs: map[int]*subscriber{}}
+}
+
+// Subscribe calls fn for every event, in publish order, on a goroutine of
+// its own. The returned function unsubscribes after the queue drains.
+func (b *EventBus) Subscribe(name string, buffer int, fn func(Event)) (unsubscribe func()) {
+	sub := &subscriber{name: name, queue: make(chan Event, buffer), done: make(chan struct{})}
+	go func() {
+		defer close(sub.done)
+		for ev := range sub.queue {
+			b.deliver(sub, fn, ev)
+		}
+	}()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(sub.queue)
+		return func() {}
+	}
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = sub
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			if _, ok := b.subs[id]; ok {
+				delete(b.subs, id)
+				close(sub.queue)
+			}
+			b.mu.Unlock()
+			<-sub.done
+		})
+	}
+}
+
+// SubscribeTo is Subscribe limited to one payload type.
+func SubscribeTo[T any](b *EventBus, name string, buffer int, fn func(Event, T)) (unsubscribe func()) {
+	return b.Subscribe(name, buffer, func(ev Event) {
+		if payload, ok := ev.Payload.(T); ok {
+			fn(ev, payload)
+		}
+	})
+}
+
+// >> A panicking subscriber loses that one event, not its subscription
+func (b *EventBus) deliver(sub *subscriber, fn func(Event), ev Event) {
+	defer func() {
+		if v := recover(); v != nil {
+			b.logger.Error("event subscriber panicked", "subscriber", sub.name, "panic", v)
+		}
+	}()
+	fn(ev)
+}
+
+// Publish stamps ev with the request's tenant and ID from ctx and queues it
+// for every subscriber. It never blocks.
+func (b *EventBus) Publish(ctx context.Context, payload any) {
+	ev := Event{Time: time.Now(), Tenant: TenantFrom(ctx), Payload: payload}
+	ev.RequestID, _ = ctx.Value(requestIDKey).(string)
+	b.publish(ev)
+}
+
+// publish queues ev as it is, for events stamped elsewhere.
+func (b *EventBus) publish(ev Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+	for _, sub := range b.subs {
+		select {
+		case sub.queue <- ev:
+		default:
+			if sub.dropped.Add(1) == 1 {
+				b.logger.Warn("event subscriber falling behind, dropping events", "subscriber", sub.name)
+			}
+		}
+	}
+}
+
+// Close stops accepting events and waits for subscribers to drain their
+// queues. It is registered as a shutdown hook.
+func (b *EventBus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	b.closed = true
+	subs := b.subs
+	b.subs = map[int]*subscriber{}
+	for _, sub := range subs {
+		close(sub.queue)
+	}
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		select {
+		case <-sub.done:
+		case <-ctx.Done():
+			return fmt.Errorf("event bus drain: %w (subscriber %s)", ctx.Err(), sub.name)
+		}
+	}
+	return nil
+}
+
+func (b *EventBus) stats() map[string]map[string]int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	out := make(map[string]map[string]int64, len(b.subs))
+	for _, sub := range b.subs {
+		out[sub.name] = map[string]int64{"queued": int64(len(sub.queue)), "dropped": sub.dropped.Load()}
+	}
+	return out
+}
+
+// Events returns the server's bus so embedders can subscribe; subscribers
+// registered before ConnectStore see the started event.
+func (s *Server) Events() *EventBus { return s.events }
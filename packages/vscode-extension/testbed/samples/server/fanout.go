@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// fanoutMessage is a UserEvent as it crosses the Redis channel. Origin
+// lets each replica ignore the copy Redis echoes back to it.
+type fanoutMessage struct {
+	Origin    string    `json:"origin"`
+	Tenant    string    `json:"tenant,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	User      User      `json:"user"`
+}
+
+// eventFanout relays user events between replicas: local ones are PUBLISHed
+// to the channel, and what other replicas publish is put on the local
+// bus with Origin set, so every instance's subscribers see every change.
+// Publishing uses the pooled client; receiving holds a connection of its
+// own, since a subscribed RESP2 connection can't run other commands.
+type eventFanout struct {
+	client  *redisClient
+	channel string
+	origin  string
+	bus     *EventBus
+	logger  *slog.Logger
+
+	mu   sync.Mutex
+	conn *redisConn // the subscribed connection, closed to stop receive
+	stop chan struct{}
+	done chan struct{}
+}
+
+// fanOutEvents starts the relay when fanout.redis_url is set.
+func (s *Server) fanOutEvents(cfg FanoutConfig) error {
+	if cfg.RedisURL == "" {
+		return nil
+	}
+	client, err := newRedisClient(cfg.RedisURL, 4)
+	if err != nil {
+		return fmt.Errorf("fanout.redis_url: %w", err)
+	}
+	f := &eventFanout{
+		client:  client,
+		channel: cfg.Channel,
+		origin:  s.elector.id,
+		bus:     s.events,
+		logger:  s.logger,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	unsubscribe := SubscribeTo(s.events, "fanout", 1024, f.send)
+	go f.receive()
+	s.OnShutdown(func(context.Context) error {
+		unsubscribe()
+		f.close()
+		return client.Close()
+	})
+	return nil
+}
+
+func (f *eventFanout) send(ev Event, ue UserEvent) {
+	if ev.Origin != "" {
+		return // relayed here; sending it on would loop
+	}
+	data, err := json.Marshal(fanoutMessage{Origin: f.origin, Tenant: ev.Tenant, RequestID: ev.RequestID, Time: ev.Time, Action: ue.Action, User: ue.User})
+	if err != nil {
+		f.logger.Error("fanout encoding failed", "err", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := f.client.Do(ctx, "PUBLISH", f.channel, string(data)); err != nil {
+		f.logger.Warn("user event not fanned out", "action", ue.Action, "request_id", ev.RequestID, "err", err)
+	}
+}
+
+// receive subscribes and relays until close, resubscribing with backoff
+// whenever the connection drops.
+// !! Pub/sub is fire and forget: events published while this replica is reconnecting never reach it
+func (f *eventFanout) receive() {
+	defer close(f.done)
+	backoff := time.Second
+	for {
+		err := f.subscribe()
+		select {
+		case <-f.stop:
+			return
+		default:
+		}
+		f.logger.Warn("fanout subscription lost", "channel", f.channel, "retry_in", backoff, "err", err)
+		select {
+		case <-f.stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// subscribe holds one subscribed connection, relaying messages until it
+// fails or is closed.
+func (f *eventFanout) subscribe() error {
+	ctx, cancel := context.WithTimeout(context.Background(), f.client.timeout)
+	rc, err := f.client.dial(ctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer rc.conn.Close()
+	if _, err := rc.do(f.client.timeout, "SUBSCRIBE", f.channel); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	select {
+	case <-f.stop:
+		f.mu.Unlock()
+		return nil
+	default:
+		f.conn = rc
+	}
+	f.mu.Unlock()
+	// Messages arrive whenever another replica publishes, so there is no
+	// deadline on the read; close unblocks it.
+	// ?? a half-open connection is never noticed; a periodic PING would need its reply told apart from messages
+	rc.conn.SetDeadline(time.Time{})
+	for {
+		reply, err := rc.read()
+		if err != nil {
+			return err
+		}
+		// A pushed message is ["message", channel, payload].
+		push, ok := reply.([]any)
+		if !ok || len(push) != 3 || push[0] != "message" {
+			continue
+		}
+		payload, _ := push[2].(string)
+		var msg fanoutMessage
+		if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+			f.logger.Warn("fanout message dropped", "err", err)
+			continue
+		}
+		if msg.Origin == f.origin {
+			continue
+		}
+		if msg.Origin == "" {
+			msg.Origin = "unknown" // an empty Origin would pass for a local event
+		}
+		f.bus.publish(Event{
+			Time:      msg.Time,
+			Tenant:    msg.Tenant,
+			RequestID: msg.RequestID,
+			Origin:    msg.Origin,
+			Payload:   UserEvent{Action: msg.Action, User: msg.User},
+		})
+	}
+}
+
+func (f *eventFanout) close() {
+	f.mu.Lock()
+	close(f.stop)
+	if f.conn != nil {
+		f.conn.conn.Close()
+	}
+	f.mu.Unlock()
+	<-f.done
+}
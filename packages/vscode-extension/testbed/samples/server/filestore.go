@@ -0,0 +1,250 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// snapshotVersion is the current on-disk format. Version 0 is the original
+// bare JSON array of users, version 1 has no tenants and version 2 has no
+// password hashes; all are still readable until `migrate` rewrites them.
+const snapshotVersion = 3
+
+type snapshotFile struct {
+	Version int                     `json:"version"`
+	Users   []storedUser            `json:"users"`
+	Tenants map[string][]storedUser `json:"tenants,omitempty"`
+}
+
+// storedUser is a User as snapshotted, including the hash the API hides.
+type storedUser struct {
+	User
+	PasswordHash string `json:"password_hash,omitempty"`
+}
+
+func storedUsers(users []User) []storedUser {
+	out := make([]storedUser, len(users))
+	for i, u := range users {
+		out[i] = storedUser{User: u, PasswordHash: u.PasswordHash}
+	}
+	return out
+}
+
+func (su storedUser) user() User {
+	u := su.User
+	u.PasswordHash = su.PasswordHash
+	return u
+}
+
+// FileStore keeps users in memory and snapshots them to a JSON file after
+// every mutation, so data survives restarts without an external database.
+// Tenant views share one snapshot file.
+type FileStore struct {
+	*UserStore
+	file *snapshotState
+}
+
+type snapshotState struct {
+	path    string
+	version int
+	mu      sync.Mutex
+
+	// Writes are group-committed: those arriving while a snapshot is being
+	// written share the next one. maxDelay holds a batch open that much
+	// longer to gather more, and maxBatch closes it early once it has that
+	// many writes; 0 disables either.
+	maxBatch int
+	maxDelay time.Duration
+	batchMu  sync.Mutex
+	pending  *snapshotBatch
+}
+
+// snapshotBatch is the writes one snapshot will cover. done is closed once
+// it has been written, with err set if that failed.
+type snapshotBatch struct {
+	writes int
+	full   chan struct{}
+	done   chan struct{}
+	err    error
+}
+
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{UserStore: NewUserStore(), file: &snapshotState{path: path, version: snapshotVersion}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snap snapshotFile
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		err = json.Unmarshal(data, &snap.Users)
+	} else {
+		err = json.Unmarshal(data, &snap)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if snap.Version > snapshotVersion {
+		return nil, fmt.Errorf("%s: snapshot version %d is newer than this binary (%d)", path, snap.Version, snapshotVersion)
+	}
+	s.file.version = snap.Version
+	for _, user := range snap.Users {
+		s.UserStore.Set(user.user())
+	}
+	for tenant, users := range snap.Tenants {
+		view := s.UserStore.View(tenant)
+		for _, user := range users {
+			view.Set(user.user())
+		}
+	}
+	return s, nil
+}
+
+func (s *FileStore) ForTenant(tenant string) Store {
+	return &FileStore{UserStore: s.UserStore.View(tenant), file: s.file}
+}
+
+// Migrate rewrites an older snapshot in the current format and reports the
+// version it upgraded from.
+func (s *FileStore) Migrate() (from int, err error) {
+	from = s.file.version
+	if from == snapshotVersion {
+		return from, nil
+	}
+	if err := s.snapshot(); err != nil {
+		return from, err
+	}
+	s.file.version = snapshotVersion
+	return from, nil
+}
+
+func (s *FileStore) Set(user User) {
+	s.UserStore.Set(user)
+	s.persist()
+}
+
+func (s *FileStore) Delete(id string) bool {
+	ok := s.UserStore.Delete(id)
+	if ok {
+		s.persist()
+	}
+	return ok
+}
+
+// Flush writes a final snapshot; it is registered as a shutdown hook.
+func (s *FileStore) Flush() error {
+	return s.snapshot()
+}
+
+// persist returns once a snapshot taken after the caller's write is on
+// disk. The first writer into a batch leads it: it waits out maxDelay, or
+// until the batch is full, then for any snapshot still being written, and
+// writes one snapshot for everyone who joined meanwhile.
+//
+// !! Snapshot errors are only logged - a full disk silently loses writes
+func (s *FileStore) persist() {
+	f := s.file
+	f.batchMu.Lock()
+	b := f.pending
+	lead := b == nil
+	if lead {
+		b = &snapshotBatch{full: make(chan struct{}), done: make(chan struct{})}
+		f.pending = b
+	}
+	b.writes++
+	if f.maxBatch > 0 && b.writes == f.maxBatch {
+		close(b.full)
+		f.pending = nil
+	}
+	f.batchMu.Unlock()
+
+	if !lead {
+		<-b.done
+	} else {
+		if f.maxDelay > 0 {
+			t := time.NewTimer(f.maxDelay)
+			select {
+			case <-t.C:
+			case <-b.full:
+			}
+			t.Stop()
+		}
+		f.mu.Lock()
+		// Writers from here on start the next batch; this snapshot still
+		// reads everything written before it.
+		f.batchMu.Lock()
+		if f.pending == b {
+			f.pending = nil
+		}
+		f.batchMu.Unlock()
+		b.err = s.writeSnapshot()
+		f.mu.Unlock()
+		close(b.done)
+	}
+	if b.err != nil {
+		slog.Error("file store snapshot failed", "path", f.path, "writes", b.writes, "err", b.err)
+	}
+}
+
+func (s *FileStore) snapshot() error {
+	s.file.mu.Lock()
+	defer s.file.mu.Unlock()
+	return s.writeSnapshot()
+}
+
+// >> Write to a temp file and rename so a crash never leaves a torn snapshot
+func (s *FileStore) writeSnapshot() error {
+	snap := snapshotFile{Version: snapshotVersion, Users: []storedUser{}}
+	for tenant, users := range s.UserStore.All() {
+		if tenant == DefaultTenant {
+			snap.Users = storedUsers(users)
+			continue
+		}
+		if snap.Tenants == nil {
+			snap.Tenants = map[string][]storedUser{}
+		}
+		snap.Tenants[tenant] = storedUsers(users)
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.file.path), ".users-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.file.path)
+}
+
+func OpenStore(cfg Config) (Store, error) {
+	switch cfg.Store.Backend {
+	case "file":
+		fs, err := OpenFileStore(cfg.Store.Path)
+		if err != nil {
+			return nil, err
+		}
+		fs.file.maxBatch, fs.file.maxDelay = cfg.Store.BatchMax, cfg.Store.BatchDelay
+		return fs, nil
+	default:
+		return NewUserStore(), nil
+	}
+}
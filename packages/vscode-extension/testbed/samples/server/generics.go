@@ -0,0 +1,125 @@
+package server
+
+import (
+	"cmp"
+	"iter"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Generic helpers for the admin endpoints. Markers sit on type parameter
+// lines and inside constraint interfaces, where brackets and ~ make the
+// surrounding syntax unlike older Go.
+
+// quantity is anything a limit or a counter can be measured in.
+type quantity interface {
+	// >> ~ so named types such as time.Duration qualify
+	~int | ~int32 | ~int64 | ~uint64 | ~float64
+}
+
+// keyed is a record that can be looked up by ID.
+type keyed[K comparable] interface {
+	key() K // ?? every implementation returns a copy; a pointer receiver would do
+}
+
+// ttlCache holds values for a fixed time after they are stored.
+type ttlCache[
+	K comparable, // !! K must not be an interface type holding a func, or Put panics
+	V any,
+] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[K]ttlEntry[V]
+}
+
+type ttlEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newTTLCache[K comparable, V any](ttl time.Duration) *ttlCache[K, V] {
+	return &ttlCache[K, V]{ttl: ttl, entries: map[K]ttlEntry[V]{}}
+}
+
+func (c *ttlCache[K, V]) Put(k K, v V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[k] = ttlEntry[V]{value: v, expires: time.Now().Add(c.ttl)}
+}
+
+func (c *ttlCache[K, V]) Get(k K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[k]
+	if !ok || time.Now().After(e.expires) {
+		// ?? expired entries are only dropped on Put; a sweep may be worth it
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// total adds up xs in their own type, so a sum of durations stays one.
+func total[T quantity](xs ...T) T {
+	var sum T
+	for _, x := range xs {
+		sum += x
+	}
+	return sum
+}
+
+// topN returns the n largest values of by over items, largest first.
+func topN[S ~[]E, E any, O cmp.Ordered]( // >> S ~[]E keeps named slice types
+	items S, n int, by func(E) O,
+) S {
+	sorted := slices.Clone(items)
+	slices.SortFunc(sorted, func(a, b E) int { return cmp.Compare(by(b), by(a)) })
+	return sorted[:min(n, len(sorted))]
+}
+
+// index maps records by their key.
+func index[K comparable, R keyed[K]](records []R) map[K]R {
+	m := make(map[K]R, len(records))
+	for _, r := range records {
+		m[r.key()] = r // !! a duplicate key silently replaces the earlier record
+	}
+	return m
+}
+
+// filtered yields the values of seq that keep accepts.
+func filtered[V any](seq iter.Seq[V], keep func(V) bool) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for v := range seq {
+			if keep(v) && !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+type tenantUsage struct {
+	tenant   string
+	requests int64
+	latency  time.Duration
+}
+
+func (u tenantUsage) key() string { return u.tenant }
+
+// Instantiations, explicit and inferred, so each form appears at least once.
+var (
+	usageCache = newTTLCache[string, []tenantUsage](time.Minute)
+	_          = total[time.Duration] // >> explicit instantiation without a call
+	_          = index[string, tenantUsage]
+)
+
+func busiestTenants(usage []tenantUsage) []tenantUsage {
+	busy := topN(usage, 5, func(u tenantUsage) int64 { return u.requests })
+	var slow []tenantUsage
+	for u := range filtered(slices.Values(busy), func(u tenantUsage) bool {
+		return u.latency > total(time.Second, 500*time.Millisecond) // ?? threshold picked by eye
+	}) {
+		slow = append(slow, u)
+	}
+	return slow
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+type Response struct {
+	Success bool        `json:"success"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	// Code identifies Error whatever language it is in; see i18n.go.
+	Code    string `json:"code,omitempty"`
+	TraceID string `json:"trace_id,omitempty"`
+	// NextCursor is set on a page of a paginated list when more follow.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// dataResponse is a successful Response with a concrete Data type, for the
+// list and get paths that dominate traffic. The encoder resolves T once
+// rather than inspecting an interface on every response; the JSON is the
+// same as Response's.
+type dataResponse[T any] struct {
+	Success    bool   `json:"success"`
+	Data       T      `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func dataOK[T any](data T) dataResponse[T] {
+	return dataResponse[T]{Success: true, Data: data}
+}
+
+// userInput is a User as clients send it: password is write-only and only
+// its hash is stored.
+type userInput struct {
+	User
+	Password string `json:"password,omitempty"`
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	renderJSON(w, r, http.StatusOK, Response{Success: true, Data: "ok"})
+}
+
+func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if s.notModified(w, r, streamFormat(r)) {
+			return
+		}
+		if r.URL.Query().Get("limit") == "" {
+			release, ok := s.heavy.acquire(w, r)
+			if !ok {
+				return
+			}
+			defer release()
+			if format := streamFormat(r); format != "" {
+				s.streamUsers(w, r, format)
+				return
+			}
+			users, ok := s.listUsers(r)
+			if !ok {
+				return
+			}
+			defer putUserSlice(users)
+			renderJSON(w, r, http.StatusOK, dataOK(*users))
+			return
+		}
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		users, ok := s.listUsers(r)
+		if !ok {
+			return
+		}
+		defer putUserSlice(users)
+		page, next := paginateUsers(*users, r.URL.Query().Get("cursor"), limit)
+		renderJSON(w, r, http.StatusOK, dataResponse[[]User]{Success: true, Data: page, NextCursor: next})
+	case http.MethodPost:
+		var in userInput
+		if !decodeJSON(w, r, &in) {
+			return
+		}
+		user := in.User
+		if user.ID == "" {
+			user.ID = s.ids.UserID()
+		}
+		user.CreatedAt = s.clock.Now()
+		store := s.storeFor(r)
+		action := "created"
+		var prev *User
+		if existing, exists := store.Get(user.ID); exists {
+			action = "updated"
+			user.CreatedAt = existing.CreatedAt
+			user.PasswordHash = existing.PasswordHash
+			prev = &existing
+		}
+		if in.Password != "" {
+			// Anyone may create a user, but only the user or an admin may
+			// replace an existing one's password.
+			if claims, _ := ClaimsFrom(r.Context()); prev != nil && claims.Subject != user.ID && !hasAdminToken(r, s.adminToken) {
+				renderJSON(w, r, http.StatusForbidden, errorResponse(r.Context(), "password_not_yours"))
+				return
+			}
+			if err := SetPassword(&user, in.Password); errors.Is(err, errNoArgon2) {
+				http.Error(w, "Password storage unavailable", http.StatusNotImplemented)
+				return
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if qerr := s.quotas.checkWrite(TenantFrom(r.Context()), store, user, prev); qerr != nil {
+			renderJSON(w, r, http.StatusForbidden, errorResponse(r.Context(), qerr.code, qerr.args...))
+			return
+		}
+		store.Set(user)
+		LoggerFrom(r.Context()).Info("user saved", "user_id", user.ID, "action", action)
+		s.events.Publish(r.Context(), UserEvent{Action: action, User: user})
+		renderJSON(w, r, http.StatusCreated, Response{Success: true, Data: user})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// userSlices recycles the slices list requests gather users into. They
+// are only returned once renderJSON has encoded the response, which it
+// does in full before writing.
+var userSlices = sync.Pool{New: func() any {
+	// Non-nil, so an empty store still encodes as [] rather than null.
+	users := make([]User, 0, 64)
+	return &users
+}}
+
+// maxPooledUsers keeps the slice from one very large listing from staying
+// pinned in the pool.
+const maxPooledUsers = 1 << 16
+
+// listUsers gathers the tenant's users into a pooled slice; the caller
+// must hand it back with putUserSlice. ok is false when the client went
+// away part way through.
+func (s *Server) listUsers(r *http.Request) (users *[]User, ok bool) {
+	users = userSlices.Get().(*[]User)
+	err := s.storeFor(r).Range(r.Context(), func(u User) bool {
+		*users = append(*users, u)
+		return true
+	})
+	if err != nil {
+		putUserSlice(users)
+		return nil, false
+	}
+	return users, true
+}
+
+func putUserSlice(users *[]User) {
+	if cap(*users) > maxPooledUsers {
+		return
+	}
+	// Drop the users themselves so a pooled slice doesn't keep them alive.
+	clear(*users)
+	*users = (*users)[:0]
+	userSlices.Put(users)
+}
+
+// paginateUsers returns up to limit users ordered by ID after cursor, the
+// last ID of the previous page, and the cursor for the page after.
+func paginateUsers(users []User, cursor string, limit int) ([]User, string) {
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	start := sort.Search(len(users), func(i int) bool { return users[i].ID > cursor })
+	page := users[start:min(start+limit, len(users))]
+	if start+limit >= len(users) {
+		return page, ""
+	}
+	return page, page[len(page)-1].ID
+}
+
+func (s *Server) handleUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathParam(r.URL.Path, "/users/")
+	if !ok {
+		http.Error(w, "User ID required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		user, ok := s.storeFor(r).Get(id)
+		if !ok {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		renderJSON(w, r, http.StatusOK, dataOK(user))
+	case http.MethodDelete:
+		if !s.storeFor(r).Delete(id) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		LoggerFrom(r.Context()).Info("user deleted", "user_id", id)
+		s.events.Publish(r.Context(), UserEvent{Action: "deleted", User: User{ID: id}})
+		renderJSON(w, r, http.StatusOK, Response{Success: true})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stepClock struct{ now time.Time }
+
+func (c *stepClock) Now() time.Time { return c.now }
+
+func TestUpsertKeepsCreatedAt(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := &stepClock{now: created}
+	store := NewUserStore()
+	srv, err := NewServer(DefaultConfig(), WithStore(store), WithClock(clock), WithLogger(slog.New(slog.DiscardHandler)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := srv.Handler()
+	for _, name := range []string{"Ada", "Ada Lovelace"} {
+		r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"id":"u-1","name":"`+name+`"}`))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("POST /users = %d: %s", w.Code, w.Body)
+		}
+		clock.now = clock.now.Add(time.Hour)
+	}
+	user, _ := store.Get("u-1")
+	if user.Name != "Ada Lovelace" || !user.CreatedAt.Equal(created) {
+		t.Errorf("after update got %q created %v, want %q created %v", user.Name, user.CreatedAt, "Ada Lovelace", created)
+	}
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// HealthChecker is one dependency consulted by /readyz. Check should honor
+// ctx; the registry cancels it after health.timeout.
+type HealthChecker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// HealthCheckFunc adapts a function to HealthChecker.
+type HealthCheckFunc struct {
+	CheckName string
+	Fn        func(ctx context.Context) error
+}
+
+func (f HealthCheckFunc) Name() string                    { return f.CheckName }
+func (f HealthCheckFunc) Check(ctx context.Context) error { return f.Fn(ctx) }
+
+// CheckResult is the per-check detail reported in the /readyz body.
+type CheckResult struct {
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Duration float64   `json:"duration_ms"`
+	Checked  time.Time `json:"checked_at"`
+}
+
+// healthRegistry runs every registered check in parallel and caches the
+// results for health.cache_ttl, so a probe storm doesn't become a storm of
+// store pings.
+type healthRegistry struct {
+	timeout time.Duration
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	checks  []HealthChecker
+	results map[string]CheckResult
+	expires time.Time
+}
+
+func newHealthRegistry(cfg HealthConfig) *healthRegistry {
+	return &healthRegistry{timeout: cfg.Timeout, ttl: cfg.CacheTTL}
+}
+
+func (h *healthRegistry) Register(c HealthChecker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, c)
+	h.expires = time.Time{}
+}
+
+// Evaluate returns the latest results and whether every check passed.
+func (h *healthRegistry) Evaluate(ctx context.Context) (map[string]CheckResult, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.results == nil || time.Now().After(h.expires) {
+		h.results = h.run(ctx)
+		h.expires = time.Now().Add(h.ttl)
+	}
+	healthy := true
+	for _, r := range h.results {
+		if r.Status != "ok" {
+			healthy = false
+		}
+	}
+	return h.results, healthy
+}
+
+// >> The lock is held while checks run so concurrent probes share one round
+func (h *healthRegistry) run(ctx context.Context) map[string]CheckResult {
+	// The results are cached for every probe, so one probe hanging up must
+	// not cancel the round and leave "context canceled" failures behind.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), h.timeout)
+	defer cancel()
+	results := make(map[string]CheckResult, len(h.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := runHealthCheck(ctx, c)
+			r := CheckResult{Status: "ok", Duration: float64(time.Since(start).Microseconds()) / 1000, Checked: start}
+			if err != nil {
+				r.Status, r.Error = "failing", err.Error()
+			}
+			mu.Lock()
+			results[c.Name()] = r
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// runHealthCheck stops waiting at the deadline even if the check ignores ctx.
+func runHealthCheck(ctx context.Context, c HealthChecker) error {
+	done := make(chan error, 1)
+	go func() { done <- c.Check(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out: %w", ctx.Err())
+	}
+}
+
+// RegisterHealthCheck adds a check to /readyz. Embedders call it before
+// Start to gate readiness on their own dependencies.
+func (s *Server) RegisterHealthCheck(c HealthChecker) {
+	s.health.Register(c)
+}
+
+// registerDefaultChecks covers the store, free disk under the paths the
+// server writes to, and the background queue backlog.
+func (s *Server) registerDefaultChecks(cfg Config) {
+	s.RegisterHealthCheck(HealthCheckFunc{CheckName: "store", Fn: s.checkStore})
+	dirs := map[string]string{}
+	if cfg.Store.Backend == "file" {
+		dirs["disk:store"] = filepath.Dir(cfg.Store.Path)
+	}
+	if cfg.Jobs.Dir != "" {
+		dirs["disk:jobs"] = cfg.Jobs.Dir
+	}
+	for name, dir := range dirs {
+		s.RegisterHealthCheck(HealthCheckFunc{CheckName: name, Fn: func(context.Context) error {
+			return checkDiskFree(dir, cfg.Health.MinFreeMB)
+		}})
+	}
+	if limit := cfg.Health.MaxQueueDepth; limit > 0 {
+		s.RegisterHealthCheck(HealthCheckFunc{CheckName: "job_queue", Fn: func(context.Context) error {
+			if n := len(s.jobs.queue); n > limit {
+				return fmt.Errorf("%d jobs queued, limit %d", n, limit)
+			}
+			return nil
+		}})
+	}
+}
+
+func (s *Server) checkStore(ctx context.Context) error {
+	if !s.started.Load() {
+		return errors.New("store not connected")
+	}
+	// Backends without a Ping are in-process; reaching them is enough.
+	store := s.store
+	if m, ok := store.(meteredStore); ok {
+		store = m.Store
+	}
+	if p, ok := store.(interface{ Ping(context.Context) error }); ok {
+		return p.Ping(ctx)
+	}
+	store.Len()
+	return nil
+}
+
+func checkDiskFree(dir string, minMB int) error {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return err
+	}
+	free := st.Bavail * uint64(st.Bsize) >> 20
+	if free < uint64(minMB) {
+		return fmt.Errorf("%s has %d MB free, need %d", dir, free, minMB)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// heavyPool bounds the requests doing work that grows with the store,
+// such as full lists and exports, so a burst of them can't take every CPU
+// from the cheap CRUD calls. It is a pool of slots rather than of
+// goroutines: a request runs its heavy part on its own goroutine while it
+// holds one.
+type heavyPool struct {
+	slots   chan struct{}
+	waiting atomic.Int64
+	queue   int64
+	timeout time.Duration
+}
+
+func newHeavyPool(cfg HeavyConfig) *heavyPool {
+	workers := cfg.Workers
+	if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return &heavyPool{slots: make(chan struct{}, workers), queue: int64(cfg.Queue), timeout: cfg.QueueTimeout}
+}
+
+// acquire takes a slot, waiting in the queue if need be. When the queue is
+// full or the wait runs out it answers 503 with Retry-After and returns
+// false; otherwise the caller must call release when done.
+func (p *heavyPool) acquire(w http.ResponseWriter, r *http.Request) (release func(), ok bool) {
+	release = func() { <-p.slots }
+	select {
+	case p.slots <- struct{}{}:
+		return release, true
+	default:
+	}
+
+	if p.waiting.Add(1) > p.queue {
+		p.waiting.Add(-1)
+		p.reject(w, r, "queue full")
+		return nil, false
+	}
+	defer p.waiting.Add(-1)
+	t := time.NewTimer(p.timeout)
+	defer t.Stop()
+	select {
+	case p.slots <- struct{}{}:
+		return release, true
+	case <-r.Context().Done():
+		return nil, false
+	case <-t.C:
+		p.reject(w, r, "queue timeout")
+		return nil, false
+	}
+}
+
+func (p *heavyPool) reject(w http.ResponseWriter, r *http.Request, reason string) {
+	LoggerFrom(r.Context()).Info("heavy request shed", "reason", reason, "workers", cap(p.slots), "queued", p.waiting.Load())
+	w.Header().Set("Retry-After", "1")
+	renderJSON(w, r, http.StatusServiceUnavailable, errorResponse(r.Context(), "server_busy"))
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request signing headers. The signature is hex HMAC-SHA256, under the
+// caller's key, of
+//
+//	METHOD \n REQUEST-URI \n TIMESTAMP \n hex(sha256(body))
+//
+// where TIMESTAMP is Unix seconds and matches the timestamp header.
+const (
+	signatureKeyHeader  = "X-Signature-Key"
+	signatureTimeHeader = "X-Signature-Timestamp"
+	signatureHeader     = "X-Signature"
+)
+
+// maxSignedBody bounds how much of a signed request is buffered to hash it.
+const maxSignedBody = 10 << 20
+
+// parseHMACKeys reads "id:secret" entries.
+func parseHMACKeys(entries []string) (map[string][]byte, error) {
+	keys := map[string][]byte{}
+	for _, entry := range entries {
+		id, secret, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || secret == "" {
+			return nil, errors.New("want id:secret")
+		}
+		if _, dup := keys[id]; dup {
+			return nil, fmt.Errorf("duplicate key id %q", id)
+		}
+		keys[id] = []byte(secret)
+	}
+	return keys, nil
+}
+
+func signatureBase(method, uri, timestamp string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return method + "\n" + uri + "\n" + timestamp + "\n" + hex.EncodeToString(sum[:])
+}
+
+// replayCache remembers signatures until they fall out of the window, so a
+// captured request can't be sent again while its timestamp is still valid.
+type replayCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+	// swept is when expired entries were last dropped; a sweep walks the
+	// whole map, so it runs at most once per replaySweepEvery.
+	swept time.Time
+}
+
+const replaySweepEvery = 10 * time.Second
+
+// check records sig and reports whether it was new. An entry past its
+// expiry no longer counts, whether or not a sweep has dropped it yet.
+func (c *replayCache) check(sig string, expires, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.swept) >= replaySweepEvery {
+		for s, exp := range c.seen {
+			if now.After(exp) {
+				delete(c.seen, s)
+			}
+		}
+		c.swept = now
+	}
+	if exp, ok := c.seen[sig]; ok && !now.After(exp) {
+		return false
+	}
+	c.seen[sig] = expires
+	return true
+}
+
+// SignatureMiddleware verifies signed requests and sets the principal to
+// the signing key. Unsigned requests pass through unless cfg.Required; any
+// request that carries a signature must verify.
+func SignatureMiddleware(cfg SigningConfig, ring *rotating[map[string][]byte]) Middleware {
+	replays := &replayCache{seen: map[string]time.Time{}}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(signatureHeader) == "" && (!cfg.Required || probePaths[r.URL.Path]) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			keyID, err := verifySignature(r, ring.Load(), cfg.Window, replays)
+			if err != nil {
+				LoggerFrom(r.Context()).Info("signature rejected", "key_id", r.Header.Get(signatureKeyHeader), "err", err)
+				renderJSON(w, r, http.StatusUnauthorized, errorFor(r.Context(), err, "signature_mismatch"))
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), "hmac:"+keyID)))
+		})
+	}
+}
+
+// verifySignature checks r's signature and restores its body for the
+// handler.
+func verifySignature(r *http.Request, keys map[string][]byte, window time.Duration, replays *replayCache) (string, error) {
+	keyID, timestamp, given := r.Header.Get(signatureKeyHeader), r.Header.Get(signatureTimeHeader), r.Header.Get(signatureHeader)
+	secret, ok := keys[keyID]
+	if !ok {
+		return "", newAPIError("unknown_signing_key")
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", newAPIError("invalid_signature_timestamp")
+	}
+	now := time.Now()
+	signedAt := time.Unix(unix, 0)
+	if signedAt.Before(now.Add(-window)) || signedAt.After(now.Add(window)) {
+		return "", newAPIError("signature_outside_window")
+	}
+	sig, err := hex.DecodeString(given)
+	if err != nil {
+		return "", newAPIError("malformed_signature")
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBody+1))
+	if err != nil {
+		return "", newAPIError("signature_body_unreadable")
+	}
+	if len(body) > maxSignedBody {
+		return "", newAPIError("signed_body_too_large")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signatureBase(r.Method, r.URL.RequestURI(), timestamp, body)))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", newAPIError("signature_mismatch")
+	}
+	// >> Only verified signatures are cached, so forged ones can't fill the cache
+	// Keyed on the decoded bytes: hex decoding ignores case, so the raw
+	// header has many spellings of the same signature.
+	if !replays.check(keyID+":"+hex.EncodeToString(sig), signedAt.Add(window), now) {
+		return "", newAPIError("signature_replayed")
+	}
+	return keyID, nil
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type shutdownHook func(ctx context.Context) error
+
+// OnShutdown registers cleanup to run after the listeners have drained.
+// Hooks run in registration order, each bounded by server.hook_timeout and
+// by the overall shutdown deadline unless that has already passed, and every
+// failure is reported.
+func (s *Server) OnShutdown(fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, fn)
+}
+
+func (s *Server) runShutdownHooks(ctx context.Context) error {
+	s.mu.Lock()
+	hooks := append([]shutdownHook(nil), s.hooks...)
+	timeout := s.cfg.Server.HookTimeout
+	s.mu.Unlock()
+
+	// >> Hooks still get their own budget after a forced close; flushing state matters most then
+	if ctx.Err() != nil {
+		ctx = context.WithoutCancel(ctx)
+	}
+
+	var errs []error
+	for i, hook := range hooks {
+		hookCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := runHook(hookCtx, hook)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shutdown hook %d: %w", i+1, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ?? A hook that ignores its context keeps running after the timeout - acceptable leak at exit?
+func runHook(ctx context.Context, hook shutdownHook) error {
+	done := make(chan error, 1)
+	go func() { done <- hook(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
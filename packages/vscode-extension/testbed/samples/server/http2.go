@@ -0,0 +1,65 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+func (s *Server) configureHTTP2(cfg H2Config) {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(cfg.Enabled)
+	s.server.Protocols = protocols
+	s.server.HTTP2 = &http.HTTP2Config{MaxConcurrentStreams: cfg.MaxConcurrentStreams}
+
+	if cfg.H2CAddr == "" {
+		return
+	}
+
+	// !! h2c has no transport security - only expose it behind a trusted load balancer
+	cleartext := new(http.Protocols)
+	cleartext.SetHTTP1(true)
+	cleartext.SetUnencryptedHTTP2(true)
+	s.addAux("h2c", &http.Server{
+		Addr:         cfg.H2CAddr,
+		Handler:      &s.handler,
+		ReadTimeout:  s.server.ReadTimeout,
+		WriteTimeout: s.server.WriteTimeout,
+		IdleTimeout:  s.server.IdleTimeout,
+		Protocols:    cleartext,
+		HTTP2:        s.server.HTTP2,
+
+		ReadHeaderTimeout: s.server.ReadHeaderTimeout,
+		MaxHeaderBytes:    s.server.MaxHeaderBytes,
+	})
+}
+
+// ?? HTTP/3 is experimental - verify QUIC is allowed through the firewall before advertising it
+func (s *Server) configureHTTP3(cfg H3Config) error {
+	if cfg.Addr == "" {
+		return nil
+	}
+	srv, err := newHTTP3Server(cfg.Addr, &s.handler, s.server.TLSConfig)
+	if err != nil {
+		return err
+	}
+	s.aux = append(s.aux, auxServer{name: "http3", addr: cfg.Addr, srv: srv})
+	return nil
+}
+
+// AltSvcMiddleware advertises the HTTP/3 endpoint on responses served over
+// TLS so clients can upgrade on their next connection.
+func AltSvcMiddleware(addr string, maxAge time.Duration) Middleware {
+	_, port, _ := net.SplitHostPort(addr)
+	value := fmt.Sprintf(`h3=":%s"; ma=%d`, port, int(maxAge.Seconds()))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil && r.ProtoMajor < 3 {
+				w.Header().Set("Alt-Svc", value)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,20 @@
+//go:build http3
+
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newHTTP3Server serves the shared handler over QUIC. quic-go negotiates its
+// own ALPN, so it gets a clone of the TCP listener's TLS config.
+func newHTTP3Server(addr string, handler http.Handler, tlsConfig *tls.Config) (auxListener, error) {
+	return &http3.Server{
+		Addr:      addr,
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(tlsConfig.Clone()),
+	}, nil
+}
@@ -0,0 +1,13 @@
+//go:build !http3
+
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+)
+
+func newHTTP3Server(string, http.Handler, *tls.Config) (auxListener, error) {
+	return nil, errors.New("HTTP/3 support not compiled in (rebuild with -tags http3)")
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Error messages in the Response envelope come from a catalog embedded
+// from locales/, one JSON file of code to message per language. Code goes
+// out beside the message and never changes with the language, so clients
+// branch on it and show the text.
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+const defaultLanguage = "en"
+
+// catalogs maps a lower-case language tag to its messages by code.
+var catalogs = loadCatalogs()
+
+func loadCatalogs() map[string]map[string]string {
+	files, _ := localeFiles.ReadDir("locales")
+	catalogs := map[string]map[string]string{}
+	for _, f := range files {
+		data, _ := localeFiles.ReadFile("locales/" + f.Name())
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			// The catalog ships in the binary, so this is a build mistake.
+			panic(fmt.Sprintf("locales/%s: %v", f.Name(), err))
+		}
+		catalogs[strings.ToLower(strings.TrimSuffix(f.Name(), path.Ext(f.Name())))] = messages
+	}
+	return catalogs
+}
+
+// localize renders code's message in lang, falling back to English and
+// then to the code itself. {1}, {2}, ... stand for args in order, so a
+// translation may reorder them or leave one out.
+func localize(lang, code string, args []any) string {
+	msg, ok := catalogs[lang][code]
+	if !ok {
+		if msg, ok = catalogs[defaultLanguage][code]; !ok {
+			msg = code // ?? an uncatalogued code reaches the client as is; a check at startup could refuse it
+		}
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	pairs := make([]string, 0, 2*len(args))
+	for i, arg := range args {
+		pairs = append(pairs, "{"+strconv.Itoa(i+1)+"}", fmt.Sprint(arg))
+	}
+	return strings.NewReplacer(pairs...).Replace(msg)
+}
+
+// apiError is an error meant for the client: a catalog code and the
+// values its message takes. Error gives the English text, for logs.
+type apiError struct {
+	code string
+	args []any
+}
+
+func newAPIError(code string, args ...any) *apiError {
+	return &apiError{code: code, args: args}
+}
+
+func (e *apiError) Error() string { return localize(defaultLanguage, e.code, e.args) }
+
+type languageKey struct{}
+
+// LanguageMiddleware picks the catalog language for the request's error
+// messages from Accept-Language.
+func LanguageMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := matchLanguage(r.Header.Get("Accept-Language"))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), languageKey{}, lang)))
+	})
+}
+
+// LanguageFrom returns the language LanguageMiddleware chose, or English.
+func LanguageFrom(ctx context.Context) string {
+	if lang, ok := ctx.Value(languageKey{}).(string); ok {
+		return lang
+	}
+	return defaultLanguage
+}
+
+// matchLanguage returns the catalog language best matching an
+// Accept-Language header: the highest-weighted range with a catalog,
+// tried as given and then as its primary subtag, so de-AT gets de.
+func matchLanguage(header string) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var ranges []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" && q > 0 {
+			ranges = append(ranges, weighted{tag, q})
+		}
+	}
+	// Stable, so equal weights keep the client's order.
+	slices.SortStableFunc(ranges, func(a, b weighted) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+	for _, r := range ranges {
+		if _, ok := catalogs[r.tag]; ok {
+			return r.tag
+		}
+		if base, _, ok := strings.Cut(r.tag, "-"); ok {
+			if _, ok := catalogs[base]; ok {
+				return base
+			}
+		}
+	}
+	return defaultLanguage
+}
+
+// errorFor is the error envelope for err: its own code when it is an
+// apiError, otherwise fallback with no arguments.
+func errorFor(ctx context.Context, err error, fallback string) Response {
+	var ae *apiError
+	if errors.As(err, &ae) {
+		return errorResponse(ctx, ae.code, ae.args...)
+	}
+	return errorResponse(ctx, fallback)
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// IDGenerator hands out the IDs that appear in responses and logs: one per
+// request, and one per user created without an ID of its own.
+type IDGenerator interface {
+	RequestID() string
+	UserID() string
+}
+
+// defaultIDs uses the arrival time in nanoseconds for requests, as before
+// IDs were injectable, and random hex for users. A stopped test clock
+// repeats request IDs, so pair WithClock with WithIDs.
+type defaultIDs struct {
+	clock Clock
+}
+
+func (g defaultIDs) RequestID() string {
+	return fmt.Sprintf("%d", g.clock.Now().UnixNano())
+}
+
+func (g defaultIDs) UserID() string {
+	return randomHex(8)
+}
+
+func randomHex(bytes int) string {
+	b := make([]byte, bytes)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,334 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrQueueClosed = errors.New("job queue closed")
+
+// Job is a unit of async work. Payload is opaque to the queue and decoded by
+// the handler registered for Kind.
+type Job struct {
+	ID        string          `json:"id"`
+	Kind      string          `json:"kind"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error,omitempty"`
+	Created   time.Time       `json:"created"`
+}
+
+type JobHandler func(ctx context.Context, job Job) error
+
+// JobBackend persists jobs so pending work survives a restart. Save is
+// called on enqueue and before every retry, Remove on success and Dead when
+// a job exhausts its attempts.
+type JobBackend interface {
+	Save(job Job) error
+	Remove(id string) error
+	Dead(job Job) error
+	Pending() ([]Job, error)
+}
+
+// memoryJobs keeps nothing; queued work is lost on exit.
+type memoryJobs struct{}
+
+func (memoryJobs) Save(Job) error          { return nil }
+func (memoryJobs) Remove(string) error     { return nil }
+func (memoryJobs) Dead(Job) error          { return nil }
+func (memoryJobs) Pending() ([]Job, error) { return nil, nil }
+
+// dirJobs stores one JSON file per job under pending/ and dead/.
+type dirJobs struct {
+	dir string
+}
+
+func openDirJobs(dir string) (dirJobs, error) {
+	for _, sub := range []string{"pending", "dead"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return dirJobs{}, err
+		}
+	}
+	return dirJobs{dir: dir}, nil
+}
+
+func (d dirJobs) file(state, id string) string {
+	return filepath.Join(d.dir, state, id+".json")
+}
+
+func (d dirJobs) Save(job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	tmp := d.file("pending", "."+job.ID)
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.file("pending", job.ID))
+}
+
+func (d dirJobs) Remove(id string) error {
+	err := os.Remove(d.file("pending", id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (d dirJobs) Dead(job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(d.file("dead", job.ID), data, 0o600); err != nil {
+		return err
+	}
+	return d.Remove(job.ID)
+}
+
+func (d dirJobs) Pending() ([]Job, error) {
+	paths, err := filepath.Glob(filepath.Join(d.dir, "pending", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var jobs []Job
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// JobQueue runs jobs on a fixed pool of workers. Failed jobs are retried
+// with exponential backoff and dead-lettered after MaxAttempts.
+type JobQueue struct {
+	cfg      JobsConfig
+	backend  JobBackend
+	logger   *slog.Logger
+	handlers map[string]JobHandler
+	// protect runs a handler; the server swaps in its supervisor so a
+	// panicking job becomes a failed attempt instead of a crash.
+	protect func(name string, fn func() error) error
+	// failed is called when a job is dead-lettered.
+	failed func(job Job, err error)
+
+	// mu guards sends on queue against Close closing it.
+	mu      sync.RWMutex
+	queue   chan Job
+	closed  bool
+	workers sync.WaitGroup
+	retries sync.WaitGroup
+	stop    chan struct{}
+	stopped sync.Once
+	nextID  atomic.Int64
+
+	succeeded, retried, dead atomic.Int64
+}
+
+func NewJobQueue(cfg JobsConfig, logger *slog.Logger) (*JobQueue, error) {
+	q := &JobQueue{
+		cfg:      cfg,
+		backend:  memoryJobs{},
+		logger:   logger,
+		handlers: map[string]JobHandler{},
+		protect:  func(_ string, fn func() error) error { return fn() },
+		failed:   func(Job, error) {},
+		queue:    make(chan Job, cfg.QueueSize),
+		stop:     make(chan struct{}),
+	}
+	if cfg.Dir != "" {
+		backend, err := openDirJobs(cfg.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("jobs.dir: %w", err)
+		}
+		q.backend = backend
+	}
+	return q, nil
+}
+
+// Handle registers the handler for a job kind. It must be called before
+// Start.
+func (q *JobQueue) Handle(kind string, fn JobHandler) {
+	q.handlers[kind] = fn
+}
+
+// Start launches the workers and requeues anything the backend still has
+// pending from a previous run.
+func (q *JobQueue) Start() error {
+	pending, err := q.backend.Pending()
+	if err != nil {
+		return err
+	}
+	for range q.cfg.Workers {
+		q.workers.Add(1)
+		go q.work()
+	}
+	for _, job := range pending {
+		q.queue <- job
+	}
+	if len(pending) > 0 {
+		q.logger.Info("requeued pending jobs", "count", len(pending))
+	}
+	return nil
+}
+
+// Enqueue persists and schedules a job. It fails fast instead of blocking
+// when the queue is full so handlers never stall on background work.
+func (q *JobQueue) Enqueue(kind string, payload any) (Job, error) {
+	if _, ok := q.handlers[kind]; !ok {
+		return Job{}, fmt.Errorf("no handler for job kind %q", kind)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, err
+	}
+	job := Job{
+		ID:      strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatInt(q.nextID.Add(1), 36),
+		Kind:    kind,
+		Payload: data,
+		Created: time.Now(),
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return Job{}, ErrQueueClosed
+	}
+	if err := q.backend.Save(job); err != nil {
+		return Job{}, err
+	}
+	select {
+	case q.queue <- job:
+		return job, nil
+	default:
+		// ?? The job is saved, so it will still run after a restart
+		return job, errors.New("job queue full")
+	}
+}
+
+func (q *JobQueue) work() {
+	defer q.workers.Done()
+	for job := range q.queue {
+		q.run(job)
+	}
+}
+
+func (q *JobQueue) run(job Job) {
+	job.Attempts++
+	logger := q.logger.With("job", job.ID, "kind", job.Kind, "attempt", job.Attempts)
+	handler, ok := q.handlers[job.Kind]
+	if !ok {
+		// Persisted by a build that registered a kind this one doesn't;
+		// no retry can help, so it's dead-lettered straight away.
+		q.deadLetter(logger, job, fmt.Errorf("no handler for job kind %q", job.Kind))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), q.cfg.Timeout)
+	err := q.protect("job/"+job.Kind, func() error { return handler(ctx, job) })
+	cancel()
+
+	if err == nil {
+		q.succeeded.Add(1)
+		if err := q.backend.Remove(job.ID); err != nil {
+			logger.Error("job cleanup failed", "err", err)
+		}
+		return
+	}
+
+	if job.Attempts >= q.cfg.MaxAttempts {
+		q.deadLetter(logger, job, err)
+		return
+	}
+	job.LastError = err.Error()
+
+	q.retried.Add(1)
+	delay := q.cfg.Backoff << (job.Attempts - 1)
+	logger.Warn("job failed, retrying", "err", err, "retry_in", delay)
+	if err := q.backend.Save(job); err != nil {
+		logger.Error("job save failed", "err", err)
+	}
+	q.retries.Add(1)
+	go func() {
+		defer q.retries.Done()
+		select {
+		case <-time.After(delay):
+			q.mu.RLock()
+			defer q.mu.RUnlock()
+			select {
+			case q.queue <- job:
+			case <-q.stop:
+			}
+		case <-q.stop:
+			// Left in the backend; the next Start picks it up.
+		}
+	}()
+}
+
+func (q *JobQueue) deadLetter(logger *slog.Logger, job Job, err error) {
+	job.LastError = err.Error()
+	q.dead.Add(1)
+	logger.Error("job dead-lettered", "err", err)
+	q.failed(job, err)
+	if err := q.backend.Dead(job); err != nil {
+		logger.Error("dead-letter write failed", "err", err)
+	}
+}
+
+// Close stops accepting jobs, abandons scheduled retries, and waits for the
+// workers to finish what is already queued. Whatever remains when ctx
+// expires stays in the backend. It is registered as a shutdown hook.
+func (q *JobQueue) Close(ctx context.Context) error {
+	// >> Stop retries before taking the lock; a pending retry send holds the read lock
+	q.stopped.Do(func() { close(q.stop) })
+	q.retries.Wait()
+
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.queue)
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("job queue drain: %w (%d queued)", ctx.Err(), len(q.queue))
+	}
+}
+
+func (q *JobQueue) stats() map[string]int64 {
+	return map[string]int64{
+		"queued":    int64(len(q.queue)),
+		"succeeded": q.succeeded.Load(),
+		"retried":   q.retried.Load(),
+		"dead":      q.dead.Load(),
+	}
+}
+
+// Jobs returns the server's queue so embedders can register handlers
+// before Start and enqueue from their own handlers.
+func (s *Server) Jobs() *JobQueue { return s.jobs }
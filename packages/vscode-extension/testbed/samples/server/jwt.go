@@ -0,0 +1,130 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Claims are the fields of an access token. Tenant scopes the token to the
+// tenant it was issued in.
+type Claims struct {
+	Issuer    string `json:"iss,omitempty"`
+	Subject   string `json:"sub"`
+	Tenant    string `json:"tenant,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	// NotBefore, when set, is the first second the token is valid.
+	NotBefore int64  `json:"nbf,omitempty"`
+	ID        string `json:"jti"`
+	// Scope is space-separated, as in OAuth 2.0.
+	Scope string `json:"scope,omitempty"`
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// signingKeys is an HS256 key ring. The first key signs; every key
+// verifies, so a new key can be put first while tokens signed with the old
+// one expire.
+type signingKeys []signingKey
+
+type signingKey struct {
+	id     string
+	secret []byte
+}
+
+// minSigningKeyLen matches the HS256 output size, as RFC 7518 requires.
+const minSigningKeyLen = 32
+
+// parseSigningKeys reads "kid:secret" entries.
+func parseSigningKeys(entries []string) (signingKeys, error) {
+	var keys signingKeys
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		id, secret, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, errors.New("signing key: want kid:secret")
+		}
+		if len(secret) < minSigningKeyLen {
+			return nil, fmt.Errorf("signing key %q: secret must be at least %d bytes", id, minSigningKeyLen)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("signing key %q: duplicate kid", id)
+		}
+		seen[id] = true
+		keys = append(keys, signingKey{id: id, secret: []byte(secret)})
+	}
+	return keys, nil
+}
+
+var b64url = base64.RawURLEncoding
+
+func (keys signingKeys) sign(c Claims) (string, error) {
+	if len(keys) == 0 {
+		return "", errors.New("no signing keys configured")
+	}
+	header, _ := json.Marshal(jwtHeader{Alg: "HS256", Typ: "JWT", Kid: keys[0].id})
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	signed := b64url.EncodeToString(header) + "." + b64url.EncodeToString(payload)
+	return signed + "." + b64url.EncodeToString(hs256(keys[0].secret, signed)), nil
+}
+
+var errInvalidToken = newAPIError("invalid_token")
+
+// verify checks the signature, expiry and nbf. Only HS256 is accepted,
+// whatever the header claims.
+func (keys signingKeys) verify(token string, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, errInvalidToken
+	}
+	var header jwtHeader
+	if raw, err := b64url.DecodeString(parts[0]); err != nil || json.Unmarshal(raw, &header) != nil {
+		return Claims{}, errInvalidToken
+	}
+	if header.Alg != "HS256" {
+		return Claims{}, errInvalidToken
+	}
+	sig, err := b64url.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, errInvalidToken
+	}
+	var key *signingKey
+	for i := range keys {
+		if keys[i].id == header.Kid {
+			key = &keys[i]
+		}
+	}
+	if key == nil || !hmac.Equal(sig, hs256(key.secret, parts[0]+"."+parts[1])) {
+		return Claims{}, errInvalidToken
+	}
+	var c Claims
+	if raw, err := b64url.DecodeString(parts[1]); err != nil || json.Unmarshal(raw, &c) != nil {
+		return Claims{}, errInvalidToken
+	}
+	if now.Unix() >= c.ExpiresAt {
+		return Claims{}, newAPIError("token_expired")
+	}
+	if now.Unix() < c.NotBefore {
+		return Claims{}, errInvalidToken
+	}
+	return c, nil
+}
+
+func hs256(secret []byte, data string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testKeys(t *testing.T, ids ...string) signingKeys {
+	t.Helper()
+	var entries []string
+	for _, id := range ids {
+		entries = append(entries, id+":"+strings.Repeat(id, minSigningKeyLen))
+	}
+	keys, err := parseSigningKeys(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// errCode is the catalog code of an *apiError, or "" for nil.
+func errCode(err error) string {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr.code
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// forge re-signs token's payload under a header of the caller's choosing.
+func forge(t *testing.T, token string, header jwtHeader, secret []byte) string {
+	t.Helper()
+	raw, _ := json.Marshal(header)
+	signed := b64url.EncodeToString(raw) + "." + strings.Split(token, ".")[1]
+	if secret == nil {
+		return signed + "."
+	}
+	return signed + "." + b64url.EncodeToString(hs256(secret, signed))
+}
+
+func TestJWTVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	keys := testKeys(t, "a")
+	claims := Claims{Subject: "u-1", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix(), ID: "jti"}
+	sign := func(c Claims) string {
+		token, err := keys.sign(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := sign(claims)
+	notBefore := claims
+	notBefore.NotBefore = now.Add(30 * time.Second).Unix()
+
+	tests := []struct {
+		name  string
+		token string
+		at    time.Time
+		want  string
+	}{
+		{"valid", valid, now, ""},
+		{"alg none", forge(t, valid, jwtHeader{Alg: "none", Typ: "JWT", Kid: "a"}, nil), now, "invalid_token"},
+		// !! HS512 with the right secret is still not HS256
+		{"alg HS512", forge(t, valid, jwtHeader{Alg: "HS512", Typ: "JWT", Kid: "a"}, keys[0].secret), now, "invalid_token"},
+		{"bad signature", valid[:len(valid)-2] + "AA", now, "invalid_token"},
+		{"other secret", forge(t, valid, jwtHeader{Alg: "HS256", Typ: "JWT", Kid: "a"}, []byte(strings.Repeat("x", minSigningKeyLen))), now, "invalid_token"},
+		{"unknown kid", forge(t, valid, jwtHeader{Alg: "HS256", Typ: "JWT", Kid: "b"}, keys[0].secret), now, "invalid_token"},
+		{"malformed", "a.b", now, "invalid_token"},
+		{"last second", valid, now.Add(time.Minute - time.Second), ""},
+		{"expired", valid, now.Add(time.Minute), "token_expired"},
+		{"before nbf", sign(notBefore), now.Add(29 * time.Second), "invalid_token"},
+		{"at nbf", sign(notBefore), now.Add(30 * time.Second), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := keys.verify(tt.token, tt.at)
+			if code := errCode(err); code != tt.want {
+				t.Fatalf("verify error = %q, want %q", code, tt.want)
+			}
+			if err == nil && got.Subject != claims.Subject {
+				t.Errorf("verify subject = %q, want %q", got.Subject, claims.Subject)
+			}
+		})
+	}
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	claims := Claims{Subject: "u-1", ExpiresAt: now.Add(time.Minute).Unix()}
+	before := testKeys(t, "old")
+	old, _ := before.sign(claims)
+
+	// The new key goes first and signs; the old one still verifies.
+	during := testKeys(t, "new", "old")
+	fresh, _ := during.sign(claims)
+	var header jwtHeader
+	raw, _ := b64url.DecodeString(strings.Split(fresh, ".")[0])
+	if json.Unmarshal(raw, &header); header.Kid != "new" {
+		t.Errorf("rotated ring signed with kid %q, want the first key's", header.Kid)
+	}
+	for name, token := range map[string]string{"old": old, "new": fresh} {
+		if _, err := during.verify(token, now); err != nil {
+			t.Errorf("during rotation, %s token: %v", name, err)
+		}
+	}
+
+	after := testKeys(t, "new")
+	if _, err := after.verify(old, now); errCode(err) != "invalid_token" {
+		t.Errorf("old token after its key was dropped: %v", err)
+	}
+	if _, err := after.verify(fresh, now); err != nil {
+		t.Errorf("new token after rotation: %v", err)
+	}
+	if _, err := (signingKeys{}).sign(claims); err == nil {
+		t.Error("an empty ring signed a token")
+	}
+}
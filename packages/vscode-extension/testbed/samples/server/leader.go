@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// LeaseBackend stores a single named lease shared by every instance.
+// Acquire takes the lease if it is free or expired and renews it if holder
+// already owns it; it reports whether holder is the leader afterwards.
+type LeaseBackend interface {
+	Acquire(holder string, ttl time.Duration) (bool, error)
+	Release(holder string) error
+}
+
+type leaseRecord struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// fileLease keeps the lease record in a file next to the shared store,
+// serialized with flock on a sibling lock file.
+// ?? flock over NFS depends on the server honoring it; prefer local or cluster filesystems
+type fileLease struct {
+	path string
+}
+
+func (l fileLease) Acquire(holder string, ttl time.Duration) (bool, error) {
+	var won bool
+	err := l.locked(func(rec leaseRecord) (*leaseRecord, error) {
+		now := time.Now()
+		if rec.Holder != "" && rec.Holder != holder && now.Before(rec.Expires) {
+			return nil, nil
+		}
+		won = true
+		return &leaseRecord{Holder: holder, Expires: now.Add(ttl)}, nil
+	})
+	return won, err
+}
+
+func (l fileLease) Release(holder string) error {
+	return l.locked(func(rec leaseRecord) (*leaseRecord, error) {
+		if rec.Holder != holder {
+			return nil, nil
+		}
+		return &leaseRecord{}, nil
+	})
+}
+
+// locked runs update with the lease file locked; a non-nil result is
+// written back before the lock is released.
+func (l fileLease) locked(update func(leaseRecord) (*leaseRecord, error)) error {
+	lock, err := os.OpenFile(l.path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	var rec leaseRecord
+	data, err := os.ReadFile(l.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	case len(data) > 0:
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return fmt.Errorf("lease %s: %w", l.path, err)
+		}
+	}
+
+	next, err := update(rec)
+	if err != nil || next == nil {
+		return err
+	}
+	data, err = json.Marshal(next)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(l.path), "."+filepath.Base(l.path)+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// Elector campaigns for the lease in the background and tracks whether this
+// instance currently leads. Without a backend every instance is the leader,
+// which is right for a single deployment.
+type Elector struct {
+	backend LeaseBackend
+	id      string
+	ttl     time.Duration
+	logger  *slog.Logger
+	leader  atomic.Bool
+}
+
+func NewElector(cfg LeaderConfig, logger *slog.Logger) *Elector {
+	e := &Elector{id: cfg.ID, ttl: cfg.TTL, logger: logger}
+	if e.id == "" {
+		host, _ := os.Hostname()
+		e.id = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if cfg.Lease != "" {
+		e.backend = fileLease{path: cfg.Lease}
+	} else {
+		e.leader.Store(true)
+	}
+	return e
+}
+
+func (e *Elector) IsLeader() bool { return e.leader.Load() }
+
+// Run renews the lease at a third of its TTL until done is closed, so a
+// leader survives two missed renewals before another instance can take over.
+func (e *Elector) Run(done <-chan struct{}) {
+	if e.backend == nil {
+		return
+	}
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	for {
+		e.campaign()
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) campaign() {
+	won, err := e.backend.Acquire(e.id, e.ttl)
+	if err != nil {
+		// !! Step down on errors; two leaders is worse than none for a tick
+		e.logger.Error("leader lease renewal failed", "id", e.id, "err", err)
+		won = false
+	}
+	if was := e.leader.Swap(won); was != won {
+		if won {
+			e.logger.Info("elected leader", "id", e.id)
+		} else {
+			e.logger.Warn("lost leadership", "id", e.id)
+		}
+	}
+}
+
+// Release gives up the lease so a peer can take over without waiting for
+// it to expire. It is registered as a shutdown hook.
+func (e *Elector) Release(context.Context) error {
+	if e.backend == nil || !e.leader.Swap(false) {
+		return nil
+	}
+	return e.backend.Release(e.id)
+}
+
+// Singleton runs fn every interval on whichever instance holds the lease.
+// Ticks on followers are skipped, not queued.
+func (s *Server) Singleton(name string, every time.Duration, fn func(ctx context.Context) error) {
+	s.supervisor.Go("singleton/"+name, func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+			if !s.elector.IsLeader() {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), every)
+			err := s.supervisor.Protect("singleton/"+name, func() error { return fn(ctx) })
+			if err != nil {
+				s.logger.Error("singleton task failed", "task", name, "err", err)
+			}
+			cancel()
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listen opens every listener the config asks for. Each one is served by the
+// same http.Server, so Shutdown drains them together. Sockets inherited from
+// systemd or from an upgrading parent take the place of any configured
+// addresses.
+func (s *Server) listen() ([]net.Listener, error) {
+	if len(s.preset) > 0 {
+		return s.preset, nil
+	}
+	for _, inherit := range []func() ([]net.Listener, error){systemdListeners, upgradeListeners} {
+		listeners, err := inherit()
+		if err != nil || len(listeners) > 0 {
+			for _, ln := range listeners {
+				s.logger.Info("using inherited socket", "network", ln.Addr().Network(), "addr", ln.Addr().String())
+			}
+			return listeners, err
+		}
+	}
+
+	var listeners []net.Listener
+	if !s.cfg.Unix.Only {
+		ln, err := s.bindPolicy().listen("tcp", s.server.Addr)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+	}
+	if s.cfg.Unix.Path != "" {
+		ln, err := listenUnix(s.cfg.Unix.Path, s.cfg.Unix.Mode)
+		if err != nil {
+			closeAll(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// >> Unix sockets are served in cleartext even when TLS is on; they never leave the host
+func (s *Server) serve(ln net.Listener, useTLS bool) error {
+	if !useTLS || ln.Addr().Network() == "unix" {
+		return s.server.Serve(ln)
+	}
+	return s.server.ServeTLS(ln, "", "")
+}
+
+func listenUnix(path, mode string) (net.Listener, error) {
+	perm, err := parseFileMode(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	// A socket left by a crashed process blocks bind; anything else at the
+	// path is someone else's file and is left alone.
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	slog.Info("listening on unix socket", "path", path, "mode", perm.String())
+	return ln, nil
+}
+
+func parseFileMode(mode string) (fs.FileMode, error) {
+	n, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || n > 0o777 {
+		return 0, fmt.Errorf("invalid file mode %q", mode)
+	}
+	return fs.FileMode(n), nil
+}
+
+func closeAll(listeners []net.Listener) {
+	for _, ln := range listeners {
+		ln.Close()
+	}
+}
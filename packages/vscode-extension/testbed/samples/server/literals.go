@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// The literals below hold text that looks like markers. Only the comments
+// around them are real; a marker inside a string, a raw string or a struct
+// tag must not be highlighted or reported.
+
+// webhookTarget is a subscriber endpoint as the admin API returns it.
+type webhookTarget struct {
+	ID  string `json:"id"`
+	URL string `json:"url"` // !! never log this field; subscribers put tokens in the query
+	// ?? should Events be a set? Duplicates are harmless but ugly in the UI
+	Events []string `json:"events,omitempty" yaml:"events"`
+	Note   string   `json:"note" help:"?? free text shown next to the target"`
+}
+
+// legacyCallbackURL is what older clients registered: an empty value
+// before the first ? and a second ? inside the query.
+//
+// >> Kept verbatim so the migration test can match it byte for byte
+const legacyCallbackURL = "https://hooks.example.com/in??token=abc&next=/done?"
+
+// statusQuery is sent to the reporting database; its comments travel with
+// it and are the database's business, not ours.
+const statusQuery = `
+-- !! not a Go comment: this is SQL inside a raw string
+SELECT id, state
+FROM jobs
+WHERE state != 'done' -- ?? also SQL
+`
+
+// statusPage renders the operator's status page.
+var statusPage = template.Must(template.New("status").Parse(`<!doctype html>
+<!-- >> an HTML comment in a raw string, not a marker -->
+<title>{{.Name}}</title>
+<pre>
+// ?? looks like a Go comment, but it is page text
+{{range .Jobs}}{{.}}
+{{end}}</pre>
+`))
+
+// usageText has a marker at the start of a line in a raw string, the case
+// -strings exists for.
+var usageText = `usage: server [flags]
+!! the -insecure flag disables TLS verification everywhere
+`
+
+func writeTargets(w http.ResponseWriter, targets []webhookTarget) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(targets); err != nil {
+		http.Error(w, "!! encoding failed", http.StatusInternalServerError) // ?? 500 or drop the connection?
+	}
+}
+
+// redactQuery hides every query value in u. Some subscribers put the
+// query in the fragment too ("#?token=..."), which is left alone.
+func redactQuery(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "??" // >> an unparsable URL is shown as ?? rather than echoed back
+	}
+	q := parsed.Query()
+	for k := range q {
+		q.Set(k, "redacted")
+	}
+	parsed.RawQuery = q.Encode()
+	return parsed.String()
+}
+
+/* A block comment next to a raw string:
+ * !! the closing backtick below must not end this comment
+ */
+var rawBacktick = "`" + `// ?? still inside the raw string` + "`"
+
+func splitDirectives(s string) []string {
+	// >> a rune literal holding a quote must not open a string
+	quote := '"'
+	return strings.FieldsFunc(s, func(r rune) bool { return r == quote || r == '`' })
+}
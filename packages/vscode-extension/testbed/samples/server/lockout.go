@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AuthFailureEvent is published for every rejected login or API key.
+// LockedFor is set when the failure started a lockout of Identity or IP.
+type AuthFailureEvent struct {
+	Method    string // login or apikey
+	Identity  string
+	IP        string
+	LockedFor time.Duration
+}
+
+// lockoutTracker counts authentication failures per identity and per
+// source address. Threshold failures within Window lock the key for Base,
+// doubling with each further lockout up to Max; the doubling resets once a
+// key has gone a full Window without failing.
+// !! Counters are per replica - an attacker spreading attempts across instances gets Threshold tries on each
+type lockoutTracker struct {
+	cfg    LockoutConfig
+	events *EventBus
+	clock  Clock
+
+	mu      sync.Mutex
+	entries map[string]*lockoutEntry
+}
+
+type lockoutEntry struct {
+	failures int
+	first    time.Time
+	last     time.Time
+	lockouts int
+	until    time.Time
+}
+
+func newLockoutTracker(cfg LockoutConfig, events *EventBus, clock Clock) *lockoutTracker {
+	return &lockoutTracker{cfg: cfg, events: events, clock: clock, entries: map[string]*lockoutEntry{}}
+}
+
+// locked returns how much longer the first locked key stays locked.
+func (t *lockoutTracker) locked(keys ...string) (time.Duration, bool) {
+	if t.cfg.Threshold <= 0 {
+		return 0, false
+	}
+	now := t.clock.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range keys {
+		if e, ok := t.entries[key]; ok && now.Before(e.until) {
+			return e.until.Sub(now), true
+		}
+	}
+	return 0, false
+}
+
+// fail records a failure against each key and publishes it.
+func (t *lockoutTracker) fail(ctx context.Context, method, identity, ip string) {
+	if t.cfg.Threshold <= 0 {
+		return
+	}
+	var lockedFor time.Duration
+	for _, key := range []string{method + ":" + identity, "ip:" + ip} {
+		if d := t.record(key); d > lockedFor {
+			lockedFor = d
+		}
+	}
+	if lockedFor > 0 {
+		LoggerFrom(ctx).Warn("authentication locked out", "method", method, "identity", identity, "ip", ip, "locked_for", lockedFor)
+	}
+	t.events.Publish(ctx, AuthFailureEvent{Method: method, Identity: identity, IP: ip, LockedFor: lockedFor})
+}
+
+func (t *lockoutTracker) record(key string) time.Duration {
+	now := t.clock.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.entries) > 10000 {
+		t.prune(now)
+	}
+	e, ok := t.entries[key]
+	if !ok {
+		e = &lockoutEntry{}
+		t.entries[key] = e
+	}
+	if now.Sub(e.last) > t.cfg.Window {
+		e.lockouts = 0
+	}
+	if now.Sub(e.first) > t.cfg.Window {
+		e.failures, e.first = 0, now
+	}
+	e.failures++
+	e.last = now
+	if e.failures < t.cfg.Threshold {
+		return 0
+	}
+	d := t.cfg.Base << e.lockouts
+	if d > t.cfg.Max || d <= 0 {
+		d = t.cfg.Max
+	}
+	e.lockouts++
+	e.failures = 0
+	e.until = now.Add(d)
+	return d
+}
+
+// succeed clears the identity's failures; the address keeps its count so
+// one valid account can't be used to reset guessing against others.
+func (t *lockoutTracker) succeed(method, identity string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.entries, method+":"+identity)
+}
+
+func (t *lockoutTracker) prune(now time.Time) {
+	for key, e := range t.entries {
+		if now.After(e.until) && now.Sub(e.last) > t.cfg.Window {
+			delete(t.entries, key)
+		}
+	}
+}
+
+func writeLockedOut(w http.ResponseWriter, r *http.Request, retry time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int((retry+time.Second-1)/time.Second)))
+	renderJSON(w, r, http.StatusTooManyRequests, errorResponse(r.Context(), "too_many_attempts"))
+}
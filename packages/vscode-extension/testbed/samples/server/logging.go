@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/pii"
+)
+
+// logLevel is shared by every handler built by newLogger so a reload can
+// change verbosity without replacing the logger.
+var logLevel = new(slog.LevelVar)
+
+func parseLogLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.ToUpper(level))); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+func newLogger(cfg LoggingConfig, w io.Writer) *slog.Logger {
+	logLevel.Set(parseLogLevel(cfg.Level))
+	piiRules.Store(newPIIRedactor(cfg))
+	pii.SetRedact(cfg.RedactPII)
+	opts := &slog.HandlerOptions{Level: logLevel}
+	if cfg.Format == "json" {
+		return slog.New(piiHandler{slog.NewJSONHandler(w, opts)})
+	}
+	return slog.New(piiHandler{slog.NewTextHandler(w, opts)})
+}
+
+type principalKey struct{}
+
+// withPrincipal records who is making the request and adds them to the
+// request logger.
+func withPrincipal(ctx context.Context, principal string) context.Context {
+	ctx = context.WithValue(ctx, principalKey{}, principal)
+	return withLogger(ctx, LoggerFrom(ctx).With("principal", principal))
+}
+
+// PrincipalFrom returns the authenticated caller, or "" for anonymous
+// requests.
+func PrincipalFrom(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return principal
+}
+
+// routeLogger adds the matched route pattern to the request logger.
+func routeLogger(pattern string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger := LoggerFrom(r.Context()).With("route", pattern)
+			next.ServeHTTP(w, r.WithContext(withLogger(r.Context(), logger)))
+		})
+	}
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// >> Unwrap lets http.ResponseController reach Flush and Hijack underneath
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// statusWriters recycles the per-request wrappers of the access-log and
+// metrics middleware, which every request passes through.
+var statusWriters = sync.Pool{New: func() any { return new(statusWriter) }}
+
+func getStatusWriter(w http.ResponseWriter) *statusWriter {
+	sw := statusWriters.Get().(*statusWriter)
+	*sw = statusWriter{ResponseWriter: w}
+	return sw
+}
+
+// putStatusWriter must only be called once the handler has returned. A
+// panicking request skips it and leaves its writer to the collector.
+func putStatusWriter(sw *statusWriter) {
+	sw.ResponseWriter = nil
+	statusWriters.Put(sw)
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// levelOverride tracks a temporary level change so it can revert on its own
+// once the incident window passes.
+var levelOverride struct {
+	mu    sync.Mutex
+	timer *time.Timer
+	until time.Time
+}
+
+// setLogLevel changes the active level, logging the change to logger. A
+// positive ttl reverts to the previous level afterwards; a later change
+// replaces any pending revert.
+func setLogLevel(logger *slog.Logger, level slog.Level, ttl time.Duration) time.Time {
+	levelOverride.mu.Lock()
+	defer levelOverride.mu.Unlock()
+
+	previous := logLevel.Level()
+	if levelOverride.timer != nil {
+		levelOverride.timer.Stop()
+		levelOverride.timer = nil
+	}
+	levelOverride.until = time.Time{}
+	logLevel.Set(level)
+	logger.Warn("log level changed", "from", previous, "to", level, "ttl", ttl)
+
+	if ttl > 0 {
+		levelOverride.until = time.Now().Add(ttl)
+		levelOverride.timer = time.AfterFunc(ttl, func() {
+			levelOverride.mu.Lock()
+			defer levelOverride.mu.Unlock()
+			logLevel.Set(previous)
+			levelOverride.timer = nil
+			levelOverride.until = time.Time{}
+			logger.Warn("log level override expired", "level", previous)
+		})
+	}
+	return levelOverride.until
+}
+
+// stepLogLevel moves one level more (negative) or less (positive) verbose,
+// clamped to debug and error. It backs the SIGTTIN/SIGTTOU fallback.
+func stepLogLevel(delta int) {
+	level := logLevel.Level() + slog.Level(delta*4)
+	setLogLevel(slog.Default(), min(max(level, slog.LevelDebug), slog.LevelError), 0)
+}
+
+type logLevelRequest struct {
+	Level    string `json:"level"`
+	Duration string `json:"duration,omitempty"`
+}
+
+type logLevelStatus struct {
+	Level string     `json:"level"`
+	Until *time.Time `json:"until,omitempty"`
+}
+
+func currentLogLevel() logLevelStatus {
+	levelOverride.mu.Lock()
+	defer levelOverride.mu.Unlock()
+	status := logLevelStatus{Level: logLevel.Level().String()}
+	if !levelOverride.until.IsZero() {
+		until := levelOverride.until
+		status.Until = &until
+	}
+	return status
+}
+
+func (s *Server) handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		renderJSON(w, r, http.StatusOK, Response{Success: true, Data: currentLogLevel()})
+	case http.MethodPut:
+		var req logLevelRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(req.Level)); err != nil {
+			http.Error(w, "Unknown log level", http.StatusBadRequest)
+			return
+		}
+		var ttl time.Duration
+		if req.Duration != "" {
+			d, err := time.ParseDuration(req.Duration)
+			if err != nil || d < 0 {
+				http.Error(w, "Invalid duration", http.StatusBadRequest)
+				return
+			}
+			ttl = d
+		}
+		previous := logLevel.Level()
+		setLogLevel(LoggerFrom(r.Context()), level, ttl)
+		s.audit(r.Context(), "admin", "loglevel.set", "", map[string]auditChange{
+			"level": {From: previous.String(), To: level.String()},
+			"ttl":   {From: nil, To: ttl.String()},
+		})
+		renderJSON(w, r, http.StatusOK, Response{Success: true, Data: currentLogLevel()})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// logDropped counts log lines discarded because a sink fell behind.
+var logDropped = expvar.NewInt("log_dropped_lines")
+
+type logSink interface {
+	io.Writer
+	Close() error
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// openLogSinks builds the writers named in cfg.Sinks. Sinks that talk to
+// another process are wrapped in asyncSink so a slow or dead one drops lines
+// instead of blocking the request that is logging.
+func openLogSinks(cfg LoggingConfig) ([]logSink, error) {
+	sinks := cfg.Sinks
+	if len(sinks) == 0 {
+		sinks = []string{"stderr"}
+		if cfg.File != "" {
+			sinks = []string{"file"}
+		}
+	}
+	var out []logSink
+	fail := func(err error) ([]logSink, error) {
+		for _, s := range out {
+			s.Close()
+		}
+		return nil, err
+	}
+	for _, name := range sinks {
+		switch name {
+		case "stderr":
+			out = append(out, nopCloser{os.Stderr})
+		case "file":
+			f, err := OpenRotatingFile(cfg)
+			if err != nil {
+				return fail(fmt.Errorf("open log file: %w", err))
+			}
+			out = append(out, f)
+		case "syslog":
+			network := ""
+			if cfg.SyslogAddr != "" {
+				network = "udp"
+			}
+			// An empty address uses the local socket, which journald also serves.
+			w, err := syslog.Dial(network, cfg.SyslogAddr, syslog.LOG_INFO|syslog.LOG_DAEMON, cfg.SyslogTag)
+			if err != nil {
+				return fail(fmt.Errorf("syslog: %w", err))
+			}
+			out = append(out, newAsyncSink(w, cfg.SinkBuffer))
+		case "http":
+			out = append(out, newAsyncSink(newHTTPSink(cfg), cfg.SinkBuffer))
+		default:
+			return fail(fmt.Errorf("unknown log sink %q", name))
+		}
+	}
+	return out, nil
+}
+
+// fanout writes each record to every sink. A failing sink doesn't stop the
+// others; its error is reported to stderr at most once a minute.
+type fanout struct {
+	sinks    []logSink
+	mu       sync.Mutex
+	reported time.Time
+}
+
+func (f *fanout) Write(p []byte) (int, error) {
+	for _, s := range f.sinks {
+		if _, err := s.Write(p); err != nil {
+			f.report(err)
+		}
+	}
+	return len(p), nil
+}
+
+func (f *fanout) report(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if time.Since(f.reported) < time.Minute {
+		return
+	}
+	f.reported = time.Now()
+	fmt.Fprintf(os.Stderr, "log sink error: %v\n", err)
+}
+
+func (f *fanout) Close() error {
+	var errs []error
+	for _, s := range f.sinks {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// asyncSink hands lines to a background writer through a bounded buffer.
+type asyncSink struct {
+	w     logSink
+	lines chan []byte
+	done  chan struct{}
+
+	// mu guards closing lines against a Write racing Close, since the
+	// default logger can still be writing while the process exits.
+	mu     sync.Mutex
+	closed bool
+}
+
+func newAsyncSink(w logSink, buffer int) *asyncSink {
+	s := &asyncSink{w: w, lines: make(chan []byte, buffer), done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		for line := range s.lines {
+			s.w.Write(line)
+		}
+	}()
+	return s
+}
+
+// !! Never blocks: a full buffer drops the line and bumps log_dropped_lines
+func (s *asyncSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		logDropped.Add(1)
+		return len(p), nil
+	}
+	select {
+	case s.lines <- bytes.Clone(p):
+	default:
+		logDropped.Add(1)
+	}
+	return len(p), nil
+}
+
+// Close flushes what is buffered, waiting at most a few seconds.
+func (s *asyncSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.lines)
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+	case <-time.After(3 * time.Second):
+		return errors.New("log sink flush timed out")
+	}
+	return s.w.Close()
+}
+
+// httpSink batches lines and POSTs them as newline-delimited records.
+type httpSink struct {
+	url    string
+	every  time.Duration
+	max    int
+	client *http.Client
+
+	mu    sync.Mutex
+	batch bytes.Buffer
+	count int
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func newHTTPSink(cfg LoggingConfig) *httpSink {
+	s := &httpSink{
+		url:    cfg.CollectorURL,
+		every:  cfg.CollectorFlush,
+		max:    cfg.CollectorBatch,
+		client: &http.Client{Timeout: 10 * time.Second},
+		stop:   make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.loop()
+	return s
+}
+
+func (s *httpSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	s.batch.Write(p)
+	s.count++
+	full := s.count >= s.max
+	s.mu.Unlock()
+	if full {
+		return len(p), s.flush()
+	}
+	return len(p), nil
+}
+
+func (s *httpSink) loop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.flush()
+		}
+	}
+}
+
+func (s *httpSink) flush() error {
+	s.mu.Lock()
+	if s.count == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	body := bytes.Clone(s.batch.Bytes())
+	lines := s.count
+	s.batch.Reset()
+	s.count = 0
+	s.mu.Unlock()
+
+	resp, err := s.client.Post(s.url, "application/x-ndjson", bytes.NewReader(body))
+	if err == nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			err = fmt.Errorf("log collector returned %s", resp.Status)
+		}
+	}
+	if err != nil {
+		// ?? No retry: a collector outage costs log lines, not memory
+		logDropped.Add(int64(lines))
+		return err
+	}
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	close(s.stop)
+	s.wg.Wait()
+	return s.flush()
+}
+
+func validateLogSinks(cfg LoggingConfig) error {
+	for _, name := range cfg.Sinks {
+		switch name {
+		case "stderr", "syslog":
+		case "file":
+			if cfg.File == "" {
+				return errors.New("log sink file requires logging.file")
+			}
+		case "http":
+			if _, err := url.ParseRequestURI(cfg.CollectorURL); err != nil {
+				return fmt.Errorf("log sink http requires logging.collector_url: %w", err)
+			}
+		default:
+			return fmt.Errorf("unknown log sink %q", name)
+		}
+	}
+	if cfg.SinkBuffer < 1 || cfg.CollectorBatch < 1 || cfg.CollectorFlush <= 0 {
+		return errors.New("logging.sink_buffer, collector_batch and collector_flush must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/storetest"
+)
+
+// Tests beside the server code, so the extension can be checked on a
+// _test file: markers sit in comments of table-driven cases and subtests,
+// and the ones inside test names are strings, found only with -strings.
+
+// userReader and userWriter split Store so a test helper can ask for no
+// more than it uses.
+type userReader interface {
+	Get(id string) (User, bool)
+	Len() int
+}
+
+type userWriter interface {
+	Set(user User)
+	Delete(id string) bool
+}
+
+type userReadWriter interface {
+	userReader // >> embedded first so Get and Len lead in gopls completions
+	userWriter
+}
+
+// countingStore wraps a Store and counts writes; every other method is
+// the embedded one.
+type countingStore struct {
+	Store
+	sets int
+}
+
+func (s *countingStore) Set(user User) {
+	s.sets++
+	s.Store.Set(user)
+}
+
+// seed writes users through w and fails t if any of them go missing.
+func seed(t *testing.T, w userReadWriter, users ...User) {
+	t.Helper()
+	for _, u := range users {
+		w.Set(u)
+	}
+	for _, u := range users {
+		if _, ok := w.Get(u.ID); !ok {
+			t.Fatalf("seed: user %s missing after Set", u.ID)
+		}
+	}
+}
+
+func TestParseBenchMix(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []benchEndpoint
+		wantErr string
+	}{
+		{
+			name: "single route",
+			spec: "GET /users",
+			want: []benchEndpoint{{method: "GET", path: "/users", weight: 1}},
+		},
+		{
+			name: "weights and lower-case methods",
+			spec: "get /users=3, post /users=1",
+			want: []benchEndpoint{
+				{method: "GET", path: "/users", weight: 3},
+				{method: "POST", path: "/users", weight: 1},
+			},
+		},
+		{
+			// >> a trailing comma is tolerated so specs can be built by appending
+			name: "trailing comma",
+			spec: "GET /health,",
+			want: []benchEndpoint{{method: "GET", path: "/health", weight: 1}},
+		},
+		{
+			name:    "?? zero weight", // a string, so not a marker without -strings
+			spec:    "GET /users=0",
+			wantErr: "weight must be a positive integer",
+		},
+		{
+			name:    "path without slash",
+			spec:    "GET users",
+			wantErr: "want METHOD /path=weight",
+		},
+		{
+			// !! an empty spec must fail, or bench would loop without sending anything
+			name:    "empty",
+			spec:    " , ",
+			wantErr: "mix is empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBenchMix(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseBenchMix(%q) error = %v, want %q", tt.spec, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseBenchMix(%q): %v", tt.spec, err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("parseBenchMix(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{10, 20, 30, 40, 50}
+	for _, tt := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, 10},
+		{0.5, 30},
+		{0.99, 40}, // ?? rounds down, so p99 of five samples is the fourth; nearest-rank would say the fifth
+		{1, 50},
+	} {
+		t.Run(fmt.Sprintf("p%v", tt.p*100), func(t *testing.T) {
+			if got := percentile(sorted, tt.p); got != tt.want {
+				t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+	if got := percentile(nil, 0.5); got != 0 {
+		t.Errorf("percentile(nil) = %v, want 0", got)
+	}
+}
+
+func TestStoreTenants(t *testing.T) {
+	mock := storetest.NewMockStore()
+	acme := &countingStore{Store: mock.ForTenant("acme")}
+	seed(t, acme, User{ID: "u1", Name: "Ada"}, User{ID: "u2", Name: "Grace"})
+
+	t.Run("writes counted through the wrapper", func(t *testing.T) {
+		if acme.sets != 2 {
+			t.Errorf("sets = %d, want 2", acme.sets)
+		}
+		if n := mock.CallCount("set"); n != 2 {
+			t.Errorf("mock saw %d sets, want 2", n)
+		}
+	})
+	t.Run("other tenants see nothing", func(t *testing.T) {
+		// !! Len on the root view must stay 0; a leak here is a cross-tenant read
+		if n := mock.Len(); n != 0 {
+			t.Errorf("default tenant Len = %d, want 0", n)
+		}
+		if _, ok := mock.ForTenant("globex").Get("u1"); ok {
+			t.Error("globex can read acme's u1")
+		}
+	})
+	t.Run("!! delete is per tenant", func(t *testing.T) {
+		if mock.ForTenant("globex").Delete("u1") {
+			t.Error("globex deleted acme's u1")
+		}
+		if !acme.Delete("u1") || acme.Len() != 1 {
+			t.Errorf("after delete Len = %d, want 1", acme.Len())
+		}
+	})
+}
+
+func TestTTLCache(t *testing.T) {
+	t.Parallel() // >> nothing here shares state, so run beside the store tests
+
+	t.Run("hit", func(t *testing.T) {
+		c := newTTLCache[string, int](time.Minute)
+		c.Put("a", 1)
+		if v, ok := c.Get("a"); !ok || v != 1 {
+			t.Errorf("Get(a) = %v, %v; want 1, true", v, ok)
+		}
+	})
+	t.Run("expired", func(t *testing.T) {
+		// ?? sleeps on the real clock; ttlCache would need a Clock to avoid it
+		c := newTTLCache[string, int](time.Millisecond)
+		c.Put("a", 1)
+		time.Sleep(5 * time.Millisecond)
+		if _, ok := c.Get("a"); ok {
+			t.Error("Get(a) after the TTL still hit")
+		}
+	})
+}
+
+func Example_total() {
+	// >> an Example's comments are markers too; only the Output block below is checked
+	fmt.Println(total(2*time.Second, 500*time.Millisecond))
+	// Output: 2.5s
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+)
+
+// memoryGuard sheds bulk requests while the process is close to its
+// memory limit, so a burst of full lists fails with 503 rather than the
+// whole process with an OOM kill. It starts shedding at the high mark
+// and stops only once use is back under the low one, so it doesn't flap
+// around a single threshold.
+type memoryGuard struct {
+	limit     int64
+	high, low int64
+	every     time.Duration
+	logger    *slog.Logger
+	shedCount CounterVec
+
+	used     atomic.Int64
+	shedding atomic.Bool
+}
+
+// newMemoryGuard returns nil when there is no limit to measure against:
+// memory.limit_bytes is 0 and GOMEMLIMIT is unset.
+func newMemoryGuard(cfg MemoryConfig, logger *slog.Logger, shed CounterVec) *memoryGuard {
+	limit := cfg.LimitBytes
+	if limit == 0 {
+		limit = debug.SetMemoryLimit(-1)
+	}
+	if limit <= 0 || limit == math.MaxInt64 {
+		return nil
+	}
+	return &memoryGuard{
+		limit:     limit,
+		high:      int64(cfg.High * float64(limit)),
+		low:       int64(cfg.Low * float64(limit)),
+		every:     cfg.Every,
+		logger:    logger,
+		shedCount: shed,
+	}
+}
+
+// memorySamples are what GOMEMLIMIT counts: everything the runtime has
+// mapped, less heap it has handed back to the OS.
+var memorySamples = []metrics.Sample{
+	{Name: "/memory/classes/total:bytes"},
+	{Name: "/memory/classes/heap/released:bytes"},
+}
+
+func readMemoryUse() int64 {
+	samples := append([]metrics.Sample(nil), memorySamples...)
+	metrics.Read(samples)
+	return int64(samples[0].Value.Uint64() - samples[1].Value.Uint64())
+}
+
+func (g *memoryGuard) run(done <-chan struct{}) {
+	ticker := time.NewTicker(g.every)
+	defer ticker.Stop()
+	for {
+		g.observe(readMemoryUse())
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (g *memoryGuard) observe(used int64) {
+	g.used.Store(used)
+	switch {
+	case used >= g.high && !g.shedding.Swap(true):
+		g.logger.Warn("memory pressure, shedding bulk requests", "used_bytes", used, "limit_bytes", g.limit)
+	case used <= g.low && g.shedding.Swap(false):
+		g.logger.Info("memory pressure eased", "used_bytes", used, "limit_bytes", g.limit)
+	}
+}
+
+// Middleware refuses rt's bulk requests with 503 while the guard is
+// shedding; other classes always pass, since they are what keeps the
+// service useful while memory is reclaimed.
+// ?? Only bulk routes are shed; a flood of small writes can still grow the heap past the limit
+func (g *memoryGuard) Middleware(rt Route) Middleware {
+	return func(next http.Handler) http.Handler {
+		if g == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !g.shedding.Load() || rt.priority(r.Method) != PriorityBulk {
+				next.ServeHTTP(w, r)
+				return
+			}
+			g.shedCount.Inc(rt.Pattern)
+			LoggerFrom(r.Context()).Info("request shed", "reason", "memory pressure", "used_bytes", g.used.Load())
+			w.Header().Set("Retry-After", "5")
+			renderJSON(w, r, http.StatusServiceUnavailable, errorResponse(r.Context(), "server_busy"))
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// A small metrics registry shared by every exporter. Instruments are defined
+// once here and rendered by the Prometheus handler and the OTLP pusher alike.
+
+type metricKind string
+
+const (
+	counterKind   metricKind = "counter"
+	gaugeKind     metricKind = "gauge"
+	histogramKind metricKind = "histogram"
+)
+
+// defaultBuckets suit request latencies in seconds.
+var defaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metricDesc struct {
+	Name    string
+	Help    string
+	Kind    metricKind
+	Labels  []string
+	Buckets []float64
+}
+
+// sample is one labelled series at gather time. Histograms fill Counts
+// (per bucket, not cumulative), Sum and Count instead of Value.
+type sample struct {
+	LabelValues []string
+	Value       float64
+	Counts      []uint64
+	Sum         float64
+	Count       uint64
+}
+
+type family struct {
+	metricDesc
+	Samples []sample
+}
+
+type collector interface {
+	desc() metricDesc
+	collect() []sample
+}
+
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Gather snapshots every instrument, sorted by name and then labels so the
+// output is stable between scrapes.
+func (r *Registry) Gather() []family {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	families := make([]family, 0, len(collectors))
+	for _, c := range collectors {
+		samples := c.collect()
+		sort.Slice(samples, func(i, j int) bool {
+			return strings.Join(samples[i].LabelValues, "\xff") < strings.Join(samples[j].LabelValues, "\xff")
+		})
+		families = append(families, family{metricDesc: c.desc(), Samples: samples})
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].Name < families[j].Name })
+	return families
+}
+
+// atomicFloat is a float64 updated with CAS, for counters and gauges.
+type atomicFloat struct{ bits atomic.Uint64 }
+
+func (f *atomicFloat) Add(v float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) Load() float64 { return math.Float64frombits(f.bits.Load()) }
+
+// vec holds one series per distinct label-value tuple.
+type vec[T any] struct {
+	metricDesc
+	mu     sync.RWMutex
+	series map[string]*T
+	labels map[string][]string
+	make   func() *T
+}
+
+func newVec[T any](d metricDesc, make func() *T) *vec[T] {
+	return &vec[T]{metricDesc: d, series: map[string]*T{}, labels: map[string][]string{}, make: make}
+}
+
+func (v *vec[T]) with(values ...string) *T {
+	// The key is built on the stack and looked up as string(key), which
+	// the compiler does without copying, so an existing series costs no
+	// allocation.
+	var buf [128]byte
+	key := buf[:0]
+	for i, value := range values {
+		if i > 0 {
+			key = append(key, 0xff)
+		}
+		key = append(key, value...)
+	}
+	v.mu.RLock()
+	s, ok := v.series[string(key)]
+	v.mu.RUnlock()
+	if ok {
+		return s
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok := v.series[string(key)]; ok {
+		return s
+	}
+	s = v.make()
+	v.series[string(key)] = s
+	v.labels[string(key)] = append([]string(nil), values...)
+	return s
+}
+
+func (v *vec[T]) desc() metricDesc { return v.metricDesc }
+
+func (v *vec[T]) each(fn func(values []string, s *T)) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	for key, s := range v.series {
+		fn(v.labels[key], s)
+	}
+}
+
+type CounterVec struct{ *vec[atomicFloat] }
+
+func (r *Registry) NewCounter(name, help string, labels ...string) CounterVec {
+	c := CounterVec{newVec(metricDesc{Name: name, Help: help, Kind: counterKind, Labels: labels}, func() *atomicFloat { return new(atomicFloat) })}
+	r.register(c)
+	return c
+}
+
+func (c CounterVec) Inc(labels ...string) { c.with(labels...).Add(1) }
+
+func (c CounterVec) collect() []sample {
+	var out []sample
+	c.each(func(values []string, s *atomicFloat) {
+		out = append(out, sample{LabelValues: values, Value: s.Load()})
+	})
+	return out
+}
+
+type histogramData struct {
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type HistogramVec struct{ *vec[histogramData] }
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) HistogramVec {
+	d := metricDesc{Name: name, Help: help, Kind: histogramKind, Labels: labels, Buckets: buckets}
+	h := HistogramVec{newVec(d, func() *histogramData {
+		return &histogramData{counts: make([]uint64, len(buckets)+1)}
+	})}
+	r.register(h)
+	return h
+}
+
+func (h HistogramVec) Observe(v float64, labels ...string) {
+	data := h.with(labels...)
+	i := sort.SearchFloat64s(h.Buckets, v)
+	data.mu.Lock()
+	data.counts[i]++
+	data.sum += v
+	data.count++
+	data.mu.Unlock()
+}
+
+func (h HistogramVec) collect() []sample {
+	var out []sample
+	h.each(func(values []string, d *histogramData) {
+		d.mu.Lock()
+		out = append(out, sample{LabelValues: values, Counts: append([]uint64(nil), d.counts...), Sum: d.sum, Count: d.count})
+		d.mu.Unlock()
+	})
+	return out
+}
+
+// gaugeFunc reads its samples at gather time, for values that already live
+// elsewhere such as the in-flight counter or runtime stats.
+type gaugeFunc struct {
+	metricDesc
+	fn func() []sample
+}
+
+func (g gaugeFunc) desc() metricDesc  { return g.metricDesc }
+func (g gaugeFunc) collect() []sample { return g.fn() }
+
+func (r *Registry) NewGaugeFunc(name, help string, kind metricKind, fn func() float64) {
+	r.register(gaugeFunc{metricDesc{Name: name, Help: help, Kind: kind}, func() []sample {
+		return []sample{{Value: fn()}}
+	}})
+}
+
+func (r *Registry) NewGaugeVecFunc(name, help string, labels []string, fn func() []sample) {
+	r.register(gaugeFunc{metricDesc{Name: name, Help: help, Kind: gaugeKind, Labels: labels}, fn})
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// parseClientAuth maps tls.client_auth to the crypto/tls policy. "request"
+// verifies a certificate when one is offered, so routes can require it
+// individually; "require" rejects the handshake without one.
+func parseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return 0, fmt.Errorf("unknown client auth mode %q (want none, request or require)", mode)
+	}
+}
+
+func loadClientCAs(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificates found")
+	}
+	return pool, nil
+}
+
+// configureClientAuth applies tls.client_auth and tls.client_ca_file.
+func configureClientAuth(tlsConfig *tls.Config, cfg TLSConfig) error {
+	mode, err := parseClientAuth(cfg.ClientAuth)
+	if err != nil || mode == tls.NoClientCert {
+		return err
+	}
+	pool, err := loadClientCAs(cfg.ClientCAFile)
+	if err != nil {
+		return fmt.Errorf("tls.client_ca_file: %w", err)
+	}
+	tlsConfig.ClientAuth = mode
+	tlsConfig.ClientCAs = pool
+	return nil
+}
+
+// certPrincipal names the caller behind a verified client certificate:
+// the first URI SAN (SPIFFE IDs), then DNS SAN, then email, then the CN.
+func certPrincipal(cert *x509.Certificate) string {
+	switch {
+	case len(cert.URIs) > 0:
+		return "cert:" + cert.URIs[0].String()
+	case len(cert.DNSNames) > 0:
+		return "cert:" + cert.DNSNames[0]
+	case len(cert.EmailAddresses) > 0:
+		return "cert:" + cert.EmailAddresses[0]
+	default:
+		return "cert:" + cert.Subject.CommonName
+	}
+}
+
+// ClientCertMiddleware sets the principal from a verified client
+// certificate, unless a token has already set one.
+func ClientCertMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && PrincipalFrom(r.Context()) == "" {
+			cert := r.TLS.VerifiedChains[0][0]
+			ctx := withPrincipal(r.Context(), certPrincipal(cert))
+			ctx = withLogger(ctx, LoggerFrom(ctx).With("client_cert_serial", cert.SerialNumber.String()))
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireClientCert rejects requests without a verified client certificate,
+// for routes listed in tls.client_cert_routes.
+func RequireClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			renderJSON(w, r, http.StatusUnauthorized, errorResponse(r.Context(), "client_cert_required"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Email is one rendered message, as it is queued and handed to a Mailer.
+type Email struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Mailer delivers email. Send may block; it runs on a job worker, and an
+// error is retried with the queue's backoff.
+type Mailer interface {
+	Send(ctx context.Context, from string, msg Email) error
+}
+
+// WithMailer replaces the mailer built from notify.provider.
+func WithMailer(m Mailer) Option {
+	return func(s *Server) { s.mailer = m }
+}
+
+func newMailer(cfg NotifyConfig, logger *slog.Logger) Mailer {
+	// A webhook provider would go here, along with the per-target delivery
+	// health, circuit breakers and pause/resume that only make sense for one.
+	if cfg.Provider == "smtp" {
+		return &smtpMailer{addr: cfg.SMTPAddr, user: cfg.SMTPUser, password: cfg.SMTPPassword}
+	}
+	return logMailer{logger: logger}
+}
+
+// logMailer writes messages to the log instead of sending them, for
+// development and tests.
+type logMailer struct {
+	logger *slog.Logger
+}
+
+func (m logMailer) Send(_ context.Context, from string, msg Email) error {
+	m.logger.Info("email", "from", from, "to", scrubPII(msg.To), "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// smtpMailer sends through one SMTP server, upgrading with STARTTLS when it
+// offers it. Credentials are only sent over TLS.
+type smtpMailer struct {
+	addr           string
+	user, password string
+}
+
+func (m *smtpMailer) Send(ctx context.Context, from string, msg Email) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline) // >> net/smtp takes no context, so the job timeout reaches it through the conn
+	}
+	host, _, _ := net.SplitHostPort(m.addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+	if m.user != "" {
+		// smtp.PlainAuth refuses to send the password unless the
+		// connection is TLS or to localhost.
+		if err := c.Auth(smtp.PlainAuth("", m.user, m.password, host)); err != nil {
+			return err
+		}
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return err
+	}
+	rcpt, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+	if err := c.Mail(sender.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(rcpt.Address); err != nil {
+		// ?? a 5xx here is permanent, yet it is retried like a timeout until MaxAttempts
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(formatEmail(from, msg, time.Now())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// formatEmail renders msg as an RFC 5322 message with CRLF line endings.
+// The subject is Q-encoded, so a name the template put there can't add
+// headers.
+func formatEmail(from string, msg Email, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	// Leading dots are left alone: the DATA writer from net/smtp stuffs them.
+	for line := range strings.Lines(msg.Body) {
+		b.WriteString(strings.TrimRight(line, "\r\n") + "\r\n")
+	}
+	return b.Bytes()
+}
+
+// emailTemplate renders one kind of notification. Templates see the
+// emailData.
+type emailTemplate struct {
+	subject, body *template.Template
+}
+
+type emailData struct {
+	User   User
+	Tenant string
+}
+
+func newEmailTemplate(name, subject, body string) emailTemplate {
+	return emailTemplate{
+		subject: template.Must(template.New(name + ".subject").Parse(subject)),
+		body:    template.Must(template.New(name + ".body").Parse(body)),
+	}
+}
+
+// userTemplates maps a UserEvent action to its email. Deletes carry only
+// the user's ID, so there is no address to write to.
+var userTemplates = map[string]emailTemplate{
+	"created": newEmailTemplate("created", "Welcome, {{.User.Name}}", `Hi {{.User.Name}},
+
+Your account has been created{{with .Tenant}} in {{.}}{{end}}. You can sign in with {{.User.Email}}.
+`),
+	"updated": newEmailTemplate("updated", "Your account details changed", `Hi {{.User.Name}},
+
+Your account details{{with .Tenant}} in {{.}}{{end}} were just changed. If this wasn't you, contact support.
+`), // !! sent to the new address only; a hijacked account changing its email never warns the old one
+}
+
+func (t emailTemplate) render(to string, data emailData) (Email, error) {
+	var subject, body strings.Builder
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return Email{}, err
+	}
+	if err := t.body.Execute(&body, data); err != nil {
+		return Email{}, err
+	}
+	return Email{To: to, Subject: strings.TrimSpace(subject.String()), Body: body.String()}, nil
+}
+
+const emailJobKind = "email"
+
+// notifier turns user events into queued emails. Messages are rendered
+// when the event arrives and the job carries the result, so a retry sends
+// exactly what the first attempt would have.
+type notifier struct {
+	from    string
+	actions []string
+	jobs    *JobQueue
+	mailer  Mailer
+	logger  *slog.Logger
+}
+
+// notifyUsers registers the email job and subscribes to user events. It
+// must run before the queue starts.
+func (s *Server) notifyUsers(cfg NotifyConfig) {
+	if cfg.Provider == "" {
+		return
+	}
+	if s.mailer == nil {
+		s.mailer = newMailer(cfg, s.logger)
+	}
+	n := &notifier{from: cfg.From, actions: cfg.Events, jobs: s.jobs, mailer: s.mailer, logger: s.logger}
+	s.jobs.Handle(emailJobKind, n.send)
+	SubscribeTo(s.events, "notify", 1024, n.userEvent)
+}
+
+func (n *notifier) userEvent(ev Event, ue UserEvent) {
+	t, ok := userTemplates[ue.Action]
+	if !ok || ev.Origin != "" || !slices.Contains(n.actions, ue.Action) || ue.User.Email == "" {
+		return
+	}
+	msg, err := t.render(ue.User.Email, emailData{User: ue.User, Tenant: ev.Tenant})
+	if err != nil {
+		n.logger.Error("email template failed", "action", ue.Action, "err", err)
+		return
+	}
+	if _, err := n.jobs.Enqueue(emailJobKind, msg); err != nil {
+		n.logger.Warn("email not queued", "action", ue.Action, "request_id", ev.RequestID, "err", err)
+	}
+}
+
+func (n *notifier) send(ctx context.Context, job Job) error {
+	var msg Email
+	if err := json.Unmarshal(job.Payload, &msg); err != nil {
+		return err
+	}
+	if _, err := mail.ParseAddress(msg.To); err != nil {
+		// Retrying can't fix the address, so the job succeeds without
+		// sending rather than burn its attempts.
+		n.logger.Warn("email dropped", "job", job.ID, "err", err)
+		return nil
+	}
+	return n.mailer.Send(ctx, n.from, msg)
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Option customizes a Server at construction time. Options are applied in
+// order after the config, so they win over file, env and flag values.
+type Option func(*Server)
+
+// WithTimeouts overrides the read, write and idle timeouts from the config.
+// Zero leaves the configured value in place.
+func WithTimeouts(read, write, idle time.Duration) Option {
+	return func(s *Server) {
+		if read > 0 {
+			s.cfg.Server.ReadTimeout = read
+		}
+		if write > 0 {
+			s.cfg.Server.WriteTimeout = write
+		}
+		if idle > 0 {
+			s.cfg.Server.IdleTimeout = idle
+		}
+	}
+}
+
+// WithMiddleware appends middleware after the built-in chain, closest to
+// the routes.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(s *Server) { s.extra = append(s.extra, mw...) }
+}
+
+// WithStore serves from store immediately instead of waiting for
+// ConnectStore.
+func WithStore(store Store) Option {
+	return func(s *Server) { s.setStore(store) }
+}
+
+// WithListener serves on ln instead of binding server.addr and unix.path.
+// It may be given more than once.
+func WithListener(ln net.Listener) Option {
+	return func(s *Server) { s.preset = append(s.preset, ln) }
+}
+
+// WithLogger sets the logger for server events and the base of every
+// request-scoped logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) { s.logger = logger }
+}
+
+// baseLogger seeds the request context so request loggers derive from the
+// server's logger rather than the process default.
+func baseLogger(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(withLogger(r.Context(), logger)))
+		})
+	}
+}
+
+// WithClock replaces real time with clock; see Clock for what follows
+// it.
+func WithClock(clock Clock) Option {
+	return func(s *Server) { s.clock = clock }
+}
+
+// WithIDs replaces the request and user ID generator.
+func WithIDs(ids IDGenerator) Option {
+	return func(s *Server) { s.ids = ids }
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// otlpExporter pushes the metrics registry to an OpenTelemetry collector
+// over OTLP/HTTP with JSON encoding. It is configured only through the
+// standard OTEL_* environment variables so it drops into existing
+// collector setups.
+type otlpExporter struct {
+	endpoint string
+	headers  http.Header
+	interval time.Duration
+	resource []otlpKV
+	client   *http.Client
+	registry *Registry
+	start    time.Time
+}
+
+// newOTLPExporter returns nil when no endpoint is configured or the metrics
+// exporter is turned off.
+func newOTLPExporter(registry *Registry) (*otlpExporter, error) {
+	if exp := os.Getenv("OTEL_METRICS_EXPORTER"); exp != "" && exp != "otlp" {
+		return nil, nil
+	}
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT")
+	if endpoint == "" {
+		base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			return nil, nil
+		}
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/metrics"
+	}
+	if _, err := url.ParseRequestURI(endpoint); err != nil {
+		return nil, fmt.Errorf("OTLP endpoint: %w", err)
+	}
+	// !! Only http/json is built in; protobuf and gRPC need the OTel SDK
+	for _, key := range []string{"OTEL_EXPORTER_OTLP_METRICS_PROTOCOL", "OTEL_EXPORTER_OTLP_PROTOCOL"} {
+		if p := os.Getenv(key); p != "" {
+			if p != "http/json" {
+				return nil, fmt.Errorf("%s=%s is not supported, use http/json", key, p)
+			}
+			break
+		}
+	}
+
+	e := &otlpExporter{
+		endpoint: endpoint,
+		headers:  http.Header{},
+		interval: time.Minute,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		registry: registry,
+		start:    time.Now(),
+	}
+	if ms := os.Getenv("OTEL_METRIC_EXPORT_INTERVAL"); ms != "" {
+		n, err := strconv.Atoi(ms)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("OTEL_METRIC_EXPORT_INTERVAL: want milliseconds, got %q", ms)
+		}
+		e.interval = time.Duration(n) * time.Millisecond
+	}
+	for _, key := range []string{"OTEL_EXPORTER_OTLP_HEADERS", "OTEL_EXPORTER_OTLP_METRICS_HEADERS"} {
+		for k, v := range otelList(os.Getenv(key)) {
+			e.headers.Set(k, v)
+		}
+	}
+	attrs := otelList(os.Getenv("OTEL_RESOURCE_ATTRIBUTES"))
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		attrs["service.name"] = name
+	}
+	if attrs["service.name"] == "" {
+		attrs["service.name"] = "human-plus-plus-sample"
+	}
+	for k, v := range attrs {
+		e.resource = append(e.resource, otlpString(k, v))
+	}
+	return e, nil
+}
+
+// otelList parses the comma-separated key=value format shared by the
+// OTEL_*_HEADERS and OTEL_RESOURCE_ATTRIBUTES variables.
+func otelList(raw string) map[string]string {
+	out := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		k, _ = url.QueryUnescape(strings.TrimSpace(k))
+		v, _ = url.QueryUnescape(strings.TrimSpace(v))
+		out[k] = v
+	}
+	return out
+}
+
+func (e *otlpExporter) run(done <-chan struct{}, logger func(error)) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), e.interval)
+			if err := e.push(ctx); err != nil {
+				logger(err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Flush pushes a final batch; it is registered as a shutdown hook.
+func (e *otlpExporter) Flush(ctx context.Context) error {
+	return e.push(ctx)
+}
+
+func (e *otlpExporter) push(ctx context.Context) error {
+	body, err := json.Marshal(e.payload(e.registry.Gather(), time.Now()))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = e.headers.Clone()
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("OTLP export: collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// The types below mirror the OTLP JSON mapping. 64-bit integers are strings,
+// as protobuf's JSON encoding requires.
+
+type otlpKV struct {
+	Key   string            `json:"key"`
+	Value map[string]string `json:"value"`
+}
+
+func otlpString(k, v string) otlpKV {
+	return otlpKV{Key: k, Value: map[string]string{"stringValue": v}}
+}
+
+type otlpPoint struct {
+	Attributes   []otlpKV  `json:"attributes,omitempty"`
+	StartTime    string    `json:"startTimeUnixNano"`
+	Time         string    `json:"timeUnixNano"`
+	AsDouble     *float64  `json:"asDouble,omitempty"`
+	Count        string    `json:"count,omitempty"`
+	Sum          *float64  `json:"sum,omitempty"`
+	BucketCounts []string  `json:"bucketCounts,omitempty"`
+	Bounds       []float64 `json:"explicitBounds,omitempty"`
+}
+
+type otlpData struct {
+	DataPoints  []otlpPoint `json:"dataPoints"`
+	Temporality int         `json:"aggregationTemporality,omitempty"`
+	Monotonic   bool        `json:"isMonotonic,omitempty"`
+}
+
+type otlpMetric struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Sum         *otlpData `json:"sum,omitempty"`
+	Gauge       *otlpData `json:"gauge,omitempty"`
+	Histogram   *otlpData `json:"histogram,omitempty"`
+}
+
+const otlpCumulative = 2
+
+func (e *otlpExporter) payload(families []family, now time.Time) map[string]any {
+	start, at := strconv.FormatInt(e.start.UnixNano(), 10), strconv.FormatInt(now.UnixNano(), 10)
+	metrics := make([]otlpMetric, 0, len(families))
+	for _, f := range families {
+		data := &otlpData{}
+		for _, s := range f.Samples {
+			p := otlpPoint{StartTime: start, Time: at}
+			for i, name := range f.Labels {
+				p.Attributes = append(p.Attributes, otlpString(name, s.LabelValues[i]))
+			}
+			if f.Kind == histogramKind {
+				sum := s.Sum
+				p.Count, p.Sum, p.Bounds = strconv.FormatUint(s.Count, 10), &sum, f.Buckets
+				for _, c := range s.Counts {
+					p.BucketCounts = append(p.BucketCounts, strconv.FormatUint(c, 10))
+				}
+			} else {
+				v := s.Value
+				p.AsDouble = &v
+			}
+			data.DataPoints = append(data.DataPoints, p)
+		}
+		m := otlpMetric{Name: f.Name, Description: f.Help}
+		switch f.Kind {
+		case counterKind:
+			data.Temporality, data.Monotonic = otlpCumulative, true
+			m.Sum = data
+		case histogramKind:
+			data.Temporality = otlpCumulative
+			m.Histogram = data
+		default:
+			m.Gauge = data
+		}
+		metrics = append(metrics, m)
+	}
+	return map[string]any{
+		"resourceMetrics": []any{map[string]any{
+			"resource": map[string]any{"attributes": e.resource},
+			"scopeMetrics": []any{map[string]any{
+				"scope":   map[string]string{"name": "human-plus-plus-sample"},
+				"metrics": metrics,
+			}},
+		}},
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !argon2
+
+package server
+
+// !! argon2id needs golang.org/x/crypto - build with -tags argon2 to store passwords
+func hashPassword(string) (string, error) { return "", errNoArgon2 }
+
+func verifyPassword(string, string) (bool, error) { return false, errNoArgon2 }
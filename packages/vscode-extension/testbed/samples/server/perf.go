@@ -0,0 +1,348 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"text/tabwriter"
+	"time"
+)
+
+var perfOpts struct {
+	run       string
+	count     int
+	save      string
+	baseline  string
+	threshold float64
+}
+
+func perfFlags(fs *flag.FlagSet) {
+	fs.StringVar(&perfOpts.run, "run", "", "regexp selecting benchmarks by name (default: all)")
+	fs.IntVar(&perfOpts.count, "count", 3, "runs per benchmark; the fastest is kept, to shed scheduling noise")
+	fs.StringVar(&perfOpts.save, "save", "", "write the results as a JSON baseline to this file")
+	fs.StringVar(&perfOpts.baseline, "baseline", "", "compare against a baseline written by -save and fail on regressions")
+	fs.Float64Var(&perfOpts.threshold, "threshold", 10, "percent slower, or more allocations, that counts as a regression")
+}
+
+// perfResult is one benchmark's numbers, as saved in a baseline.
+type perfResult struct {
+	NsPerOp     float64 `json:"ns_per_op"`
+	BytesPerOp  int64   `json:"bytes_per_op"`
+	AllocsPerOp int64   `json:"allocs_per_op"`
+}
+
+type perfBench struct {
+	name string
+	fn   func(b *testing.B)
+}
+
+const perfUsers = 1000
+
+// runPerf runs in-process benchmarks of the store, the middleware chain
+// and whole requests over loopback: BenchmarkPerf's, run through
+// testing.Benchmark so a built binary can run them too. Against a
+// -baseline it exits non-zero when any benchmark regressed by more than
+// -threshold, e.g. in CI:
+//
+//	samples perf -save base.json        # on the main branch
+//	samples perf -baseline base.json    # on the change
+//
+// Baselines are only comparable on the same machine and Go version.
+func runPerf(_ Config, _ []string) error {
+	filter, err := regexp.Compile(perfOpts.run)
+	if err != nil {
+		return fmt.Errorf("-run: %w", err)
+	}
+	var baseline map[string]perfResult
+	if perfOpts.baseline != "" {
+		data, err := os.ReadFile(perfOpts.baseline)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &baseline); err != nil {
+			return fmt.Errorf("%s: %w", perfOpts.baseline, err)
+		}
+	}
+
+	srv, base, stop, err := startPerfServer()
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	results := map[string]perfResult{}
+	var names []string
+	for _, bench := range perfBenches(srv, base) {
+		if !filter.MatchString(bench.name) {
+			continue
+		}
+		var best perfResult
+		for i := range max(perfOpts.count, 1) {
+			r := testing.Benchmark(bench.fn)
+			if r.N == 0 {
+				return fmt.Errorf("%s: benchmark failed", bench.name)
+			}
+			got := perfResult{NsPerOp: float64(r.T.Nanoseconds()) / float64(r.N), BytesPerOp: r.AllocedBytesPerOp(), AllocsPerOp: r.AllocsPerOp()}
+			if i == 0 || got.NsPerOp < best.NsPerOp {
+				best = got
+			}
+		}
+		results[bench.name] = best
+		names = append(names, bench.name)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no benchmark matches %q", perfOpts.run)
+	}
+
+	regressed := printPerfReport(os.Stdout, names, results, baseline, perfOpts.threshold)
+	if perfOpts.save != "" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(perfOpts.save, append(data, '\n'), 0o644); err != nil {
+			return err
+		}
+	}
+	if len(regressed) > 0 {
+		return fmt.Errorf("%d benchmark(s) regressed beyond %.0f%%: %s", len(regressed), perfOpts.threshold, strings.Join(regressed, ", "))
+	}
+	return nil
+}
+
+// startPerfServer runs a quiet server from the defaults on a loopback
+// port, seeded with perfUsers users.
+func startPerfServer() (*Server, string, func(), error) {
+	cfg := DefaultConfig()
+	cfg.Server.DrainDelay = 0
+	cfg.Audit.File = ""
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, "", nil, err
+	}
+	srv, err := NewServer(cfg, WithStore(seededStore()), WithListener(ln), WithLogger(slog.New(slog.DiscardHandler)))
+	if err != nil {
+		ln.Close()
+		return nil, "", nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan error, 1)
+	go func() { ran <- srv.Run(ctx) }()
+	stop := func() {
+		cancel()
+		<-ran
+	}
+	for deadline := time.Now().Add(5 * time.Second); !srv.started.Load(); {
+		if time.Now().After(deadline) {
+			stop()
+			return nil, "", nil, errors.New("server did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return srv, "http://" + ln.Addr().String(), stop, nil
+}
+
+func seededStore() *UserStore {
+	store := NewUserStore()
+	for i := range perfUsers {
+		store.Set(User{ID: fmt.Sprintf("perf-%d", i), Name: "Perf User", Email: "perf@example.com", CreatedAt: time.Now()})
+	}
+	return store
+}
+
+func perfBenches(srv *Server, base string) []perfBench {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 64
+	client := &http.Client{Transport: transport}
+	var created atomic.Int64
+
+	return []perfBench{
+		{"store/get", func(b *testing.B) {
+			store := seededStore()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					store.Get(fmt.Sprintf("perf-%d", rand.Intn(perfUsers)))
+				}
+			})
+		}},
+		{"store/set", func(b *testing.B) {
+			store := seededStore()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					store.Set(User{ID: fmt.Sprintf("perf-%d", rand.Intn(perfUsers)), Name: "Perf User"})
+				}
+			})
+		}},
+		{"store/list", func(b *testing.B) {
+			store := seededStore()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					store.List()
+				}
+			})
+		}},
+		// Mostly reads with some writes, so readers and writers contend.
+		{"store/mixed", func(b *testing.B) {
+			store := seededStore()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					id := fmt.Sprintf("perf-%d", rand.Intn(perfUsers))
+					switch n := rand.Intn(100); {
+					case n < 80:
+						store.Get(id)
+					case n < 95:
+						store.Set(User{ID: id, Name: "Perf User"})
+					default:
+						store.List()
+					}
+				}
+			})
+		}},
+		// mux/health and chain/health differ only by the middleware, so
+		// their gap is the chain's per-request cost.
+		{"mux/health", func(b *testing.B) {
+			perfServe(b, srv.mux, http.MethodGet, "/health")
+		}},
+		{"chain/health", func(b *testing.B) {
+			perfServe(b, srv.Handler(), http.MethodGet, "/health")
+		}},
+		// The access log and route metrics run on every request and should
+		// allocate nothing; against a baseline, any allocation here fails.
+		{"middleware/access-log", func(b *testing.B) {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil)).With("request_id", "perf")
+			r := httptest.NewRequest(http.MethodGet, "/users/perf-1", nil)
+			perfMiddleware(b, LoggingMiddleware, r.WithContext(withLogger(r.Context(), logger)))
+		}},
+		{"middleware/metrics", func(b *testing.B) {
+			perfMiddleware(b, srv.metrics.RouteMiddleware("/users/"), httptest.NewRequest(http.MethodGet, "/users/perf-1", nil))
+		}},
+		{"chain/get-user", func(b *testing.B) {
+			perfServe(b, srv.Handler(), http.MethodGet, "/users/perf-1")
+		}},
+		{"http/get-user", func(b *testing.B) {
+			perfRequest(b, client, http.MethodGet, base+"/users/perf-1", nil)
+		}},
+		{"http/list-users", func(b *testing.B) {
+			perfRequest(b, client, http.MethodGet, base+"/users?limit=50", nil)
+		}},
+		{"http/create-user", func(b *testing.B) {
+			perfRequest(b, client, http.MethodPost, base+"/users", func() io.Reader {
+				return strings.NewReader(fmt.Sprintf(`{"id":"perf-new-%d","name":"Perf User","email":"perf@example.com"}`, created.Add(1)))
+			})
+		}},
+	}
+}
+
+// perfMiddleware measures mw alone, around a handler and writer that do
+// nothing, so any allocation reported is the middleware's own.
+func perfMiddleware(b *testing.B, mw Middleware, r *http.Request) {
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }))
+	w := discardWriter{header: http.Header{}}
+	b.ReportAllocs()
+	for b.Loop() {
+		h.ServeHTTP(w, r)
+	}
+}
+
+type discardWriter struct{ header http.Header }
+
+func (w discardWriter) Header() http.Header         { return w.header }
+func (w discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w discardWriter) WriteHeader(int)             {}
+
+func perfServe(b *testing.B, h http.Handler, method, path string) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+			if w.Code >= 300 {
+				b.Errorf("%s %s: status %d", method, path, w.Code)
+				return
+			}
+		}
+	})
+}
+
+func perfRequest(b *testing.B, client *http.Client, method, url string, body func() io.Reader) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			var r io.Reader
+			if body != nil {
+				r = body()
+			}
+			req, err := http.NewRequest(method, url, r)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			if r != nil {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				b.Errorf("%s %s: status %d", method, url, resp.StatusCode)
+				return
+			}
+		}
+	})
+}
+
+// printPerfReport prints one row per benchmark and, with a baseline, the
+// change against it. It returns the names that regressed.
+func printPerfReport(out io.Writer, names []string, results, baseline map[string]perfResult, threshold float64) []string {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "benchmark\tns/op\tB/op\tallocs/op\t")
+	if baseline != nil {
+		fmt.Fprint(tw, "Δ ns/op\tΔ allocs\t\t")
+	}
+	fmt.Fprintln(tw)
+	var regressed []string
+	limit := 1 + threshold/100
+	for _, name := range names {
+		r := results[name]
+		fmt.Fprintf(tw, "%s\t%.0f\t%d\t%d\t", name, r.NsPerOp, r.BytesPerOp, r.AllocsPerOp)
+		if baseline != nil {
+			if old, ok := baseline[name]; !ok {
+				fmt.Fprint(tw, "new\t\t\t")
+			} else {
+				status := "ok"
+				if r.NsPerOp > old.NsPerOp*limit || (r.AllocsPerOp > old.AllocsPerOp && float64(r.AllocsPerOp) > float64(old.AllocsPerOp)*limit) {
+					status = "REGRESSED"
+					regressed = append(regressed, name)
+				}
+				fmt.Fprintf(tw, "%s\t%+d\t%s\t", perfChange(r.NsPerOp, old.NsPerOp), r.AllocsPerOp-old.AllocsPerOp, status)
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+	return regressed
+}
+
+func perfChange(now, before float64) string {
+	if before == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f%%", (now-before)/before*100)
+}
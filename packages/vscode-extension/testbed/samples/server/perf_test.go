@@ -0,0 +1,16 @@
+package server
+
+import "testing"
+
+// BenchmarkPerf runs the perf command's benchmarks under go test -bench,
+// one sub-benchmark each, e.g. -bench 'Perf/store/' for the store alone.
+func BenchmarkPerf(b *testing.B) {
+	srv, base, stop, err := startPerfServer()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer stop()
+	for _, bench := range perfBenches(srv, base) {
+		b.Run(bench.name, bench.fn)
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/pii"
+)
+
+// piiRules is shared by the log handler and the error reporter, set from
+// logging.redact_pii and logging.pii_keys by newLogger.
+var piiRules atomic.Pointer[piiRedactor]
+
+// piiRedactor masks personal data before it leaves the process. Attributes
+// whose key is in keys are masked whole; any other string is scanned for
+// email addresses. Struct fields tagged pii:"true" are masked wherever the
+// struct is logged, by pii.LogValue.
+type piiRedactor struct {
+	keys map[string]bool
+}
+
+func newPIIRedactor(cfg LoggingConfig) *piiRedactor {
+	if !cfg.RedactPII {
+		return nil
+	}
+	keys := make(map[string]bool, len(cfg.PIIKeys))
+	for _, k := range cfg.PIIKeys {
+		keys[strings.ToLower(k)] = true
+	}
+	return &piiRedactor{keys: keys}
+}
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// scrubPII masks every email address in free text such as log messages,
+// paths and error strings. It is a no-op while redaction is off.
+func scrubPII(s string) string {
+	if piiRules.Load() == nil || !strings.Contains(s, "@") {
+		return s
+	}
+	return emailPattern.ReplaceAllStringFunc(s, pii.Mask)
+}
+
+func (p *piiRedactor) attr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	switch a.Value.Kind() {
+	case slog.KindString:
+		if p.keys[strings.ToLower(a.Key)] {
+			a.Value = slog.StringValue(pii.Mask(a.Value.String()))
+		} else {
+			a.Value = slog.StringValue(scrubPII(a.Value.String()))
+		}
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		out := make([]slog.Attr, len(attrs))
+		for i, child := range attrs {
+			out[i] = p.attr(child)
+		}
+		a.Value = slog.GroupValue(out...)
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(scrubPII(err.Error()))
+		}
+	}
+	return a
+}
+
+// piiHandler applies piiRules to every record before the wrapped handler
+// formats it.
+type piiHandler struct{ inner slog.Handler }
+
+func (h piiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h piiHandler) Handle(ctx context.Context, r slog.Record) error {
+	p := piiRules.Load()
+	if p == nil {
+		return h.inner.Handle(ctx, r)
+	}
+	out := slog.NewRecord(r.Time, r.Level, scrubPII(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(p.attr(a))
+		return true
+	})
+	return h.inner.Handle(ctx, out)
+}
+
+func (h piiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if p := piiRules.Load(); p != nil {
+		masked := make([]slog.Attr, len(attrs))
+		for i, a := range attrs {
+			masked[i] = p.attr(a)
+		}
+		attrs = masked
+	}
+	return piiHandler{h.inner.WithAttrs(attrs)}
+}
+
+func (h piiHandler) WithGroup(name string) slog.Handler {
+	return piiHandler{h.inner.WithGroup(name)}
+}
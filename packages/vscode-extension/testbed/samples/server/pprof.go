@@ -0,0 +1,41 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+)
+
+func (s *Server) debugRoutes(cfg DebugConfig) []Route {
+	var routes []Route
+	if cfg.Pprof {
+		routes = append(routes,
+			Route{Pattern: "/debug/pprof/", Handler: pprof.Index, Admin: true},
+			Route{Pattern: "/debug/pprof/cmdline", Handler: pprof.Cmdline, Admin: true},
+			Route{Pattern: "/debug/pprof/profile", Handler: pprof.Profile, Admin: true},
+			Route{Pattern: "/debug/pprof/symbol", Handler: pprof.Symbol, Admin: true},
+			Route{Pattern: "/debug/pprof/trace", Handler: pprof.Trace, Admin: true},
+		)
+	}
+	if cfg.Expvar {
+		routes = append(routes, Route{Pattern: "/debug/vars", Handler: s.handleVars, Admin: true})
+	}
+	return routes
+}
+
+// >> A dedicated debug listener must be loopback-only; it skips admin auth
+func (s *Server) newDebugServer(addr string) (*http.Server, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("debug listener %s is not a loopback address", addr)
+	}
+	mux := http.NewServeMux()
+	for _, rt := range s.debugRoutes(s.cfg.Debug) {
+		mux.Handle(rt.Pattern, rt.Handler)
+	}
+	return &http.Server{Addr: addr, Handler: mux}, nil
+}
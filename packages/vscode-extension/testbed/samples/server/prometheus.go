@@ -0,0 +1,238 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/store"
+)
+
+// serverMetrics are the instruments the server itself updates.
+type serverMetrics struct {
+	registry   *Registry
+	requests   CounterVec
+	latency    HistogramVec
+	storeOps   CounterVec
+	limited    CounterVec
+	memoryShed CounterVec
+}
+
+func (s *Server) newMetrics() *serverMetrics {
+	r := &Registry{}
+	m := &serverMetrics{
+		registry:   r,
+		requests:   r.NewCounter("http_requests_total", "HTTP requests by method, route and status code.", "method", "route", "code"),
+		latency:    r.NewHistogram("http_request_duration_seconds", "HTTP request latency by route.", defaultBuckets, "route"),
+		storeOps:   r.NewCounter("store_operations_total", "Store calls by operation.", "op"),
+		limited:    r.NewCounter("http_rate_limited_total", "Requests rejected by the rate limiter."),
+		memoryShed: r.NewCounter("http_memory_shed_total", "Bulk requests shed under memory pressure, by route.", "route"),
+	}
+	r.NewGaugeFunc("memory_pressure", "1 while bulk requests are shed for memory pressure.", gaugeKind, func() float64 {
+		if s.memory != nil && s.memory.shedding.Load() {
+			return 1
+		}
+		return 0
+	})
+	r.NewGaugeFunc("http_requests_in_flight", "Requests currently being served.", gaugeKind, func() float64 {
+		return float64(s.stats.inFlight.Load())
+	})
+	r.NewGaugeFunc("store_users", "Users in the default tenant.", gaugeKind, func() float64 {
+		if !s.started.Load() {
+			return 0
+		}
+		return float64(s.store.Len())
+	})
+	r.NewGaugeVecFunc("job_queue_jobs", "Background jobs by state since start (queued is current).", []string{"state"}, func() []sample {
+		var out []sample
+		for state, n := range s.jobs.stats() {
+			out = append(out, sample{LabelValues: []string{state}, Value: float64(n)})
+		}
+		return out
+	})
+	r.NewGaugeFunc("go_goroutines", "Number of goroutines.", gaugeKind, func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	memStat := func(name, help string, kind metricKind, read func(*runtime.MemStats) float64) {
+		r.NewGaugeFunc(name, help, kind, func() float64 {
+			var ms runtime.MemStats
+			runtime.ReadMemStats(&ms)
+			return read(&ms)
+		})
+	}
+	memStat("go_memstats_heap_alloc_bytes", "Heap bytes allocated and in use.", gaugeKind, func(ms *runtime.MemStats) float64 { return float64(ms.HeapAlloc) })
+	memStat("go_memstats_sys_bytes", "Bytes obtained from the OS.", gaugeKind, func(ms *runtime.MemStats) float64 { return float64(ms.Sys) })
+	memStat("go_gc_cycles_total", "Completed GC cycles.", counterKind, func(ms *runtime.MemStats) float64 { return float64(ms.NumGC) })
+	memStat("go_gc_pause_seconds_total", "Total GC stop-the-world pause time.", counterKind, func(ms *runtime.MemStats) float64 {
+		return time.Duration(ms.PauseTotalNs).Seconds()
+	})
+	r.NewGaugeFunc("process_start_time_seconds", "Start time of the process since the Unix epoch.", gaugeKind, func() float64 {
+		return float64(startTime.UnixNano()) / 1e9
+	})
+	r.NewGaugeVecFunc("build_info", "Build metadata; the value is always 1.", []string{"version", "go_version", "revision"}, func() []sample {
+		bi := buildInfo()
+		return []sample{{LabelValues: []string{bi["version"], bi["go_version"], bi["vcs.revision"]}, Value: 1}}
+	})
+	return m
+}
+
+// RouteMiddleware records the request count and latency for one route.
+func (m *serverMetrics) RouteMiddleware(pattern string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := getStatusWriter(w)
+			next.ServeHTTP(sw, r)
+			status := sw.status
+			putStatusWriter(sw)
+			if status == 0 {
+				status = http.StatusOK
+			}
+			m.requests.Inc(r.Method, pattern, statusLabel(status))
+			m.latency.Observe(time.Since(start).Seconds(), pattern)
+		})
+	}
+}
+
+// statusLabels holds every valid status code as a label, so counting a
+// request doesn't format one.
+var statusLabels = func() (labels [600]string) {
+	for code := range labels {
+		labels[code] = strconv.Itoa(code)
+	}
+	return labels
+}()
+
+func statusLabel(code int) string {
+	if code >= 0 && code < len(statusLabels) {
+		return statusLabels[code]
+	}
+	return strconv.Itoa(code)
+}
+
+// meteredStore counts store calls, including those made through tenant
+// views.
+type meteredStore struct {
+	Store
+	ops CounterVec
+}
+
+func (s meteredStore) Get(id string) (User, bool) {
+	s.ops.Inc("get")
+	return s.Store.Get(id)
+}
+
+func (s meteredStore) Set(user User) {
+	s.ops.Inc("set")
+	s.Store.Set(user)
+}
+
+func (s meteredStore) Delete(id string) bool {
+	s.ops.Inc("delete")
+	return s.Store.Delete(id)
+}
+
+func (s meteredStore) List() []User {
+	s.ops.Inc("list")
+	return s.Store.List()
+}
+
+func (s meteredStore) Range(ctx context.Context, fn func(User) bool) error {
+	s.ops.Inc("range")
+	return s.Store.Range(ctx, fn)
+}
+
+func (s meteredStore) Suggest(prefix string, n int) ([]User, bool) {
+	s.ops.Inc("suggest")
+	if sg, ok := s.Store.(store.Suggester); ok {
+		return sg.Suggest(prefix, n)
+	}
+	return nil, false
+}
+
+func (s meteredStore) ForTenant(tenant string) Store {
+	return meteredStore{Store: s.Store.ForTenant(tenant), ops: s.ops}
+}
+
+// Flush passes through so snapshot tasks still reach a file store.
+func (s meteredStore) Flush() error {
+	if f, ok := s.Store.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writePrometheus(w, s.metrics.registry.Gather())
+}
+
+// writePrometheus renders families in the Prometheus text exposition format.
+func writePrometheus(out io.Writer, families []family) {
+	w := bufio.NewWriter(out)
+	defer w.Flush()
+	for _, f := range families {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.Name, f.Help, f.Name, f.Kind)
+		for _, s := range f.Samples {
+			if f.Kind != histogramKind {
+				fmt.Fprintf(w, "%s%s %s\n", f.Name, promLabels(f.Labels, s.LabelValues, "", ""), promFloat(s.Value))
+				continue
+			}
+			var cumulative uint64
+			for i, bound := range f.Buckets {
+				cumulative += s.Counts[i]
+				fmt.Fprintf(w, "%s_bucket%s %d\n", f.Name, promLabels(f.Labels, s.LabelValues, "le", promFloat(bound)), cumulative)
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.Name, promLabels(f.Labels, s.LabelValues, "le", "+Inf"), s.Count)
+			fmt.Fprintf(w, "%s_sum%s %s\n", f.Name, promLabels(f.Labels, s.LabelValues, "", ""), promFloat(s.Sum))
+			fmt.Fprintf(w, "%s_count%s %d\n", f.Name, promLabels(f.Labels, s.LabelValues, "", ""), s.Count)
+		}
+	}
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, name, promEscaper.Replace(values[i]))
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, extraName, extraValue)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func promFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func (s *Server) newMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	return &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: s.cfg.Server.ReadTimeout}
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/usersvc/userpb"
+)
+
+// eventPublisher sends one encoded event to a broker. Where the broker
+// partitions, as Kafka does, key picks the partition, so events with the
+// same key arrive in the order they were published.
+type eventPublisher interface {
+	Publish(ctx context.Context, subject, key string, value []byte) error
+	Close() error
+}
+
+// publishedUser is the JSON form of a UserEvent on the wire. Deletes carry
+// only the ID.
+type publishedUser struct {
+	Action    string    `json:"action"`
+	Tenant    string    `json:"tenant,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Time      time.Time `json:"time"`
+	User      User      `json:"user"`
+}
+
+var userEventTypes = map[string]userpb.EventType{
+	"created": userpb.EventType_EVENT_TYPE_CREATED,
+	"updated": userpb.EventType_EVENT_TYPE_UPDATED,
+	"deleted": userpb.EventType_EVENT_TYPE_DELETED,
+}
+
+// encodeUserEvent renders ue as encoding, json or protobuf; the protobuf
+// form is a users.v1.UserEvent.
+func encodeUserEvent(encoding string, ev Event, ue UserEvent) ([]byte, error) {
+	if encoding == "protobuf" {
+		u := &userpb.User{Id: ue.User.ID, Name: ue.User.Name, Email: ue.User.Email, Tenant: ev.Tenant}
+		if !ue.User.CreatedAt.IsZero() {
+			u.CreatedUnix = ue.User.CreatedAt.Unix()
+		}
+		return (&userpb.UserEvent{Type: userEventTypes[ue.Action], User: u, AtUnix: ev.Time.Unix()}).Marshal()
+	}
+	return json.Marshal(publishedUser{Action: ue.Action, Tenant: ev.Tenant, RequestID: ev.RequestID, Time: ev.Time, User: ue.User})
+}
+
+func newEventPublisher(cfg PublishConfig) (eventPublisher, error) {
+	switch cfg.Broker {
+	case "kafka":
+		return newKafkaPublisher(cfg.Addrs)
+	case "nats":
+		return newNATSPublisher(cfg.Addrs)
+	}
+	return nil, fmt.Errorf("unknown broker %q", cfg.Broker)
+}
+
+// publishUserEvents forwards every user event to the configured broker.
+// Kafka gets them on Topic keyed by user ID; NATS on the subject
+// Topic.<action>, so a consumer can subscribe to Topic.* or one action.
+func (s *Server) publishUserEvents(cfg PublishConfig) error {
+	if cfg.Broker == "" {
+		return nil
+	}
+	pub, err := newEventPublisher(cfg)
+	if err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+	unsubscribe := SubscribeTo(s.events, "publish", cfg.Buffer, func(ev Event, ue UserEvent) {
+		if ev.Origin != "" {
+			return // the replica it came from has published it
+		}
+		value, err := encodeUserEvent(cfg.Encoding, ev, ue)
+		if err != nil {
+			s.logger.Error("user event encoding failed", "err", err)
+			return
+		}
+		subject := cfg.Topic
+		if cfg.Broker == "nats" {
+			subject += "." + ue.Action
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+		defer cancel()
+		if err := pub.Publish(ctx, subject, ue.User.ID, value); err != nil {
+			// !! the event is lost: nothing replays what failed to publish while the broker was down
+			s.logger.Warn("user event not published", "broker", cfg.Broker, "action", ue.Action, "request_id", ev.RequestID, "err", err)
+		}
+	})
+	s.OnShutdown(func(context.Context) error {
+		unsubscribe()
+		return pub.Close()
+	})
+	return nil
+}
+
+// natsPublisher speaks just enough of the NATS client protocol to publish:
+// CONNECT once, then PUB followed by PING, whose PONG confirms the server
+// has processed the message. A connection that fails is redialed once.
+// ?? Only the first reachable address is used; there is no cluster discovery from INFO
+type natsPublisher struct {
+	addrs   []string
+	timeout time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+	// connect is the CONNECT payload, carrying credentials from the URL.
+	connect []byte
+}
+
+// newNATSPublisher takes nats://[user:pass@]host[:port] addresses.
+func newNATSPublisher(addrs []string) (eventPublisher, error) {
+	p := &natsPublisher{timeout: 2 * time.Second}
+	opts := map[string]any{"verbose": false, "pedantic": false, "name": "human-plus-plus", "lang": "go"}
+	for _, raw := range addrs {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme != "nats" || u.Host == "" {
+			return nil, fmt.Errorf("nats address %q: want nats://[user:pass@]host[:port]", raw)
+		}
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "4222")
+		}
+		p.addrs = append(p.addrs, host)
+		if u.User != nil {
+			opts["user"] = u.User.Username()
+			opts["pass"], _ = u.User.Password()
+		}
+	}
+	if len(p.addrs) == 0 {
+		return nil, errors.New("nats: no addresses")
+	}
+	p.connect, _ = json.Marshal(opts)
+	return p, nil
+}
+
+func (p *natsPublisher) Publish(ctx context.Context, subject, _ string, value []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	reused := p.conn != nil
+	err := p.publish(ctx, subject, value)
+	if err != nil && reused {
+		// The server may have closed an idle connection.
+		err = p.publish(ctx, subject, value)
+	}
+	return err
+}
+
+// publish sends one message, dialing first if need be. On a protocol or
+// network error it drops the connection, so the caller may retry once.
+func (p *natsPublisher) publish(ctx context.Context, subject string, value []byte) error {
+	if p.conn == nil {
+		if err := p.dial(ctx); err != nil {
+			return err
+		}
+	}
+	deadline := time.Now().Add(p.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	p.conn.SetDeadline(deadline)
+	msg := fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", subject, len(value), value)
+	if _, err := io.WriteString(p.conn, msg); err != nil {
+		p.drop()
+		return err
+	}
+	if err := p.awaitPong(); err != nil {
+		p.drop()
+		return err
+	}
+	return nil
+}
+
+func (p *natsPublisher) dial(ctx context.Context) error {
+	var errs []error
+	for _, addr := range p.addrs {
+		d := net.Dialer{Timeout: p.timeout}
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		p.conn, p.r = conn, bufio.NewReader(conn)
+		conn.SetDeadline(time.Now().Add(p.timeout))
+		// The server speaks first, with INFO.
+		if line, err := p.r.ReadString('\n'); err != nil || !strings.HasPrefix(line, "INFO ") {
+			p.drop()
+			errs = append(errs, fmt.Errorf("nats %s: no INFO from server", addr))
+			continue
+		}
+		if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", p.connect); err != nil {
+			p.drop()
+			errs = append(errs, err)
+			continue
+		}
+		if err := p.awaitPong(); err != nil {
+			p.drop()
+			errs = append(errs, fmt.Errorf("nats %s: %w", addr, err))
+			continue
+		}
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// awaitPong reads until the PONG for our PING, answering the server's own
+// PINGs on the way.
+func (p *natsPublisher) awaitPong() error {
+	for {
+		line, err := p.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := io.WriteString(p.conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.Trim(strings.TrimSpace(line[len("-ERR"):]), "'"))
+		}
+	}
+}
+
+func (p *natsPublisher) drop() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn, p.r = nil, nil
+	}
+}
+
+func (p *natsPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.drop()
+	return nil
+}
@@ -0,0 +1,33 @@
+//go:build kafka
+
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type kafkaPublisher struct {
+	w *kafka.Writer
+}
+
+// newKafkaPublisher writes to the brokers at addrs, host:port each. Keys
+// are hashed to partitions, so one user's events stay in order.
+func newKafkaPublisher(addrs []string) (eventPublisher, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("kafka: no broker addresses")
+	}
+	return &kafkaPublisher{w: &kafka.Writer{
+		Addr:         kafka.TCP(addrs...),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}}, nil
+}
+
+func (p *kafkaPublisher) Publish(ctx context.Context, topic, key string, value []byte) error {
+	return p.w.WriteMessages(ctx, kafka.Message{Topic: topic, Key: []byte(key), Value: value})
+}
+
+func (p *kafkaPublisher) Close() error { return p.w.Close() }
@@ -0,0 +1,9 @@
+//go:build !kafka
+
+package server
+
+import "errors"
+
+func newKafkaPublisher([]string) (eventPublisher, error) {
+	return nil, errors.New("Kafka support not compiled in (rebuild with -tags kafka)")
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Priority is a route's standing when the server is saturated. Lower
+// values come first: they take a free slot ahead of any waiting behind
+// them and are the last to be shed.
+type Priority int
+
+const (
+	// PriorityCritical requests, the probes, skip the limiter entirely.
+	PriorityCritical Priority = iota
+	PriorityRead
+	PriorityWrite
+	// PriorityBulk is for work that grows with the store.
+	PriorityBulk
+	numPriorities
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityCritical:
+		return "critical"
+	case PriorityRead:
+		return "read"
+	case PriorityWrite:
+		return "write"
+	case PriorityBulk:
+		return "bulk"
+	}
+	return "priority(" + strconv.Itoa(int(p)) + ")"
+}
+
+// priority returns the class rt declares for method, falling back to its
+// "*" entry; HEAD shares GET's. Undeclared GETs are reads and everything
+// else a write.
+func (rt Route) priority(method string) Priority {
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	if p, ok := rt.Priority[method]; ok {
+		return p
+	}
+	if p, ok := rt.Priority["*"]; ok {
+		return p
+	}
+	if method == http.MethodGet || method == http.MethodOptions {
+		return PriorityRead
+	}
+	return PriorityWrite
+}
+
+// qosLimiter caps the requests running handlers at once. Past the cap a
+// request waits in a queue, by priority and then arrival; when the queue
+// is full a newcomer displaces the newest waiter of a lower class, or is
+// turned away if there is none. A freed slot goes straight to the best
+// waiter, so a steady stream of bulk work can't starve reads.
+type qosLimiter struct {
+	max     int
+	queue   int
+	timeout time.Duration
+
+	mu       sync.Mutex
+	inFlight int
+	waiting  int
+	waiters  [numPriorities][]*qosWaiter
+}
+
+// qosWaiter's ready receives true when it is handed a slot, or false when
+// a better request displaced it.
+type qosWaiter struct {
+	ready chan bool
+}
+
+func newQoSLimiter(cfg QoSConfig) *qosLimiter {
+	return &qosLimiter{max: cfg.MaxInFlight, queue: cfg.Queue, timeout: cfg.QueueTimeout}
+}
+
+// Middleware holds each of rt's requests to a slot. It is a no-op when
+// qos.max_in_flight is 0.
+func (l *qosLimiter) Middleware(rt Route) Middleware {
+	return func(next http.Handler) http.Handler {
+		if l.max <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := rt.priority(r.Method)
+			if p == PriorityCritical {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !l.acquire(w, r, p) {
+				return
+			}
+			defer l.release()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// acquire takes a slot for a request of priority p, waiting if need be.
+// When it can't, it answers 503 with Retry-After and returns false;
+// otherwise the caller must call release when done.
+func (l *qosLimiter) acquire(w http.ResponseWriter, r *http.Request, p Priority) bool {
+	l.mu.Lock()
+	if l.inFlight < l.max {
+		l.inFlight++
+		l.mu.Unlock()
+		return true
+	}
+	if l.waiting >= l.queue && !l.displace(p) {
+		l.mu.Unlock()
+		l.reject(w, r, p, "queue full")
+		return false
+	}
+	waiter := &qosWaiter{ready: make(chan bool, 1)}
+	l.waiters[p] = append(l.waiters[p], waiter)
+	l.waiting++
+	l.mu.Unlock()
+
+	t := time.NewTimer(l.timeout)
+	defer t.Stop()
+	var reason string
+	select {
+	case granted := <-waiter.ready:
+		if granted {
+			return true
+		}
+		l.reject(w, r, p, "displaced")
+		return false
+	case <-t.C:
+		reason = "queue timeout"
+	case <-r.Context().Done():
+	}
+	if !l.leave(p, waiter) {
+		// It was granted or displaced as it gave up; a granted slot goes
+		// to the next waiter.
+		if <-waiter.ready {
+			l.release()
+		}
+	}
+	if reason != "" {
+		l.reject(w, r, p, reason)
+	}
+	return false
+}
+
+// displace drops the newest waiter of the lowest class below p, reporting
+// whether there was one. l.mu is held.
+func (l *qosLimiter) displace(p Priority) bool {
+	for class := numPriorities - 1; class > p; class-- {
+		if n := len(l.waiters[class]); n > 0 {
+			victim := l.waiters[class][n-1]
+			l.waiters[class] = l.waiters[class][:n-1]
+			l.waiting--
+			victim.ready <- false
+			return true
+		}
+	}
+	return false
+}
+
+// leave takes waiter out of the queue, reporting false if it was already
+// handed a slot or displaced.
+func (l *qosLimiter) leave(p Priority, waiter *qosWaiter) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, w := range l.waiters[p] {
+		if w == waiter {
+			l.waiters[p] = append(l.waiters[p][:i], l.waiters[p][i+1:]...)
+			l.waiting--
+			return true
+		}
+	}
+	return false
+}
+
+// release hands the slot to the best waiter, or frees it.
+func (l *qosLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for class := range l.waiters {
+		if len(l.waiters[class]) > 0 {
+			next := l.waiters[class][0]
+			l.waiters[class] = l.waiters[class][1:]
+			l.waiting--
+			next.ready <- true
+			return
+		}
+	}
+	l.inFlight--
+}
+
+func (l *qosLimiter) reject(w http.ResponseWriter, r *http.Request, p Priority, reason string) {
+	LoggerFrom(r.Context()).Info("request shed", "priority", p, "reason", reason, "max_in_flight", l.max)
+	w.Header().Set("Retry-After", "1")
+	renderJSON(w, r, http.StatusServiceUnavailable, errorResponse(r.Context(), "server_busy"))
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// queued waits until n requests are waiting on l.
+func queued(t *testing.T, l *qosLimiter, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		l.mu.Lock()
+		waiting := l.waiting
+		l.mu.Unlock()
+		if waiting == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d waiting, want %d", waiting, n)
+		}
+	}
+}
+
+// qosRequest is a request whose sheds aren't logged.
+func qosRequest() *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/users", nil)
+	return r.WithContext(withLogger(r.Context(), slog.New(slog.DiscardHandler)))
+}
+
+// acquireAsync asks l for a slot at p and reports the outcome on the
+// returned channel: 200 when granted, or the status it was turned away with.
+func acquireAsync(l *qosLimiter, p Priority) <-chan int {
+	done := make(chan int, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		if l.acquire(w, qosRequest(), p) {
+			done <- http.StatusOK
+			return
+		}
+		done <- w.Code
+	}()
+	return done
+}
+
+func TestQoSInFlightLimit(t *testing.T) {
+	l := newQoSLimiter(QoSConfig{MaxInFlight: 2, Queue: 0, QueueTimeout: time.Second})
+	for range 2 {
+		if got := <-acquireAsync(l, PriorityRead); got != http.StatusOK {
+			t.Fatalf("acquire under the cap = %d", got)
+		}
+	}
+	w := httptest.NewRecorder()
+	if l.acquire(w, qosRequest(), PriorityRead) {
+		t.Fatal("acquire past the cap with no queue succeeded")
+	}
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("shed request = %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	l.release()
+	if got := <-acquireAsync(l, PriorityRead); got != http.StatusOK {
+		t.Errorf("acquire after a release = %d", got)
+	}
+}
+
+func TestQoSMiddlewareSkipsCritical(t *testing.T) {
+	l := newQoSLimiter(QoSConfig{MaxInFlight: 1})
+	l.inFlight = 1 // full
+	h := l.Middleware(Route{Priority: map[string]Priority{"*": PriorityCritical}})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("critical route while full = %d, want 200", w.Code)
+	}
+}
+
+func TestQoSQueueOverflow(t *testing.T) {
+	l := newQoSLimiter(QoSConfig{MaxInFlight: 1, Queue: 1, QueueTimeout: time.Minute})
+	if got := <-acquireAsync(l, PriorityRead); got != http.StatusOK {
+		t.Fatalf("first acquire = %d", got)
+	}
+	bulk := acquireAsync(l, PriorityBulk)
+	queued(t, l, 1)
+
+	// The queue is full: a read displaces the waiting bulk request...
+	read := acquireAsync(l, PriorityRead)
+	if got := <-bulk; got != http.StatusServiceUnavailable {
+		t.Errorf("displaced bulk request = %d, want 503", got)
+	}
+	queued(t, l, 1)
+	// ...while a write finds nothing below it to displace.
+	if got := <-acquireAsync(l, PriorityWrite); got != http.StatusServiceUnavailable {
+		t.Errorf("write with the queue full of reads = %d, want 503", got)
+	}
+	l.release()
+	if got := <-read; got != http.StatusOK {
+		t.Errorf("queued read after a release = %d, want 200", got)
+	}
+}
+
+func TestQoSReleaseOrder(t *testing.T) {
+	l := newQoSLimiter(QoSConfig{MaxInFlight: 1, Queue: 2, QueueTimeout: time.Minute})
+	<-acquireAsync(l, PriorityRead)
+	bulk := acquireAsync(l, PriorityBulk)
+	queued(t, l, 1)
+	read := acquireAsync(l, PriorityRead)
+	queued(t, l, 2)
+
+	l.release()
+	if got := <-read; got != http.StatusOK {
+		t.Fatalf("read queued behind bulk = %d, want the slot first", got)
+	}
+	select {
+	case got := <-bulk:
+		t.Fatalf("bulk request finished with %d while the read holds the slot", got)
+	default:
+	}
+	l.release()
+	if got := <-bulk; got != http.StatusOK {
+		t.Errorf("bulk after the read = %d, want 200", got)
+	}
+}
+
+func TestQoSQueueTimeout(t *testing.T) {
+	l := newQoSLimiter(QoSConfig{MaxInFlight: 1, Queue: 1, QueueTimeout: 20 * time.Millisecond})
+	<-acquireAsync(l, PriorityRead)
+	if got := <-acquireAsync(l, PriorityRead); got != http.StatusServiceUnavailable {
+		t.Errorf("request past the queue timeout = %d, want 503", got)
+	}
+	queued(t, l, 0)
+	l.release()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight != 0 {
+		t.Errorf("inFlight = %d after every slot was released", l.inFlight)
+	}
+}
@@ -0,0 +1,379 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/store"
+)
+
+// quotaLimits caps one tenant or API key. Zero fields are unlimited; API
+// keys take only a request rate.
+type quotaLimits struct {
+	Users        int
+	StorageBytes int64
+	Rate         rateLimit
+}
+
+// quotaRules holds the parsed quotas.limits by tenant and by API key ID,
+// "*" standing for any without an entry of its own.
+type quotaRules struct {
+	tenants map[string]quotaLimits
+	apiKeys map[string]quotaLimits
+}
+
+func (q quotaRules) empty() bool { return len(q.tenants) == 0 && len(q.apiKeys) == 0 }
+
+func lookupQuota(rules map[string]quotaLimits, name string) (quotaLimits, bool) {
+	if limits, ok := rules[name]; ok {
+		return limits, true
+	}
+	limits, ok := rules["*"]
+	return limits, ok
+}
+
+// parseQuotas reads entries such as
+// "tenant:acme,users=1000,storage_bytes=10485760,requests=600,window=1m"
+// or "apikey:3f9a,requests=10,window=1s". The window is required with
+// requests, as in a route limit.
+func parseQuotas(entries []string) (quotaRules, error) {
+	rules := quotaRules{tenants: map[string]quotaLimits{}, apiKeys: map[string]quotaLimits{}}
+	for _, entry := range entries {
+		parts := strings.Split(strings.TrimSpace(entry), ",")
+		kind, name, _ := strings.Cut(parts[0], ":")
+		var target map[string]quotaLimits
+		switch kind {
+		case "tenant":
+			target = rules.tenants
+		case "apikey":
+			target = rules.apiKeys
+		default:
+			return quotaRules{}, fmt.Errorf("quota %q: must start with tenant:<name> or apikey:<id>", entry)
+		}
+		if name == "" {
+			return quotaRules{}, fmt.Errorf("quota %q: needs a %s name or *", entry, kind)
+		}
+		if _, ok := target[name]; ok {
+			return quotaRules{}, fmt.Errorf("quota %q: %s already has a quota", entry, parts[0])
+		}
+		var limits quotaLimits
+		for _, part := range parts[1:] {
+			key, value, _ := strings.Cut(part, "=")
+			var err error
+			switch key {
+			case "users":
+				limits.Users, err = strconv.Atoi(value)
+				if err == nil && limits.Users < 0 {
+					err = errors.New("users must not be negative")
+				}
+			case "storage_bytes":
+				limits.StorageBytes, err = strconv.ParseInt(value, 10, 64)
+				if err == nil && limits.StorageBytes < 0 {
+					err = errors.New("storage_bytes must not be negative")
+				}
+			case "requests":
+				limits.Rate.Requests, err = strconv.Atoi(value)
+				if err == nil && limits.Rate.Requests < 0 {
+					err = errors.New("requests must not be negative")
+				}
+			case "window":
+				limits.Rate.Window, err = time.ParseDuration(value)
+				if err == nil && limits.Rate.Window <= 0 {
+					err = errors.New("window must be positive")
+				}
+			default:
+				err = fmt.Errorf("unknown key %q", key)
+			}
+			if err != nil {
+				return quotaRules{}, fmt.Errorf("quota %q: %w", entry, err)
+			}
+		}
+		if (limits.Rate.Requests > 0) != (limits.Rate.Window > 0) {
+			return quotaRules{}, fmt.Errorf("quota %q: requests and window go together", entry)
+		}
+		if kind == "apikey" && (limits.Users > 0 || limits.StorageBytes > 0) {
+			return quotaRules{}, fmt.Errorf("quota %q: API keys take only requests and window", entry)
+		}
+		target[name] = limits
+	}
+	return rules, nil
+}
+
+// quotas enforces quotas.limits: request rates in Middleware, through the
+// rate limiter's counters, and users and storage when a user is saved.
+type quotas struct {
+	rules   quotaRules
+	limiter *rateLimiting
+	usage   *quotaUsage
+	clock   Clock
+
+	mu sync.Mutex
+	// windows is the last decision seen for each rate key, for the admin
+	// report; the limiter has no way to read a count without adding one.
+	windows map[string]quotaWindow
+}
+
+type quotaWindow struct {
+	used int
+	ends time.Time
+}
+
+func newQuotas(clock Clock) *quotas {
+	return &quotas{usage: &quotaUsage{tenants: map[string]*tenantStorage{}}, clock: clock, windows: map[string]quotaWindow{}}
+}
+
+// quotaUsage tracks each tenant's storage, the size of its users as JSON.
+// A tenant is counted with one pass over the store the first time its
+// usage is wanted, and kept current by usageStore from then on.
+type quotaUsage struct {
+	mu      sync.Mutex
+	tenants map[string]*tenantStorage
+}
+
+type tenantStorage struct {
+	// mu is held across a write and its adjustment, so a count can't
+	// miss or double a write racing it.
+	mu    sync.Mutex
+	known bool
+	bytes int64
+}
+
+func (u *quotaUsage) tenant(name string) *tenantStorage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	t, ok := u.tenants[name]
+	if !ok {
+		t = &tenantStorage{}
+		u.tenants[name] = t
+	}
+	return t
+}
+
+// storageBytes returns the tenant's usage, counting store, its view of the
+// tenant, if this is the first time.
+// ?? Writes another replica makes to a shared store aren't seen until this one restarts
+func (t *tenantStorage) storageBytes(store Store) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.known {
+		var total int64
+		store.Range(context.Background(), func(u User) bool {
+			total += userSize(u)
+			return true
+		})
+		t.bytes, t.known = total, true
+	}
+	return t.bytes
+}
+
+func userSize(u User) int64 {
+	data, _ := json.Marshal(u)
+	return int64(len(data))
+}
+
+// usageStore keeps counted tenants' usage current. Until a tenant is
+// counted its writes pass straight through.
+type usageStore struct {
+	Store
+	tenant string
+	usage  *quotaUsage
+}
+
+func (s usageStore) Set(user User) {
+	t := s.usage.tenant(s.tenant)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.known {
+		s.Store.Set(user)
+		return
+	}
+	if old, ok := s.Store.Get(user.ID); ok {
+		t.bytes -= userSize(old)
+	}
+	s.Store.Set(user)
+	t.bytes += userSize(user)
+}
+
+func (s usageStore) Delete(id string) bool {
+	t := s.usage.tenant(s.tenant)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.known {
+		return s.Store.Delete(id)
+	}
+	old, ok := s.Store.Get(id)
+	deleted := s.Store.Delete(id)
+	if deleted && ok {
+		t.bytes -= userSize(old)
+	}
+	return deleted
+}
+
+// Suggest passes through so the backend's prefix index is still used.
+func (s usageStore) Suggest(prefix string, n int) ([]User, bool) {
+	if sg, ok := s.Store.(store.Suggester); ok {
+		return sg.Suggest(prefix, n)
+	}
+	return nil, false
+}
+
+func (s usageStore) ForTenant(tenant string) Store {
+	return usageStore{Store: s.Store.ForTenant(tenant), tenant: tenant, usage: s.usage}
+}
+
+// Flush passes through so snapshot tasks still reach a file store.
+func (s usageStore) Flush() error {
+	if f, ok := s.Store.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// checkWrite refuses saving user to the tenant's store when it would take
+// the tenant over its users or storage quota. prev is the user it
+// replaces, if any; an update never counts as a new user.
+// ?? Concurrent creates are checked apart, so together they can overshoot by a few
+func (q *quotas) checkWrite(tenant string, store Store, user User, prev *User) *apiError {
+	limits, ok := lookupQuota(q.rules.tenants, tenant)
+	if !ok {
+		return nil
+	}
+	if limits.Users > 0 && prev == nil && store.Len() >= limits.Users {
+		return newAPIError("quota_users", limits.Users)
+	}
+	if limits.StorageBytes > 0 {
+		grows := userSize(user)
+		if prev != nil {
+			grows -= userSize(*prev)
+		}
+		if grows > 0 && q.usage.tenant(tenant).storageBytes(store)+grows > limits.StorageBytes {
+			return newAPIError("quota_storage", limits.StorageBytes)
+		}
+	}
+	return nil
+}
+
+// Middleware counts each request against its tenant's rate quota and,
+// for an API key, the key's, refusing it with 429 once either is spent.
+// Probes are exempt.
+func (q *quotas) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probePaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tenant := TenantFrom(r.Context())
+		if limits, ok := lookupQuota(q.rules.tenants, tenant); ok && !q.allow(w, r, "tenant:"+tenant, limits.Rate) {
+			return
+		}
+		if id, ok := strings.CutPrefix(PrincipalFrom(r.Context()), "apikey:"); ok {
+			if limits, ok := lookupQuota(q.rules.apiKeys, id); ok && !q.allow(w, r, "apikey:"+id, limits.Rate) {
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow counts r against subject's rate, answering 429 when it is over.
+// Like the rate limiter it falls back to per-replica counters when Redis
+// is unreachable.
+func (q *quotas) allow(w http.ResponseWriter, r *http.Request, subject string, limit rateLimit) bool {
+	if limit.Requests <= 0 {
+		return true
+	}
+	key := "quota:" + subject
+	d, err := q.limiter.allow(r.Context(), key, limit)
+	if err != nil {
+		d, _ = q.limiter.fallback.Allow(r.Context(), key, limit)
+	}
+	q.mu.Lock()
+	q.windows[key] = quotaWindow{used: limit.Requests - d.Remaining, ends: q.clock.Now().Add(d.Reset)}
+	q.mu.Unlock()
+	if d.Allowed {
+		return true
+	}
+	LoggerFrom(r.Context()).Info("request quota exceeded", "quota", subject)
+	w.Header().Set("Retry-After", strconv.Itoa(int((d.Reset+time.Second-1)/time.Second)))
+	renderJSON(w, r, http.StatusTooManyRequests, errorResponse(r.Context(), "quota_rate", limit.Requests, limit.Window))
+	return false
+}
+
+// quotaGauge is one quota in the admin report. A Limit of 0 is unlimited.
+type quotaGauge struct {
+	Used  int64 `json:"used"`
+	Limit int64 `json:"limit"`
+}
+
+type rateGauge struct {
+	Used   int    `json:"used"`
+	Limit  int    `json:"limit"`
+	Window string `json:"window"`
+}
+
+type apiKeyQuota struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	Requests *rateGauge `json:"requests,omitempty"`
+}
+
+type quotaReport struct {
+	Tenant       string        `json:"tenant"`
+	Users        quotaGauge    `json:"users"`
+	StorageBytes quotaGauge    `json:"storage_bytes"`
+	Requests     *rateGauge    `json:"requests,omitempty"`
+	APIKeys      []apiKeyQuota `json:"api_keys"`
+}
+
+// rateGauge reports key's use of limit in the current window, nil when
+// there is no rate quota.
+// ?? Used is this replica's last view; with Redis, others' requests show once this one counts another
+func (q *quotas) rateGauge(key string, limit rateLimit) *rateGauge {
+	if limit.Requests <= 0 {
+		return nil
+	}
+	g := &rateGauge{Limit: limit.Requests, Window: limit.Window.String()}
+	q.mu.Lock()
+	if win, ok := q.windows[key]; ok && q.clock.Now().Before(win.ends) {
+		g.Used = win.used
+	}
+	q.mu.Unlock()
+	return g
+}
+
+// handleQuotas reports the tenant's usage against its quotas, and that of
+// each of its API keys.
+func (s *Server) handleQuotas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tenant, store := TenantFrom(r.Context()), s.storeFor(r)
+	limits, _ := lookupQuota(s.quotas.rules.tenants, tenant)
+	report := quotaReport{
+		Tenant:       tenant,
+		Users:        quotaGauge{Used: int64(store.Len()), Limit: int64(limits.Users)},
+		StorageBytes: quotaGauge{Used: s.quotas.usage.tenant(tenant).storageBytes(store), Limit: limits.StorageBytes},
+		Requests:     s.quotas.rateGauge("quota:tenant:"+tenant, limits.Rate),
+		APIKeys:      []apiKeyQuota{},
+	}
+	for _, key := range s.apiKeys.list(tenant) {
+		if key.RevokedAt != nil {
+			continue
+		}
+		keyLimits, _ := lookupQuota(s.quotas.rules.apiKeys, key.ID)
+		report.APIKeys = append(report.APIKeys, apiKeyQuota{
+			ID:       key.ID,
+			Name:     key.Name,
+			Requests: s.quotas.rateGauge("quota:apikey:"+key.ID, keyLimits.Rate),
+		})
+	}
+	renderJSON(w, r, http.StatusOK, dataOK(report))
+}
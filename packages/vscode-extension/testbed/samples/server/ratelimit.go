@@ -0,0 +1,578 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rateLimit allows Requests per fixed Window.
+type rateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+type rateDecision struct {
+	Allowed   bool
+	Remaining int
+	Reset     time.Duration
+}
+
+// RateLimiter counts one request against key and decides whether it fits
+// within limit.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit rateLimit) (rateDecision, error)
+}
+
+func decide(count int64, limit rateLimit, reset time.Duration) rateDecision {
+	remaining := limit.Requests - int(count)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return rateDecision{Allowed: count <= int64(limit.Requests), Remaining: remaining, Reset: reset}
+}
+
+// localLimiter keeps fixed-window counters in memory, so each replica
+// enforces the limit on its own.
+type localLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*localWindow
+	clock   Clock
+}
+
+type localWindow struct {
+	ends  time.Time
+	count int64
+}
+
+func newLocalLimiter(clock Clock) *localLimiter {
+	return &localLimiter{windows: map[string]*localWindow{}, clock: clock}
+}
+
+func (l *localLimiter) Allow(_ context.Context, key string, limit rateLimit) (rateDecision, error) {
+	now := l.clock.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	win, ok := l.windows[key]
+	if !ok || !now.Before(win.ends) {
+		if len(l.windows) > 10000 {
+			l.prune(now)
+		}
+		win = &localWindow{ends: now.Add(limit.Window)}
+		l.windows[key] = win
+	}
+	win.count++
+	return decide(win.count, limit, win.ends.Sub(now)), nil
+}
+
+func (l *localLimiter) prune(now time.Time) {
+	for key, win := range l.windows {
+		if !now.Before(win.ends) {
+			delete(l.windows, key)
+		}
+	}
+}
+
+// redisWindowScript increments the window counter, starting its expiry on
+// the first request, and returns the count and the milliseconds left.
+const redisWindowScript = `local n = redis.call('INCR', KEYS[1])
+if n == 1 then redis.call('PEXPIRE', KEYS[1], ARGV[1]) end
+return {n, redis.call('PTTL', KEYS[1])}`
+
+// redisLimiter shares fixed-window counters between replicas through
+// Redis, so a key gets the same limit whichever instance it reaches.
+type redisLimiter struct {
+	client *redisClient
+	prefix string
+}
+
+func (l *redisLimiter) Allow(ctx context.Context, key string, limit rateLimit) (rateDecision, error) {
+	reply, err := l.client.Do(ctx, "EVAL", redisWindowScript, "1", l.prefix+key, strconv.FormatInt(limit.Window.Milliseconds(), 10))
+	if err != nil {
+		return rateDecision{}, err
+	}
+	items, ok := reply.([]any)
+	if !ok || len(items) != 2 {
+		return rateDecision{}, fmt.Errorf("redis: unexpected rate limit reply %v", reply)
+	}
+	count, _ := items[0].(int64)
+	ttl, _ := items[1].(int64)
+	return decide(count, limit, time.Duration(ttl)*time.Millisecond), nil
+}
+
+// overrideStore holds per-key limits set through the admin API.
+type overrideStore interface {
+	get(ctx context.Context, key string) (rateLimit, bool)
+	set(ctx context.Context, key string, limit rateLimit) error
+	remove(ctx context.Context, key string) error
+	list(ctx context.Context) (map[string]rateLimit, error)
+}
+
+type localOverrides struct {
+	mu     sync.RWMutex
+	limits map[string]rateLimit
+}
+
+func (o *localOverrides) get(_ context.Context, key string) (rateLimit, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	limit, ok := o.limits[key]
+	return limit, ok
+}
+
+func (o *localOverrides) set(_ context.Context, key string, limit rateLimit) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.limits[key] = limit
+	return nil
+}
+
+func (o *localOverrides) remove(_ context.Context, key string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.limits, key)
+	return nil
+}
+
+func (o *localOverrides) list(context.Context) (map[string]rateLimit, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	out := make(map[string]rateLimit, len(o.limits))
+	for k, v := range o.limits {
+		out[k] = v
+	}
+	return out, nil
+}
+
+// redisOverrides keeps overrides in one Redis hash so every replica
+// applies them. Lookups read a local copy that is refetched every
+// overrideTTL; until then a change made on another replica is not seen.
+type redisOverrides struct {
+	client *redisClient
+	hash   string
+
+	mu      sync.Mutex
+	cached  map[string]rateLimit
+	fetched time.Time
+}
+
+const overrideTTL = 5 * time.Second
+
+func (o *redisOverrides) get(ctx context.Context, key string) (rateLimit, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if time.Since(o.fetched) > overrideTTL {
+		// >> On a Redis error the stale copy keeps serving until the next attempt
+		if limits, err := o.fetch(ctx); err == nil {
+			o.cached = limits
+		}
+		o.fetched = time.Now()
+	}
+	limit, ok := o.cached[key]
+	return limit, ok
+}
+
+func (o *redisOverrides) set(ctx context.Context, key string, limit rateLimit) error {
+	data, _ := json.Marshal(limit)
+	_, err := o.client.Do(ctx, "HSET", o.hash, key, string(data))
+	o.invalidate()
+	return err
+}
+
+func (o *redisOverrides) remove(ctx context.Context, key string) error {
+	_, err := o.client.Do(ctx, "HDEL", o.hash, key)
+	o.invalidate()
+	return err
+}
+
+func (o *redisOverrides) list(ctx context.Context) (map[string]rateLimit, error) {
+	return o.fetch(ctx)
+}
+
+func (o *redisOverrides) invalidate() {
+	o.mu.Lock()
+	o.fetched = time.Time{}
+	o.mu.Unlock()
+}
+
+func (o *redisOverrides) fetch(ctx context.Context) (map[string]rateLimit, error) {
+	reply, err := o.client.Do(ctx, "HGETALL", o.hash)
+	if err != nil {
+		return nil, err
+	}
+	items, _ := reply.([]any)
+	out := make(map[string]rateLimit, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		key, _ := items[i].(string)
+		raw, _ := items[i+1].(string)
+		var limit rateLimit
+		if json.Unmarshal([]byte(raw), &limit) == nil {
+			out[key] = limit
+		}
+	}
+	return out, nil
+}
+
+// routeLimit is one route group's limit, parsed from an entry like
+//
+//	POST /users,requests=10,window=1m,key=ip
+//
+// The method is optional. The pattern is a route pattern from the route
+// table; one ending in * covers every pattern with that prefix, so
+// "/admin/*" puts the admin routes in one group sharing one budget. Key
+// says whose requests are counted together: principal, the default, as
+// rateLimitKey does; apikey, the API key, or the address for any other
+// caller; or ip, the address alone. Requests of 0 exempts the group.
+type routeLimit struct {
+	Method  string
+	Pattern string
+	Limit   rateLimit
+	Key     string
+}
+
+func parseRouteLimits(entries []string) ([]routeLimit, error) {
+	var out []routeLimit
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		parts := strings.Split(strings.TrimSpace(entry), ",")
+		rt := routeLimit{Pattern: parts[0], Key: "principal"}
+		if method, pattern, ok := strings.Cut(parts[0], " "); ok {
+			rt.Method, rt.Pattern = strings.ToUpper(method), strings.TrimSpace(pattern)
+		}
+		if !strings.HasPrefix(rt.Pattern, "/") {
+			return nil, fmt.Errorf("route limit %q: must start with [METHOD] /pattern", entry)
+		}
+		window := false
+		for _, part := range parts[1:] {
+			key, value, _ := strings.Cut(part, "=")
+			var err error
+			switch key {
+			case "requests":
+				rt.Limit.Requests, err = strconv.Atoi(value)
+				if err == nil && rt.Limit.Requests < 0 {
+					err = errors.New("requests must not be negative")
+				}
+			case "window":
+				rt.Limit.Window, err = time.ParseDuration(value)
+				if err == nil && rt.Limit.Window <= 0 {
+					err = errors.New("window must be positive")
+				}
+				window = true
+			case "key":
+				rt.Key = value
+				if value != "principal" && value != "apikey" && value != "ip" {
+					err = fmt.Errorf("key %q must be principal, apikey or ip", value)
+				}
+			default:
+				err = fmt.Errorf("unknown key %q", key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("route limit %q: %w", entry, err)
+			}
+		}
+		if !window {
+			return nil, fmt.Errorf("route limit %q: needs a window", entry)
+		}
+		if seen[rt.group()] {
+			return nil, fmt.Errorf("route limit %q: route group already has a limit", entry)
+		}
+		seen[rt.group()] = true
+		out = append(out, rt)
+	}
+	return out, nil
+}
+
+// group names the route group in counter keys and logs.
+func (rt routeLimit) group() string {
+	if rt.Method == "" {
+		return rt.Pattern
+	}
+	return rt.Method + " " + rt.Pattern
+}
+
+func (rt routeLimit) matches(method, pattern string) bool {
+	if rt.Method != "" && rt.Method != method {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(rt.Pattern, "*"); ok {
+		return strings.HasPrefix(pattern, prefix)
+	}
+	return rt.Pattern == pattern
+}
+
+// key identifies the caller within the group, scoped to the tenant as
+// rateLimitKey is.
+func (rt routeLimit) key(r *http.Request) string {
+	var key string
+	switch principal := PrincipalFrom(r.Context()); {
+	case rt.Key == "principal":
+		return "route:" + rt.group() + ":" + rateLimitKey(r)
+	case rt.Key == "apikey" && strings.HasPrefix(principal, "apikey:"):
+		key = principal
+	default:
+		key = "ip:" + clientIP(r)
+	}
+	if tenant := TenantFrom(r.Context()); tenant != "" {
+		key = tenant + "/" + key
+	}
+	return "route:" + rt.group() + ":" + key
+}
+
+// rateLimiting holds the counters and overrides that outlive a reload;
+// the limits themselves belong to each Middleware. When Redis is
+// unreachable it falls back to per-replica counters rather than failing
+// requests.
+type rateLimiting struct {
+	limiter   RateLimiter
+	fallback  *localLimiter
+	overrides overrideStore
+	logger    *slog.Logger
+	limited   CounterVec
+	degraded  atomic.Bool
+	retryAt   atomic.Int64
+	redis     *redisClient
+	// lookup finds the request's route pattern, as the server's mux does.
+	lookup func(*http.Request) (http.Handler, string)
+}
+
+const redisRetry = 5 * time.Second
+
+var errLimiterDegraded = errors.New("rate limiter degraded")
+
+func newRateLimiting(cfg RateLimitConfig, logger *slog.Logger, limited CounterVec, clock Clock) (*rateLimiting, error) {
+	rl := &rateLimiting{
+		fallback: newLocalLimiter(clock),
+		logger:   logger,
+		limited:  limited,
+	}
+	if cfg.RedisURL == "" {
+		rl.limiter = rl.fallback
+		rl.overrides = &localOverrides{limits: map[string]rateLimit{}}
+		return rl, nil
+	}
+	client, err := newRedisClient(cfg.RedisURL, 16)
+	if err != nil {
+		return nil, fmt.Errorf("ratelimit.redis_url: %w", err)
+	}
+	rl.redis = client
+	rl.limiter = &redisLimiter{client: client, prefix: cfg.KeyPrefix}
+	rl.overrides = &redisOverrides{client: client, hash: cfg.KeyPrefix + "overrides"}
+	return rl, nil
+}
+
+func (rl *rateLimiting) Close(context.Context) error {
+	if rl.redis == nil {
+		return nil
+	}
+	return rl.redis.Close()
+}
+
+// clientIP is the address of the peer, without its port.
+// ?? Behind a proxy every caller shares its address; trusted X-Forwarded-For handling is not built in
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimitKey identifies the caller: the authenticated principal, such as
+// an API key or user, or the client address for anonymous requests. Keys
+// are scoped to the tenant.
+func rateLimitKey(r *http.Request) string {
+	key := PrincipalFrom(r.Context())
+	if key == "" {
+		key = "ip:" + clientIP(r)
+	}
+	if tenant := TenantFrom(r.Context()); tenant != "" {
+		key = tenant + "/" + key
+	}
+	return key
+}
+
+// routeLimit returns the first of routes matching r.
+func (rl *rateLimiting) routeLimit(routes []routeLimit, r *http.Request) (routeLimit, bool) {
+	if len(routes) == 0 || rl.lookup == nil {
+		return routeLimit{}, false
+	}
+	_, pattern := rl.lookup(r)
+	for _, rt := range routes {
+		if rt.matches(r.Method, pattern) {
+			return rt, true
+		}
+	}
+	return routeLimit{}, false
+}
+
+// Middleware applies cfg's default limit, or a key's override, and its
+// route groups, checked in order with the first matching a request
+// replacing the default. Requests over the limit get 429, and every
+// response reports the caller's budget in RateLimit-* headers. Probes are
+// limited only by a route group that names them. Each reload builds a new
+// one; the counters carry over.
+func (rl *rateLimiting) Middleware(cfg RateLimitConfig) Middleware {
+	def := rateLimit{Requests: cfg.Requests, Window: cfg.Window}
+	// Validate has already rejected malformed route limits.
+	routes, _ := parseRouteLimits(cfg.Routes)
+	return func(next http.Handler) http.Handler {
+		return rl.middleware(def, routes, next)
+	}
+}
+
+func (rl *rateLimiting) middleware(def rateLimit, routes []routeLimit, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			key   string
+			limit rateLimit
+		)
+		if rt, ok := rl.routeLimit(routes, r); ok {
+			// ?? admin overrides only replace the default; a route group's limit ignores them
+			key, limit = rt.key(r), rt.Limit
+		} else {
+			if probePaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key = rateLimitKey(r)
+			var ok bool
+			if limit, ok = rl.overrides.get(r.Context(), key); !ok {
+				limit = def
+			}
+		}
+		if limit.Requests <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		d, err := rl.allow(r.Context(), key, limit)
+		if err != nil {
+			d, _ = rl.fallback.Allow(r.Context(), key, limit)
+		}
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(int((d.Reset+time.Second-1)/time.Second)))
+		if !d.Allowed {
+			rl.limited.Inc()
+			LoggerFrom(r.Context()).Info("rate limited", "key", key)
+			w.Header().Set("Retry-After", w.Header().Get("RateLimit-Reset"))
+			renderJSON(w, r, http.StatusTooManyRequests, errorResponse(r.Context(), "rate_limited"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow asks the shared limiter, backing off for redisRetry after a failure
+// so an outage doesn't add a dial timeout to every request.
+func (rl *rateLimiting) allow(ctx context.Context, key string, limit rateLimit) (rateDecision, error) {
+	if time.Now().UnixNano() < rl.retryAt.Load() {
+		return rateDecision{}, errLimiterDegraded
+	}
+	d, err := rl.limiter.Allow(ctx, key, limit)
+	if err != nil {
+		rl.retryAt.Store(time.Now().Add(redisRetry).UnixNano())
+		if !rl.degraded.Swap(true) {
+			rl.logger.Warn("rate limiter unavailable, limiting per replica", "err", err)
+		}
+		return rateDecision{}, err
+	}
+	if rl.degraded.Swap(false) {
+		rl.logger.Info("rate limiter recovered")
+	}
+	return d, nil
+}
+
+// rateLimitOverride is an override as the admin API reads and writes it.
+type rateLimitOverride struct {
+	Key      string `json:"key"`
+	Requests int    `json:"requests"`
+	Window   string `json:"window"`
+}
+
+func overrideView(key string, limit rateLimit) rateLimitOverride {
+	return rateLimitOverride{Key: key, Requests: limit.Requests, Window: limit.Window.String()}
+}
+
+// handleRateLimits lists overrides. Keys are as rateLimitKey builds them,
+// e.g. "apikey:3f9a" or "acme/user:42".
+func (s *Server) handleRateLimits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limits, err := s.rateLimit.overrides.list(r.Context())
+	if err != nil {
+		LoggerFrom(r.Context()).Error("rate limit overrides unavailable", "err", err)
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	out := make([]rateLimitOverride, 0, len(limits))
+	for key, limit := range limits {
+		out = append(out, overrideView(key, limit))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	renderJSON(w, r, http.StatusOK, Response{Success: true, Data: out})
+}
+
+// handleRateLimit sets (PUT) or clears (DELETE) the override for one key.
+// A requests of 0 exempts the key.
+func (s *Server) handleRateLimit(w http.ResponseWriter, r *http.Request) {
+	key, ok := pathParam(r.URL.Path, "/admin/ratelimits/")
+	if !ok {
+		http.Error(w, "Missing key", http.StatusBadRequest)
+		return
+	}
+	var (
+		err    error
+		action string
+		after  any
+	)
+	before, had := s.rateLimit.overrides.get(r.Context(), key)
+	switch r.Method {
+	case http.MethodPut:
+		var req rateLimitOverride
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if req.Requests < 0 {
+			unprocessable(w, r, "field_negative", "requests")
+			return
+		}
+		window, perr := time.ParseDuration(req.Window)
+		if perr != nil || window <= 0 {
+			http.Error(w, "window must be a positive duration", http.StatusBadRequest)
+			return
+		}
+		limit := rateLimit{Requests: req.Requests, Window: window}
+		err = s.rateLimit.overrides.set(r.Context(), key, limit)
+		action, after = "set", overrideView(key, limit)
+	case http.MethodDelete:
+		err = s.rateLimit.overrides.remove(r.Context(), key)
+		action = "removed"
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		LoggerFrom(r.Context()).Error("rate limit override failed", "key", key, "err", err)
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	LoggerFrom(r.Context()).Info("rate limit override "+action, "key", key)
+	var prev any
+	if had {
+		prev = overrideView(key, before)
+	}
+	s.audit(r.Context(), "admin", "ratelimit."+action, key, map[string]auditChange{"override": {From: prev, To: after}})
+	renderJSON(w, r, http.StatusOK, Response{Success: true})
+}
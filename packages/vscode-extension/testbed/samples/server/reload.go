@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+)
+
+// swapHandler lets the middleware chain be rebuilt on reload while keeping
+// the listener, and every open connection, untouched.
+type swapHandler struct {
+	v atomic.Value
+}
+
+func (h *swapHandler) Store(next http.Handler) {
+	h.v.Store(next)
+}
+
+func (h *swapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.v.Load().(http.Handler).ServeHTTP(w, r)
+}
+
+type ReloadReport struct {
+	Applied         []string
+	RestartRequired []string
+}
+
+// Reload applies the reloadable subset of next. Changes to any other key are
+// reported but ignored until the process restarts.
+func (s *Server) Reload(next Config) (ReloadReport, error) {
+	var report ReloadReport
+	if err := next.Validate(); err != nil {
+		return report, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.certs != nil {
+		if err := s.certs.Reload(); err != nil {
+			return report, fmt.Errorf("reload TLS key pair: %w", err)
+		}
+		report.Applied = append(report.Applied, "tls (key pair)")
+	}
+
+	merged := s.cfg
+	diff := map[string]auditChange{}
+	updated := map[string]reflect.Value{}
+	next.eachField(func(f configField) { updated[f.name] = f.value })
+	merged.eachField(func(f configField) {
+		value := updated[f.name]
+		if reflect.DeepEqual(f.value.Interface(), value.Interface()) {
+			return
+		}
+		if !f.reload {
+			report.RestartRequired = append(report.RestartRequired, f.name)
+			return
+		}
+		from, to := f.value.Interface(), value.Interface()
+		if f.secret {
+			from, to = redactSecret(fmt.Sprint(from)), redactSecret(fmt.Sprint(to))
+		}
+		diff[f.name] = auditChange{From: from, To: to}
+		f.value.Set(value)
+		report.Applied = append(report.Applied, f.name)
+	})
+
+	logLevel.Set(parseLogLevel(merged.Logging.Level))
+	if len(report.Applied) > 0 {
+		s.handler.Store(s.buildHandler(merged))
+		s.cfg = merged
+		s.events.Publish(context.Background(), ConfigReloadEvent{Applied: report.Applied})
+		s.audit(context.Background(), "config", "config.reload", "", diff)
+	}
+	return report, nil
+}
+
+func (s *Server) ReloadFromArgs(args []string) {
+	cfg, err := LoadConfig(args)
+	if err != nil {
+		s.logger.Error("reload rejected", "err", err)
+		return
+	}
+	report, err := s.Reload(cfg)
+	if err != nil {
+		s.logger.Error("reload rejected", "err", err)
+		return
+	}
+	s.logger.Info("reload applied", "keys", report.Applied)
+	if len(report.RestartRequired) > 0 {
+		s.logger.Warn("reload ignored keys that require a restart", "keys", report.RestartRequired)
+	}
+}
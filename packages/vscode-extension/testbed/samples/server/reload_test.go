@@ -0,0 +1,138 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestReloadDuringRequests serves requests while Reload swaps the config
+// underneath them; run with -race, any handler reading s.cfg shows up.
+func TestReloadDuringRequests(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Audit.File = ""
+	cfg.Admin.Token = "admin-token"
+	cfg.Auth.SigningKeys = []string{"k1:" + strings.Repeat("s", minSigningKeyLen)}
+	store := NewUserStore()
+	store.Set(User{ID: "u-1", Name: "Ada"})
+	srv, err := NewServer(cfg, WithStore(store), WithLogger(slog.New(slog.DiscardHandler)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := srv.Handler()
+	post := func(path, body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		return r
+	}
+	requests := []struct {
+		request func() *http.Request
+		want    int
+	}{
+		{func() *http.Request { return httptest.NewRequest(http.MethodGet, "/openapi.json", nil) }, http.StatusOK},
+		{func() *http.Request {
+			token := srv.refresh.issue("u-1", DefaultTenant, randomHex(8), time.Hour)
+			return post("/auth/refresh", `{"refresh_token":"`+token+`"}`)
+		}, http.StatusOK},
+		// Checks the caller against the admin token.
+		{func() *http.Request {
+			return post("/users", `{"id":"u-1","name":"Ada","password":"correct horse"}`)
+		}, http.StatusForbidden},
+		// Revokes the user's tokens for the access token lifetime.
+		{func() *http.Request {
+			id := randomHex(8)
+			store.Set(User{ID: id, Name: "Grace"})
+			r := post("/admin/users/"+id+"/anonymize", "")
+			r.Header.Set("Authorization", "Bearer "+cfg.Admin.Token)
+			return r
+		}, http.StatusOK},
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for _, tt := range requests {
+		wg.Go(func() {
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				r, w := tt.request(), httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				if w.Code != tt.want {
+					t.Errorf("%s %s = %d, want %d", r.Method, r.URL.Path, w.Code, tt.want)
+					return
+				}
+			}
+		})
+	}
+	for i := range 50 {
+		next := cfg
+		next.Middleware.CompressMin = i + 1
+		if _, err := srv.Reload(next); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestReloadLeavesFaultsOff(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Audit.File = ""
+	srv, err := NewServer(cfg, WithStore(NewUserStore()), WithLogger(slog.New(slog.DiscardHandler)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := cfg
+	next.Middleware.Faults = true
+	next.Middleware.FaultErrors = true
+	report, err := srv.Reload(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Applied) > 0 || !slices.Contains(report.RestartRequired, "middleware.faults") {
+		t.Errorf("reload applied %v, needs a restart for %v; want middleware.faults to need a restart", report.Applied, report.RestartRequired)
+	}
+}
+
+func TestReloadRateLimits(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Audit.File = ""
+	cfg.RateLimit.Requests, cfg.RateLimit.Window = 1, time.Hour
+	srv, err := NewServer(cfg, WithStore(NewUserStore()), WithLogger(slog.New(slog.DiscardHandler)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/u-1", nil))
+		return w
+	}
+	get()
+	if w := get(); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request under a limit of 1 = %d, want 429", w.Code)
+	}
+
+	next := cfg
+	next.RateLimit.Requests = 5
+	report, err := srv.Reload(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(report.Applied, "ratelimit.requests") {
+		t.Fatalf("reload applied %v, want ratelimit.requests", report.Applied)
+	}
+	// The two requests so far still count against the new limit.
+	w := get()
+	if w.Code == http.StatusTooManyRequests || w.Header().Get("RateLimit-Limit") != "5" || w.Header().Get("RateLimit-Remaining") != "2" {
+		t.Errorf("after reload: %d, limit %s, remaining %s; want the new limit of 5 with 2 left",
+			w.Code, w.Header().Get("RateLimit-Limit"), w.Header().Get("RateLimit-Remaining"))
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/middleware"
+)
+
+// maxPooledBuffer keeps one oversized response, such as a full user list,
+// from pinning its buffer in the pool for good.
+const maxPooledBuffer = 64 << 10
+
+var renderBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// renderJSON encodes v in full before anything reaches the client, so an
+// encoding error becomes a clean 500 rather than a truncated body under a
+// status that has already been sent. The body goes out with its
+// Content-Length and, unless the handler chose otherwise, an
+// application/json Content-Type.
+func renderJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	buf := renderBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			renderBuffers.Put(buf)
+		}
+	}()
+
+	if err := encodeBody(currentCodec(), buf, v, timestampFormatFrom(r.Context())); err != nil {
+		LoggerFrom(r.Context()).Error("encoding response failed", "err", err)
+		buf.Reset()
+		json.NewEncoder(buf).Encode(errorResponse(r.Context(), "internal_error"))
+		status = http.StatusInternalServerError
+	}
+	h := w.Header()
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", "application/json")
+	}
+	h.Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// errorResponse is the JSON error envelope for a catalog code, with the
+// message in the request's language and the trace ID so a failure can be
+// found in the logs.
+func errorResponse(ctx context.Context, code string, args ...any) Response {
+	resp := Response{Success: false, Code: code, Error: localize(LanguageFrom(ctx), code, args)}
+	if tc, ok := middleware.TraceFrom(ctx); ok {
+		resp.TraceID = tc.TraceID
+	}
+	return resp
+}
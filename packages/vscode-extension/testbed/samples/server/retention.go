@@ -0,0 +1,222 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// retentionRule deletes or anonymizes a tenant's users created more than
+// OlderThan ago.
+// ?? Users carry no last-activity time, so "inactive for N days" can't be expressed yet
+type retentionRule struct {
+	Tenant    string
+	OlderThan time.Duration
+	Action    string // delete or anonymize
+}
+
+// parseRetentionRules reads entries such as
+// "tenant:acme,older_than=365d,action=anonymize". Without a tenant: part
+// the rule is for the default tenant. older_than takes a Go duration or
+// a whole number of days.
+func parseRetentionRules(entries []string) ([]retentionRule, error) {
+	var out []retentionRule
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		parts := strings.Split(strings.TrimSpace(entry), ",")
+		var rule retentionRule
+		if name, ok := strings.CutPrefix(parts[0], "tenant:"); ok {
+			if !validTenant.MatchString(name) {
+				return nil, fmt.Errorf("retention rule %q: invalid tenant %q", entry, name)
+			}
+			rule.Tenant, parts = name, parts[1:]
+		}
+		for _, part := range parts {
+			key, value, _ := strings.Cut(part, "=")
+			var err error
+			switch key {
+			case "older_than":
+				rule.OlderThan, err = parseAge(value)
+			case "action":
+				rule.Action = value
+				if value != "delete" && value != "anonymize" {
+					err = fmt.Errorf("action %q must be delete or anonymize", value)
+				}
+			default:
+				err = fmt.Errorf("unknown key %q", key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("retention rule %q: %w", entry, err)
+			}
+		}
+		if rule.OlderThan == 0 || rule.Action == "" {
+			return nil, fmt.Errorf("retention rule %q: needs older_than and action", entry)
+		}
+		if seen[rule.Tenant] {
+			return nil, fmt.Errorf("retention rule %q: tenant already has a rule", entry)
+		}
+		seen[rule.Tenant] = true
+		out = append(out, rule)
+	}
+	return out, nil
+}
+
+func parseAge(value string) (time.Duration, error) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("older_than %q: %w", value, err)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(value); err != nil {
+			return 0, err
+		}
+	}
+	if d <= 0 {
+		return 0, errors.New("older_than must be positive")
+	}
+	return d, nil
+}
+
+// maxRetentionIDs caps the IDs a report lists per rule; Count has them all.
+const maxRetentionIDs = 100
+
+type retentionReport struct {
+	Time   time.Time             `json:"time"`
+	DryRun bool                  `json:"dry_run"`
+	Rules  []retentionRuleReport `json:"rules"`
+}
+
+type retentionRuleReport struct {
+	Tenant    string `json:"tenant"`
+	Action    string `json:"action"`
+	OlderThan string `json:"older_than"`
+	// Count is the users the rule removed or scrubbed, or with dry_run
+	// would have.
+	Count int      `json:"count"`
+	IDs   []string `json:"ids"`
+}
+
+// retention applies the retention rules and keeps the last run's report.
+type retention struct {
+	rules  []retentionRule
+	dryRun bool
+
+	mu   sync.Mutex // held for a whole run, so runs don't overlap
+	last *retentionReport
+}
+
+// applyRetention runs every rule, only reporting what it would do when
+// dryRun is set.
+func (s *Server) applyRetention(ctx context.Context, dryRun bool) (retentionReport, error) {
+	s.retention.mu.Lock()
+	defer s.retention.mu.Unlock()
+	now := s.clock.Now()
+	report := retentionReport{Time: now, DryRun: dryRun, Rules: []retentionRuleReport{}}
+	for _, rule := range s.retention.rules {
+		cutoff := now.Add(-rule.OlderThan)
+		store := s.store.ForTenant(rule.Tenant)
+		var expired []User
+		if err := store.Range(ctx, func(u User) bool {
+			if u.CreatedAt.Before(cutoff) {
+				expired = append(expired, u)
+			}
+			return true
+		}); err != nil {
+			return report, err
+		}
+		rr := retentionRuleReport{Tenant: rule.Tenant, Action: rule.Action, OlderThan: rule.OlderThan.String(), IDs: []string{}}
+		tctx := context.WithValue(ctx, tenantKey{}, rule.Tenant)
+		for _, u := range expired {
+			var applied bool
+			switch {
+			case rule.Action == "anonymize" && dryRun:
+				_, cleared := scrubUser(u)
+				applied = len(cleared) > 0
+			case rule.Action == "anonymize":
+				applied = s.anonymizeUser(tctx, store, u, "retention")
+			case dryRun:
+				applied = true
+			default:
+				if applied = store.Delete(u.ID); applied {
+					s.events.Publish(tctx, UserEvent{Action: "deleted", User: User{ID: u.ID}})
+					s.audit(tctx, "retention", "user.delete", u.ID, nil)
+				}
+			}
+			if !applied {
+				continue
+			}
+			rr.Count++
+			if len(rr.IDs) < maxRetentionIDs {
+				rr.IDs = append(rr.IDs, u.ID)
+			}
+		}
+		if rr.Count > 0 {
+			s.logger.Info("retention rule applied", "tenant", rule.Tenant, "action", rule.Action, "users", rr.Count, "dry_run", dryRun)
+		}
+		report.Rules = append(report.Rules, rr)
+	}
+	s.retention.last = &report
+	return report, nil
+}
+
+// scheduleRetention runs the rules on cfg.Schedule. It is leader-only so
+// replicas sharing a store don't race each other through the same users.
+func (s *Server) scheduleRetention(cfg RetentionConfig) error {
+	// Validate has already rejected malformed rules.
+	rules, _ := parseRetentionRules(cfg.Rules)
+	s.retention = &retention{rules: rules, dryRun: cfg.DryRun}
+	if cfg.Schedule == "" || len(rules) == 0 {
+		return nil
+	}
+	return s.Schedule("retention", cfg.Schedule, true, func(ctx context.Context) error {
+		if !s.started.Load() {
+			return nil
+		}
+		_, err := s.applyRetention(ctx, cfg.DryRun)
+		return err
+	})
+}
+
+// handleRetention shows the last run's report (GET) or runs the rules now
+// (POST), as a dry run with ?dry_run=true or when retention.dry_run is
+// set.
+func (s *Server) handleRetention(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.retention.mu.Lock()
+		last := s.retention.last
+		s.retention.mu.Unlock()
+		renderJSON(w, r, http.StatusOK, dataOK(last))
+	case http.MethodPost:
+		dryRun := s.retention.dryRun
+		if v := r.URL.Query().Get("dry_run"); v != "" {
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				http.Error(w, "dry_run must be a boolean", http.StatusBadRequest)
+				return
+			}
+			dryRun = dryRun || parsed
+		}
+		release, ok := s.heavy.acquire(w, r)
+		if !ok {
+			return
+		}
+		defer release()
+		report, err := s.applyRetention(r.Context(), dryRun)
+		if err != nil {
+			return
+		}
+		renderJSON(w, r, http.StatusOK, dataOK(report))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
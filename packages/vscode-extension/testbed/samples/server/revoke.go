@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// revocationList holds the IDs (jti) of access tokens revoked before they
+// expire, and per user the time before which all of theirs were. Entries
+// only need to outlive the tokens, so both implementations forget them at
+// their expiry.
+type revocationList interface {
+	revoke(ctx context.Context, jti string, expires time.Time) error
+	// revokeSubject revokes every token of subject in tenant issued at or
+	// before before; expires is when the last of them runs out.
+	revokeSubject(ctx context.Context, tenant, subject string, before, expires time.Time) error
+	revoked(ctx context.Context, claims Claims) (bool, error)
+}
+
+// subjectKey names a user's tokens across tenants, whose IDs may repeat.
+func subjectKey(tenant, subject string) string {
+	return tenant + "/" + subject
+}
+
+type subjectRevocation struct {
+	before  int64 // Unix seconds, as a token's iat
+	expires time.Time
+}
+
+// memoryRevocations is per replica: a token revoked on one instance stays
+// valid on the others. Use the Redis list when running more than one.
+type memoryRevocations struct {
+	mu       sync.Mutex
+	ids      map[string]time.Time
+	subjects map[string]subjectRevocation
+	clock    Clock
+}
+
+func (m *memoryRevocations) revoke(_ context.Context, jti string, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ids[jti] = expires
+	return nil
+}
+
+func (m *memoryRevocations) revokeSubject(_ context.Context, tenant, subject string, before, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subjects[subjectKey(tenant, subject)] = subjectRevocation{before: before.Unix(), expires: expires}
+	return nil
+}
+
+func (m *memoryRevocations) revoked(_ context.Context, claims Claims) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.clock.Now()
+	if expires, ok := m.ids[claims.ID]; ok && now.Before(expires) {
+		return true, nil
+	}
+	sub, ok := m.subjects[subjectKey(claims.Tenant, claims.Subject)]
+	return ok && now.Before(sub.expires) && claims.IssuedAt <= sub.before, nil
+}
+
+func (m *memoryRevocations) prune(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for jti, expires := range m.ids {
+		if now.After(expires) {
+			delete(m.ids, jti)
+		}
+	}
+	for key, sub := range m.subjects {
+		if now.After(sub.expires) {
+			delete(m.subjects, key)
+		}
+	}
+}
+
+// run prunes expired entries every interval until done is closed.
+func (m *memoryRevocations) run(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			m.prune(m.clock.Now())
+		}
+	}
+}
+
+// redisRevocations stores each revoked jti, and each user's revocation
+// time, as a key that Redis expires with the tokens, so no pruning is
+// needed.
+type redisRevocations struct {
+	client *redisClient
+	prefix string
+	clock  Clock
+}
+
+func (r *redisRevocations) revoke(ctx context.Context, jti string, expires time.Time) error {
+	ttl := expires.Sub(r.clock.Now()).Milliseconds()
+	if ttl <= 0 {
+		return nil
+	}
+	_, err := r.client.Do(ctx, "SET", r.prefix+jti, "1", "PX", strconv.FormatInt(ttl, 10))
+	return err
+}
+
+func (r *redisRevocations) revokeSubject(ctx context.Context, tenant, subject string, before, expires time.Time) error {
+	ttl := expires.Sub(r.clock.Now()).Milliseconds()
+	if ttl <= 0 {
+		return nil
+	}
+	_, err := r.client.Do(ctx, "SET", r.prefix+"sub:"+subjectKey(tenant, subject), strconv.FormatInt(before.Unix(), 10), "PX", strconv.FormatInt(ttl, 10))
+	return err
+}
+
+// revoked looks up the jti and the subject in one round trip.
+func (r *redisRevocations) revoked(ctx context.Context, claims Claims) (bool, error) {
+	reply, err := r.client.Do(ctx, "MGET", r.prefix+claims.ID, r.prefix+"sub:"+subjectKey(claims.Tenant, claims.Subject))
+	if err != nil {
+		return false, err
+	}
+	values, _ := reply.([]any)
+	if len(values) != 2 {
+		return false, fmt.Errorf("redis: MGET returned %d values", len(values))
+	}
+	if values[0] != nil {
+		return true, nil
+	}
+	before, ok := values[1].(string)
+	if !ok {
+		return false, nil
+	}
+	n, err := strconv.ParseInt(before, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("redis: revocation time %q: %w", before, err)
+	}
+	return claims.IssuedAt <= n, nil
+}
+
+func newRevocationList(cfg AuthConfig, clock Clock) (revocationList, *redisClient, error) {
+	if cfg.RevocationRedisURL == "" {
+		return &memoryRevocations{ids: map[string]time.Time{}, subjects: map[string]subjectRevocation{}, clock: clock}, nil, nil
+	}
+	client, err := newRedisClient(cfg.RevocationRedisURL, 8)
+	if err != nil {
+		return nil, nil, fmt.Errorf("auth.revocation_redis_url: %w", err)
+	}
+	return &redisRevocations{client: client, prefix: "hpp:revoked:", clock: clock}, client, nil
+}
+
+type claimsKey struct{}
+
+// ClaimsFrom returns the verified access token's claims.
+func ClaimsFrom(ctx context.Context) (Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(Claims)
+	return c, ok
+}
+
+type logoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// handleLogout revokes the presented access token and, when the body names
+// one, the refresh token's whole family, ending the session everywhere it
+// was refreshed.
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, ok := ClaimsFrom(r.Context())
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		renderJSON(w, r, http.StatusUnauthorized, errorResponse(r.Context(), "access_token_required"))
+		return
+	}
+	var req logoutRequest
+	if r.ContentLength != 0 && !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := s.revoked.revoke(r.Context(), claims.ID, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		LoggerFrom(r.Context()).Error("token revocation failed", "err", err)
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if req.RefreshToken != "" {
+		s.refresh.revokeFamily(req.RefreshToken, claims.Subject, TenantFrom(r.Context()))
+	}
+	LoggerFrom(r.Context()).Info("logged out", "user_id", claims.Subject)
+	renderJSON(w, r, http.StatusOK, Response{Success: true})
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Route is a single registration in the server's route table. Cross-cutting
+// behavior such as deprecation and admin auth is driven from here rather
+// than the handlers.
+type Route struct {
+	Pattern    string
+	Handler    http.HandlerFunc
+	Deprecated *Deprecation
+	Admin      bool
+	// Scopes maps a method, or "*" for any, to the scopes a credential
+	// must carry; see RequireScopes. They are published at /openapi.json.
+	Scopes map[string][]string
+	// Responses maps a method to the statuses its handler answers with
+	// and the body of each. They are published at /openapi.json and
+	// checked by the contract command; statuses from the shared
+	// middleware are added to every documented method.
+	Responses map[string]map[int]apiBody
+	// Cache maps a method, or "*" for any, to its Cache-Control; see
+	// CacheControl for what undeclared methods get.
+	Cache map[string]CachePolicy
+	// Priority maps a method, or "*" for any, to its class when the
+	// server is saturated; undeclared GETs are reads and other methods
+	// writes. See qosLimiter.
+	Priority map[string]Priority
+}
+
+type Deprecation struct {
+	Since       time.Time
+	Sunset      time.Time
+	Replacement string
+}
+
+func (s *Server) routes() []Route {
+	critical := map[string]Priority{"*": PriorityCritical}
+	routes := []Route{
+		{Pattern: "/health", Handler: s.handleHealth, Responses: map[string]map[int]apiBody{
+			http.MethodGet: {http.StatusOK: envelope("")},
+		}, Cache: map[string]CachePolicy{"*": noStore}, Priority: critical},
+		{Pattern: "/readyz", Handler: s.handleReady, Cache: map[string]CachePolicy{"*": noStore}, Priority: critical},
+		{Pattern: "/startupz", Handler: s.handleStartup, Cache: map[string]CachePolicy{"*": noStore}, Priority: critical},
+		{Pattern: "/openapi.json", Handler: s.handleOpenAPI, Cache: map[string]CachePolicy{
+			http.MethodGet: {MaxAge: 5 * time.Minute, StaleWhileRevalidate: time.Hour},
+		}},
+		{Pattern: "/users", Handler: s.handleUsers, Scopes: map[string][]string{
+			http.MethodGet:  {"users:read"},
+			http.MethodPost: {"users:write"},
+		}, Responses: map[string]map[int]apiBody{
+			http.MethodGet: {http.StatusOK: envelope([]User{}), http.StatusNotModified: {}, http.StatusBadRequest: textBody},
+			http.MethodPost: {
+				http.StatusCreated:               envelope(User{}),
+				http.StatusBadRequest:            errorOrText,
+				http.StatusForbidden:             errorBody,
+				http.StatusRequestEntityTooLarge: errorBody,
+				http.StatusUnprocessableEntity:   errorBody,
+				http.StatusNotImplemented:        textBody,
+			},
+		}, Cache: map[string]CachePolicy{
+			// ?? a tenant's list can be seconds stale in the browser; is that acceptable right after a POST?
+			http.MethodGet: {Private: true, MaxAge: 5 * time.Second, StaleWhileRevalidate: 30 * time.Second},
+		}, Priority: map[string]Priority{
+			// Pages too: listUsers copies the whole tenant before paginating.
+			http.MethodGet: PriorityBulk,
+		}},
+		{Pattern: "/users/changes", Handler: s.handleUserChanges, Scopes: map[string][]string{
+			http.MethodGet: {"users:read"},
+		}, Responses: map[string]map[int]apiBody{
+			http.MethodGet: {http.StatusOK: envelope(changesPage{}), http.StatusBadRequest: errorBody, http.StatusGone: errorBody},
+		}, Cache: map[string]CachePolicy{"*": noStore},
+			// A long poll mostly waits; holding a slot for it would starve the rest.
+			Priority: critical},
+		{Pattern: "/users/suggest", Handler: s.handleUserSuggest, Scopes: map[string][]string{
+			http.MethodGet: {"users:read"},
+		}, Responses: map[string]map[int]apiBody{
+			http.MethodGet: {http.StatusOK: envelope(suggestPage{}), http.StatusBadRequest: errorOrText},
+		}, Cache: map[string]CachePolicy{
+			http.MethodGet: {Private: true, MaxAge: 10 * time.Second},
+		}},
+		{Pattern: "/users/", Handler: s.handleUser, Scopes: map[string][]string{
+			http.MethodGet:    {"users:read"},
+			http.MethodDelete: {"users:write"},
+		}, Responses: map[string]map[int]apiBody{
+			http.MethodGet:    {http.StatusOK: envelope(User{}), http.StatusBadRequest: textBody, http.StatusNotFound: textBody},
+			http.MethodDelete: {http.StatusOK: envelope(nil), http.StatusBadRequest: textBody, http.StatusNotFound: textBody},
+		}, Cache: map[string]CachePolicy{
+			http.MethodGet: {Private: true, MaxAge: 30 * time.Second, StaleWhileRevalidate: time.Minute},
+		}},
+		{Pattern: "/admin/loglevel", Handler: s.handleLogLevel, Admin: true},
+		{Pattern: "/admin/runtime", Handler: s.handleRuntime, Admin: true},
+		{Pattern: "/admin/slo", Handler: s.handleSLO, Admin: true},
+		{Pattern: "/admin/apikeys", Handler: s.handleAPIKeys, Admin: true},
+		{Pattern: "/admin/apikeys/", Handler: s.handleAPIKey, Admin: true},
+		{Pattern: "/admin/ratelimits", Handler: s.handleRateLimits, Admin: true},
+		{Pattern: "/admin/ratelimits/", Handler: s.handleRateLimit, Admin: true},
+		{Pattern: "/admin/quotas", Handler: s.handleQuotas, Admin: true},
+		{Pattern: "/admin/users/anonymize", Handler: s.handleAnonymizeUsers, Admin: true, Priority: map[string]Priority{"*": PriorityBulk}},
+		{Pattern: "/admin/users/", Handler: s.handleAnonymizeUser, Admin: true},
+		{Pattern: "/admin/retention", Handler: s.handleRetention, Admin: true, Priority: map[string]Priority{http.MethodPost: PriorityBulk}},
+		{Pattern: "/admin/audit", Handler: s.handleAudit, Admin: true},
+	}
+	if len(s.jwtKeys.Load()) > 0 {
+		routes = append(routes,
+			Route{Pattern: "/auth/login", Handler: s.handleLogin},
+			Route{Pattern: "/auth/refresh", Handler: s.handleRefresh},
+			Route{Pattern: "/auth/logout", Handler: s.handleLogout},
+		)
+	}
+	if s.cfg.Metrics.Enabled && s.cfg.Metrics.Addr == "" {
+		routes = append(routes, Route{Pattern: "/metrics", Handler: s.handleMetrics, Admin: true})
+	}
+	if s.cfg.Debug.Addr == "" {
+		routes = append(routes, s.debugRoutes(s.cfg.Debug)...)
+	}
+	return routes
+}
+
+func (s *Server) routeHandler(rt Route) http.Handler {
+	h := s.qos.Middleware(rt)(rt.Handler)
+	h = s.memory.Middleware(rt)(h)
+	if rt.Deprecated != nil {
+		h = DeprecationMiddleware(*rt.Deprecated)(h)
+	}
+	if len(rt.Scopes) > 0 {
+		h = RequireScopes(rt, !s.cfg.Auth.RequireScopes)(h)
+	}
+	if rt.Admin {
+		h = AdminAuthMiddleware(s.adminToken)(h)
+	}
+	for _, pattern := range s.cfg.TLS.ClientCertRoutes {
+		if pattern == rt.Pattern {
+			h = RequireClientCert(h)
+		}
+	}
+	h = CacheControl(rt)(h)
+	h = s.metrics.RouteMiddleware(rt.Pattern)(h)
+	h = s.slo.RouteMiddleware(rt.Pattern)(h)
+	h = routeLogger(rt.Pattern)(h)
+	return s.stats.RouteMiddleware(rt.Pattern)(h)
+}
+
+// >> Header formats follow RFC 9745 (Deprecation) and RFC 8594 (Sunset)
+func DeprecationMiddleware(d Deprecation) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if d.Since.IsZero() {
+				w.Header().Set("Deprecation", "true")
+			} else {
+				w.Header().Set("Deprecation", fmt.Sprintf("@%d", d.Since.Unix()))
+			}
+			if !d.Sunset.IsZero() {
+				w.Header().Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if d.Replacement != "" {
+				w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, d.Replacement))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// pathParam returns what follows prefix in path, the ID in a subtree route
+// like "/users/". ok is false when path is outside prefix or nothing
+// follows it. It is pure so the extraction can be fuzzed apart from the
+// mux.
+func pathParam(path, prefix string) (param string, ok bool) {
+	param, ok = strings.CutPrefix(path, prefix)
+	return param, ok && param != ""
+}
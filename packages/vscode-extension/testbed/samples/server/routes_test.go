@@ -0,0 +1,32 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzPathParam(f *testing.F) {
+	for _, seed := range [][2]string{
+		{"/users/u1", "/users/"},
+		{"/users/", "/users/"},
+		{"/users", "/users/"},
+		{"/admin/users/u1/anonymize", "/admin/users/"},
+		{"/users/a/b", "/users/"},
+		{"/usersx/u1", "/users/"},
+		{"", ""},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+	f.Fuzz(func(t *testing.T, path, prefix string) {
+		param, ok := pathParam(path, prefix)
+		if !ok {
+			if strings.HasPrefix(path, prefix) && len(path) > len(prefix) {
+				t.Fatalf("pathParam(%q, %q) refused a path with something after the prefix", path, prefix)
+			}
+			return
+		}
+		if param == "" || prefix+param != path {
+			t.Fatalf("pathParam(%q, %q) = %q, which doesn't rebuild the path", path, prefix, param)
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// connTracker counts the main server's connections by state via
+// http.Server.ConnState. With maxIdle set it also closes a connection that
+// goes idle while that many others already are.
+type connTracker struct {
+	mu      sync.Mutex
+	states  map[net.Conn]http.ConnState
+	opened  int64
+	maxIdle int
+	idle    int
+}
+
+func (t *connTracker) track(c net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.states == nil {
+		t.states = map[net.Conn]http.ConnState{}
+	}
+	if t.states[c] == http.StateIdle {
+		t.idle--
+	}
+	switch state {
+	case http.StateNew:
+		t.opened++
+		t.states[c] = state
+	case http.StateHijacked, http.StateClosed:
+		delete(t.states, c)
+	case http.StateIdle:
+		if t.maxIdle > 0 && t.idle >= t.maxIdle {
+			// The server sees this as the client hanging up; its later
+			// StateClosed finds the entry already gone.
+			delete(t.states, c)
+			c.Close()
+			return
+		}
+		t.idle++
+		t.states[c] = state
+	default:
+		t.states[c] = state
+	}
+}
+
+func (t *connTracker) counts() map[string]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts := map[string]int64{"open": int64(len(t.states)), "accepted_total": t.opened}
+	for _, state := range t.states {
+		counts[state.String()]++
+	}
+	return counts
+}
+
+type runtimeStats struct {
+	Goroutines  int              `json:"goroutines"`
+	OpenFDs     int              `json:"open_fds"`
+	Connections map[string]int64 `json:"connections"`
+	InFlight    int64            `json:"in_flight"`
+	Heap        heapStats        `json:"heap"`
+	GC          gcStats          `json:"gc"`
+	Uptime      float64          `json:"uptime_seconds"`
+}
+
+type heapStats struct {
+	Alloc    uint64 `json:"alloc_bytes"`
+	Sys      uint64 `json:"sys_bytes"`
+	Idle     uint64 `json:"idle_bytes"`
+	Released uint64 `json:"released_bytes"`
+	Objects  uint64 `json:"objects"`
+	Stack    uint64 `json:"stack_inuse_bytes"`
+}
+
+type gcStats struct {
+	Count      uint32     `json:"count"`
+	PauseTotal float64    `json:"pause_total_ms"`
+	LastPause  float64    `json:"last_pause_ms"`
+	Last       *time.Time `json:"last,omitempty"`
+	NextTarget uint64     `json:"next_target_bytes"`
+	CPU        float64    `json:"cpu_fraction"`
+}
+
+func (s *Server) runtimeStats() runtimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	gc := gcStats{
+		Count:      m.NumGC,
+		PauseTotal: float64(m.PauseTotalNs) / 1e6,
+		NextTarget: m.NextGC,
+		CPU:        m.GCCPUFraction,
+	}
+	if m.NumGC > 0 {
+		gc.LastPause = float64(m.PauseNs[(m.NumGC+255)%256]) / 1e6
+		last := time.Unix(0, int64(m.LastGC))
+		gc.Last = &last
+	}
+	return runtimeStats{
+		Goroutines:  runtime.NumGoroutine(),
+		OpenFDs:     countOpenFDs(),
+		Connections: s.conns.counts(),
+		InFlight:    s.stats.inFlight.Load(),
+		Heap: heapStats{
+			Alloc:    m.HeapAlloc,
+			Sys:      m.HeapSys,
+			Idle:     m.HeapIdle,
+			Released: m.HeapReleased,
+			Objects:  m.HeapObjects,
+			Stack:    m.StackInuse,
+		},
+		GC:     gc,
+		Uptime: time.Since(startTime).Seconds(),
+	}
+}
+
+// countOpenFDs reads /proc, or /dev/fd where there is no procfs. It returns
+// -1 when neither is available.
+func countOpenFDs() int {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		if entries, err := os.ReadDir(dir); err == nil {
+			// The directory handle used to list it is counted too.
+			return len(entries) - 1
+		}
+	}
+	return -1
+}
+
+func (s *Server) handleRuntime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	renderJSON(w, r, http.StatusOK, Response{Success: true, Data: s.runtimeStats()})
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type scopesKey struct{}
+
+// withScopes records the scopes granted by the request's access token.
+func withScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey{}, scopes)
+}
+
+// ScopesFrom returns the granted scopes and whether the request carried an
+// access token or API key at all. A key's scopes are those apikeys.roles
+// grants its roles; certificates and signatures are not scoped.
+func ScopesFrom(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(scopesKey{}).([]string)
+	return scopes, ok
+}
+
+// requiredScopes returns the scopes rt declares for method, falling back
+// to its "*" entry.
+func (rt Route) requiredScopes(method string) []string {
+	if scopes, ok := rt.Scopes[method]; ok {
+		return scopes
+	}
+	return rt.Scopes["*"]
+}
+
+// RequireScopes answers 403 to an access token lacking any scope rt
+// declares for the method. Requests without a token pass unless anonymous
+// is false, in which case they get 401.
+func RequireScopes(rt Route, anonymous bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			required := rt.requiredScopes(r.Method)
+			if len(required) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			granted, ok := ScopesFrom(r.Context())
+			if !ok && anonymous {
+				next.ServeHTTP(w, r)
+				return
+			}
+			for _, scope := range required {
+				if !slices.Contains(granted, scope) {
+					challenge := `Bearer error="insufficient_scope", scope="` + strings.Join(required, " ") + `"`
+					status := http.StatusForbidden
+					if !ok {
+						challenge, status = `Bearer scope="`+strings.Join(required, " ")+`"`, http.StatusUnauthorized
+					}
+					LoggerFrom(r.Context()).Info("insufficient scope", "required", required, "granted", granted)
+					w.Header().Set("WWW-Authenticate", challenge)
+					renderJSON(w, r, status, errorResponse(r.Context(), "missing_scope", scope))
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// openAPIPath turns a subtree pattern into a templated path:
+// "/users/" becomes "/users/{id}".
+func openAPIPath(pattern string) string {
+	if len(pattern) > 1 && strings.HasSuffix(pattern, "/") {
+		return pattern + "{id}"
+	}
+	return pattern
+}
+
+// openAPIDoc describes the route table: one operation per method a route
+// declares scopes or responses for, with the scopes as its bearer security
+// requirement, and the full route-to-scope summary under x-scopes.
+func openAPIDoc(routes []Route) map[string]any {
+	paths := map[string]any{}
+	summary := map[string]map[string][]string{}
+	for _, rt := range routes {
+		item := map[string]any{}
+		if rt.Admin {
+			item["x-admin"] = true
+		}
+		seen := map[string]bool{}
+		var methods []string
+		for method := range rt.Scopes {
+			seen[method] = true
+			methods = append(methods, method)
+		}
+		for method := range rt.Responses {
+			if !seen[method] {
+				methods = append(methods, method)
+			}
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			op := map[string]any{}
+			if scopes := rt.requiredScopes(method); len(scopes) > 0 {
+				op["security"] = []map[string][]string{{"bearer": scopes}}
+			}
+			if scopes, ok := rt.Scopes[method]; ok {
+				if summary[rt.Pattern] == nil {
+					summary[rt.Pattern] = map[string][]string{}
+				}
+				summary[rt.Pattern][method] = scopes
+			}
+			if responses, ok := rt.Responses[method]; ok {
+				op["responses"] = openAPIResponses(responses)
+			}
+			if method == "*" {
+				item["x-any-method"] = op
+			} else {
+				item[strings.ToLower(method)] = op
+			}
+		}
+		if rt.Deprecated != nil {
+			item["x-deprecated"] = true
+		}
+		paths[openAPIPath(rt.Pattern)] = item
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]string{"title": "human-plus-plus", "version": "1"},
+		"paths":   paths,
+		"components": map[string]any{
+			"securitySchemes": map[string]any{
+				"bearer": map[string]string{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+		"x-scopes": summary,
+	}
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	renderJSON(w, r, http.StatusOK, s.openAPI)
+}
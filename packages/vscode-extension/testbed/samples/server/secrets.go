@@ -0,0 +1,327 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSecretNotFound is returned by providers for names they don't hold.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretProvider fetches secrets by name from outside the config file.
+type SecretProvider interface {
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+// envSecrets reads PREFIX+NAME, with the name upper-cased and dashes and
+// dots turned into underscores.
+type envSecrets struct{ prefix string }
+
+func (p envSecrets) GetSecret(_ context.Context, name string) (string, error) {
+	key := p.prefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+	if v, ok := os.LookupEnv(key); ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("%w: $%s", ErrSecretNotFound, key)
+}
+
+// fileSecrets reads one file per secret from dir, the layout of Docker and
+// Kubernetes secret mounts. A trailing newline is dropped.
+type fileSecrets struct{ dir string }
+
+func (p fileSecrets) GetSecret(_ context.Context, name string) (string, error) {
+	if name != filepath.Base(name) {
+		return "", fmt.Errorf("secret name %q must not contain a path", name)
+	}
+	data, err := os.ReadFile(filepath.Join(p.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// vaultSecrets reads from a HashiCorp Vault KV v2 mount. The secret name is
+// the path under mount and the value is its "value" field.
+type vaultSecrets struct {
+	addr   string
+	mount  string
+	token  string
+	client *http.Client
+}
+
+// ?? Only token auth is built in; AppRole and Kubernetes auth need the Vault SDK
+func newVaultSecrets(addr, mount string) (*vaultSecrets, error) {
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		return nil, errors.New("secrets.provider vault requires VAULT_TOKEN")
+	}
+	return &vaultSecrets{addr: strings.TrimSuffix(addr, "/"), mount: mount, token: token, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (p *vaultSecrets) GetSecret(ctx context.Context, name string) (string, error) {
+	u := fmt.Sprintf("%s/v1/%s/data/%s", p.addr, p.mount, url.PathEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: vault %s/%s", ErrSecretNotFound, p.mount, name)
+	}
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return "", fmt.Errorf("vault returned %s", resp.Status)
+	}
+	var body struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("vault response: %w", err)
+	}
+	v, ok := body.Data.Data["value"]
+	if !ok {
+		return "", fmt.Errorf("vault %s/%s has no \"value\" field", p.mount, name)
+	}
+	return v, nil
+}
+
+func newSecretProvider(cfg SecretsConfig) (SecretProvider, error) {
+	switch cfg.Provider {
+	case "env":
+		return envSecrets{prefix: cfg.EnvPrefix}, nil
+	case "file":
+		return fileSecrets{dir: cfg.Dir}, nil
+	case "vault":
+		return newVaultSecrets(cfg.VaultAddr, cfg.VaultMount)
+	default:
+		// !! KMS-encrypted secrets need a cloud SDK - decrypt them into a file mount instead
+		return nil, fmt.Errorf("unknown secrets provider %q (want env, file or vault)", cfg.Provider)
+	}
+}
+
+// SecretCache serves secrets from memory, refetching them every refresh
+// interval and calling OnChange subscribers when a value rotates.
+type SecretCache struct {
+	provider SecretProvider
+	every    time.Duration
+	logger   *slog.Logger
+
+	mu       sync.Mutex
+	values   map[string]string
+	watchers map[string][]func(string)
+}
+
+func NewSecretCache(provider SecretProvider, every time.Duration, logger *slog.Logger) *SecretCache {
+	return &SecretCache{provider: provider, every: every, logger: logger, values: map[string]string{}, watchers: map[string][]func(string){}}
+}
+
+// Get returns the cached value, fetching it on first use.
+func (c *SecretCache) Get(ctx context.Context, name string) (string, error) {
+	c.mu.Lock()
+	v, ok := c.values[name]
+	c.mu.Unlock()
+	if ok {
+		return v, nil
+	}
+	v, err := c.provider.GetSecret(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	c.values[name] = v
+	c.mu.Unlock()
+	return v, nil
+}
+
+// OnChange calls fn with the new value each time a refresh finds name has
+// rotated. fn must not block.
+func (c *SecretCache) OnChange(name string, fn func(value string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watchers[name] = append(c.watchers[name], fn)
+}
+
+// run refreshes every cached secret until done is closed.
+func (c *SecretCache) run(done <-chan struct{}) {
+	ticker := time.NewTicker(c.every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			c.refresh()
+		}
+	}
+}
+
+func (c *SecretCache) refresh() {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.values))
+	for name := range c.values {
+		names = append(names, name)
+	}
+	c.mu.Unlock()
+
+	for _, name := range names {
+		ctx, cancel := context.WithTimeout(context.Background(), c.every)
+		v, err := c.provider.GetSecret(ctx, name)
+		cancel()
+		if err != nil {
+			// >> A failed refresh keeps serving the last good value
+			c.logger.Warn("secret refresh failed", "secret", name, "err", err)
+			continue
+		}
+		c.mu.Lock()
+		changed := c.values[name] != v
+		c.values[name] = v
+		watchers := slices.Clone(c.watchers[name])
+		c.mu.Unlock()
+		if changed {
+			c.logger.Info("secret rotated", "secret", name)
+			for _, fn := range watchers {
+				fn(v)
+			}
+		}
+	}
+}
+
+func validateSecrets(c Config) error {
+	cfg := c.Secrets
+	switch cfg.Provider {
+	case "env", "file":
+	case "vault":
+		if cfg.VaultAddr == "" || cfg.VaultMount == "" {
+			return errors.New("secrets.provider vault requires secrets.vault_addr and secrets.vault_mount")
+		}
+	default:
+		return fmt.Errorf("secrets.provider: unknown provider %q (want env, file or vault)", cfg.Provider)
+	}
+	if cfg.Refresh <= 0 {
+		return errors.New("secrets.refresh must be positive")
+	}
+	if c.Auth.SigningKeysSecret != "" && len(c.Auth.SigningKeys) > 0 {
+		return errors.New("set auth.signing_keys or auth.signing_keys_secret, not both")
+	}
+	if c.Signing.KeysSecret != "" && len(c.Signing.Keys) > 0 {
+		return errors.New("set signing.keys or signing.keys_secret, not both")
+	}
+	if c.Session.KeysSecret != "" && len(c.Session.Keys) > 0 {
+		return errors.New("set session.keys or session.keys_secret, not both")
+	}
+	return nil
+}
+
+// rotating holds a value that a secret rotation can replace while requests
+// are reading it.
+type rotating[T any] struct{ p atomic.Pointer[T] }
+
+func (r *rotating[T]) Load() T {
+	if p := r.p.Load(); p != nil {
+		return *p
+	}
+	var zero T
+	return zero
+}
+
+func (r *rotating[T]) Store(v T) { r.p.Store(&v) }
+
+// splitSecretList reads a secret holding one entry per line or comma, the
+// same entries the config list would hold.
+func splitSecretList(v string) []string {
+	var out []string
+	for _, entry := range strings.FieldsFunc(v, func(r rune) bool { return r == '\n' || r == ',' }) {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+// loadSecretKeys fetches the key list named by secret, parses it, stores
+// it, and re-parses on rotation. A rotated value that fails to parse is
+// logged and the previous keys stay in use.
+func loadSecretKeys[T any](ctx context.Context, s *Server, secret string, parse func([]string) (T, error), store func(T)) error {
+	v, err := s.secrets.Get(ctx, secret)
+	if err != nil {
+		return fmt.Errorf("secret %s: %w", secret, err)
+	}
+	keys, err := parse(splitSecretList(v))
+	if err != nil {
+		return fmt.Errorf("secret %s: %w", secret, err)
+	}
+	store(keys)
+	s.secrets.OnChange(secret, func(v string) {
+		keys, err := parse(splitSecretList(v))
+		if err != nil {
+			s.logger.Error("rotated secret rejected, keeping previous value", "secret", secret, "err", err)
+			return
+		}
+		store(keys)
+	})
+	return nil
+}
+
+// loadKeys resolves the JWT, HMAC and session key rings, from the config
+// or from the secrets they name. Validate has already rejected malformed inline
+// keys.
+func (s *Server) loadKeys(cfg Config) error {
+	provider, err := newSecretProvider(cfg.Secrets)
+	if err != nil {
+		return err
+	}
+	s.secrets = NewSecretCache(provider, cfg.Secrets.Refresh, s.logger)
+
+	jwtKeys, _ := parseSigningKeys(cfg.Auth.SigningKeys)
+	s.jwtKeys.Store(jwtKeys)
+	hmacKeys, _ := parseHMACKeys(cfg.Signing.Keys)
+	s.hmacKeys.Store(hmacKeys)
+	cookieKeys, _ := parseSessionKeys(cfg.Session.Keys)
+	s.cookieKeys.Store(cookieKeys)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if name := cfg.Auth.SigningKeysSecret; name != "" {
+		if err := loadSecretKeys(ctx, s, name, parseSigningKeys, s.jwtKeys.Store); err != nil {
+			return fmt.Errorf("auth.signing_keys_secret: %w", err)
+		}
+	}
+	if name := cfg.Signing.KeysSecret; name != "" {
+		if err := loadSecretKeys(ctx, s, name, parseHMACKeys, s.hmacKeys.Store); err != nil {
+			return fmt.Errorf("signing.keys_secret: %w", err)
+		}
+	}
+	if name := cfg.Session.KeysSecret; name != "" {
+		if err := loadSecretKeys(ctx, s, name, parseSessionKeys, s.cookieKeys.Store); err != nil {
+			return fmt.Errorf("session.keys_secret: %w", err)
+		}
+	}
+	return nil
+}
+
+// Secrets returns the server's secret cache so embedders can read their
+// own secrets, such as database passwords, and follow their rotation.
+func (s *Server) Secrets() *SecretCache { return s.secrets }
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// waitForUpgrade cancels the run once SIGUSR2 has successfully handed
+// the listeners to a new process. A failed upgrade keeps serving.
+func waitForUpgrade(ctx context.Context, server *Server, upgrade <-chan os.Signal, stop context.CancelFunc) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-upgrade:
+			if err := server.Upgrade(); err != nil {
+				slog.Error("upgrade failed, continuing to serve", "err", err)
+				continue
+			}
+			stop()
+			return
+		}
+	}
+}
+
+func runServe(cfg Config, args []string) error {
+	slog.Info("configuration loaded", cfg.RedactedAttrs()...)
+
+	server, err := NewServer(cfg)
+	if err != nil {
+		return fmt.Errorf("server setup: %w", err)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			server.ReloadFromArgs(args)
+		}
+	}()
+
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+	go func() {
+		for range usr1 {
+			server.DumpDiagnostics(cfg.Server.DiagDir)
+		}
+	}()
+
+	// !! SIGUSR2 is taken by binary upgrades, so log level steps use TTIN/TTOU
+	verbosity := make(chan os.Signal, 1)
+	signal.Notify(verbosity, syscall.SIGTTIN, syscall.SIGTTOU)
+	go func() {
+		for sig := range verbosity {
+			if sig == syscall.SIGTTIN {
+				stepLogLevel(-1)
+			} else {
+				stepLogLevel(1)
+			}
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	upgrade := make(chan os.Signal, 1)
+	signal.Notify(upgrade, syscall.SIGUSR2)
+	go waitForUpgrade(ctx, server, upgrade, stop)
+
+	return server.Run(ctx)
+}
@@ -0,0 +1,506 @@
+// Package server is the HTTP service: the Server, its middleware chain,
+// handlers and config, and the subcommands the binary runs. NewServer
+// builds one to embed in another program; RunCommand is the whole command
+// line, flags, config file and signals included.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/middleware"
+)
+
+type Server struct {
+	store      Store
+	server     *http.Server
+	mux        *http.ServeMux
+	extra      []Middleware
+	preset     []net.Listener
+	logger     *slog.Logger
+	handler    swapHandler
+	stats      requestStats
+	conns      connTracker
+	shutdown   shutdownProgress
+	draining   atomic.Bool
+	started    atomic.Bool
+	certs      *certReloader
+	elector    *Elector
+	jobs       *JobQueue
+	events     *EventBus
+	supervisor *Supervisor
+	metrics    *serverMetrics
+	reporter   ErrorReporter
+	mailer     Mailer
+	health     *healthRegistry
+	slo        *sloSet
+	secrets    *SecretCache
+	jwtKeys    rotating[signingKeys]
+	hmacKeys   rotating[map[string][]byte]
+	cookieKeys rotating[sessionKeys]
+	refresh    *refreshStore
+	revoked    revocationList
+	auth       AuthConfig
+	adminToken string
+	apiKeys    *apiKeyStore
+	rateLimit  *rateLimiting
+	quotas     *quotas
+	lockouts   *lockoutTracker
+	changes    *changeLog
+	versions   *collectionVersions
+	heavy      *heavyPool
+	qos        *qosLimiter
+	memory     *memoryGuard
+	retention  *retention
+	suggest    SuggestConfig
+	openAPI    map[string]any
+	auditLog   *auditLog
+	otlp       *otlpExporter
+	profiler   *profiler
+	scheduler  Scheduler
+	aux        []auxServer
+	clock      Clock
+	ids        IDGenerator
+	done       chan struct{}
+
+	mu        sync.Mutex
+	cfg       Config
+	hooks     []shutdownHook
+	listeners []net.Listener
+}
+
+func NewServer(cfg Config, opts ...Option) (*Server, error) {
+	s := &Server{cfg: cfg, logger: slog.Default(), done: make(chan struct{}), clock: realClock{}}
+	s.metrics = s.newMetrics()
+	s.versions = newCollectionVersions()
+	s.quotas = newQuotas(s.clock)
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.ids == nil {
+		s.ids = defaultIDs{clock: s.clock}
+	}
+	cfg = s.cfg
+	if cfg.Chaos.Enabled {
+		// !! Chaos testing only - injected store and Redis failures reach real clients
+		s.logger.Warn("chaos mode enabled", "store_latency", cfg.Chaos.StoreLatency, "error_percent", cfg.Chaos.ErrorPercent, "shutdown_jitter", cfg.Chaos.ShutdownJitter)
+		chaosRules.Store(&cfg.Chaos)
+	} else {
+		chaosRules.Store(nil)
+	}
+	codec, err := newJSONCodec(cfg.Server.JSONCodec)
+	if err != nil {
+		return nil, err
+	}
+	responseCodec.Store(&codec)
+	s.slo = newSLOSet(cfg.SLO, s.metrics.registry)
+	if err := s.loadKeys(cfg); err != nil {
+		return nil, err
+	}
+	s.refresh = newRefreshStore(s.clock)
+	// Handlers read the auth settings and admin token from here: none of
+	// them reload, and s.cfg is only safe to read under s.mu.
+	s.auth = cfg.Auth
+	s.adminToken = cfg.Admin.Token
+	revoked, revokedRedis, err := newRevocationList(cfg.Auth, s.clock)
+	if err != nil {
+		return nil, err
+	}
+	s.revoked = revoked
+	if revokedRedis != nil {
+		s.OnShutdown(func(context.Context) error { return revokedRedis.Close() })
+	}
+	apiKeys, err := openAPIKeyStore(cfg.APIKeys.File, s.clock)
+	if err != nil {
+		return nil, fmt.Errorf("apikeys.file: %w", err)
+	}
+	s.apiKeys = apiKeys
+	rateLimit, err := newRateLimiting(cfg.RateLimit, s.logger, s.metrics.limited, s.clock)
+	if err != nil {
+		return nil, err
+	}
+	s.rateLimit = rateLimit
+	s.OnShutdown(s.rateLimit.Close)
+	// Validate has already rejected malformed quotas.
+	s.quotas.rules, _ = parseQuotas(cfg.Quotas.Limits)
+	s.quotas.limiter, s.quotas.clock = s.rateLimit, s.clock
+	if s.reporter == nil {
+		reporter, err := newErrorReporter(cfg.Errors, s.logger)
+		if err != nil {
+			return nil, err
+		}
+		s.reporter = reporter
+	}
+	s.supervisor = newSupervisor(s.logger, cfg.Server.DiagDir, s.done)
+	s.supervisor.report = s.reportCrash
+	s.elector = NewElector(cfg.Leader, s.logger)
+	s.OnShutdown(s.elector.Release)
+	jobs, err := NewJobQueue(cfg.Jobs, s.logger)
+	if err != nil {
+		return nil, err
+	}
+	s.jobs = jobs
+	s.jobs.protect = s.supervisor.Protect
+	s.jobs.failed = s.reportJobFailure
+	s.OnShutdown(s.jobs.Close)
+	s.events = NewEventBus(s.logger)
+	s.OnShutdown(s.events.Close)
+	s.lockouts = newLockoutTracker(cfg.Lockout, s.events, s.clock)
+	s.changes = newChangeLog(cfg.Changes, cfg.Server.WriteTimeout)
+	s.suggest = cfg.Suggest
+	SubscribeTo(s.events, "changes", 1024, s.changes.record)
+	SubscribeTo(s.events, "versions", 1024, s.versions.bumpRelayed)
+	s.notifyUsers(cfg.Notify)
+	if err := s.publishUserEvents(cfg.Publish); err != nil {
+		return nil, err
+	}
+	if err := s.fanOutEvents(cfg.Fanout); err != nil {
+		return nil, err
+	}
+	s.heavy = newHeavyPool(cfg.Heavy)
+	s.qos = newQoSLimiter(cfg.QoS)
+	s.memory = newMemoryGuard(cfg.Memory, s.logger, s.metrics.memoryShed)
+	auditLog, err := openAuditLog(cfg.Audit)
+	if err != nil {
+		return nil, fmt.Errorf("audit.file: %w", err)
+	}
+	s.auditLog = auditLog
+	s.OnShutdown(s.auditLog.Close)
+	s.auditAuthFailures()
+	s.health = newHealthRegistry(cfg.Health)
+	s.registerDefaultChecks(cfg)
+	s.scheduler = Scheduler{jitter: cfg.Schedule.Jitter, srv: s}
+	if err := s.scheduleMaintenance(cfg.Schedule); err != nil {
+		return nil, err
+	}
+	if err := s.scheduleRetention(cfg.Retention); err != nil {
+		return nil, err
+	}
+
+	// >> ServeMux indexes patterns in a tree by segment, so lookup cost follows path length, not route count
+	s.mux = http.NewServeMux()
+	routes := s.routes()
+	for _, rt := range routes {
+		s.mux.Handle(rt.Pattern, s.routeHandler(rt))
+	}
+	// The route table is fixed from here on, so its description is built
+	// once; building it per request would read s.cfg under Reload.
+	s.openAPI = openAPIDoc(routes)
+	s.rateLimit.lookup = s.mux.Handler
+	s.handler.Store(s.buildHandler(cfg))
+
+	s.server = &http.Server{
+		Addr:         cfg.Server.Addr,
+		Handler:      &s.handler,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		ConnState:    s.conns.track,
+
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+	s.server.SetKeepAlivesEnabled(cfg.Server.KeepAlives)
+	s.conns.maxIdle = cfg.Server.MaxIdleConns
+
+	if cfg.TLS.Enabled() {
+		certs, err := newCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLS key pair: %w", err)
+		}
+		tlsConfig, err := newTLSConfig(cfg.TLS, certs.GetCertificate)
+		if err != nil {
+			return nil, err
+		}
+		s.certs = certs
+		s.server.TLSConfig = tlsConfig
+	}
+
+	if cfg.ACME.Enabled() {
+		getCert, protos, challenge, err := newACMEManager(cfg.ACME, redirectHandler(cfg.Server.Addr))
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig, err := newTLSConfig(cfg.TLS, getCert)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.NextProtos = protos
+		s.server.TLSConfig = tlsConfig
+		if challenge != nil {
+			s.addAux("acme", &http.Server{
+				Addr:              cfg.ACME.HTTPAddr,
+				Handler:           challenge,
+				ReadHeaderTimeout: cfg.Server.ReadTimeout,
+			})
+		}
+	}
+
+	// The ACME challenge listener already redirects everything it doesn't answer.
+	if addr := cfg.TLS.RedirectAddr; addr != "" && !(s.hasAux("acme") && addr == cfg.ACME.HTTPAddr) {
+		s.addAux("redirect", &http.Server{
+			Addr:              addr,
+			Handler:           redirectHandler(cfg.Server.Addr),
+			ReadHeaderTimeout: cfg.Server.ReadTimeout,
+		})
+	}
+
+	otlp, err := newOTLPExporter(s.metrics.registry)
+	if err != nil {
+		return nil, err
+	}
+	s.otlp = otlp
+	if otlp != nil {
+		s.OnShutdown(otlp.Flush)
+	}
+
+	s.profiler = newProfiler(cfg.Profiling, s.logger)
+
+	if cfg.Metrics.Enabled && cfg.Metrics.Addr != "" {
+		s.addAux("metrics", s.newMetricsServer(cfg.Metrics.Addr))
+	}
+
+	if cfg.Debug.Enabled() && cfg.Debug.Addr != "" {
+		debug, err := s.newDebugServer(cfg.Debug.Addr)
+		if err != nil {
+			return nil, err
+		}
+		s.addAux("debug", debug)
+	}
+
+	s.configureHTTP2(cfg.HTTP2)
+	if err := s.configureHTTP3(cfg.HTTP3); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Handler returns the full middleware chain and routes, as served on every
+// listener. It follows reloads.
+func (s *Server) Handler() http.Handler { return &s.handler }
+
+func (s *Server) buildHandler(cfg Config) http.Handler {
+	// Validate has already rejected malformed roles.
+	roleScopes, _ := parseRoleScopes(cfg.APIKeys.Roles)
+	middlewares := []Middleware{
+		s.stats.Middleware,
+		baseLogger(s.logger),
+		RequestIDMiddleware(s.ids.RequestID),
+		middleware.Trace,
+		LanguageMiddleware,
+		TenantMiddleware(cfg.Tenant),
+		BearerAuthMiddleware(&s.jwtKeys, s.revoked, s.clock),
+		APIKeyMiddleware(s.apiKeys, s.lockouts, roleScopes),
+		ClientCertMiddleware,
+		ErrorReportMiddleware(s.reporter),
+		RecoveryMiddleware,
+		LoggingMiddleware,
+		BodyLimitsMiddleware(cfg.Server),
+		TimestampMiddleware(cfg.Server.Timestamps),
+		s.startupGate,
+	}
+	if cfg.Signing.Enabled() {
+		middlewares = append(middlewares, SignatureMiddleware(cfg.Signing, &s.hmacKeys))
+	}
+	middlewares = append(middlewares, s.rateLimit.Middleware(cfg.RateLimit))
+	if !s.quotas.rules.empty() {
+		middlewares = append(middlewares, s.quotas.Middleware)
+	}
+	if cfg.Session.Enabled() {
+		middlewares = append(middlewares, SessionMiddleware(cfg.Session, &s.cookieKeys, s.clock))
+	}
+	middlewares = append(middlewares, configMiddleware(cfg)...)
+	middlewares = append(middlewares, s.extra...)
+	return Chain(middlewares...)(s.mux)
+}
+
+func configMiddleware(cfg Config) []Middleware {
+	var middlewares []Middleware
+	mw := cfg.Middleware
+	if mw.MirrorURL != "" {
+		middlewares = append(middlewares, middleware.Mirror(mw.MirrorURL, mw.MirrorPercent))
+	}
+	if mw.Faults {
+		slog.Warn("fault injection enabled", "config", mw.FaultConfig())
+		middlewares = append(middlewares, middleware.Faults(mw.FaultConfig()))
+	}
+	if cfg.HTTP3.Addr != "" {
+		middlewares = append(middlewares, AltSvcMiddleware(cfg.HTTP3.Addr, cfg.HTTP3.AltSvcMaxAge))
+	}
+	if mw.Compress {
+		middlewares = append(middlewares, middleware.Compression(mw.CompressLevel, mw.CompressMin))
+	}
+	if mw.Decompress {
+		middlewares = append(middlewares, middleware.Decompression(mw.DecompressMax))
+	}
+	if mw.LogBodies {
+		middlewares = append(middlewares, middleware.BodyLog(mw.LogBodyMax, mw.Redact))
+	}
+	// Validate has already rejected malformed flags.
+	features, _ := middleware.ParseFeatures(cfg.Features.Flags)
+	middlewares = append(middlewares, middleware.ResolveFeatures(features, cfg.Features.KeyHeader))
+	return middlewares
+}
+
+type auxListener interface {
+	ListenAndServe() error
+	Shutdown(ctx context.Context) error
+}
+
+// auxServer is a secondary listener that shares the main server's lifecycle
+// but shuts down independently of it.
+type auxServer struct {
+	name string
+	addr string
+	srv  auxListener
+}
+
+func (s *Server) addAux(name string, srv *http.Server) {
+	s.aux = append(s.aux, auxServer{name: name, addr: srv.Addr, srv: retryingServer{srv: srv, bind: s.bindPolicy()}})
+}
+
+func (s *Server) hasAux(name string) bool {
+	for _, aux := range s.aux {
+		if aux.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) Start() error {
+	for _, aux := range s.aux {
+		go func() {
+			s.logger.Info("listener starting", "listener", aux.name, "addr", aux.addr)
+			if err := aux.srv.ListenAndServe(); err != http.ErrServerClosed {
+				s.logger.Error("listener failed", "listener", aux.name, "err", err)
+			}
+		}()
+	}
+	if s.certs != nil {
+		s.supervisor.Go("tls-watch", func() { s.certs.Watch(s.cfg.TLS.WatchEvery, s.done) })
+	}
+	s.supervisor.Go("leader-election", func() { s.elector.Run(s.done) })
+	s.supervisor.Go("secrets-refresh", func() { s.secrets.run(s.done) })
+	if mem, ok := s.revoked.(*memoryRevocations); ok {
+		s.supervisor.Go("revocation-prune", func() { mem.run(time.Minute, s.done) })
+	}
+	if s.otlp != nil {
+		s.supervisor.Go("otlp-export", func() {
+			s.otlp.run(s.done, func(err error) { s.logger.Warn("OTLP export failed", "err", err) })
+		})
+	}
+	if s.profiler != nil {
+		s.supervisor.Go("profiler", func() { s.profiler.run(s.done) })
+	}
+	if s.memory != nil {
+		s.supervisor.Go("memory-guard", func() { s.memory.run(s.done) })
+	}
+	if err := s.jobs.Start(); err != nil {
+		return fmt.Errorf("start job queue: %w", err)
+	}
+	s.scheduler.Start()
+
+	listeners, err := s.listen()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.listeners = listeners
+	s.mu.Unlock()
+	notifyUpgradeReady()
+
+	// Serve fills in a default TLSConfig for HTTP/2, so decide up front.
+	useTLS := s.server.TLSConfig != nil
+	// The cap wraps listeners only here, so Upgrade still hands over the
+	// raw sockets.
+	limit := newConnLimit(s.cfg.Server.MaxConns)
+	errs := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		go func() { errs <- s.serve(limit.wrap(ln), useTLS) }()
+	}
+	return <-errs
+}
+
+// >> Graceful shutdown waits for in-flight requests to complete
+func (s *Server) Shutdown(ctx context.Context) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	s.shutdown.enter("draining")
+	s.mu.Lock()
+	every := s.cfg.Server.ShutdownReportEvery
+	s.mu.Unlock()
+	go s.reportShutdown(every, stop)
+
+	chaosPause(ctx, "draining")
+	s.drain(ctx)
+	close(s.done)
+	s.shutdown.enter("closing listeners")
+	for _, aux := range s.aux {
+		if err := aux.srv.Shutdown(ctx); err != nil {
+			s.logger.Warn("listener shutdown", "listener", aux.name, "err", err)
+		}
+	}
+	err := s.server.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		s.logger.Error("shutdown timed out, closing connections", "in_flight", s.stats.inFlightSummary())
+		s.server.Close()
+	}
+	s.events.Publish(context.Background(), LifecycleEvent{State: "stopped"})
+	s.shutdown.enter("running hooks")
+	chaosPause(ctx, "running hooks")
+	err = errors.Join(err, s.runShutdownHooks(ctx))
+	s.logger.Info("shutdown complete", "elapsed", s.shutdown.elapsed().Round(time.Millisecond))
+	return err
+}
+
+// Run serves until ctx is cancelled or a listener fails, then shuts down
+// gracefully within server.shutdown_grace. It connects the configured
+// store unless WithStore already supplied one. Cancellation is a clean stop
+// and returns nil; embedders and tests use it instead of signals.
+func (s *Server) Run(ctx context.Context) error {
+	s.mu.Lock()
+	cfg := s.cfg
+	s.mu.Unlock()
+	failed := make(chan error, 1)
+	go func() {
+		s.logger.Info("server starting", "addr", cfg.Server.Addr, "store", cfg.Store.Backend)
+		if err := s.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			failed <- err
+		}
+	}()
+
+	var runErr error
+	if !s.started.Load() {
+		store, err := s.ConnectStore(cfg)
+		if err != nil {
+			runErr = fmt.Errorf("store unavailable: %w", err)
+		} else if f, ok := store.(interface{ Flush() error }); ok {
+			s.OnShutdown(func(context.Context) error { return f.Flush() })
+		}
+	}
+	if runErr == nil {
+		select {
+		case <-ctx.Done():
+		case err := <-failed:
+			runErr = fmt.Errorf("server failed: %w", err)
+		}
+	}
+
+	s.logger.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownGrace)
+	defer cancel()
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		return errors.Join(runErr, fmt.Errorf("server forced to shutdown: %w", err))
+	}
+	s.logger.Info("server stopped")
+	return runErr
+}
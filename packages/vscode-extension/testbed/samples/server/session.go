@@ -0,0 +1,243 @@
+package server
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionKeys is an AES-256-GCM key ring for session cookies. The first
+// key seals; every key opens, so a new key can be put first while cookies
+// sealed with the old one age out.
+type sessionKeys []sessionKey
+
+type sessionKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// parseSessionKeys reads "kid:secret" entries. The cipher key is derived
+// from the secret, so any secret of at least minSigningKeyLen bytes works.
+func parseSessionKeys(entries []string) (sessionKeys, error) {
+	var keys sessionKeys
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		id, secret, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || strings.Contains(id, ".") {
+			return nil, errors.New("session key: want kid:secret, with no dot in kid")
+		}
+		if len(secret) < minSigningKeyLen {
+			return nil, fmt.Errorf("session key %q: secret must be at least %d bytes", id, minSigningKeyLen)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("session key %q: duplicate kid", id)
+		}
+		seen[id] = true
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte("human-plus-plus session cookie"))
+		block, _ := aes.NewCipher(mac.Sum(nil))
+		aead, _ := cipher.NewGCM(block)
+		keys = append(keys, sessionKey{id: id, aead: aead})
+	}
+	return keys, nil
+}
+
+// sessionPayload is what the cookie carries once opened. Expires is inside
+// the sealed data so a cookie kept past MaxAge is refused even if the
+// browser sends it.
+type sessionPayload struct {
+	Values  map[string]string `json:"v"`
+	Expires int64             `json:"exp"`
+}
+
+// seal encrypts p as "kid.base64url(nonce|ciphertext)". The cookie name and
+// kid are authenticated, so a value can't be moved to another cookie.
+func (keys sessionKeys) seal(name string, p sessionPayload) (string, error) {
+	if len(keys) == 0 {
+		return "", errors.New("no session keys configured")
+	}
+	plain, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	key := keys[0]
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := key.aead.Seal(nonce, nonce, plain, []byte(name+"|"+key.id))
+	return key.id + "." + b64url.EncodeToString(sealed), nil
+}
+
+var errInvalidSession = errors.New("invalid session cookie")
+
+func (keys sessionKeys) open(name, value string, now time.Time) (sessionPayload, error) {
+	id, data, ok := strings.Cut(value, ".")
+	if !ok {
+		return sessionPayload{}, errInvalidSession
+	}
+	sealed, err := b64url.DecodeString(data)
+	if err != nil {
+		return sessionPayload{}, errInvalidSession
+	}
+	for _, key := range keys {
+		if key.id != id {
+			continue
+		}
+		n := key.aead.NonceSize()
+		if len(sealed) < n {
+			return sessionPayload{}, errInvalidSession
+		}
+		plain, err := key.aead.Open(nil, sealed[:n], sealed[n:], []byte(name+"|"+id))
+		if err != nil {
+			return sessionPayload{}, errInvalidSession
+		}
+		var p sessionPayload
+		if json.Unmarshal(plain, &p) != nil || now.Unix() >= p.Expires {
+			return sessionPayload{}, errInvalidSession
+		}
+		return p, nil
+	}
+	return sessionPayload{}, errInvalidSession
+}
+
+// Session is the per-request view of the session cookie. Changes are
+// written back as a fresh cookie when the response starts.
+type Session struct {
+	mu      sync.Mutex
+	values  map[string]string
+	changed bool
+	cleared bool
+}
+
+func (s *Session) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+func (s *Session) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	s.changed = true
+}
+
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+	s.changed = true
+}
+
+// Clear empties the session and expires the cookie.
+func (s *Session) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = map[string]string{}
+	s.cleared = true
+}
+
+type sessionKeyCtx struct{}
+
+// SessionFrom returns the request's session, or nil when sessions are not
+// configured.
+func SessionFrom(ctx context.Context) *Session {
+	sess, _ := ctx.Value(sessionKeyCtx{}).(*Session)
+	return sess
+}
+
+// SessionMiddleware opens the session cookie, if any, into a Session for
+// the handler and seals it again when it changed. A cookie that fails to
+// open, including one sealed by a retired key, starts an empty session.
+func SessionMiddleware(cfg SessionConfig, ring *rotating[sessionKeys], clock Clock) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sess := &Session{values: map[string]string{}}
+			if c, err := r.Cookie(cfg.CookieName); err == nil {
+				p, err := ring.Load().open(cfg.CookieName, c.Value, clock.Now())
+				if err != nil {
+					LoggerFrom(r.Context()).Debug("session cookie rejected", "err", err)
+				} else if p.Values != nil {
+					sess.values = p.Values
+				}
+			}
+			sw := &sessionWriter{ResponseWriter: w, r: r, cfg: cfg, ring: ring, clock: clock, sess: sess}
+			next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), sessionKeyCtx{}, sess)))
+			sw.writeCookie()
+		})
+	}
+}
+
+// sessionWriter sets the cookie just before the headers go out, which is
+// the last moment a handler's session changes can still be sent.
+type sessionWriter struct {
+	http.ResponseWriter
+	r       *http.Request
+	cfg     SessionConfig
+	ring    *rotating[sessionKeys]
+	clock   Clock
+	sess    *Session
+	written bool
+}
+
+func (w *sessionWriter) WriteHeader(status int) {
+	w.writeCookie()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *sessionWriter) Write(b []byte) (int, error) {
+	w.writeCookie()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *sessionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *sessionWriter) writeCookie() {
+	if w.written {
+		return
+	}
+	w.written = true
+	w.sess.mu.Lock()
+	defer w.sess.mu.Unlock()
+	cookie := &http.Cookie{
+		Name:     w.cfg.CookieName,
+		Path:     "/",
+		Secure:   w.cfg.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	switch {
+	case w.sess.cleared && len(w.sess.values) == 0:
+		cookie.MaxAge = -1
+	case w.sess.changed || w.sess.cleared:
+		value, err := w.ring.Load().seal(w.cfg.CookieName, sessionPayload{Values: w.sess.values, Expires: w.clock.Now().Add(w.cfg.MaxAge).Unix()})
+		if err != nil {
+			LoggerFrom(w.r.Context()).Error("session seal failed", "err", err)
+			return
+		}
+		// >> Browsers cap cookies near 4KB; larger sessions are dropped rather than truncated
+		if len(value) > 4000 {
+			LoggerFrom(w.r.Context()).Error("session too large for a cookie", "bytes", len(value))
+			return
+		}
+		cookie.Value = value
+		cookie.MaxAge = int(w.cfg.MaxAge.Seconds())
+	default:
+		return
+	}
+	http.SetCookie(w.ResponseWriter, cookie)
+}
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sloBuckets is how finely the rolling window is sliced. Old requests leave
+// the window one bucket at a time.
+const sloBuckets = 60
+
+// Objective is one route's SLO, parsed from an entry like
+//
+//	/users,latency=250ms,target=99,availability=99.9
+//
+// meaning 99% of /users requests finish within 250ms and 99.9% don't fail
+// with a 5xx. Either half may be omitted.
+type Objective struct {
+	Route        string        `json:"route"`
+	Latency      time.Duration `json:"-"`
+	Target       float64       `json:"latency_target,omitempty"`
+	Availability float64       `json:"availability_target,omitempty"`
+}
+
+func parseObjectives(entries []string) (map[string]Objective, error) {
+	out := map[string]Objective{}
+	for _, entry := range entries {
+		parts := strings.Split(strings.TrimSpace(entry), ",")
+		o := Objective{Route: parts[0]}
+		if !strings.HasPrefix(o.Route, "/") {
+			return nil, fmt.Errorf("objective %q: must start with a route pattern", entry)
+		}
+		for _, part := range parts[1:] {
+			key, value, _ := strings.Cut(part, "=")
+			var err error
+			switch key {
+			case "latency":
+				o.Latency, err = time.ParseDuration(value)
+			case "target":
+				o.Target, err = parsePercent(value)
+			case "availability":
+				o.Availability, err = parsePercent(value)
+			default:
+				err = fmt.Errorf("unknown key %q", key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("objective %q: %w", entry, err)
+			}
+		}
+		if (o.Latency > 0) != (o.Target > 0) {
+			return nil, fmt.Errorf("objective %q: latency and target go together", entry)
+		}
+		if o.Target == 0 && o.Availability == 0 {
+			return nil, fmt.Errorf("objective %q: needs latency and target, or availability", entry)
+		}
+		if _, dup := out[o.Route]; dup {
+			return nil, fmt.Errorf("objective %q: route already has an objective", entry)
+		}
+		out[o.Route] = o
+	}
+	return out, nil
+}
+
+func parsePercent(s string) (float64, error) {
+	p, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || p <= 0 || p >= 100 {
+		return 0, fmt.Errorf("target %q must be between 0 and 100 exclusive", s)
+	}
+	return p, nil
+}
+
+type sloBucket struct {
+	index              int64
+	total, slow, fails int64
+}
+
+// sloTracker keeps per-bucket counts for one route over the rolling window.
+type sloTracker struct {
+	objective Objective
+	width     time.Duration
+
+	mu      sync.Mutex
+	buckets [sloBuckets]sloBucket
+}
+
+func (t *sloTracker) record(d time.Duration, status int, now time.Time) {
+	idx := now.UnixNano() / int64(t.width)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := &t.buckets[idx%sloBuckets]
+	if b.index != idx {
+		*b = sloBucket{index: idx}
+	}
+	b.total++
+	if t.objective.Latency > 0 && d > t.objective.Latency {
+		b.slow++
+	}
+	if status >= 500 {
+		b.fails++
+	}
+}
+
+// sums totals the newest n buckets.
+func (t *sloTracker) sums(n int, now time.Time) (total, slow, fails int64) {
+	cur := now.UnixNano() / int64(t.width)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, b := range t.buckets {
+		if b.index > cur-int64(n) && b.index <= cur {
+			total += b.total
+			slow += b.slow
+			fails += b.fails
+		}
+	}
+	return total, slow, fails
+}
+
+// SLOIndicator is the state of one half of an objective. Compliance is the
+// good fraction, as a percentage; a burn rate of 1 spends the error budget
+// exactly over the window.
+type SLOIndicator struct {
+	Target     float64 `json:"target"`
+	Compliance float64 `json:"compliance"`
+	BurnRate   float64 `json:"burn_rate"`
+	ShortBurn  float64 `json:"burn_rate_short"`
+}
+
+type SLOStatus struct {
+	Objective
+	Threshold    string        `json:"latency_threshold,omitempty"`
+	Requests     int64         `json:"requests"`
+	LatencySLI   *SLOIndicator `json:"latency,omitempty"`
+	Availability *SLOIndicator `json:"availability,omitempty"`
+}
+
+func indicator(target float64, bad, total, shortBad, shortTotal int64) *SLOIndicator {
+	budget := 1 - target/100
+	ind := &SLOIndicator{Target: target, Compliance: 100}
+	if total > 0 {
+		ind.Compliance = 100 * float64(total-bad) / float64(total)
+		ind.BurnRate = float64(bad) / float64(total) / budget
+	}
+	if shortTotal > 0 {
+		ind.ShortBurn = float64(shortBad) / float64(shortTotal) / budget
+	}
+	return ind
+}
+
+func (t *sloTracker) status(shortBuckets int, now time.Time) SLOStatus {
+	total, slow, fails := t.sums(sloBuckets, now)
+	sTotal, sSlow, sFails := t.sums(shortBuckets, now)
+	st := SLOStatus{Objective: t.objective, Requests: total}
+	if t.objective.Target > 0 {
+		st.Threshold = t.objective.Latency.String()
+		st.LatencySLI = indicator(t.objective.Target, slow, total, sSlow, sTotal)
+	}
+	if t.objective.Availability > 0 {
+		st.Availability = indicator(t.objective.Availability, fails, total, sFails, sTotal)
+	}
+	return st
+}
+
+// sloSet tracks every configured objective. Only routes with an objective
+// pay for the bookkeeping.
+type sloSet struct {
+	trackers map[string]*sloTracker
+	short    int
+}
+
+func newSLOSet(cfg SLOConfig, registry *Registry) *sloSet {
+	// Validate has already rejected malformed objectives.
+	objectives, _ := parseObjectives(cfg.Objectives)
+	width := cfg.Window / sloBuckets
+	set := &sloSet{trackers: map[string]*sloTracker{}, short: max(1, int(cfg.ShortWindow/width))}
+	for route, o := range objectives {
+		set.trackers[route] = &sloTracker{objective: o, width: width}
+	}
+	if len(objectives) > 0 {
+		set.register(registry)
+	}
+	return set
+}
+
+func (set *sloSet) register(r *Registry) {
+	each := func(fn func(route, sli string, ind *SLOIndicator) []sample) func() []sample {
+		return func() []sample {
+			var out []sample
+			for _, st := range set.statuses() {
+				if st.LatencySLI != nil {
+					out = append(out, fn(st.Route, "latency", st.LatencySLI)...)
+				}
+				if st.Availability != nil {
+					out = append(out, fn(st.Route, "availability", st.Availability)...)
+				}
+			}
+			return out
+		}
+	}
+	r.NewGaugeVecFunc("slo_compliance_ratio", "Fraction of requests meeting the objective over the SLO window.", []string{"route", "sli"},
+		each(func(route, sli string, ind *SLOIndicator) []sample {
+			return []sample{{LabelValues: []string{route, sli}, Value: ind.Compliance / 100}}
+		}))
+	r.NewGaugeVecFunc("slo_burn_rate", "Error budget burn rate; 1 spends the budget exactly over the window.", []string{"route", "sli", "window"},
+		each(func(route, sli string, ind *SLOIndicator) []sample {
+			return []sample{
+				{LabelValues: []string{route, sli, "long"}, Value: ind.BurnRate},
+				{LabelValues: []string{route, sli, "short"}, Value: ind.ShortBurn},
+			}
+		}))
+}
+
+func (set *sloSet) statuses() []SLOStatus {
+	now := time.Now()
+	out := make([]SLOStatus, 0, len(set.trackers))
+	for _, t := range set.trackers {
+		out = append(out, t.status(set.short, now))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Route < out[j].Route })
+	return out
+}
+
+// RouteMiddleware records latency and status for pattern when it has an
+// objective, and is a no-op otherwise.
+func (set *sloSet) RouteMiddleware(pattern string) Middleware {
+	t, ok := set.trackers[pattern]
+	if !ok {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			t.record(time.Since(start), sw.status, time.Now())
+		})
+	}
+}
+
+func (s *Server) handleSLO(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	renderJSON(w, r, http.StatusOK, Response{Success: true, Data: s.slo.statuses()})
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ConnectStore opens the configured backend, retrying with backoff for up
+// to store.connect_timeout. Until it succeeds /startupz reports 503 and all
+// other non-probe routes are rejected.
+func (s *Server) ConnectStore(cfg Config) (Store, error) {
+	deadline := time.Now().Add(cfg.Store.ConnectTimeout)
+	backoff := 100 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		store, err := OpenStore(cfg)
+		if err == nil {
+			s.setStore(store)
+			return store, nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return nil, fmt.Errorf("store %s unavailable after %d attempts: %w", cfg.Store.Backend, attempt, err)
+		}
+		s.logger.Warn("store not ready", "backend", cfg.Store.Backend, "attempt", attempt, "retry_in", backoff, "err", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, 5*time.Second)
+	}
+}
+
+// >> started is set only after store is assigned, which publishes it to handlers
+func (s *Server) setStore(store Store) {
+	// chaosStore is inert unless chaos mode is on; it is always in place
+	// because WithStore runs before the config is final.
+	s.store = meteredStore{Store: chaosStore{Store: versionedStore{Store: usageStore{Store: store, usage: s.quotas.usage}, versions: s.versions}}, ops: s.metrics.storeOps}
+	s.started.Store(true)
+	if s.events != nil {
+		s.events.Publish(context.Background(), LifecycleEvent{State: "started"})
+	}
+}
+
+func (s *Server) handleStartup(w http.ResponseWriter, r *http.Request) {
+	if !s.started.Load() {
+		renderJSON(w, r, http.StatusServiceUnavailable, errorResponse(r.Context(), "starting"))
+		return
+	}
+	renderJSON(w, r, http.StatusOK, Response{Success: true, Data: "started"})
+}
+
+var probePaths = map[string]bool{"/health": true, "/readyz": true, "/startupz": true}
+
+func (s *Server) startupGate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.started.Load() && !probePaths[r.URL.Path] {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Service starting", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
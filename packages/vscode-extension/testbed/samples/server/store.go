@@ -0,0 +1,16 @@
+package server
+
+import "github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/store"
+
+// The user model and store live in package store, so code outside this
+// binary can implement and test against them; the aliases keep their
+// names here.
+type (
+	User      = store.User
+	Store     = store.Store
+	UserStore = store.UserStore
+)
+
+const DefaultTenant = store.DefaultTenant
+
+func NewUserStore() *UserStore { return store.NewUserStore() }
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var stressOpts struct {
+	workers     int
+	duration    time.Duration
+	subscribers int
+	keys        int
+	reloadEvery time.Duration
+}
+
+func stressFlags(fs *flag.FlagSet) {
+	fs.IntVar(&stressOpts.workers, "workers", 16, "concurrent HTTP workers; as many again call the store directly")
+	fs.DurationVar(&stressOpts.duration, "duration", 10*time.Second, "how long to run before shutting down under load")
+	fs.IntVar(&stressOpts.subscribers, "subscribers", 4, "user event subscribers, plus one that keeps resubscribing")
+	fs.IntVar(&stressOpts.keys, "keys", 64, "user IDs to spread operations over; fewer means more collisions")
+	fs.DurationVar(&stressOpts.reloadEvery, "reload-every", 100*time.Millisecond, "interval between config reloads; 0 disables them")
+}
+
+// runStress drives an in-process server from every side at once:
+// colliding writes, reads, lists and deletes over HTTP and straight on the
+// store, event subscribers that come and go, config reloads, and finally a
+// shutdown that starts while all of it is still running. It is meant to be
+// run under the race detector,
+//
+//	go run -race . stress -duration 30s
+//
+// without which it still fails on crashes and unexpected 5xx answers but
+// cannot see data races. The server is built from the defaults on a
+// loopback port, so no configured store or listener is touched.
+func runStress(_ Config, _ []string) error {
+	if stressOpts.workers < 1 || stressOpts.keys < 1 {
+		return errors.New("workers and keys must be at least 1")
+	}
+	cfg := DefaultConfig()
+	cfg.Server.DrainDelay = 0
+	cfg.Audit.File = ""
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	srv, err := NewServer(cfg, WithStore(NewUserStore()), WithListener(ln))
+	if err != nil {
+		return err
+	}
+	base := "http://" + ln.Addr().String()
+
+	runCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	ran := make(chan error, 1)
+	go func() { ran <- srv.Run(runCtx) }()
+
+	var stopping atomic.Bool
+	var delivered, reloads atomic.Int64
+	st := &stressStats{statuses: map[int]int64{}}
+	loadCtx, endLoad := context.WithCancel(context.Background())
+	defer endLoad()
+	var wg sync.WaitGroup
+
+	for i := range stressOpts.subscribers {
+		SubscribeTo(srv.Events(), fmt.Sprintf("stress-%d", i), 16, func(_ Event, ev UserEvent) {
+			_ = ev.User.ID
+			delivered.Add(1)
+		})
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for loadCtx.Err() == nil {
+			unsubscribe := SubscribeTo(srv.Events(), "stress-churn", 1, func(Event, UserEvent) { delivered.Add(1) })
+			time.Sleep(time.Millisecond)
+			unsubscribe()
+		}
+	}()
+
+	if stressOpts.reloadEvery > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			levels := []string{"info", "warn"}
+			for n := 0; loadCtx.Err() == nil; n++ {
+				next := cfg
+				next.Logging.Level = levels[n%2]
+				next.Server.MaxBodyBytes = cfg.Server.MaxBodyBytes + int64(n%2)
+				if _, err := srv.Reload(next); err == nil {
+					reloads.Add(1)
+				}
+				time.Sleep(stressOpts.reloadEvery)
+			}
+		}()
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = stressOpts.workers
+	client := &http.Client{Transport: transport, Timeout: 10 * time.Second}
+	for w := range stressOpts.workers {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for n := w; loadCtx.Err() == nil; n += stressOpts.workers {
+				status, err := stressRequest(client, base, n)
+				st.record(status, err, stopping.Load())
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for n := w; loadCtx.Err() == nil; n += stressOpts.workers {
+				stressStore(srv, n)
+			}
+		}()
+	}
+
+	time.Sleep(stressOpts.duration)
+	stopping.Store(true)
+	stop()
+	runErr := <-ran
+	endLoad()
+	wg.Wait()
+
+	st.report(delivered.Load(), reloads.Load())
+	switch {
+	case runErr != nil:
+		return fmt.Errorf("server: %w", runErr)
+	case st.unexpected.Load() > 0:
+		return fmt.Errorf("%d requests failed before shutdown began", st.unexpected.Load())
+	}
+	return nil
+}
+
+// stressRequest issues the n-th request of the mix. IDs repeat across
+// workers so writes and deletes race on the same users.
+func stressRequest(client *http.Client, base string, n int) (int, error) {
+	id := fmt.Sprintf("stress-%d", n%stressOpts.keys)
+	var req *http.Request
+	var err error
+	switch n % 5 {
+	case 0:
+		body := fmt.Sprintf(`{"id":%q,"name":"Stress %d","email":"stress%d@example.com"}`, id, n, n)
+		req, err = http.NewRequest(http.MethodPost, base+"/users", bytes.NewBufferString(body))
+		if req != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	case 1:
+		req, err = http.NewRequest(http.MethodGet, base+"/users/"+id, nil)
+	case 2:
+		req, err = http.NewRequest(http.MethodGet, base+"/users?limit=10&cursor=stress-"+fmt.Sprint(n%stressOpts.keys), nil)
+	case 3:
+		req, err = http.NewRequest(http.MethodDelete, base+"/users/"+id, nil)
+	default:
+		req, err = http.NewRequest(http.MethodGet, base+"/users", nil)
+	}
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// stressStore works the store beside the handlers, across tenant views of
+// the same data.
+func stressStore(srv *Server, n int) {
+	store := srv.store.ForTenant([]string{DefaultTenant, "stress-a", "stress-b"}[n%3])
+	id := fmt.Sprintf("stress-%d", n%stressOpts.keys)
+	switch n % 4 {
+	case 0:
+		store.Set(User{ID: id, Name: "Direct", CreatedAt: time.Now()})
+	case 1:
+		store.Get(id)
+	case 2:
+		store.Delete(id)
+	default:
+		_ = store.Len() + len(store.List())
+	}
+}
+
+type stressStats struct {
+	mu         sync.Mutex
+	statuses   map[int]int64
+	transport  int64
+	unexpected atomic.Int64
+}
+
+// record counts one result. Once shutdown has begun, refused connections
+// and 503s are the expected outcome; before it, any transport error or
+// 5xx is a failure.
+func (s *stressStats) record(status int, err error, stopping bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.transport++
+		if !stopping {
+			s.unexpected.Add(1)
+		}
+		return
+	}
+	s.statuses[status]++
+	if status >= 500 && !stopping {
+		s.unexpected.Add(1)
+	}
+}
+
+func (s *stressStats) report(delivered, reloads int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	codes := make([]int, 0, len(s.statuses))
+	var total int64
+	for code, n := range s.statuses {
+		codes = append(codes, code)
+		total += n
+	}
+	sort.Ints(codes)
+	fmt.Printf("%d responses, %d transport errors, %d events delivered, %d reloads\n", total, s.transport, delivered, reloads)
+	for _, code := range codes {
+		fmt.Printf("  %d  %d\n", code, s.statuses[code])
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/store"
+)
+
+// suggestPage is GET /users/suggest's data.
+type suggestPage struct {
+	Users []User `json:"users"`
+	// Partial is set when the latency budget ran out before every user
+	// was considered.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// handleUserSuggest answers GET /users/suggest?prefix=&limit= for
+// typeahead: up to limit users whose name or email starts with prefix,
+// ignoring case, within suggest.budget.
+func (s *Server) handleUserSuggest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cfg := s.suggest
+	prefix := r.URL.Query().Get("prefix")
+	if prefix == "" {
+		renderJSON(w, r, http.StatusBadRequest, errorResponse(r.Context(), "field_required", "prefix"))
+		return
+	}
+	limit := cfg.Limit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, cfg.MaxLimit)
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), cfg.Budget)
+	defer cancel()
+	users, partial := store.Suggest(ctx, s.storeFor(r), prefix, limit)
+	if users == nil {
+		users = []User{}
+	}
+	renderJSON(w, r, http.StatusOK, dataOK(suggestPage{Users: users, Partial: partial}))
+}
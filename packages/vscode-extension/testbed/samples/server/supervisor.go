@@ -0,0 +1,120 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	superviseMinBackoff = 100 * time.Millisecond
+	superviseMaxBackoff = 30 * time.Second
+	// A worker that ran this long before crashing restarts from the minimum
+	// backoff again instead of continuing to climb.
+	superviseStableAfter = time.Minute
+)
+
+// Supervisor runs background goroutines that must not take the process
+// down with them. A panic is written out as a crash report and the worker
+// is restarted with exponential backoff until done is closed.
+type Supervisor struct {
+	logger   *slog.Logger
+	crashDir string
+	done     <-chan struct{}
+	// report is told about every panic, after the crash report is written.
+	report func(name string, v any, stack []byte)
+
+	mu       sync.Mutex
+	restarts map[string]int
+}
+
+func newSupervisor(logger *slog.Logger, crashDir string, done <-chan struct{}) *Supervisor {
+	return &Supervisor{logger: logger, crashDir: crashDir, done: done, restarts: map[string]int{}}
+}
+
+// Go starts fn under supervision. Returning normally ends the worker; only
+// panics cause a restart.
+func (sv *Supervisor) Go(name string, fn func()) {
+	go func() {
+		backoff := superviseMinBackoff
+		for {
+			start := time.Now()
+			if !sv.runOnce(name, fn) {
+				return
+			}
+			if time.Since(start) > superviseStableAfter {
+				backoff = superviseMinBackoff
+			}
+			sv.mu.Lock()
+			sv.restarts[name]++
+			sv.mu.Unlock()
+			sv.logger.Warn("restarting crashed worker", "worker", name, "backoff", backoff)
+			select {
+			case <-sv.done:
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, superviseMaxBackoff)
+		}
+	}()
+}
+
+func (sv *Supervisor) runOnce(name string, fn func()) (panicked bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			panicked = true
+			sv.crash(name, v, debug.Stack())
+		}
+	}()
+	fn()
+	return false
+}
+
+// Protect runs fn on the calling goroutine and turns a panic into an error,
+// for units of work like a job or a scheduled task where the caller already
+// has its own retry policy.
+func (sv *Supervisor) Protect(name string, fn func() error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			sv.crash(name, v, debug.Stack())
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return fn()
+}
+
+// crash writes a report to crashDir, or to the log when it is unset, in the
+// same way as DumpDiagnostics.
+func (sv *Supervisor) crash(name string, v any, stack []byte) {
+	if sv.report != nil {
+		defer sv.report(name, v, stack)
+	}
+	at := time.Now()
+	report := fmt.Sprintf("=== crash in %s pid=%d at %s\npanic: %v\n\n%s", name, os.Getpid(), at.Format(time.RFC3339), v, stack)
+	if sv.crashDir == "" {
+		sv.logger.Error("worker panicked", "worker", name, "panic", v, "stack", string(stack))
+		return
+	}
+	safe := strings.NewReplacer("/", "_", " ", "_").Replace(name)
+	path := filepath.Join(sv.crashDir, fmt.Sprintf("crash-%s-%d-%s.txt", safe, os.Getpid(), at.Format("20060102T150405.000")))
+	if err := os.WriteFile(path, []byte(report), 0o600); err != nil {
+		sv.logger.Error("worker panicked; crash report failed", "worker", name, "panic", v, "err", err)
+		return
+	}
+	sv.logger.Error("worker panicked", "worker", name, "panic", v, "report", path)
+}
+
+func (sv *Supervisor) stats() map[string]int {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	out := make(map[string]int, len(sv.restarts))
+	for name, n := range sv.restarts {
+		out[name] = n
+	}
+	return out
+}
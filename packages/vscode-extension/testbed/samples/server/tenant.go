@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+type tenantKey struct{}
+
+var validTenant = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// TenantMiddleware resolves the tenant from the configured header, then from
+// the subdomain of cfg.Domain, and scopes the request logger to it. Requests
+// naming no tenant use DefaultTenant unless cfg.Required is set; probes are
+// always allowed through.
+func TenantMiddleware(cfg TenantConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant, ok := tenantFromRequest(r, cfg)
+			if !ok {
+				http.Error(w, "Invalid tenant", http.StatusBadRequest)
+				return
+			}
+			if tenant == DefaultTenant && cfg.Required && !probePaths[r.URL.Path] {
+				http.Error(w, "Tenant required", http.StatusBadRequest)
+				return
+			}
+			ctx := context.WithValue(r.Context(), tenantKey{}, tenant)
+			if tenant != DefaultTenant {
+				ctx = withLogger(ctx, LoggerFrom(ctx).With("tenant", tenant))
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func tenantFromRequest(r *http.Request, cfg TenantConfig) (string, bool) {
+	tenant := strings.ToLower(r.Header.Get(cfg.Header))
+	if tenant == "" && cfg.Domain != "" {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		// ?? Only a single label counts; a.b.example.com is rejected, not tenant "a.b"
+		if sub, ok := strings.CutSuffix(strings.ToLower(host), "."+cfg.Domain); ok {
+			tenant = sub
+		}
+	}
+	if tenant == "" {
+		return DefaultTenant, true
+	}
+	return tenant, validTenant.MatchString(tenant)
+}
+
+// TenantFrom returns the request's tenant, or DefaultTenant outside one.
+func TenantFrom(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// storeFor scopes the store to the request's tenant.
+func (s *Server) storeFor(r *http.Request) Store {
+	return s.store.ForTenant(TenantFrom(r.Context()))
+}
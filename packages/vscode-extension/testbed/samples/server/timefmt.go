@@ -0,0 +1,269 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timestamp formats for response bodies, from server.timestamps or a
+// request's ?ts=. rfc3339 is what encoding/json writes, the value's own
+// zone included; utc is the same forced to UTC; unix is whole seconds
+// since the epoch, as a JSON number.
+const (
+	tsRFC3339 = "rfc3339"
+	tsUTC     = "utc"
+	tsUnix    = "unix"
+)
+
+func validTimestampFormat(format string) bool {
+	return format == tsRFC3339 || format == tsUTC || format == tsUnix
+}
+
+type timestampKey struct{}
+
+// TimestampMiddleware records the response timestamp format: the
+// request's ?ts=, else def. An unknown ?ts= is refused with 400 rather
+// than answered in a format the client didn't ask for.
+func TimestampMiddleware(def string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			format := def
+			if ts := r.URL.Query().Get("ts"); ts != "" {
+				if !validTimestampFormat(ts) {
+					renderJSON(w, r, http.StatusBadRequest, errorResponse(r.Context(), "invalid_ts", ts))
+					return
+				}
+				format = ts
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), timestampKey{}, format)))
+		})
+	}
+}
+
+func timestampFormatFrom(ctx context.Context) string {
+	if format, ok := ctx.Value(timestampKey{}).(string); ok {
+		return format
+	}
+	return tsRFC3339
+}
+
+// formatTime renders t as format does, for output that isn't JSON, such
+// as the CSV export.
+func formatTime(t time.Time, format string) string {
+	switch format {
+	case tsUTC:
+		return t.UTC().Format(time.RFC3339Nano)
+	case tsUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// encodeBody encodes v with codec, its times in format. rfc3339 needs no
+// help from the encoder; for the others v is first copied into a tree of
+// plain values with every time.Time already formatted, so any codec
+// writes them the same way and no struct needs a tag or a wrapper type.
+func encodeBody(codec jsonCodec, w io.Writer, v any, format string) error {
+	if format == tsRFC3339 || format == "" {
+		return codec.Encode(w, v)
+	}
+	tree, err := withTimes(reflect.ValueOf(v), format)
+	if err != nil {
+		return err
+	}
+	return codec.Encode(w, tree)
+}
+
+var (
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// withTimes mirrors v as encoding/json would see it, with time.Time
+// values replaced by their formatted form. Struct fields keep their order
+// through jsonObject; values that marshal themselves, and anything with
+// no time.Time inside, are passed through to the codec as they are.
+// ?? Embedded fields that collide by name are all written, where encoding/json keeps only the dominant one
+func withTimes(v reflect.Value, format string) (any, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if format == tsUnix {
+			return t.Unix(), nil // >> the zero time becomes -62135596800, not null; omitempty never drops a struct anyway
+		}
+		return formatTime(t, format), nil
+	}
+	if !containsTime(v.Type()) || v.Type().Implements(marshalerType) || v.Type().Implements(textMarshalerType) {
+		return v.Interface(), nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return withTimes(v.Elem(), format)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		fallthrough
+	case reflect.Array:
+		items := make([]any, v.Len())
+		for i := range items {
+			item, err := withTimes(v.Index(i), format)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("timestamps: map key type %s is not supported", v.Type().Key())
+		}
+		m := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			item, err := withTimes(iter.Value(), format)
+			if err != nil {
+				return nil, err
+			}
+			m[iter.Key().String()] = item
+		}
+		return m, nil
+	case reflect.Struct:
+		var obj jsonObject
+		if err := obj.addFields(v, format); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	}
+	return v.Interface(), nil
+}
+
+// containsTime reports whether a value of t can hold a time.Time that
+// encoding/json would reach. Interfaces might hold anything.
+func containsTime(t reflect.Type) bool {
+	return containsTimeSeen(t, map[reflect.Type]bool{})
+}
+
+func containsTimeSeen(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if t == timeType || t.Kind() == reflect.Interface {
+		return true
+	}
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return containsTimeSeen(t.Elem(), seen)
+	case reflect.Struct:
+		for i := range t.NumField() {
+			if f := t.Field(i); (f.IsExported() || f.Anonymous) && containsTimeSeen(f.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonObject is a struct as withTimes rewrote it, members in field order.
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	name  string
+	value any
+}
+
+// addFields follows encoding/json's rules for what addFields in
+// contract.go names: json tags, "-", omitempty and promoted fields of
+// embedded structs.
+func (o *jsonObject) addFields(v reflect.Value, format string) error {
+	t := v.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fv := v.Field(i)
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue
+				}
+				ft, fv = ft.Elem(), fv.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := o.addFields(fv, format); err != nil {
+					return err
+				}
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+		}
+		if strings.Contains(opts, "omitempty") && isEmptyValue(fv) {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		value, err := withTimes(fv, format)
+		if err != nil {
+			return err
+		}
+		*o = append(*o, jsonMember{name, value})
+	}
+	return nil
+}
+
+// isEmptyValue is encoding/json's test for omitempty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(m.name)
+		b.Write(key)
+		b.WriteByte(':')
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
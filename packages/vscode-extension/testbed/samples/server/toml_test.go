@@ -0,0 +1,72 @@
+package server
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want tomlDoc
+	}{
+		{"empty", "", tomlDoc{"": {}}},
+		{"top-level key", "name = demo", tomlDoc{"": {"name": "demo"}}},
+		{"tables", "[server]\naddr = \":8080\"\n\n[ logging ]\nlevel = debug\n", tomlDoc{
+			"":        {},
+			"server":  {"addr": ":8080"},
+			"logging": {"level": "debug"},
+		}},
+		{"reopened table", "[a]\nx = 1\n[b]\n[a]\ny = 2", tomlDoc{"": {}, "a": {"x": "1", "y": "2"}, "b": {}}},
+		{"basic string escapes", `s = "tab\there \"quoted\" \u00e9"`, tomlDoc{"": {"s": "tab\there \"quoted\" é"}}},
+		{"literal string", `s = 'C:\path\no escapes'`, tomlDoc{"": {"s": `C:\path\no escapes`}}},
+		{"comments", "# heading\nkey = value # trailing\nurl = \"http://x/#frag\" # after a quoted #\n  # indented", tomlDoc{"": {
+			"key": "value",
+			"url": "http://x/#frag",
+		}}},
+		{"arrays", `a = ["one", 'two', three, "with, comma"]`, tomlDoc{"": {"a": []string{"one", "two", "three", "with, comma"}}}},
+		{"trailing comma", `a = ["x", ]`, tomlDoc{"": {"a": []string{"x"}}}},
+		{"empty array", `a = []`, tomlDoc{"": {"a": []string(nil)}}},
+		{"escaped quote in array", `a = ["say \"hi\", please", b]`, tomlDoc{"": {"a": []string{`say "hi", please`, "b"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTOML(strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTOML(%q)\n got %#v\nwant %#v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"unclosed table", "[server", `line 1: invalid table header "[server"`},
+		{"array of tables", "[[servers]]", `line 1: invalid table header "[[servers]]"`},
+		{"no equals", "[a]\njust words", "line 2: expected key = value"},
+		{"missing value", "key =", "line 1: key: missing value"},
+		{"duplicate key", "a = 1\na = 2", `line 2: duplicate key "a"`},
+		{"unterminated array", "a = [1, 2", "line 1: a: unterminated array"},
+		{"nested array", "a = [[1]]", "line 1: a: nested arrays are not supported"},
+		{"unterminated string", `a = "open`, "line 1: a: invalid syntax"},
+		// The # sits inside the open quote, so it is not stripped as a comment.
+		{"unterminated literal", `a = 'open # not a comment`, "line 1: a: unterminated literal string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTOML(strings.NewReader(tt.in))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("parseTOML(%q) error = %v, want %q", tt.in, err, tt.want)
+			}
+		})
+	}
+}
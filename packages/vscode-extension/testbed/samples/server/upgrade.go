@@ -0,0 +1,122 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+const (
+	upgradeFDsEnv   = "UPGRADE_LISTEN_FDS"
+	upgradeReadyEnv = "UPGRADE_READY_FD"
+)
+
+// Upgrade starts a copy of this binary that inherits the listening sockets
+// and waits until it is serving. On success the caller should shut this
+// process down gracefully; the kernel keeps queuing connections on the
+// shared sockets throughout, so none are refused.
+//
+// ?? Auxiliary listeners (redirect, h2c, ACME, HTTP/3) are not handed over and race to rebind
+func (s *Server) Upgrade() error {
+	s.mu.Lock()
+	listeners := s.listeners
+	timeout := s.cfg.Server.UpgradeTimeout
+	s.mu.Unlock()
+	if len(listeners) == 0 {
+		return errors.New("no listeners to hand over")
+	}
+
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, ln := range listeners {
+		fl, ok := ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("listener %s cannot be inherited", ln.Addr())
+		}
+		f, err := fl.File()
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+
+	ready, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer ready.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		readyW.Close()
+		return err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.ExtraFiles = append(files, readyW)
+	cmd.Env = append(os.Environ(),
+		upgradeFDsEnv+"="+strconv.Itoa(len(files)),
+		upgradeReadyEnv+"="+strconv.Itoa(sdListenFDsStart+len(files)),
+	)
+	err = cmd.Start()
+	readyW.Close()
+	if err != nil {
+		return err
+	}
+
+	// !! If the child never reports ready we keep serving and leave it to exit on its own
+	result := make(chan error, 1)
+	go func() {
+		_, err := io.ReadFull(ready, make([]byte, 1))
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		if err != nil {
+			cmd.Process.Kill()
+			return fmt.Errorf("new process (pid %d) exited before becoming ready", cmd.Process.Pid)
+		}
+	case <-time.After(timeout):
+		cmd.Process.Kill()
+		return fmt.Errorf("new process (pid %d) not ready after %s", cmd.Process.Pid, timeout)
+	}
+
+	// The new process owns the socket paths now; closing ours must not unlink them.
+	for _, ln := range listeners {
+		if ul, ok := ln.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	s.logger.Info("upgrade: new process is serving", "pid", cmd.Process.Pid)
+	return nil
+}
+
+func upgradeListeners() ([]net.Listener, error) {
+	n, err := strconv.Atoi(os.Getenv(upgradeFDsEnv))
+	os.Unsetenv(upgradeFDsEnv)
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	return fileListeners(sdListenFDsStart, n, nil)
+}
+
+// notifyUpgradeReady tells the parent process that this one is serving.
+func notifyUpgradeReady() {
+	fd, err := strconv.Atoi(os.Getenv(upgradeReadyEnv))
+	os.Unsetenv(upgradeReadyEnv)
+	if err != nil {
+		return
+	}
+	f := os.NewFile(uintptr(fd), "upgrade-ready")
+	f.Write([]byte{1})
+	f.Close()
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+var startTime = time.Now()
+
+// handleVars serves the process-wide expvar set plus this server's counters
+// in expvar's format. Server vars are rendered here instead of published so
+// that more than one Server can exist in a process.
+func (s *Server) handleVars(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintf(w, "{\n")
+	first := true
+	emit := func(key, value string) {
+		if !first {
+			fmt.Fprintf(w, ",\n")
+		}
+		first = false
+		fmt.Fprintf(w, "%q: %s", key, value)
+	}
+	expvar.Do(func(kv expvar.KeyValue) { emit(kv.Key, kv.Value.String()) })
+	for key, value := range s.vars() {
+		b, _ := json.Marshal(value)
+		emit(key, string(b))
+	}
+	fmt.Fprintf(w, "\n}\n")
+}
+
+func (s *Server) vars() map[string]interface{} {
+	users := 0
+	if s.started.Load() {
+		users = s.store.Len()
+	}
+	return map[string]interface{}{
+		"requests": map[string]int64{
+			"completed": s.stats.completed.Load(),
+			"in_flight": s.stats.inFlight.Load(),
+		},
+		"store_users":    users,
+		"jobs":           s.jobs.stats(),
+		"events":         s.events.stats(),
+		"schedule":       s.scheduler.statuses(),
+		"shutdown":       s.shutdownVars(),
+		"restarts":       s.supervisor.stats(),
+		"leader":         map[string]interface{}{"id": s.elector.id, "leader": s.elector.IsLeader()},
+		"uptime_seconds": int64(time.Since(startTime).Seconds()),
+		"build":          buildInfo(),
+	}
+}
+
+func buildInfo() map[string]string {
+	info := map[string]string{}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info["go_version"] = bi.GoVersion
+	info["version"] = bi.Main.Version
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision", "vcs.time", "vcs.modified":
+			info[setting.Key] = setting.Value
+		}
+	}
+	return info
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// workGroup runs functions in goroutines and returns the first error, as
+// golang.org/x/sync/errgroup does, without pulling in the module. The
+// first failure cancels the context the others were given.
+type workGroup struct {
+	wg     sync.WaitGroup
+	cancel context.CancelCauseFunc
+	once   sync.Once
+	err    error
+	// sem bounds how many functions run at once; nil means no bound.
+	sem chan struct{}
+}
+
+func newWorkGroup(ctx context.Context, limit int) (*workGroup, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	g := &workGroup{cancel: cancel}
+	if limit > 0 {
+		g.sem = make(chan struct{}, limit)
+	}
+	return g, ctx
+}
+
+// Go runs fn in a new goroutine once a slot is free.
+func (g *workGroup) Go(fn func() error) {
+	if g.sem != nil {
+		g.sem <- struct{}{} // >> blocks the caller, not a goroutine, so a huge fan-out can't pile up goroutines
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			if g.sem != nil {
+				<-g.sem
+			}
+		}()
+		if err := fn(); err != nil {
+			g.once.Do(func() {
+				g.err = err
+				g.cancel(err)
+			})
+		}
+	}()
+}
+
+// Wait waits for every function and returns the first error.
+func (g *workGroup) Wait() error {
+	g.wg.Wait()
+	g.cancel(nil)
+	return g.err
+}
+
+// poolResult is one item's outcome from runPool.
+type poolResult[R any] struct {
+	Index int
+	Value R
+	Err   error
+}
+
+// runPool feeds items to workers goroutines and sends each result on the
+// returned channel, in completion order. The channel is closed once every
+// item is done or ctx is cancelled.
+func runPool[T, R any](ctx context.Context, workers int, items []T, fn func(context.Context, T) (R, error)) <-chan poolResult[R] {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	jobs := make(chan int)
+	results := make(chan poolResult[R], workers) // ?? is a buffer of workers enough to keep them busy while the caller is slow?
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				v, err := fn(ctx, items[i])
+				select {
+				case results <- poolResult[R]{Index: i, Value: v, Err: err}:
+				case <-ctx.Done():
+					return // !! drains nothing: items still queued in jobs are silently skipped
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := range items {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// backendCheck is what checkBackends learned about one backend.
+type backendCheck struct {
+	Name    string
+	Latency time.Duration
+}
+
+// checkBackends pings every backend at once, at most limit in flight,
+// and fails fast: the first unreachable backend cancels the rest.
+func checkBackends(ctx context.Context, names []string, limit int, ping func(context.Context, string) error) ([]backendCheck, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second) // >> one deadline for the whole sweep, not per backend
+	defer cancel()
+
+	g, ctx := newWorkGroup(ctx, limit)
+	checks := make([]backendCheck, len(names))
+	for i, name := range names {
+		g.Go(func() error {
+			start := time.Now()
+			if err := ping(ctx, name); err != nil {
+				return fmt.Errorf("backend %s: %w", name, err)
+			}
+			checks[i] = backendCheck{Name: name, Latency: time.Since(start)} // each goroutine owns its index, so no lock
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return checks, nil
+}
+
+// warmTenants loads every tenant's settings through a pool and collects
+// the failures instead of stopping at the first.
+func warmTenants(ctx context.Context, tenants []string, load func(context.Context, string) (int, error)) (loaded int, err error) {
+	var errs []error
+	for r := range runPool(ctx, 8, tenants, load) {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", tenants[r.Index], r.Err))
+			continue
+		}
+		loaded += r.Value
+	}
+	// ?? should a cancelled ctx be reported even when every finished tenant succeeded?
+	if ctx.Err() != nil {
+		errs = append(errs, context.Cause(ctx))
+	}
+	return loaded, errors.Join(errs...)
+}
+
+// ticker calls fn every interval until ctx is done, skipping ticks while
+// the previous call is still running rather than queueing them. It
+// returns once the last call has too.
+func ticker(ctx context.Context, interval time.Duration, fn func(context.Context)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	busy := make(chan struct{}, 1)
+	for {
+		select {
+		case <-ctx.Done():
+			busy <- struct{}{} // wait out a call in flight
+			return
+		case <-t.C:
+			select {
+			case busy <- struct{}{}:
+				go func() {
+					defer func() { <-busy }()
+					fn(ctx)
+				}()
+			default:
+				// !! a slow fn silently loses ticks; count them if anyone ever asks why a job ran late
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package servertest
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is a settable Clock for WithClock. It only moves when told.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+// Sequence is an IDGenerator for WithIDs that numbers request and user
+// IDs separately, "req-1", "req-2", ... and "user-1", ..., so snapshots and
+// logs are the same on every run.
+type Sequence struct {
+	requests atomic.Int64
+	users    atomic.Int64
+}
+
+func NewSequence() *Sequence {
+	return &Sequence{}
+}
+
+func (s *Sequence) RequestID() string {
+	return "req-" + strconv.FormatInt(s.requests.Add(1), 10)
+}
+
+func (s *Sequence) UserID() string {
+	return "user-" + strconv.FormatInt(s.users.Add(1), 10)
+}
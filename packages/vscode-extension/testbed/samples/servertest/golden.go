@@ -0,0 +1,90 @@
+package servertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite servertest golden files with the current output")
+
+// ScrubKeys are object keys whose values vary between runs. Any string
+// that parses as an RFC 3339 time is scrubbed too, whatever its key.
+var ScrubKeys = []string{"trace_id", "request_id", "access_token", "refresh_token", "token"}
+
+// Canonicalize re-encodes a JSON body with sorted keys and two-space
+// indentation and replaces run-dependent values with placeholders:
+// "<time>" for timestamps and "<scrubbed>" for ScrubKeys and extra. A body
+// that isn't JSON is returned unchanged.
+func Canonicalize(body []byte, extra ...string) []byte {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	keys := map[string]bool{}
+	for _, k := range append(ScrubKeys, extra...) {
+		keys[k] = true
+	}
+	v = scrub(v, keys)
+	out, _ := json.MarshalIndent(v, "", "  ")
+	return append(out, '\n')
+}
+
+func scrub(v any, keys map[string]bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if keys[k] && child != nil {
+				v[k] = "<scrubbed>"
+				continue
+			}
+			v[k] = scrub(child, keys)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = scrub(child, keys)
+		}
+	case string:
+		if _, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return "<time>"
+		}
+	}
+	return v
+}
+
+// Golden compares got with testdata/name.golden, or rewrites the file
+// when the test runs with -update.
+func Golden(t testing.TB, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("servertest: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("servertest: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("servertest: %v; run with -update to create it", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s differs from the golden file; run with -update if the change is intended\n--- want\n%s\n--- got\n%s", path, want, got)
+	}
+}
+
+// Golden snapshots the status and the canonicalized body under name.
+// extra names further keys to scrub.
+func (r *Result) Golden(name string, extra ...string) *Result {
+	r.t.Helper()
+	snapshot := fmt.Appendf(nil, "status: %d\n\n", r.Status)
+	Golden(r.t, name, append(snapshot, Canonicalize(r.Body, extra...)...))
+	return r
+}
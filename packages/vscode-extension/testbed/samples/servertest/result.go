@@ -0,0 +1,85 @@
+package servertest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Envelope is the server's Response as it appears on the wire.
+type Envelope struct {
+	Success    bool            `json:"success"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	TraceID    string          `json:"trace_id,omitempty"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// Result is a completed response. Its Expect methods fail the test and
+// return the Result so checks chain.
+type Result struct {
+	Status int
+	Header http.Header
+	Body   []byte
+
+	t    testing.TB
+	name string
+}
+
+func (r *Result) ExpectStatus(code int) *Result {
+	r.t.Helper()
+	if r.Status != code {
+		r.t.Fatalf("%s: status %d, want %d; body: %s", r.name, r.Status, code, r.Body)
+	}
+	return r
+}
+
+func (r *Result) ExpectHeader(key, value string) *Result {
+	r.t.Helper()
+	if got := r.Header.Get(key); got != value {
+		r.t.Fatalf("%s: header %s = %q, want %q", r.name, key, got, value)
+	}
+	return r
+}
+
+// Envelope decodes the body, failing the test if it isn't one.
+func (r *Result) Envelope() Envelope {
+	r.t.Helper()
+	var env Envelope
+	if err := json.Unmarshal(r.Body, &env); err != nil {
+		r.t.Fatalf("%s: body is not a response envelope: %v; body: %s", r.name, err, r.Body)
+	}
+	return env
+}
+
+func (r *Result) ExpectSuccess() *Result {
+	r.t.Helper()
+	if env := r.Envelope(); !env.Success {
+		r.t.Fatalf("%s: success = false, error %q", r.name, env.Error)
+	}
+	return r
+}
+
+// ExpectError checks for a failed envelope whose error contains substr.
+func (r *Result) ExpectError(substr string) *Result {
+	r.t.Helper()
+	env := r.Envelope()
+	if env.Success {
+		r.t.Fatalf("%s: success = true, want error containing %q", r.name, substr)
+	}
+	if !strings.Contains(env.Error, substr) {
+		r.t.Fatalf("%s: error %q, want it to contain %q", r.name, env.Error, substr)
+	}
+	return r
+}
+
+// Data decodes the envelope's data into v.
+func (r *Result) Data(v any) *Result {
+	r.t.Helper()
+	env := r.Envelope()
+	if err := json.Unmarshal(env.Data, v); err != nil {
+		r.t.Fatalf("%s: decode data: %v; data: %s", r.name, err, env.Data)
+	}
+	return r
+}
@@ -0,0 +1,199 @@
+// Package store holds the user model and the Store interface every
+// backend implements, with the in-memory UserStore the others build on.
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/pii"
+)
+
+// DefaultTenant holds the users of single-tenant deployments and of
+// requests that don't name a tenant.
+const DefaultTenant = ""
+
+type User struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name" pii:"true"`
+	Email     string    `json:"email" pii:"true"`
+	CreatedAt time.Time `json:"created_at"`
+	// PasswordHash is never serialized to clients.
+	PasswordHash string `json:"-"`
+}
+
+// LogValue masks the pii-tagged fields wherever a User is logged.
+func (u User) LogValue() slog.Value { return pii.LogValue(u) }
+
+type Store interface {
+	Get(id string) (User, bool)
+	Set(user User)
+	Delete(id string) bool
+	List() []User
+	// Range calls fn for each user, in no particular order, until fn
+	// returns false or ctx ends, without copying all the users into one
+	// slice.
+	// It returns ctx.Err() if ctx cut it short.
+	Range(ctx context.Context, fn func(User) bool) error
+	Len() int
+	// ForTenant returns a view of the same store limited to one tenant.
+	ForTenant(tenant string) Store
+}
+
+// Error is the panic value of a failed Store call, since Store methods
+// have no error results; the server's recovery middleware answers it
+// with a 500, as it would any panic.
+type Error struct {
+	Op  string
+	Err error
+}
+
+func (e *Error) Error() string { return fmt.Sprintf("store %s: %v", e.Op, e.Err) }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// userData is shared by every tenant view of a UserStore.
+type userData struct {
+	mu      sync.RWMutex
+	tenants map[string]map[string]User
+	index   map[string]prefixIndex
+}
+
+// !! In-memory store is not persistent - replace with database in production
+type UserStore struct {
+	data   *userData
+	tenant string
+}
+
+func NewUserStore() *UserStore {
+	return &UserStore{
+		data: &userData{tenants: make(map[string]map[string]User), index: make(map[string]prefixIndex)},
+	}
+}
+
+func (s *UserStore) ForTenant(tenant string) Store {
+	return s.View(tenant)
+}
+
+// View is ForTenant typed as a *UserStore, for stores that embed one.
+func (s *UserStore) View(tenant string) *UserStore {
+	return &UserStore{data: s.data, tenant: tenant}
+}
+
+func (s *UserStore) Get(id string) (User, bool) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+	user, ok := s.data.tenants[s.tenant][id]
+	return user, ok
+}
+
+func (s *UserStore) Set(user User) {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	users, ok := s.data.tenants[s.tenant]
+	if !ok {
+		users = make(map[string]User)
+		s.data.tenants[s.tenant] = users
+	}
+	ix := s.data.index[s.tenant]
+	if old, ok := users[user.ID]; ok {
+		ix = ix.remove(old)
+	}
+	users[user.ID] = user
+	s.data.index[s.tenant] = ix.add(user)
+}
+
+func (s *UserStore) Delete(id string) bool {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	users := s.data.tenants[s.tenant]
+	if old, ok := users[id]; ok {
+		delete(users, id)
+		s.data.index[s.tenant] = s.data.index[s.tenant].remove(old)
+		return true
+	}
+	return false
+}
+
+func (s *UserStore) Len() int {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+	return len(s.data.tenants[s.tenant])
+}
+
+func (s *UserStore) List() []User {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+	users := make([]User, 0, len(s.data.tenants[s.tenant]))
+	for _, user := range s.data.tenants[s.tenant] {
+		users = append(users, user)
+	}
+	return users
+}
+
+// rangeChunk is how many users Range copies per hold of the read lock.
+const rangeChunk = 256
+
+// Range walks a snapshot of the tenant's IDs, copying their users a chunk
+// at a time and calling fn with no lock held, so a slow fn, such as a
+// client reading a stream, never holds up writers. Users deleted before
+// their chunk is copied are skipped, and users added meanwhile aren't
+// visited.
+func (s *UserStore) Range(ctx context.Context, fn func(User) bool) error {
+	s.data.mu.RLock()
+	ids := make([]string, 0, len(s.data.tenants[s.tenant]))
+	for id := range s.data.tenants[s.tenant] {
+		ids = append(ids, id)
+	}
+	s.data.mu.RUnlock()
+
+	chunk := make([]User, 0, min(rangeChunk, len(ids)))
+	for start := 0; start < len(ids); start += rangeChunk {
+		chunk = chunk[:0]
+		s.data.mu.RLock()
+		for _, id := range ids[start:min(start+rangeChunk, len(ids))] {
+			if user, ok := s.data.tenants[s.tenant][id]; ok {
+				chunk = append(chunk, user)
+			}
+		}
+		s.data.mu.RUnlock()
+		for _, user := range chunk {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !fn(user) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// Suggest serves the package-level Suggest from the tenant's prefix index.
+func (s *UserStore) Suggest(prefix string, n int) ([]User, bool) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+	users := []User{}
+	for _, id := range s.data.index[s.tenant].match(prefix, n) {
+		users = append(users, s.data.tenants[s.tenant][id])
+	}
+	return users, true
+}
+
+// All returns every tenant's users, for snapshots.
+func (s *UserStore) All() map[string][]User {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+	out := make(map[string][]User, len(s.data.tenants))
+	for tenant, users := range s.data.tenants {
+		list := make([]User, 0, len(users))
+		for _, user := range users {
+			list = append(list, user)
+		}
+		out[tenant] = list
+	}
+	return out
+}
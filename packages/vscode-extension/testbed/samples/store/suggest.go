@@ -0,0 +1,103 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// prefixIndex orders a tenant's users by lower-cased name and by
+// lower-cased email, one entry each, so the users whose name or email
+// starts with a given prefix are a single run of it.
+type prefixIndex []prefixEntry
+
+type prefixEntry struct {
+	key string
+	id  string
+}
+
+func compareEntries(a, b prefixEntry) int {
+	if c := cmp.Compare(a.key, b.key); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.id, b.id)
+}
+
+func suggestKeys(u User) []string {
+	var keys []string
+	for _, field := range []string{u.Name, u.Email} {
+		if key := strings.ToLower(field); key != "" && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (ix prefixIndex) add(u User) prefixIndex {
+	for _, key := range suggestKeys(u) {
+		e := prefixEntry{key, u.ID}
+		if i, found := slices.BinarySearchFunc(ix, e, compareEntries); !found {
+			ix = slices.Insert(ix, i, e)
+		}
+	}
+	return ix
+}
+
+func (ix prefixIndex) remove(u User) prefixIndex {
+	for _, key := range suggestKeys(u) {
+		if i, found := slices.BinarySearchFunc(ix, prefixEntry{key, u.ID}, compareEntries); found {
+			ix = slices.Delete(ix, i, i+1)
+		}
+	}
+	return ix
+}
+
+// match returns the IDs of up to n users with a key starting with prefix,
+// in key order, each once.
+func (ix prefixIndex) match(prefix string, n int) []string {
+	prefix = strings.ToLower(prefix)
+	i, _ := slices.BinarySearchFunc(ix, prefixEntry{key: prefix}, compareEntries)
+	var ids []string
+	for ; i < len(ix) && len(ids) < n && strings.HasPrefix(ix[i].key, prefix); i++ {
+		if !slices.Contains(ids, ix[i].id) {
+			ids = append(ids, ix[i].id)
+		}
+	}
+	return ids
+}
+
+// Suggester is a Store with a prefix index. ok is false when the backend
+// behind a wrapper keeps none, and the caller should scan instead.
+type Suggester interface {
+	Suggest(prefix string, n int) (users []User, ok bool)
+}
+
+// Suggest returns up to n of store's users whose name or email starts
+// with prefix, from the index when there is one. Scanning stops when ctx
+// ends, and partial reports that the result may be missing matches.
+func Suggest(ctx context.Context, store Store, prefix string, n int) (users []User, partial bool) {
+	if sg, ok := store.(Suggester); ok {
+		if users, ok := sg.Suggest(prefix, n); ok {
+			return users, false
+		}
+	}
+	prefix = strings.ToLower(prefix)
+	var ix prefixIndex
+	matched := map[string]User{}
+	err := store.Range(ctx, func(u User) bool {
+		for _, key := range suggestKeys(u) {
+			if strings.HasPrefix(key, prefix) {
+				ix = ix.add(u)
+				matched[u.ID] = u
+				break
+			}
+		}
+		return true
+	})
+	for _, id := range ix.match(prefix, n) {
+		users = append(users, matched[id])
+	}
+	return users, errors.Is(err, context.DeadlineExceeded)
+}
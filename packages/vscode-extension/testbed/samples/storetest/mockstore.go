@@ -0,0 +1,161 @@
+// Package storetest provides MockStore, a store.Store for handler tests
+// that need the backend to be slow or to fail.
+package storetest
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/store"
+)
+
+// MockStore is a store.Store for tests that records every call and can be
+// told to slow down or fail. Data is kept in a store.UserStore, so it
+// behaves like the memory backend until a fault says otherwise.
+//
+// ?? Store methods have no error results, so an injected error is raised as a panic, which the server's recovery middleware answers with a 500; Flush returns it normally
+type MockStore struct {
+	data   *store.UserStore
+	state  *mockState
+	tenant string
+}
+
+// Fault is injected into one operation: "get", "set", "delete", "list",
+// "range", "len" or "flush".
+type Fault struct {
+	// Err fails the call; nil only delays it.
+	Err     error
+	Latency time.Duration
+	// Times limits the fault to the next Times calls; 0 keeps it until
+	// Clear.
+	Times int
+}
+
+// Call is one recorded call. ID is empty for list, len and flush.
+type Call struct {
+	Op     string
+	Tenant string
+	ID     string
+}
+
+type mockState struct {
+	mu     sync.Mutex
+	calls  []Call
+	faults map[string]*Fault
+}
+
+// NewMockStore returns a mock holding users in the default tenant.
+func NewMockStore(users ...store.User) *MockStore {
+	m := &MockStore{data: store.NewUserStore(), state: &mockState{faults: map[string]*Fault{}}}
+	for _, user := range users {
+		m.data.Set(user)
+	}
+	return m
+}
+
+// Inject sets the fault for op, replacing any earlier one. It applies to
+// every tenant view.
+func (m *MockStore) Inject(op string, f Fault) {
+	m.state.mu.Lock()
+	defer m.state.mu.Unlock()
+	m.state.faults[op] = &f
+}
+
+// Clear removes every fault.
+func (m *MockStore) Clear() {
+	m.state.mu.Lock()
+	defer m.state.mu.Unlock()
+	clear(m.state.faults)
+}
+
+// Calls returns the calls made so far, across tenants, in order.
+func (m *MockStore) Calls() []Call {
+	m.state.mu.Lock()
+	defer m.state.mu.Unlock()
+	return slices.Clone(m.state.calls)
+}
+
+// CallCount returns how many times op was called.
+func (m *MockStore) CallCount(op string) int {
+	m.state.mu.Lock()
+	defer m.state.mu.Unlock()
+	n := 0
+	for _, c := range m.state.calls {
+		if c.Op == op {
+			n++
+		}
+	}
+	return n
+}
+
+// call records op and applies its fault, returning the error to fail
+// with, if any.
+func (m *MockStore) call(op, id string) error {
+	m.state.mu.Lock()
+	m.state.calls = append(m.state.calls, Call{Op: op, Tenant: m.tenant, ID: id})
+	f, ok := m.state.faults[op]
+	var fault Fault
+	if ok {
+		fault = *f
+		if f.Times > 0 {
+			if f.Times--; f.Times == 0 {
+				delete(m.state.faults, op)
+			}
+		}
+	}
+	m.state.mu.Unlock()
+	if fault.Latency > 0 {
+		time.Sleep(fault.Latency)
+	}
+	if fault.Err != nil {
+		return &store.Error{Op: op, Err: fault.Err}
+	}
+	return nil
+}
+
+func (m *MockStore) mustCall(op, id string) {
+	if err := m.call(op, id); err != nil {
+		panic(err)
+	}
+}
+
+func (m *MockStore) ForTenant(tenant string) store.Store {
+	return &MockStore{data: m.data.View(tenant), state: m.state, tenant: tenant}
+}
+
+func (m *MockStore) Get(id string) (store.User, bool) {
+	m.mustCall("get", id)
+	return m.data.Get(id)
+}
+
+func (m *MockStore) Set(user store.User) {
+	m.mustCall("set", user.ID)
+	m.data.Set(user)
+}
+
+func (m *MockStore) Delete(id string) bool {
+	m.mustCall("delete", id)
+	return m.data.Delete(id)
+}
+
+func (m *MockStore) List() []store.User {
+	m.mustCall("list", "")
+	return m.data.List()
+}
+
+func (m *MockStore) Range(ctx context.Context, fn func(store.User) bool) error {
+	m.mustCall("range", "")
+	return m.data.Range(ctx, fn)
+}
+
+func (m *MockStore) Len() int {
+	m.mustCall("len", "")
+	return m.data.Len()
+}
+
+// Flush lets snapshot and shutdown paths be failed without a panic.
+func (m *MockStore) Flush() error {
+	return m.call("flush", "")
+}
@@ -0,0 +1,48 @@
+// Package sysinfo reports host facts for the server's /debug/host page:
+// CPU count, page size and uptime. On Linux with cgo it asks libc; other
+// builds fall back to what the Go runtime knows.
+//
+// The package is also testbed material: compiler directives and cgo
+// preambles are comments too, and markers sit right beside them.
+package sysinfo
+
+//go:generate go run golang.org/x/tools/cmd/stringer -type=Source -trimprefix=Source
+
+import (
+	"time"
+	_ "unsafe" // for go:linkname
+)
+
+// Source says where a Snapshot's numbers came from.
+type Source int
+
+const (
+	SourceLibc    Source = iota // sysconf(3) via cgo
+	SourceRuntime               // runtime.NumCPU and friends
+)
+
+// Snapshot is the host as of one call to Read.
+type Snapshot struct {
+	CPUs     int
+	PageSize int
+	Uptime   time.Duration
+	Source   Source
+}
+
+// !! nanotime is the runtime's monotonic clock; go:linkname ties us to an
+// internal symbol that Go only keeps reachable for compatibility
+//
+//go:linkname nanotime runtime.nanotime
+//go:noescape
+func nanotime() int64
+
+// started is when the process first read the clock, so Uptime is process
+// uptime, not host uptime.
+var started = nanotime()
+
+// Read returns the current snapshot.
+func Read() Snapshot {
+	s := read()
+	s.Uptime = time.Duration(nanotime() - started) // >> monotonic, so a wall-clock step can't make it negative
+	return s
+}
@@ -0,0 +1,28 @@
+//go:build cgo && linux
+
+// >> A marker between the build constraint and the package clause; the
+// constraint must still apply, and only this line is a marker.
+
+package sysinfo
+
+/*
+#include <unistd.h>
+
+// ?? not a marker: in this Go block comment the line opens with //, which
+// is C for the compiler cgo hands the preamble to
+
+static long page_size(void) {
+	return sysconf(_SC_PAGESIZE); // !! mid-line, so not a marker; -1 on error, which read() checks
+}
+*/
+import "C"
+
+func read() Snapshot {
+	// ?? _SC_NPROCESSORS_ONLN ignores cgroup CPU limits; runtime.NumCPU doesn't
+	cpus := int(C.sysconf(C._SC_NPROCESSORS_ONLN))
+	page := int(C.page_size())
+	if cpus < 1 || page < 1 {
+		return readRuntime()
+	}
+	return Snapshot{CPUs: cpus, PageSize: page, Source: SourceLibc}
+}
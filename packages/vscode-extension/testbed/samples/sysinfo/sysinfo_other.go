@@ -0,0 +1,5 @@
+//go:build !cgo || !linux
+
+package sysinfo
+
+func read() Snapshot { return readRuntime() }
@@ -0,0 +1,248 @@
+// Package usersvc implements userpb.UserService in memory, beside the
+// generated userpb package, so the extension sees markers in hand-written
+// code and none in what protoc wrote.
+package usersvc
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fielding/human-plus-plus/packages/vscode-extension/testbed/samples/usersvc/userpb"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+	// watchBuffer is how many events a watcher may fall behind by before
+	// it is dropped.
+	watchBuffer = 16
+)
+
+type Service struct {
+	userpb.UnimplementedUserServiceServer
+
+	mu       sync.Mutex
+	users    map[string]*userpb.User
+	order    []string // ids in creation order, for paging
+	byEmail  map[string]string
+	created  map[string]string // request_id to user id
+	nextID   int
+	watchers map[chan *userpb.UserEvent]struct{}
+	now      func() time.Time
+}
+
+func New() *Service {
+	return &Service{
+		users:    map[string]*userpb.User{},
+		byEmail:  map[string]string{},
+		created:  map[string]string{},
+		watchers: map[chan *userpb.UserEvent]struct{}{},
+		now:      time.Now,
+	}
+}
+
+func (s *Service) GetUser(_ context.Context, req *userpb.GetUserRequest) (*userpb.User, error) {
+	if req.GetId() == "" {
+		return nil, userpb.Errorf(userpb.InvalidArgument, "id is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[req.GetId()]
+	if !ok {
+		return nil, userpb.Errorf(userpb.NotFound, "user %s not found", req.GetId())
+	}
+	return clone(u), nil
+}
+
+func (s *Service) ListUsers(_ context.Context, req *userpb.ListUsersRequest) (*userpb.ListUsersResponse, error) {
+	size := int(req.GetPageSize())
+	switch {
+	case size < 0:
+		return nil, userpb.Errorf(userpb.InvalidArgument, "page_size must not be negative")
+	case size == 0:
+		size = defaultPageSize
+	case size > maxPageSize:
+		size = maxPageSize // >> clamped rather than rejected, as AIP-158 asks
+	}
+	// ?? the token is a bare offset, so a delete between pages skips a user; an id cursor wouldn't
+	start := 0
+	if tok := req.GetPageToken(); tok != "" {
+		n, err := strconv.Atoi(tok)
+		if err != nil || n < 0 {
+			return nil, userpb.Errorf(userpb.InvalidArgument, "malformed page_token")
+		}
+		start = n
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &userpb.ListUsersResponse{}
+	matched := 0
+	for _, id := range s.order {
+		u := s.users[id]
+		if req.GetRole() != userpb.Role_ROLE_UNSPECIFIED && u.GetRole() != req.GetRole() {
+			continue
+		}
+		if matched >= start && len(resp.Users) < size {
+			resp.Users = append(resp.Users, clone(u))
+		}
+		matched++
+	}
+	resp.TotalSize = int32(matched)
+	if next := start + len(resp.Users); next < matched {
+		resp.NextPageToken = strconv.Itoa(next)
+	}
+	return resp, nil
+}
+
+func (s *Service) CreateUser(_ context.Context, req *userpb.CreateUserRequest) (*userpb.User, error) {
+	in := req.GetUser()
+	if in.GetName() == "" || !strings.Contains(in.GetEmail(), "@") {
+		return nil, userpb.Errorf(userpb.InvalidArgument, "user needs a name and an email")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.created[req.GetRequestId()]; ok && req.GetRequestId() != "" {
+		return clone(s.users[id]), nil
+	}
+	email := strings.ToLower(in.GetEmail())
+	if _, taken := s.byEmail[email]; taken {
+		return nil, userpb.Errorf(userpb.AlreadyExists, "email %s is taken", in.GetEmail())
+	}
+
+	s.nextID++
+	u := clone(in)
+	u.Id = "u" + strconv.Itoa(s.nextID)
+	u.CreatedUnix = s.now().Unix()
+	if u.Role == userpb.Role_ROLE_UNSPECIFIED {
+		u.Role = userpb.Role_ROLE_MEMBER
+	}
+	s.users[u.Id] = u
+	s.order = append(s.order, u.Id)
+	s.byEmail[email] = u.Id
+	if req.GetRequestId() != "" {
+		s.created[req.GetRequestId()] = u.Id // !! never pruned; grows with every create that sends a request_id
+	}
+	s.publish(userpb.EventType_EVENT_TYPE_CREATED, u)
+	return clone(u), nil
+}
+
+func (s *Service) UpdateUser(_ context.Context, req *userpb.UpdateUserRequest) (*userpb.User, error) {
+	in := req.GetUser()
+	if len(req.GetUpdateMask()) == 0 {
+		return nil, userpb.Errorf(userpb.InvalidArgument, "update_mask is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.users[in.GetId()]
+	if !ok {
+		return nil, userpb.Errorf(userpb.NotFound, "user %s not found", in.GetId())
+	}
+	u := clone(cur)
+	for _, path := range req.GetUpdateMask() {
+		switch path {
+		case "name":
+			u.Name = in.GetName()
+		case "email":
+			email := strings.ToLower(in.GetEmail())
+			if id, taken := s.byEmail[email]; taken && id != u.Id {
+				return nil, userpb.Errorf(userpb.AlreadyExists, "email %s is taken", in.GetEmail())
+			}
+			delete(s.byEmail, strings.ToLower(u.Email))
+			s.byEmail[email] = u.Id
+			u.Email = in.GetEmail()
+		case "role":
+			u.Role = in.GetRole()
+		case "tags":
+			u.Tags = slices.Clone(in.GetTags())
+		case "address":
+			u.Address = cloneAddress(in.GetAddress())
+		default:
+			// ?? a bad path after an email change leaves byEmail updated but the user not
+			return nil, userpb.Errorf(userpb.InvalidArgument, "cannot update %q", path)
+		}
+	}
+	s.users[u.Id] = u
+	s.publish(userpb.EventType_EVENT_TYPE_UPDATED, u)
+	return clone(u), nil
+}
+
+func (s *Service) DeleteUser(_ context.Context, req *userpb.DeleteUserRequest) (*userpb.DeleteUserResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[req.GetId()]
+	if !ok {
+		if req.GetAllowMissing() {
+			return &userpb.DeleteUserResponse{}, nil
+		}
+		return nil, userpb.Errorf(userpb.NotFound, "user %s not found", req.GetId())
+	}
+	delete(s.users, u.Id)
+	delete(s.byEmail, strings.ToLower(u.Email))
+	s.order = slices.DeleteFunc(s.order, func(id string) bool { return id == u.Id })
+	s.publish(userpb.EventType_EVENT_TYPE_DELETED, u)
+	return &userpb.DeleteUserResponse{Deleted: true}, nil
+}
+
+func (s *Service) WatchUsers(_ *userpb.WatchUsersRequest, stream userpb.UserService_WatchUsersServer) error {
+	ch := make(chan *userpb.UserEvent, watchBuffer)
+	s.mu.Lock()
+	s.watchers[ch] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.watchers, ch)
+		s.mu.Unlock()
+	}()
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-ch:
+			if !ok {
+				return userpb.Errorf(userpb.ResourceExhausted, "watcher fell more than %d events behind", watchBuffer)
+			}
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// publish sends an event to every watcher. s.mu must be held. A watcher
+// whose buffer is full is closed and dropped rather than block the write.
+func (s *Service) publish(typ userpb.EventType, u *userpb.User) {
+	ev := &userpb.UserEvent{Type: typ, User: clone(u), AtUnix: s.now().Unix()}
+	for ch := range s.watchers {
+		select {
+		case ch <- ev: // >> every watcher gets the same *UserEvent; none of them may modify it
+		default:
+			close(ch)
+			delete(s.watchers, ch)
+		}
+	}
+}
+
+func clone(u *userpb.User) *userpb.User {
+	if u == nil {
+		return &userpb.User{}
+	}
+	c := *u
+	c.Tags = slices.Clone(u.Tags)
+	c.Address = cloneAddress(u.Address)
+	return &c
+}
+
+func cloneAddress(a *userpb.Address) *userpb.Address {
+	if a == nil {
+		return nil
+	}
+	c := *a
+	return &c
+}
@@ -0,0 +1,8 @@
+// Package userpb holds the messages and service of user.proto.
+//
+// The *.pb.go files are generated. wire.go, status.go and transport.go stand
+// in for the protobuf and gRPC runtimes, covering only what user.proto
+// needs, so the package builds without either module.
+package userpb
+
+//go:generate protoc --go-lite_out=. --go-lite_opt=paths=source_relative --go-lite-grpc_out=. --go-lite-grpc_opt=paths=source_relative user.proto
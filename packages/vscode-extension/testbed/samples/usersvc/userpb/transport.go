@@ -0,0 +1,178 @@
+package userpb
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Message is what the generated types implement.
+type Message interface {
+	Marshal() ([]byte, error)
+	Unmarshal([]byte) error
+	Reset()
+}
+
+// ServerStream is the server side of a streaming call.
+type ServerStream interface {
+	Context() context.Context
+	SendMsg(m Message) error
+	RecvMsg(m Message) error
+}
+
+type (
+	methodHandler func(srv interface{}, ctx context.Context, dec func(Message) error) (Message, error)
+	streamHandler func(srv interface{}, stream ServerStream) error
+)
+
+// MethodDesc describes a unary method.
+type MethodDesc struct {
+	MethodName string
+	Handler    methodHandler
+}
+
+// StreamDesc describes a streaming method.
+type StreamDesc struct {
+	StreamName    string
+	Handler       streamHandler
+	ServerStreams bool
+	ClientStreams bool
+}
+
+// ServiceDesc describes a service, as the generated code declares it.
+type ServiceDesc struct {
+	ServiceName string
+	HandlerType interface{}
+	Methods     []MethodDesc
+	Streams     []StreamDesc
+	Metadata    string
+}
+
+// ServiceRegistrar is what RegisterUserServiceServer registers with.
+type ServiceRegistrar interface {
+	RegisterService(desc *ServiceDesc, impl interface{})
+}
+
+// maxFrame caps a request message, as grpc-go's default receive limit does.
+const maxFrame = 4 << 20
+
+// Server serves registered services over HTTP with gRPC framing: each
+// message is a five-byte header, a compressed flag and a big-endian
+// length, then the bytes. The status goes in the Grpc-Status and
+// Grpc-Message trailers.
+type Server struct {
+	mu      sync.RWMutex
+	unary   map[string]func(context.Context, func(Message) error) (Message, error)
+	streams map[string]func(ServerStream) error
+}
+
+func NewServer() *Server {
+	return &Server{
+		unary:   map[string]func(context.Context, func(Message) error) (Message, error){},
+		streams: map[string]func(ServerStream) error{},
+	}
+}
+
+func (s *Server) RegisterService(desc *ServiceDesc, impl interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range desc.Methods {
+		h := m.Handler
+		s.unary["/"+desc.ServiceName+"/"+m.MethodName] = func(ctx context.Context, dec func(Message) error) (Message, error) {
+			return h(impl, ctx, dec)
+		}
+	}
+	for _, st := range desc.Streams {
+		h := st.Handler
+		s.streams["/"+desc.ServiceName+"/"+st.StreamName] = func(stream ServerStream) error {
+			return h(impl, stream)
+		}
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+	s.mu.RLock()
+	unary, isUnary := s.unary[r.URL.Path]
+	stream, isStream := s.streams[r.URL.Path]
+	s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/grpc+proto")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	st := &httpStream{ctx: r.Context(), w: w, body: r.Body}
+	var err error
+	switch {
+	case isUnary:
+		var out Message
+		out, err = unary(r.Context(), st.RecvMsg)
+		if err == nil {
+			err = st.SendMsg(out)
+		}
+	case isStream:
+		err = stream(st)
+	default:
+		err = Errorf(Unimplemented, "unknown method %s", r.URL.Path)
+	}
+	status := StatusOf(err)
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(status.Code)))
+	w.Header().Set("Grpc-Message", status.Message)
+}
+
+// httpStream reads request frames from body and writes response frames
+// to w, flushing each so a streamed message isn't held in a buffer.
+type httpStream struct {
+	ctx  context.Context
+	w    http.ResponseWriter
+	body io.Reader
+}
+
+func (st *httpStream) Context() context.Context { return st.ctx }
+
+func (st *httpStream) RecvMsg(m Message) error {
+	var hdr [5]byte
+	if _, err := io.ReadFull(st.body, hdr[:]); err != nil {
+		return Errorf(InvalidArgument, "reading message header: %v", err)
+	}
+	if hdr[0] != 0 {
+		return Errorf(Unimplemented, "compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+	if n > maxFrame {
+		return Errorf(ResourceExhausted, "message of %d bytes exceeds %d", n, maxFrame)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(st.body, b); err != nil {
+		return Errorf(InvalidArgument, "reading message: %v", err)
+	}
+	if err := m.Unmarshal(b); err != nil {
+		return Errorf(InvalidArgument, "%v", err)
+	}
+	return nil
+}
+
+func (st *httpStream) SendMsg(m Message) error {
+	if err := st.ctx.Err(); err != nil {
+		return err
+	}
+	b, err := m.Marshal()
+	if err != nil {
+		return Errorf(Internal, "encoding response: %v", err)
+	}
+	frame := make([]byte, 5, 5+len(b))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(b)))
+	if _, err := st.w.Write(append(frame, b...)); err != nil {
+		return err
+	}
+	if err := http.NewResponseController(st.w).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
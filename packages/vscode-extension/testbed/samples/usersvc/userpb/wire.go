@@ -0,0 +1,87 @@
+package userpb
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// wireType is the low three bits of a field's tag.
+type wireType uint8
+
+const (
+	wireVarint  wireType = 0
+	wireFixed64 wireType = 1
+	wireBytes   wireType = 2
+	wireFixed32 wireType = 5
+)
+
+var errTruncated = errors.New("userpb: truncated or malformed message")
+
+func appendTag(b []byte, num int, typ wireType) []byte {
+	return binary.AppendUvarint(b, uint64(num)<<3|uint64(typ))
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	return binary.AppendUvarint(b, v)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendBytes(b, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// The consume functions return the bytes they read as n, or a negative n
+// if b is too short or malformed.
+
+func consumeVarint(b []byte) (v uint64, n int) {
+	v, n = binary.Uvarint(b)
+	if n <= 0 {
+		return 0, -1
+	}
+	return v, n
+}
+
+func consumeTag(b []byte) (num int, typ wireType, n int) {
+	v, n := consumeVarint(b)
+	if n < 0 || v>>3 == 0 {
+		return 0, 0, -1
+	}
+	return int(v >> 3), wireType(v & 7), n
+}
+
+func consumeBytes(b []byte) (v []byte, n int) {
+	l, n := consumeVarint(b)
+	if n < 0 || l > uint64(len(b)-n) {
+		return nil, -1
+	}
+	return b[n : n+int(l)], n + int(l)
+}
+
+// consumeField skips a field the message doesn't know, so a newer client
+// can talk to an older server.
+func consumeField(typ wireType, b []byte) int {
+	switch typ {
+	case wireVarint:
+		_, n := consumeVarint(b)
+		return n
+	case wireFixed64:
+		if len(b) < 8 {
+			return -1
+		}
+		return 8
+	case wireBytes:
+		_, n := consumeBytes(b)
+		return n
+	case wireFixed32:
+		if len(b) < 4 {
+			return -1
+		}
+		return 4
+	}
+	return -1 // >> groups (wire types 3 and 4) are proto2 only and never appear here
+}